@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/maisi/unraid-filehasher/internal/format"
+	"github.com/maisi/unraid-filehasher/internal/hasher"
+	"github.com/maisi/unraid-filehasher/internal/scanner"
+	"github.com/spf13/cobra"
+)
+
+// benchRound is the throughput measured at one worker count.
+type benchRound struct {
+	Workers int     `json:"workers"`
+	Files   int     `json:"files"`
+	Bytes   int64   `json:"bytes"`
+	Seconds float64 `json:"seconds"`
+	Rate    float64 `json:"rate_bytes_per_sec"`
+}
+
+// benchTolerance is how far below the best rate a smaller worker count may be
+// and still be recommended: extra workers that gain less aren't worth the
+// added seeking and memory.
+const benchTolerance = 0.10
+
+func benchCmd() *cobra.Command {
+	var path string
+	var sample int
+	var workerCounts []int
+	var probeMB int64
+
+	cmd := &cobra.Command{
+		Use:   "bench --path DIR",
+		Short: "Measure hashing throughput at several worker counts",
+		Long: `Hash a random sample of files under --path with 1, 2, 4 and 8 parallel
+workers (see --worker-counts) and report the throughput of each, recommending the
+smallest worker count within 10% of the fastest. Use it to pick --workers for
+verify and rehash on a particular disk.
+
+Each worker count reads its own share of the sample, so no round profits from
+files an earlier one left in the page cache; files cached before the bench
+still make it optimistic. At most --probe-mb is read from each file. Nothing
+is written to the catalog.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if path == "" {
+				return fmt.Errorf("--path is required")
+			}
+			if sample <= 0 {
+				return fmt.Errorf("invalid --sample %d (must be positive)", sample)
+			}
+			if probeMB <= 0 {
+				return fmt.Errorf("invalid --probe-mb %d (must be positive)", probeMB)
+			}
+			if len(workerCounts) == 0 {
+				return fmt.Errorf("--worker-counts needs at least one count")
+			}
+			for _, w := range workerCounts {
+				if w <= 0 {
+					return fmt.Errorf("invalid worker count %d (must be positive)", w)
+				}
+			}
+			if sample < len(workerCounts) {
+				return fmt.Errorf("--sample %d is too small for %d worker counts", sample, len(workerCounts))
+			}
+			absPath, err := filepath.Abs(path)
+			if err != nil {
+				return fmt.Errorf("resolve path %s: %w", path, err)
+			}
+
+			sc, err := scanner.New(excludes)
+			if err != nil {
+				return err
+			}
+			if !jsonOut {
+				fmt.Printf("Sampling %d files under %s...\n", sample, absPath)
+			}
+			d := scanner.DiskInfo{Name: scanner.ResolveDisk(absPath, absPath), Path: absPath}
+			est := &diskEstimate{}
+			if err := walkForEstimate(sc, d, est, sample); err != nil {
+				return fmt.Errorf("walk %s: %w", absPath, err)
+			}
+			if len(est.samples) < len(workerCounts) {
+				return fmt.Errorf("found only %d files under %s, need at least %d", len(est.samples), absPath, len(workerCounts))
+			}
+
+			// Deal the sample out round-robin so every count gets its own files
+			// of a similar size mix
+			shares := make([][]hasher.FileInfo, len(workerCounts))
+			for i, fi := range est.samples {
+				shares[i%len(shares)] = append(shares[i%len(shares)], fi)
+			}
+
+			var rounds []benchRound
+			for i, w := range workerCounts {
+				files, bytes, elapsed := hashSample(shares[i], w, probeMB*1024*1024)
+				r := benchRound{Workers: w, Files: files, Bytes: bytes, Seconds: elapsed.Seconds()}
+				if elapsed > 0 {
+					r.Rate = float64(bytes) / elapsed.Seconds()
+				}
+				rounds = append(rounds, r)
+				if !jsonOut {
+					fmt.Printf("  %2d workers: %s\n", w, format.Bytes(int64(r.Rate))+"/s")
+				}
+			}
+			best := recommendWorkers(rounds)
+
+			if jsonOut {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(map[string]interface{}{
+					"path":        absPath,
+					"files":       est.Files,
+					"sampled":     len(est.samples),
+					"rounds":      rounds,
+					"recommended": best,
+				})
+			}
+
+			fmt.Println()
+			fmt.Printf("  %-8s %8s %12s %14s\n", "WORKERS", "FILES", "READ", "RATE")
+			for _, r := range rounds {
+				mark := ""
+				if r.Workers == best {
+					mark = "  <- recommended"
+				}
+				fmt.Printf("  %-8d %8d %12s %14s%s\n", r.Workers, r.Files, format.Bytes(r.Bytes),
+					format.Bytes(int64(r.Rate))+"/s", mark)
+			}
+			fmt.Println()
+			if best == 0 {
+				fmt.Println("  Nothing could be read; no recommendation")
+				return nil
+			}
+			fmt.Printf("  Recommended: --workers %d for %s\n", best, absPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&path, "path", "", "directory to sample files from, e.g. /mnt/disk1")
+	cmd.Flags().IntVar(&sample, "sample", 200, "number of randomly sampled files, split between the worker counts")
+	cmd.Flags().IntSliceVar(&workerCounts, "worker-counts", []int{1, 2, 4, 8}, "worker counts to compare")
+	cmd.Flags().Int64Var(&probeMB, "probe-mb", 64, "maximum MiB to read from each sampled file")
+	return cmd
+}
+
+// recommendWorkers picks the smallest worker count whose rate is within
+// benchTolerance of the best one, or 0 if no round read anything.
+func recommendWorkers(rounds []benchRound) int {
+	var bestRate float64
+	for _, r := range rounds {
+		bestRate = max(bestRate, r.Rate)
+	}
+	if bestRate == 0 {
+		return 0
+	}
+	sorted := append([]benchRound(nil), rounds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Workers < sorted[j].Workers })
+	for _, r := range sorted {
+		if r.Rate >= bestRate*(1-benchTolerance) {
+			return r.Workers
+		}
+	}
+	return 0
+}
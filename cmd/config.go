@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/maisi/unraid-filehasher/internal/format"
+	"github.com/spf13/cobra"
+)
+
+// cliConfig holds the settings that may come from config.yaml.
+type cliConfig struct {
+	DB       string
+	Excludes []string
+	Workers  int
+	Port     int
+	Units    string
+	APIToken string
+
+	// Mail server for report --email
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUser     string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// Per-disk hash workers by disk type; 0 keeps the built-in default
+	HDDWorkers     int
+	SSDWorkers     int
+	UnknownWorkers int
+}
+
+// configPaths lists where config.yaml is looked for, first match wins.
+func configPaths() []string {
+	var paths []string
+	if home, err := os.UserHomeDir(); err == nil {
+		paths = append(paths, filepath.Join(home, ".config", "filehasher", "config.yaml"))
+	}
+	return append(paths, "/boot/config/filehasher/config.yaml")
+}
+
+// loadConfig reads the first config file that exists. It returns an empty
+// config and path when there is none.
+func loadConfig() (cliConfig, string, error) {
+	for _, p := range configPaths() {
+		f, err := os.Open(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return cliConfig{}, p, err
+		}
+		defer f.Close()
+		cfg, err := parseConfig(bufio.NewScanner(f))
+		if err != nil {
+			return cliConfig{}, p, fmt.Errorf("%s: %w", p, err)
+		}
+		return cfg, p, nil
+	}
+	return cliConfig{}, "", nil
+}
+
+// parseConfig understands the small YAML subset filehasher needs:
+//
+//	db: /mnt/cache/appdata/filehasher.db
+//	workers: 2
+//	hdd_workers: 2
+//	port: 8787
+//	units: si
+//	api_token: s3cret
+//	smtp_host: smtp.example.com
+//	smtp_port: 587
+//	smtp_user: tower@example.com
+//	smtp_password: s3cret
+//	smtp_from: tower@example.com
+//	excludes:
+//	  - \.tmp$
+//	  - /\.Trash-
+//
+// Lists may also be written inline as [a, b]. Values may be quoted.
+func parseConfig(sc *bufio.Scanner) (cliConfig, error) {
+	var cfg cliConfig
+	listKey := ""
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		raw := sc.Text()
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// "- item" continues the preceding list key
+		if strings.HasPrefix(line, "- ") || line == "-" {
+			if listKey == "" {
+				return cfg, fmt.Errorf("line %d: list item without a key", lineNo)
+			}
+			cfg.Excludes = append(cfg.Excludes, unquote(strings.TrimSpace(strings.TrimPrefix(line, "-"))))
+			continue
+		}
+		listKey = ""
+
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			return cfg, fmt.Errorf("line %d: expected \"key: value\"", lineNo)
+		}
+		key = strings.TrimSpace(key)
+		val = strings.TrimSpace(val)
+
+		switch key {
+		case "db":
+			cfg.DB = unquote(val)
+		case "units":
+			cfg.Units = unquote(val)
+		case "api_token":
+			cfg.APIToken = unquote(val)
+		case "smtp_host":
+			cfg.SMTPHost = unquote(val)
+		case "smtp_user":
+			cfg.SMTPUser = unquote(val)
+		case "smtp_password":
+			cfg.SMTPPassword = unquote(val)
+		case "smtp_from":
+			cfg.SMTPFrom = unquote(val)
+		case "excludes":
+			switch {
+			case val == "":
+				listKey = key
+			case strings.HasPrefix(val, "[") && strings.HasSuffix(val, "]"):
+				for _, item := range strings.Split(strings.Trim(val, "[]"), ",") {
+					if item = strings.TrimSpace(item); item != "" {
+						cfg.Excludes = append(cfg.Excludes, unquote(item))
+					}
+				}
+			default:
+				cfg.Excludes = append(cfg.Excludes, unquote(val))
+			}
+		case "workers", "port", "hdd_workers", "ssd_workers", "unknown_workers", "smtp_port":
+			n, err := strconv.Atoi(unquote(val))
+			if err != nil || n <= 0 {
+				return cfg, fmt.Errorf("line %d: %s must be a positive integer", lineNo, key)
+			}
+			switch key {
+			case "workers":
+				cfg.Workers = n
+			case "port":
+				cfg.Port = n
+			case "hdd_workers":
+				cfg.HDDWorkers = n
+			case "ssd_workers":
+				cfg.SSDWorkers = n
+			case "smtp_port":
+				cfg.SMTPPort = n
+			default:
+				cfg.UnknownWorkers = n
+			}
+		default:
+			return cfg, fmt.Errorf("line %d: unknown key %q", lineNo, key)
+		}
+	}
+	return cfg, sc.Err()
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' && s[len(s)-1] == '"' || s[0] == '\'' && s[len(s)-1] == '\'') {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// applyConfig fills flags the user didn't set on the command line.
+// Precedence is flag > environment (FILEHASHER_DB, FILEHASHER_API_TOKEN,
+// FILEHASHER_SMTP_PASSWORD) > config file > built-in default.
+func applyConfig(cmd *cobra.Command) error {
+	cfg, _, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("load config: %w", err)
+	}
+
+	setDefault := func(name, val string) error {
+		f := cmd.Flags().Lookup(name)
+		if f == nil || f.Changed || val == "" {
+			return nil
+		}
+		return f.Value.Set(val)
+	}
+
+	db := cfg.DB
+	if env := os.Getenv("FILEHASHER_DB"); env != "" {
+		db = env
+	}
+	if err := setDefault("db", db); err != nil {
+		return err
+	}
+	if dbPath == "" {
+		dbPath = defaultDBPath()
+	}
+
+	if f := cmd.Flags().Lookup("exclude"); f != nil && !f.Changed && len(cfg.Excludes) > 0 {
+		excludes = cfg.Excludes
+	}
+	if cfg.Workers > 0 {
+		if err := setDefault("workers", strconv.Itoa(cfg.Workers)); err != nil {
+			return err
+		}
+	}
+	for flag, n := range map[string]int{
+		"hdd-workers":     cfg.HDDWorkers,
+		"ssd-workers":     cfg.SSDWorkers,
+		"unknown-workers": cfg.UnknownWorkers,
+	} {
+		if n > 0 {
+			if err := setDefault(flag, strconv.Itoa(n)); err != nil {
+				return err
+			}
+		}
+	}
+	if cfg.Port > 0 {
+		if err := setDefault("port", strconv.Itoa(cfg.Port)); err != nil {
+			return err
+		}
+	}
+	token := cfg.APIToken
+	if env := os.Getenv("FILEHASHER_API_TOKEN"); env != "" {
+		token = env
+	}
+	if err := setDefault("api-token", token); err != nil {
+		return err
+	}
+	password := cfg.SMTPPassword
+	if env := os.Getenv("FILEHASHER_SMTP_PASSWORD"); env != "" {
+		password = env
+	}
+	for flag, val := range map[string]string{
+		"smtp-host":     cfg.SMTPHost,
+		"smtp-user":     cfg.SMTPUser,
+		"smtp-password": password,
+		"smtp-from":     cfg.SMTPFrom,
+	} {
+		if err := setDefault(flag, val); err != nil {
+			return err
+		}
+	}
+	if cfg.SMTPPort > 0 {
+		if err := setDefault("smtp-port", strconv.Itoa(cfg.SMTPPort)); err != nil {
+			return err
+		}
+	}
+	if err := setDefault("units", cfg.Units); err != nil {
+		return err
+	}
+	return format.SetUnits(units)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    cliConfig
+		wantErr string // substring of the error, "" for none
+	}{
+		{
+			name: "scalars and comments",
+			in:   "# catalog\ndb: /mnt/cache/fh.db\n\nworkers: 2\nport: 8787\nunits: si\n",
+			want: cliConfig{DB: "/mnt/cache/fh.db", Workers: 2, Port: 8787, Units: "si"},
+		},
+		{
+			name: "block list",
+			in:   "excludes:\n  - \\.tmp$\n  - /\\.Trash-\ndb: x.db\n",
+			want: cliConfig{DB: "x.db", Excludes: []string{`\.tmp$`, `/\.Trash-`}},
+		},
+		{
+			name: "inline list",
+			in:   "excludes: [\\.tmp$, '/cache/', \"a b\"]\n",
+			want: cliConfig{Excludes: []string{`\.tmp$`, "/cache/", "a b"}},
+		},
+		{
+			name: "single exclude",
+			in:   "excludes: \\.part$\n",
+			want: cliConfig{Excludes: []string{`\.part$`}},
+		},
+		{
+			name: "quoted values",
+			in:   "db: \"/mnt/user/app data/fh.db\"\napi_token: 's3:cret'\nsmtp_port: \"465\"\n",
+			want: cliConfig{DB: "/mnt/user/app data/fh.db", APIToken: "s3:cret", SMTPPort: 465},
+		},
+		{
+			name: "worker counts by disk type",
+			in:   "hdd_workers: 2\nssd_workers: 8\nunknown_workers: 3\n",
+			want: cliConfig{HDDWorkers: 2, SSDWorkers: 8, UnknownWorkers: 3},
+		},
+		{name: "unknown key", in: "db: x\nthreads: 4\n", wantErr: `line 2: unknown key "threads"`},
+		{name: "stray list item", in: "- \\.tmp$\n", wantErr: "line 1: list item without a key"},
+		{name: "list item after a scalar", in: "db: x\n- y\n", wantErr: "line 2: list item without a key"},
+		{name: "no colon", in: "db /x\n", wantErr: `line 1: expected "key: value"`},
+		{name: "bad number", in: "workers: many\n", wantErr: "line 1: workers must be a positive integer"},
+		{name: "zero", in: "port: 0\n", wantErr: "port must be a positive integer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseConfig(bufio.NewScanner(strings.NewReader(tt.in)))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseConfig: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	home := t.TempDir()
+	dbs := t.TempDir()
+	configDB := filepath.Join(dbs, "config.db")
+	envDB := filepath.Join(dbs, "env.db")
+	flagDB := filepath.Join(dbs, "flag.db")
+	if err := os.MkdirAll(filepath.Join(home, ".config", "filehasher"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".config", "filehasher", "config.yaml"), []byte("db: "+configDB+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data := t.TempDir()
+	if err := os.WriteFile(filepath.Join(data, "a.txt"), []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// scan creates whichever catalog it was pointed at
+	scan := func(env []string, args ...string) string {
+		t.Helper()
+		cmd := exec.Command(os.Args[0], append(append([]string{"scan"}, args...), data)...)
+		cmd.Env = append(os.Environ(), append([]string{"FILEHASHER_TEST_MAIN=1", "NO_COLOR=1", "HOME=" + home, "FILEHASHER_DB="}, env...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("scan %v: %v\n%s", args, err, out)
+		}
+		var created []string
+		for _, p := range []string{configDB, envDB, flagDB} {
+			if _, err := os.Stat(p); err == nil {
+				created = append(created, filepath.Base(p))
+				os.Remove(p)
+			}
+		}
+		return strings.Join(created, ",")
+	}
+
+	if got := scan(nil); got != "config.db" {
+		t.Errorf("with only the config file, scan used %q, want config.db", got)
+	}
+	if got := scan([]string{"FILEHASHER_DB=" + envDB}); got != "env.db" {
+		t.Errorf("FILEHASHER_DB and the config file: scan used %q, want env.db", got)
+	}
+	if got := scan([]string{"FILEHASHER_DB=" + envDB}, "--db", flagDB); got != "flag.db" {
+		t.Errorf("--db, FILEHASHER_DB and the config file: scan used %q, want flag.db", got)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/maisi/unraid-filehasher/internal/db"
+	"github.com/maisi/unraid-filehasher/internal/format"
+	"github.com/maisi/unraid-filehasher/internal/logx"
+	"github.com/spf13/cobra"
+)
+
+func dbCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "db",
+		Short: "Database maintenance",
+	}
+	cmd.AddCommand(dbBackupCmd())
+	cmd.AddCommand(dbVacuumCmd())
+	cmd.AddCommand(dbIntegrityCheckCmd())
+	return cmd
+}
+
+func dbBackupCmd() *cobra.Command {
+	var out string
+
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Copy the catalog to a backup file",
+		Long: `Write a consistent copy of the catalog to --out (default: next to the
+catalog, named after it with a timestamp). The copy is taken with VACUUM INTO,
+so scans and the dashboard can keep using the catalog meanwhile; it is
+compacted and self-contained. An existing file is never overwritten.
+
+To restore, stop filehasher and copy the backup over the catalog (removing
+any -wal and -shm files next to it).`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := db.Open(dbPath, dbOptions)
+			if err != nil {
+				return fmt.Errorf("open database: %w", err)
+			}
+			defer database.Close()
+
+			if out == "" {
+				out = backupPath(dbPath, time.Now())
+			}
+			if err := database.BackupTo(out); err != nil {
+				return err
+			}
+			size := dbFileSize(out)
+
+			if jsonOut {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(map[string]interface{}{
+					"path": out,
+					"size": size,
+				})
+			}
+			fmt.Printf("Backup written: %s (%s)\n", out, format.Bytes(size))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&out, "out", "", "backup file to create (default: <catalog>.<timestamp>.bak)")
+	return cmd
+}
+
+// backupPath names an automatic backup of the catalog at dbPath taken at t,
+// adding a counter if a backup from the same second exists.
+func backupPath(dbPath string, t time.Time) string {
+	base := dbPath + "." + t.Format("20060102-150405")
+	path := base + ".bak"
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); err != nil {
+			return path
+		}
+		path = fmt.Sprintf("%s-%d.bak", base, i)
+	}
+}
+
+// backupFirst backs up the catalog before a command that rewrites it, for
+// --backup-first.
+func backupFirst(database *db.DB) error {
+	out := backupPath(dbPath, time.Now())
+	if err := database.BackupTo(out); err != nil {
+		return fmt.Errorf("--backup-first: %w", err)
+	}
+	if !jsonOut {
+		logx.Infof("Catalog backed up to %s\n", out)
+	}
+	return nil
+}
+
+func dbVacuumCmd() *cobra.Command {
+	var backup bool
+
+	cmd := &cobra.Command{
+		Use:   "vacuum",
+		Short: "Reclaim unused space in the database file",
+		Long: `Run VACUUM and PRAGMA optimize to shrink the database after rows were
+removed, e.g. after pruning missing files.
+
+VACUUM rebuilds the whole file: it needs free space about equal to the
+database size next to it, and holds an exclusive lock until it finishes, so
+scans, verifies and the web dashboard have to wait. Run it while nothing else
+is using the database.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := db.Open(dbPath, dbOptions)
+			if err != nil {
+				return fmt.Errorf("open database: %w", err)
+			}
+			defer database.Close()
+
+			if backup {
+				if err := backupFirst(database); err != nil {
+					return err
+				}
+			}
+
+			before := dbFileSize(dbPath)
+			logx.Warnf("vacuum needs about %s of free space and locks the database until it finishes\n",
+				format.Bytes(before))
+
+			if err := database.Vacuum(); err != nil {
+				return fmt.Errorf("vacuum: %w", err)
+			}
+			after := dbFileSize(dbPath)
+			reclaimed := before - after
+			if reclaimed < 0 {
+				reclaimed = 0 // nothing to reclaim; the rebuilt file can come out a page or two larger
+			}
+
+			if jsonOut {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(map[string]interface{}{
+					"size_before": before,
+					"size_after":  after,
+					"reclaimed":   reclaimed,
+				})
+			}
+			fmt.Printf("Vacuum complete: %s -> %s (%s reclaimed)\n",
+				format.Bytes(before), format.Bytes(after), format.Bytes(reclaimed))
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&backup, "backup-first", false, "back up the catalog before vacuuming (see db backup)")
+	return cmd
+}
+
+func dbIntegrityCheckCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "integrity-check",
+		Short: "Check the database file for corruption",
+		Long: `Run PRAGMA integrity_check on the catalog database and list any problems.
+Exits with status 2 if the database is damaged.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := db.Open(dbPath, dbOptions)
+			if err != nil {
+				return fmt.Errorf("open database: %w", err)
+			}
+			defer database.Close()
+
+			problems, err := database.IntegrityCheck()
+			if err != nil {
+				return fmt.Errorf("integrity check: %w", err)
+			}
+
+			if jsonOut {
+				if problems == nil {
+					problems = []string{}
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(map[string]interface{}{
+					"ok":       len(problems) == 0,
+					"problems": problems,
+				}); err != nil {
+					return err
+				}
+			} else if len(problems) == 0 {
+				fmt.Printf("Integrity check passed: %s\n", dbPath)
+			} else {
+				fmt.Printf("Integrity check found %d problems in %s:\n", len(problems), dbPath)
+				for _, p := range problems {
+					fmt.Printf("  %s\n", p)
+				}
+			}
+
+			if len(problems) > 0 {
+				database.Close()
+				exit(2) // non-zero exit for cron alerting
+			}
+			return nil
+		},
+	}
+}
+
+// dbFileSize returns the size of the database file plus its WAL, or 0 if
+// they can't be stat'ed.
+func dbFileSize(path string) int64 {
+	var total int64
+	for _, p := range []string{path, path + "-wal"} {
+		if fi, err := os.Stat(p); err == nil {
+			total += fi.Size()
+		}
+	}
+	return total
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/maisi/unraid-filehasher/internal/db"
+	"github.com/maisi/unraid-filehasher/internal/format"
+	"github.com/spf13/cobra"
+)
+
+func dupesCmd() *cobra.Command {
+	var minSizeStr string
+	var minCount int
+
+	cmd := &cobra.Command{
+		Use:   "dupes",
+		Short: "Find duplicate files by hash",
+		Long: `List sets of tracked files with the same hash and size, largest wasted
+space (size times extra copies) first. Missing files are ignored.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			minSize, err := format.ParseSize(minSizeStr)
+			if err != nil {
+				return fmt.Errorf("invalid --min-size: %w", err)
+			}
+			if minCount < 2 {
+				return fmt.Errorf("invalid --min-count %d (must be at least 2)", minCount)
+			}
+
+			database, err := db.Open(dbPath, dbOptions)
+			if err != nil {
+				return fmt.Errorf("open database: %w", err)
+			}
+			defer database.Close()
+
+			sets, err := database.FindDuplicates(minCount, minSize)
+			if err != nil {
+				return fmt.Errorf("find duplicates: %w", err)
+			}
+
+			var totalWasted int64
+			for _, set := range sets {
+				totalWasted += set[0].Size * int64(len(set)-1)
+			}
+
+			if jsonOut {
+				type setOut struct {
+					SHA256 string   `json:"sha256"`
+					Size   int64    `json:"size"`
+					Wasted int64    `json:"wasted"`
+					Paths  []string `json:"paths"`
+				}
+				out := make([]setOut, 0, len(sets))
+				for _, set := range sets {
+					so := setOut{SHA256: set[0].SHA256, Size: set[0].Size, Wasted: set[0].Size * int64(len(set)-1)}
+					for _, f := range set {
+						so.Paths = append(so.Paths, f.Path)
+					}
+					out = append(out, so)
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(map[string]interface{}{
+					"sets":         out,
+					"total_wasted": totalWasted,
+				})
+			}
+
+			if len(sets) == 0 {
+				fmt.Println("No duplicate files found.")
+				return nil
+			}
+			for _, set := range sets {
+				fmt.Printf("%d copies of %s (%s wasted)  sha256: %s\n",
+					len(set), format.Bytes(set[0].Size), format.Bytes(set[0].Size*int64(len(set)-1)), shortHash(set[0].SHA256))
+				for _, f := range set {
+					fmt.Printf("  [%s] %s\n", f.Disk, f.Path)
+				}
+				fmt.Println()
+			}
+			fmt.Printf("%d duplicate sets, %s wasted\n", len(sets), format.Bytes(totalWasted))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&minSizeStr, "min-size", "1M", "ignore files smaller than this (e.g. 0, 512K, 100M)")
+	cmd.Flags().IntVar(&minCount, "min-count", 2, "only show sets with at least this many copies")
+	return cmd
+}
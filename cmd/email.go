@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/maisi/unraid-filehasher/internal/db"
+	"github.com/maisi/unraid-filehasher/internal/format"
+	"github.com/maisi/unraid-filehasher/internal/logx"
+	"github.com/maisi/unraid-filehasher/internal/notify"
+	"github.com/maisi/unraid-filehasher/internal/web"
+)
+
+// maxMailedCorrupted caps the corrupted files a summary mail lists.
+const maxMailedCorrupted = 100
+
+// summaryMail holds the report --email settings.
+type summaryMail struct {
+	to     []string
+	always bool
+	since  string
+	smtp   notify.SMTP
+}
+
+// send mails the overview, unless nothing needs attention and always is
+// off.
+func (m summaryMail) send(database *db.DB) error {
+	if m.smtp.From == "" {
+		m.smtp.From = m.smtp.Username
+	}
+	now := time.Now()
+	since, err := format.ParseSince(m.since, now)
+	if err != nil {
+		return fmt.Errorf("invalid --email-since: %w", err)
+	}
+	stats, err := database.GetStats(db.StatsOptions{})
+	if err != nil {
+		return fmt.Errorf("get stats: %w", err)
+	}
+	diskStats, err := database.GetDiskStats(db.StatsOptions{})
+	if err != nil {
+		return fmt.Errorf("get disk stats: %w", err)
+	}
+	corrupted, err := database.GetNewlyCorrupted(since)
+	if err != nil {
+		return fmt.Errorf("get corrupted files: %w", err)
+	}
+
+	host, _ := os.Hostname()
+	summary := web.SummaryEmail{
+		Host:         host,
+		Version:      version,
+		Generated:    now,
+		Stats:        stats,
+		Disks:        diskStats,
+		Since:        since,
+		NewCorrupted: corrupted,
+	}
+	if len(corrupted) > maxMailedCorrupted {
+		summary.NewCorrupted = corrupted[:maxMailedCorrupted]
+		summary.MoreCorrupted = len(corrupted) - maxMailedCorrupted
+	}
+
+	if !summary.Problems() && !m.always {
+		logx.Infof("All clear; no summary mailed (use --email-always to send one anyway)\n")
+		return nil
+	}
+
+	var body bytes.Buffer
+	if err := web.RenderSummaryEmail(&body, summary); err != nil {
+		return fmt.Errorf("render summary: %w", err)
+	}
+	if err := m.smtp.SendHTML(m.to, summarySubject(host, stats), body.String()); err != nil {
+		return fmt.Errorf("send summary: %w", err)
+	}
+	logx.Infof("Summary mailed to %s\n", strings.Join(m.to, ", "))
+	return nil
+}
+
+// summarySubject names the problems, so the inbox alone tells good days
+// from bad ones.
+func summarySubject(host string, stats *db.Stats) string {
+	var problems []string
+	if stats.CorruptedFiles > 0 {
+		problems = append(problems, fmt.Sprintf("%d corrupted", stats.CorruptedFiles))
+	}
+	if stats.ErrorFiles > 0 {
+		problems = append(problems, fmt.Sprintf("%d unreadable", stats.ErrorFiles))
+	}
+	if stats.MissingFiles > 0 {
+		problems = append(problems, fmt.Sprintf("%d missing", stats.MissingFiles))
+	}
+	if len(problems) == 0 {
+		return fmt.Sprintf("filehasher on %s: all clear", host)
+	}
+	return fmt.Sprintf("filehasher on %s: %s", host, strings.Join(problems, ", "))
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/maisi/unraid-filehasher/internal/logx"
+)
+
+var (
+	// ephemeral is set by --ephemeral (or --db :memory:): the catalog only
+	// lasts for this run.
+	ephemeral bool
+	// ephemeralDir holds the throwaway catalog until removeEphemeralDB.
+	ephemeralDir string
+)
+
+// useEphemeralDB points dbPath at a fresh catalog in a temporary directory.
+// It is a file rather than an in-memory database so a scan's writer and its
+// lookups still run side by side under WAL; on Unraid $TMPDIR is in RAM
+// anyway, so nothing is written to a disk.
+func useEphemeralDB() error {
+	dir, err := os.MkdirTemp("", "filehasher-")
+	if err != nil {
+		return fmt.Errorf("ephemeral catalog: %w", err)
+	}
+	ephemeralDir = dir
+	dbPath = filepath.Join(dir, "catalog.db")
+	return nil
+}
+
+// removeEphemeralDB deletes the ephemeral catalog, if there is one.
+func removeEphemeralDB() {
+	if ephemeralDir == "" {
+		return
+	}
+	if err := os.RemoveAll(ephemeralDir); err != nil {
+		logx.Warnf("remove ephemeral catalog: %v\n", err)
+	}
+	ephemeralDir = ""
+}
+
+// exit ends the process with code, releasing the catalog lock and removing
+// an ephemeral catalog first. Commands use it instead of os.Exit for their
+// cron-facing exit codes.
+func exit(code int) {
+	unlockCatalog()
+	removeEphemeralDB()
+	os.Exit(code)
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maisi/unraid-filehasher/internal/format"
+	"github.com/maisi/unraid-filehasher/internal/hasher"
+	"github.com/maisi/unraid-filehasher/internal/logx"
+	"github.com/maisi/unraid-filehasher/internal/scanner"
+	"github.com/spf13/cobra"
+)
+
+// diskEstimate is the walk totals and probe result for one scan target.
+type diskEstimate struct {
+	Disk       string        `json:"disk"`
+	Path       string        `json:"path"`
+	Type       string        `json:"type"`
+	Files      int64         `json:"files"`
+	Bytes      int64         `json:"bytes"`
+	ProbeFiles int           `json:"probe_files"`
+	ProbeBytes int64         `json:"probe_bytes"`
+	Rate       float64       `json:"rate_bytes_per_sec"`
+	Estimate   time.Duration `json:"-"`
+	Err        string        `json:"error,omitempty"`
+
+	samples []hasher.FileInfo
+}
+
+func estimateCmd() *cobra.Command {
+	var autoDetect bool
+	var diskTypeOverride string
+	var excludeSimple []string
+	var excludeAppdata bool
+	var probeFiles int
+	var probeMB int64
+	var maxDepth int
+	var crossFS bool
+	var oneFS bool
+	var nohashMarker string
+	var noCacheDirTag bool
+	var excludeFSTypes []string
+
+	cmd := &cobra.Command{
+		Use:   "estimate [paths...]",
+		Short: "Estimate how long a full scan would take",
+		Long: `Walk the specified directories (or auto-detect Unraid disks) without hashing
+to count files and bytes, then hash a few randomly sampled files per disk to
+measure throughput, and print the estimated time for a full re-hash.
+
+Nothing is written to the catalog. Disks are walked and probed in parallel,
+the same way "scan --auto" hashes them, so the overall estimate is that of
+the slowest disk. Probes read at most --probe-mb from each sampled file and
+may be optimistic if those files are already in the page cache.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if probeFiles <= 0 {
+				return fmt.Errorf("invalid --probe-files %d (must be positive)", probeFiles)
+			}
+			if maxDepth < scanner.NoMaxDepth {
+				return fmt.Errorf("invalid --max-depth %d (must be 0 or positive, or -1 for no limit)", maxDepth)
+			}
+			if strings.ContainsRune(nohashMarker, '/') {
+				return fmt.Errorf("invalid --nohash-marker %q (must be a file name)", nohashMarker)
+			}
+			if probeMB <= 0 {
+				return fmt.Errorf("invalid --probe-mb %d (must be positive)", probeMB)
+			}
+
+			disks, err := resolveTargets(autoDetect, diskTypeOverride, args)
+			if err != nil {
+				return err
+			}
+
+			sc, err := scanner.New(buildExcludePatterns(excludeSimple, excludeAppdata))
+			if err != nil {
+				return err
+			}
+			sc.MaxDepth = maxDepth
+			sc.CrossFilesystems = crossFS
+			sc.OneFilesystem = oneFS
+			sc.NohashMarker = nohashMarker
+			sc.CacheDirTag = !noCacheDirTag
+			sc.ExcludeFSTypes = excludeFSTypes
+
+			if !jsonOut {
+				fmt.Println("Walking (metadata only) and probing throughput...")
+			}
+
+			estimates := make([]*diskEstimate, len(disks))
+			var wg sync.WaitGroup
+			for i, d := range disks {
+				est := &diskEstimate{Disk: d.Name, Path: d.Path, Type: d.Type.String()}
+				estimates[i] = est
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := walkForEstimate(sc, d, est, probeFiles); err != nil {
+						est.Err = err.Error()
+						return
+					}
+					probeThroughput(d, est, probeMB*1024*1024)
+				}()
+			}
+			wg.Wait()
+
+			var totalFiles, totalBytes int64
+			var overall time.Duration
+			for _, est := range estimates {
+				totalFiles += est.Files
+				totalBytes += est.Bytes
+				if est.Estimate > overall {
+					overall = est.Estimate
+				}
+			}
+
+			if jsonOut {
+				type diskOut struct {
+					*diskEstimate
+					Estimate string `json:"estimate"`
+				}
+				var diskList []diskOut
+				for _, est := range estimates {
+					diskList = append(diskList, diskOut{diskEstimate: est, Estimate: est.Estimate.String()})
+				}
+				out := map[string]interface{}{
+					"disks":       diskList,
+					"total_files": totalFiles,
+					"total_bytes": totalBytes,
+					"estimate":    overall.String(),
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(out)
+			}
+
+			fmt.Println()
+			fmt.Printf("  %-12s %12s %12s %14s %12s\n", "DISK", "FILES", "SIZE", "RATE", "ESTIMATE")
+			for _, est := range estimates {
+				rate, eta := "-", "-"
+				if est.Rate > 0 {
+					rate = format.Bytes(int64(est.Rate)) + "/s"
+					eta = est.Estimate.String()
+				}
+				fmt.Printf("  %-12s %12d %12s %14s %12s\n",
+					est.Disk, est.Files, format.Bytes(est.Bytes), rate, eta)
+				if est.Err != "" {
+					logx.Warnf("%s: %s\n", est.Disk, est.Err)
+				}
+			}
+			fmt.Println()
+			fmt.Printf("  Total files:     %d\n", totalFiles)
+			fmt.Printf("  Total size:      %s\n", format.Bytes(totalBytes))
+			fmt.Printf("  Estimated time:  %s (disks hashed in parallel)\n", overall)
+			if overall > 0 {
+				fmt.Printf("  Finishes around: %s if started now\n", time.Now().Add(overall).Format("2006-01-02 15:04"))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&autoDetect, "auto", false, "auto-detect Unraid array disks and cache")
+	cmd.Flags().StringVar(&diskTypeOverride, "disk-type", "auto", "force disk type for targets: auto|hdd|ssd")
+	cmd.Flags().StringArrayVar(&excludeSimple, "exclude-simple", nil, "simple exclude (substring match on full path); repeatable")
+	cmd.Flags().BoolVar(&excludeAppdata, "exclude-appdata", false, "exclude Unraid appdata folders")
+	cmd.Flags().IntVar(&maxDepth, "max-depth", scanner.NoMaxDepth, "same as scan --max-depth")
+	cmd.Flags().BoolVar(&crossFS, "cross-filesystems", false, "same as scan --cross-filesystems")
+	cmd.Flags().BoolVar(&oneFS, "one-filesystem", false, "same as scan --one-filesystem")
+	cmd.Flags().StringVar(&nohashMarker, "nohash-marker", scanner.DefaultNohashMarker, "same as scan --nohash-marker")
+	cmd.Flags().BoolVar(&noCacheDirTag, "no-cachedir-tag", false, "same as scan --no-cachedir-tag")
+	cmd.MarkFlagsMutuallyExclusive("cross-filesystems", "one-filesystem")
+	cmd.Flags().StringSliceVar(&excludeFSTypes, "exclude-fstype", nil, "same as scan --exclude-fstype")
+	cmd.Flags().IntVar(&probeFiles, "probe-files", 8, "number of randomly sampled files to hash per disk")
+	cmd.Flags().Int64Var(&probeMB, "probe-mb", 64, "maximum MiB to read from each sampled file")
+	return cmd
+}
+
+// walkForEstimate totals files and bytes under d and keeps a uniform random
+// sample of up to n files (reservoir sampling) for the throughput probe.
+func walkForEstimate(sc *scanner.Scanner, d scanner.DiskInfo, est *diskEstimate, n int) error {
+	files := make(chan hasher.FileInfo, 256)
+	var walkErr error
+	go func() {
+		defer close(files)
+		walkErr = sc.Walk(d.Path, d.Name, files)
+	}()
+
+	for fi := range files {
+		est.Files++
+		est.Bytes += fi.Size
+		if len(est.samples) < n {
+			est.samples = append(est.samples, fi)
+		} else if j := rand.Int64N(est.Files); j < int64(n) {
+			est.samples[j] = fi
+		}
+	}
+	return walkErr
+}
+
+// probeThroughput hashes the sampled files with the worker count scan would
+// use for this disk type and derives bytes/second and the time to hash est.Bytes.
+func probeThroughput(d scanner.DiskInfo, est *diskEstimate, limit int64) {
+	if len(est.samples) == 0 {
+		return
+	}
+
+	files, bytes, elapsed := hashSample(est.samples, workerDefaults.For(d.Type), limit)
+	est.ProbeFiles, est.ProbeBytes = files, bytes
+	if est.ProbeBytes == 0 || elapsed <= 0 {
+		return
+	}
+	est.Rate = float64(est.ProbeBytes) / elapsed.Seconds()
+	est.Estimate = time.Duration(float64(est.Bytes) / est.Rate * float64(time.Second)).Round(time.Second)
+}
+
+// hashSample reads up to limit bytes of each file with the given number of
+// parallel workers and returns how many files were read without error, the
+// bytes read and the wall time taken.
+func hashSample(sample []hasher.FileInfo, workers int, limit int64) (int, int64, time.Duration) {
+	work := make(chan hasher.FileInfo)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var files int
+	var bytes int64
+	start := time.Now()
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for fi := range work {
+				n, err := hasher.HashPrefix(fi.Path, limit)
+				mu.Lock()
+				if err == nil {
+					files++
+				}
+				bytes += n
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, fi := range sample {
+		work <- fi
+	}
+	close(work)
+	wg.Wait()
+	return files, bytes, time.Since(start)
+}
+
+// planDisk is what scan --dry-run would hash on one target.
+type planDisk struct {
+	Disk  string `json:"disk"`
+	Path  string `json:"path,omitempty"`
+	Type  string `json:"type"`
+	Files int64  `json:"files"`
+	Bytes int64  `json:"bytes"`
+	Err   string `json:"error,omitempty"`
+}
+
+// printScanPlan walks the scan targets (or the --stdin list) with excludes
+// applied and prints the files and bytes a scan would consider per disk. It
+// neither hashes nor opens the catalog.
+func printScanPlan(sc *scanner.Scanner, disks []scanner.DiskInfo, fromStdin bool) error {
+	var plan []*planDisk
+	if fromStdin {
+		// Listed paths resolve to their own disks; group by those.
+		byDisk := map[string]*planDisk{}
+		files := make(chan hasher.FileInfo, 256)
+		var walkErr error
+		go func() {
+			defer close(files)
+			walkErr = sc.WalkList(os.Stdin, files)
+		}()
+		for fi := range files {
+			pd := byDisk[fi.Disk]
+			if pd == nil {
+				pd = &planDisk{Disk: fi.Disk, Type: disks[0].Type.String()}
+				byDisk[fi.Disk] = pd
+				plan = append(plan, pd)
+			}
+			pd.Files++
+			pd.Bytes += fi.Size
+		}
+		if walkErr != nil {
+			return fmt.Errorf("read paths from stdin: %w", walkErr)
+		}
+	} else {
+		plan = make([]*planDisk, len(disks))
+		var wg sync.WaitGroup
+		for i, d := range disks {
+			pd := &planDisk{Disk: d.Name, Path: d.Path, Type: d.Type.String()}
+			plan[i] = pd
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				est := &diskEstimate{}
+				if err := walkForEstimate(sc, d, est, 0); err != nil {
+					pd.Err = err.Error()
+				}
+				pd.Files, pd.Bytes = est.Files, est.Bytes
+			}()
+		}
+		wg.Wait()
+	}
+
+	var totalFiles, totalBytes int64
+	for _, pd := range plan {
+		totalFiles += pd.Files
+		totalBytes += pd.Bytes
+	}
+
+	if jsonOut {
+		if plan == nil {
+			plan = []*planDisk{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]interface{}{
+			"dry_run":        true,
+			"disks":          plan,
+			"total_files":    totalFiles,
+			"total_bytes":    totalBytes,
+			"max_depth":      sc.MaxDepth,
+			"skipped_recent": sc.SkippedRecent(),
+		})
+	}
+
+	fmt.Println("Dry run: nothing will be hashed or written to the catalog")
+	fmt.Println()
+	fmt.Printf("  %-12s %-6s %12s %12s  %s\n", "DISK", "TYPE", "FILES", "SIZE", "PATH")
+	for _, pd := range plan {
+		fmt.Printf("  %-12s %-6s %12d %12s  %s\n", pd.Disk, pd.Type, pd.Files, format.Bytes(pd.Bytes), pd.Path)
+		if pd.Err != "" {
+			logx.Warnf("%s: %s\n", pd.Disk, pd.Err)
+		}
+	}
+	fmt.Println()
+	fmt.Printf("  Total files:     %d\n", totalFiles)
+	fmt.Printf("  Total size:      %s\n", format.Bytes(totalBytes))
+	if sc.MaxDepth >= 0 && !fromStdin {
+		fmt.Printf("  Max depth:       %d (files in deeper directories are not counted)\n", sc.MaxDepth)
+	}
+	if n := sc.SkippedRecent(); n > 0 {
+		fmt.Printf("  Too recent:      %d (modified within --min-age %s, not counted)\n", n, sc.MinAge)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/maisi/unraid-filehasher/internal/scanner"
+	"github.com/spf13/cobra"
+)
+
+// excludeCheck is check-excludes' verdict for one path.
+type excludeCheck struct {
+	Path      string `json:"path"`
+	Excluded  bool   `json:"excluded"`
+	Pattern   string `json:"pattern,omitempty"`
+	Matched   string `json:"matched,omitempty"`    // path itself or the excluded ancestor directory
+	SkippedAs string `json:"skipped_as,omitempty"` // "file" or "directory"
+	Note      string `json:"note,omitempty"`
+}
+
+func checkExcludesCmd() *cobra.Command {
+	var paths []string
+	var fromStdin bool
+	var excludeSimple []string
+	var excludeAppdata bool
+
+	cmd := &cobra.Command{
+		Use:   "check-excludes --path PATH... | --stdin",
+		Short: "Show which exclude pattern, if any, skips a path",
+		Long: `Compile the exclude patterns exactly as scan does (--exclude, --exclude-simple,
+--exclude-appdata and the config file) and report for each path whether a
+scan would skip it, which pattern is responsible, and whether it is skipped
+as a file or because a directory above it is excluded.
+
+The paths don't have to exist. With --stdin, newline-separated paths are
+read from stdin, e.g.
+  find /mnt/disk1/photos | filehasher check-excludes --stdin`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if fromStdin == (len(paths) > 0) {
+				return fmt.Errorf("give either --path or --stdin")
+			}
+
+			sc, err := scanner.New(buildExcludePatterns(excludeSimple, excludeAppdata))
+			if err != nil {
+				return err
+			}
+
+			var checks []excludeCheck
+			check := func(p string) {
+				c := checkExclude(sc, p)
+				if jsonOut {
+					checks = append(checks, c)
+					return
+				}
+				printExcludeCheck(c)
+			}
+			if fromStdin {
+				if err := eachLine(os.Stdin, check); err != nil {
+					return err
+				}
+			} else {
+				for _, p := range paths {
+					check(p)
+				}
+			}
+
+			if jsonOut {
+				if checks == nil {
+					checks = []excludeCheck{}
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(checks)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&paths, "path", nil, "path to check; repeatable")
+	cmd.Flags().BoolVar(&fromStdin, "stdin", false, "read newline-separated paths to check from stdin")
+	cmd.Flags().StringArrayVar(&excludeSimple, "exclude-simple", nil, "simple exclude (substring match on full path); repeatable")
+	cmd.Flags().BoolVar(&excludeAppdata, "exclude-appdata", false, "exclude Unraid appdata folders")
+	return cmd
+}
+
+// checkExclude explains how a scan would treat path. Relative paths are
+// made absolute first, like the scanner sees them.
+func checkExclude(sc *scanner.Scanner, path string) excludeCheck {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	c := excludeCheck{Path: path}
+	info, statErr := os.Lstat(path)
+
+	c.Pattern, c.Matched = sc.ExcludedBy(path)
+	if c.Pattern != "" {
+		c.Excluded = true
+		c.SkippedAs = "directory"
+		if c.Matched == path && (statErr != nil || !info.IsDir()) {
+			c.SkippedAs = "file"
+		}
+		return c
+	}
+
+	// Not excluded, but the walk has other reasons to pass it over
+	switch {
+	case statErr != nil:
+		c.Note = "does not exist"
+	case info.IsDir():
+		c.Note = "directory; its contents are scanned"
+	case !info.Mode().IsRegular():
+		c.Note = "not a regular file; scan skips it"
+	case info.Size() == 0:
+		c.Note = "empty; scan never catalogs empty files"
+	}
+	return c
+}
+
+func printExcludeCheck(c excludeCheck) {
+	switch {
+	case !c.Excluded && c.Note != "":
+		fmt.Printf("included  %s (%s)\n", c.Path, c.Note)
+	case !c.Excluded:
+		fmt.Printf("included  %s\n", c.Path)
+	case c.Matched == c.Path:
+		fmt.Printf("EXCLUDED  %s (%s matched by %s)\n", c.Path, c.SkippedAs, c.Pattern)
+	default:
+		fmt.Printf("EXCLUDED  %s (directory %s matched by %s)\n", c.Path, c.Matched, c.Pattern)
+	}
+}
+
+// eachLine calls fn for every non-empty line of r.
+func eachLine(r io.Reader, fn func(string)) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		if line := strings.TrimRight(sc.Text(), "\r"); line != "" {
+			fn(line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("read stdin: %w", err)
+	}
+	return nil
+}
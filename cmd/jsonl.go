@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/maisi/unraid-filehasher/internal/logx"
+)
+
+// fileLine is one --jsonl record: a file scan or verify just finished with.
+type fileLine struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256,omitempty"`
+	// Expected is the stored hash a verify compared against
+	Expected string `json:"expected_sha256,omitempty"`
+	Status   string `json:"status"`
+	Size     int64  `json:"size"`
+	Error    string `json:"error,omitempty"`
+}
+
+// lineWriter streams --jsonl records to stdout as files complete, one JSON
+// object per line. A nil lineWriter drops them.
+type lineWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// newLineWriter returns the writer for --jsonl, or nil without it. Regular
+// output (summaries, per-file detail) moves to stderr so stdout carries
+// nothing but records.
+func newLineWriter() (*lineWriter, error) {
+	if !jsonlOut {
+		return nil, nil
+	}
+	if jsonOut {
+		return nil, fmt.Errorf("--jsonl cannot be combined with --json")
+	}
+	logx.SetOutput(os.Stderr, os.Stderr)
+	return &lineWriter{enc: json.NewEncoder(os.Stdout)}, nil
+}
+
+// write emits one record. After a failed write (e.g. the reader of the pipe
+// went away) the rest are dropped with a single warning.
+func (lw *lineWriter) write(l fileLine) {
+	if lw == nil {
+		return
+	}
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	if lw.err != nil {
+		return
+	}
+	if lw.err = lw.enc.Encode(l); lw.err != nil {
+		logx.Warnf("--jsonl: %v; no more records will be written\n", lw.err)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestLineWriterShape(t *testing.T) {
+	var buf bytes.Buffer
+	lw := &lineWriter{enc: json.NewEncoder(&buf)}
+	lw.write(fileLine{Path: "/mnt/disk1/a.mkv", SHA256: "aa", Status: "new", Size: 3})
+	lw.write(fileLine{Path: "/mnt/disk1/b.mkv", SHA256: "bb", Expected: "cc", Status: "corrupted", Size: 5})
+	lw.write(fileLine{Path: "/mnt/disk1/c.mkv", Status: "error", Error: "input/output error"})
+	lw.write(fileLine{Path: "/mnt/disk1/d.mkv", Status: "missing"})
+
+	want := `{"path":"/mnt/disk1/a.mkv","sha256":"aa","status":"new","size":3}
+{"path":"/mnt/disk1/b.mkv","sha256":"bb","expected_sha256":"cc","status":"corrupted","size":5}
+{"path":"/mnt/disk1/c.mkv","status":"error","size":0,"error":"input/output error"}
+{"path":"/mnt/disk1/d.mkv","status":"missing","size":0}
+`
+	if buf.String() != want {
+		t.Errorf("lines =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	// A nil writer (no --jsonl) drops records
+	var none *lineWriter
+	none.write(fileLine{Path: "x"})
+}
+
+type failingWriter struct{ n int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.n++
+	return 0, errors.New("broken pipe")
+}
+
+func TestLineWriterStopsAfterError(t *testing.T) {
+	w := &failingWriter{}
+	lw := &lineWriter{enc: json.NewEncoder(w)}
+	lw.write(fileLine{Path: "a"})
+	lw.write(fileLine{Path: "b"})
+	if w.n != 1 || lw.err == nil {
+		t.Errorf("writes = %d, err = %v; want 1 write and the error kept", w.n, lw.err)
+	}
+}
+
+func TestScanJSONL(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	content := []byte("some content\n")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+
+	// stdout only: it must carry nothing but records
+	cmd := exec.Command(os.Args[0], "scan", "--db", dbPath, "--jsonl", dir)
+	cmd.Env = append(os.Environ(), "FILEHASHER_TEST_MAIN=1", "NO_COLOR=1")
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("scan --jsonl: %v", err)
+	}
+	var lines []map[string]interface{}
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		var line map[string]interface{}
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			t.Fatalf("not a JSON line: %q (%v)", sc.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines, want 1:\n%s", len(lines), out)
+	}
+	sum := sha256.Sum256(content)
+	line := lines[0]
+	if line["path"] != path || line["sha256"] != hex.EncodeToString(sum[:]) || line["size"] != float64(len(content)) {
+		t.Errorf("line = %v", line)
+	}
+	if _, ok := line["status"].(string); !ok {
+		t.Errorf("line has no status: %v", line)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/maisi/unraid-filehasher/internal/db"
+	"github.com/maisi/unraid-filehasher/internal/format"
+	"github.com/spf13/cobra"
+)
+
+func lookupCmd() *cobra.Command {
+	var pathBase string
+
+	cmd := &cobra.Command{
+		Use:   "lookup <path>",
+		Short: "Show the catalog record and hash history of one file",
+		Long: `Print everything the catalog knows about a single file: hash, size, status,
+when it was first seen and last verified, and any recorded hash changes.
+
+The path is matched as given, then as an absolute path, then relative to
+--path-base (for catalogs scanned with --path-mode relative).`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := db.Open(dbPath, dbOptions)
+			if err != nil {
+				return fmt.Errorf("open database: %w", err)
+			}
+			defer database.Close()
+
+			rec, err := findRecord(database, args[0], pathBase)
+			if err != nil {
+				return err
+			}
+			if rec == nil {
+				return fmt.Errorf("%s is not tracked", args[0])
+			}
+			changes, err := database.GetFileHistory(rec.Path)
+			if err != nil {
+				return fmt.Errorf("get file history: %w", err)
+			}
+			if changes == nil {
+				changes = []*db.FileChange{}
+			}
+
+			if jsonOut {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(map[string]interface{}{
+					"file":    rec,
+					"history": changes,
+				})
+			}
+
+			algo := rec.Algo
+			if algo == "" {
+				algo = "sha256"
+			}
+			fmt.Printf("Path:          %s\n", rec.Path)
+			fmt.Printf("Disk:          %s\n", rec.Disk)
+			fmt.Printf("Status:        %s\n", rec.Status)
+			fmt.Printf("Size:          %s (%d bytes)\n", format.Bytes(rec.Size), rec.Size)
+			fmt.Printf("Modified:      %s\n", time.Unix(rec.Mtime, rec.MtimeNsec).Format(time.RFC3339))
+			fmt.Printf("Hash:          %s (%s)\n", rec.SHA256, algo)
+			fmt.Printf("First seen:    %s\n", rec.FirstSeen.Format(time.RFC3339))
+			fmt.Printf("Last verified: %s\n", rec.LastVerified.Format(time.RFC3339))
+
+			if len(changes) > 0 {
+				fmt.Printf("\nHistory:\n")
+				for _, c := range changes {
+					fmt.Printf("  %s  %-8s %s -> %s  %s -> %s\n",
+						c.ChangedAt.Format(time.RFC3339), c.Reason,
+						shortHash(c.OldSHA256), shortHash(c.NewSHA256),
+						format.Bytes(c.OldSize), format.Bytes(c.NewSize))
+				}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&pathBase, "path-base", db.DefaultPathBase, "where relative catalog paths (scan --path-mode relative) are found")
+	return cmd
+}
+
+// findRecord looks path up as stored, as an absolute path, and relative to
+// pathBase, returning nil if none of them is tracked.
+func findRecord(database *db.DB, path, pathBase string) (*db.FileRecord, error) {
+	candidates := []string{path}
+	if abs, err := filepath.Abs(path); err == nil {
+		candidates = append(candidates, abs)
+		if rel, err := db.RelativePath(abs, pathBase); err == nil {
+			candidates = append(candidates, rel)
+		}
+	}
+	for _, p := range candidates {
+		rec, err := database.GetFileByPath(p)
+		if err != nil {
+			return nil, fmt.Errorf("look up %s: %w", p, err)
+		}
+		if rec != nil {
+			return rec, nil
+		}
+	}
+	return nil, nil
+}
+
+func shortHash(h string) string {
+	if len(h) > 16 {
+		return h[:16] + "..."
+	}
+	return h
+}
@@ -1,22 +1,36 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
+	"text/template"
 	"time"
 
 	"github.com/maisi/unraid-filehasher/internal/db"
 	"github.com/maisi/unraid-filehasher/internal/format"
 	"github.com/maisi/unraid-filehasher/internal/hasher"
+	"github.com/maisi/unraid-filehasher/internal/logx"
+	"github.com/maisi/unraid-filehasher/internal/notify"
+	"github.com/maisi/unraid-filehasher/internal/progress"
 	"github.com/maisi/unraid-filehasher/internal/scanner"
 	"github.com/maisi/unraid-filehasher/internal/verifier"
 	"github.com/maisi/unraid-filehasher/internal/web"
+	"github.com/maisi/unraid-filehasher/internal/xattr"
 	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 	"github.com/vbauerster/mpb/v8"
@@ -24,10 +38,22 @@ import (
 )
 
 var (
-	version  = "dev"
-	dbPath   string
-	jsonOut  bool
-	excludes []string
+	version   = "dev"
+	dbPath    string
+	jsonOut   bool
+	jsonlOut  bool // --jsonl of scan and verify
+	dbOptions db.Options
+	excludes  []string
+	units     string
+	quiet     bool
+	verbose   bool
+	logFormat string
+	poolNames []string
+	noColor   bool
+
+	// workerDefaults sizes per-disk hash pipelines by disk type; set from
+	// --hdd-workers, --ssd-workers and --unknown-workers.
+	workerDefaults = scanner.BuiltinWorkers
 )
 
 func defaultDBPath() string {
@@ -35,7 +61,7 @@ func defaultDBPath() string {
 	if _, err := os.Stat("/boot/config"); err == nil {
 		dir := "/boot/config/filehasher"
 		if err := os.MkdirAll(dir, 0755); err != nil {
-			fmt.Fprintf(os.Stderr, "warning: create config dir %s: %v\n", dir, err)
+			logx.Warnf("create config dir %s: %v\n", dir, err)
 			return "filehasher.db"
 		}
 		return filepath.Join(dir, "filehasher.db")
@@ -44,6 +70,35 @@ func defaultDBPath() string {
 	return "filehasher.db"
 }
 
+// showProgressBars reports whether live progress bars should be drawn: on a
+// terminal, at the normal output level, and not for --json. Verbose output
+// prints a line per file instead.
+func showProgressBars() bool {
+	return !jsonOut && !jsonlOut && isatty.IsTerminal(os.Stderr.Fd()) &&
+		logx.Enabled(logx.LevelNormal) && !logx.Enabled(logx.LevelVerbose)
+}
+
+// useColor reports whether results on stdout should be colored: on a
+// terminal, not for --json, and unless --no-color or NO_COLOR
+// (https://no-color.org) asks otherwise.
+func useColor() bool {
+	return !noColor && !jsonOut && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" &&
+		isatty.IsTerminal(os.Stdout.Fd())
+}
+
+// routeThroughBars sends logx output through p while its bars are drawn, so
+// warnings print above the bars instead of tearing them; stdout goes the same
+// way when it is the same terminal. It returns where result lines should be
+// written. Restore with logx.SetOutput(os.Stdout, os.Stderr) after p.Wait.
+func routeThroughBars(p *mpb.Progress) io.Writer {
+	stdout := io.Writer(os.Stdout)
+	if isatty.IsTerminal(os.Stdout.Fd()) {
+		stdout = p
+	}
+	logx.SetOutput(stdout, p)
+	return stdout
+}
+
 func main() {
 	rootCmd := &cobra.Command{
 		Use:     "filehasher",
@@ -75,16 +130,100 @@ Additional help topics:{{range .Commands}}{{if .IsAdditionalHelpTopicCommand}}
 Use "{{.CommandPath}} [command] --help" for more information about a command.{{end}}
 `)
 
-	rootCmd.PersistentFlags().StringVar(&dbPath, "db", defaultDBPath(), "path to SQLite database")
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		f, err := logx.ParseFormat(logFormat)
+		if err != nil {
+			return err
+		}
+		logx.SetFormat(f)
+		switch {
+		case quiet:
+			logx.SetLevel(logx.LevelQuiet)
+		case verbose:
+			logx.SetLevel(logx.LevelVerbose)
+		}
+		format.SetColor(useColor())
+		// Named pools (beyond cache*) from Unraid's config plus --pool-name
+		pools, err := scanner.LoadPoolNames(scanner.PoolConfigDir)
+		if err != nil {
+			logx.Warnf("%v\n", err)
+		}
+		if err := scanner.SetPoolNames(append(pools, poolNames...)); err != nil {
+			return fmt.Errorf("--pool-name: %w", err)
+		}
+		if err := applyConfig(cmd); err != nil {
+			return err
+		}
+		if ephemeral || dbPath == db.MemoryPath {
+			ephemeral = true
+			if err := useEphemeralDB(); err != nil {
+				return err
+			}
+		}
+		if dbOptions.BusyTimeout <= 0 {
+			return fmt.Errorf("invalid --db-busy-timeout %s (must be positive)", dbOptions.BusyTimeout)
+		}
+		if dbOptions.CacheSizeKB <= 0 {
+			return fmt.Errorf("invalid --db-cache-kb %d (must be positive)", dbOptions.CacheSizeKB)
+		}
+		switch strings.ToLower(dbOptions.Synchronous) {
+		case "off", "normal", "full":
+		default:
+			return fmt.Errorf("invalid --db-synchronous %q (expected off|normal|full)", dbOptions.Synchronous)
+		}
+		for _, f := range []struct {
+			name string
+			n    int
+		}{{"hdd-workers", workerDefaults.HDD}, {"ssd-workers", workerDefaults.SSD}, {"unknown-workers", workerDefaults.Unknown}} {
+			if f.n <= 0 {
+				return fmt.Errorf("invalid --%s %d (must be positive)", f.name, f.n)
+			}
+		}
+		return nil
+	}
+
+	rootCmd.PersistentFlags().StringVar(&dbPath, "db", "", "path to SQLite database (default: $FILEHASHER_DB, config file, or auto-detected)")
+	rootCmd.PersistentFlags().BoolVar(&ephemeral, "ephemeral", false, "use a throwaway catalog that is deleted on exit, for one-off checks (same as --db :memory:)")
+	rootCmd.PersistentFlags().DurationVar(&dbOptions.BusyTimeout, "db-busy-timeout", db.DefaultBusyTimeout, "how long to wait for a database lock held by another process (e.g. the dashboard) before failing")
+	rootCmd.PersistentFlags().IntVar(&dbOptions.CacheSizeKB, "db-cache-kb", db.DefaultCacheSizeKB, "SQLite page cache per connection, in KiB")
+	rootCmd.PersistentFlags().StringVar(&dbOptions.Synchronous, "db-synchronous", strings.ToLower(db.DefaultSynchronous), "SQLite synchronous mode: off, normal (safe with WAL) or full (also keeps the last commits on power loss)")
 	rootCmd.PersistentFlags().BoolVar(&jsonOut, "json", false, "output results as JSON")
 	rootCmd.PersistentFlags().StringSliceVarP(&excludes, "exclude", "e", nil, "regex patterns to exclude (can be repeated)")
+	rootCmd.PersistentFlags().StringVar(&units, "units", "iec", "size units: iec (KiB, MiB, powers of 1024) or si (KB, MB, powers of 1000)")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "only print results, warnings and errors (no progress or summaries); for cron")
+	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "also print every file as it is processed")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "never color output (colors are only used when stdout is a terminal; NO_COLOR is honored too)")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "how warnings, errors and events are written to stderr: text or json (one object per line)")
+	rootCmd.PersistentFlags().IntVar(&workerDefaults.HDD, "hdd-workers", scanner.BuiltinWorkers.HDD, "hash workers per HDD when none are given")
+	rootCmd.PersistentFlags().IntVar(&workerDefaults.SSD, "ssd-workers", scanner.BuiltinWorkers.SSD, "hash workers per SSD when none are given")
+	rootCmd.PersistentFlags().IntVar(&workerDefaults.Unknown, "unknown-workers", scanner.BuiltinWorkers.Unknown, "hash workers per disk of unknown type when none are given")
+	rootCmd.PersistentFlags().StringSliceVar(&poolNames, "pool-name", nil, "named pool under /mnt to treat like a cache pool, e.g. nvme (repeatable; pools in "+scanner.PoolConfigDir+" are found automatically)")
+	rootCmd.MarkFlagsMutuallyExclusive("quiet", "verbose")
 
 	rootCmd.AddCommand(scanCmd())
+	rootCmd.AddCommand(detectCmd())
+	rootCmd.AddCommand(disksCmd())
 	rootCmd.AddCommand(verifyCmd())
+	rootCmd.AddCommand(verifySharesCmd())
+	rootCmd.AddCommand(estimateCmd())
+	rootCmd.AddCommand(benchCmd())
+	rootCmd.AddCommand(checkExcludesCmd())
 	rootCmd.AddCommand(reportCmd())
+	rootCmd.AddCommand(ackCmd())
+	rootCmd.AddCommand(rehashCmd())
+	rootCmd.AddCommand(migrateHashCmd())
+	rootCmd.AddCommand(dupesCmd())
+	rootCmd.AddCommand(lookupCmd())
+	rootCmd.AddCommand(inspectCmd())
+	rootCmd.AddCommand(dbCmd())
 	rootCmd.AddCommand(serverCmd())
 
-	if err := rootCmd.Execute(); err != nil {
+	// Errors are printed through logx so --log-format json applies to them too
+	rootCmd.SilenceErrors = true
+	err := rootCmd.Execute()
+	removeEphemeralDB()
+	if err != nil {
+		logx.Errorf("%v\n", err)
 		os.Exit(1)
 	}
 }
@@ -96,6 +235,26 @@ func scanCmd() *cobra.Command {
 	var excludeSimple []string
 	var excludeAppdata bool
 	var hddTwoPhase bool
+	var maxDepth int
+	var minAge time.Duration
+	var pathMode string
+	var pathBase string
+	var reconcile bool
+	var preWalk bool
+	var fromStdin bool
+	var secondaryHash string
+	var readRetries int
+	var dryRun bool
+	var chunked bool
+	var crossFS bool
+	var oneFS bool
+	var nohashMarker string
+	var noCacheDirTag bool
+	var excludeFSTypes []string
+	var tag string
+	var trackPerms bool
+	var xattrName string
+	var shatag bool
 
 	cmd := &cobra.Command{
 		Use:   "scan [paths...]",
@@ -107,78 +266,147 @@ By default, incremental mode is used: files whose size and mtime haven't
 changed since the last scan are skipped. Use --full to force re-hashing
 every file.
 
+With --reconcile, tracked files under a scanned root that the walk didn't
+see, and that no longer exist on disk, are marked missing.
+
+With --stdin, no directories are walked: newline-separated file paths are
+read from stdin and exactly those files are hashed (each once, even if
+listed twice), e.g.
+  find /mnt/disk1/photos -newer stamp | filehasher scan --stdin
+
+With --chunked, files are hashed in 64 MiB pieces. A file that only grew
+since its last chunked hash (e.g. an append-only log) then costs one piece
+plus the new tail to re-hash instead of the whole file.
+
+With --tag, every file the scan sees is tagged (e.g. "backups"), including
+unchanged ones it skips; report, verify and the dashboard can then be
+narrowed to that tag. Files keep their tag when later scanned without one.
+
+With --dry-run, the targets are only walked (excludes and --max-depth
+applied) and the files and bytes per disk are printed; nothing is hashed and
+the catalog is not opened.
+
 When using --auto, each disk gets its own hashing pipeline with worker
-counts tuned to the disk type (1 worker for HDDs, 4 for SSDs).`,
+counts tuned to the disk type: by default 1 worker for HDDs, 4 for SSDs and 2
+when the type is unknown. Override these with --hdd-workers, --ssd-workers and
+--unknown-workers, or hdd_workers, ssd_workers and unknown_workers in
+config.yaml.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if hddTwoPhase && !jsonOut {
-				fmt.Println("HDD mode: two-phase scan enabled (walk first, then hash)")
+			if jsonlOut && dryRun {
+				return fmt.Errorf("--jsonl cannot be combined with --dry-run")
 			}
-
-			// Determine scan targets
-			var disks []scanner.DiskInfo
-
-			// Optional disk type override (useful when /sys detection is wrong or unavailable)
-			var overrideType *scanner.DiskType
-			switch strings.ToLower(strings.TrimSpace(diskTypeOverride)) {
-			case "", "auto":
-				// no override
-			case "hdd":
-				dt := scanner.DiskTypeHDD
-				overrideType = &dt
-			case "ssd":
-				dt := scanner.DiskTypeSSD
-				overrideType = &dt
-			default:
-				return fmt.Errorf("invalid --disk-type %q (expected auto|hdd|ssd)", diskTypeOverride)
+			lines, err := newLineWriter()
+			if err != nil {
+				return err
+			}
+			if hddTwoPhase && !jsonOut && !dryRun {
+				logx.Infof("HDD mode: two-phase scan enabled (walk first, then hash)\n")
+			}
+			if minAge < 0 {
+				return fmt.Errorf("invalid --min-age %s (must be 0 or positive)", minAge)
+			}
+			if maxDepth < scanner.NoMaxDepth {
+				return fmt.Errorf("invalid --max-depth %d (must be 0 or positive, or -1 for no limit)", maxDepth)
+			}
+			if !hasher.SupportedSecondary(secondaryHash) {
+				return fmt.Errorf("invalid --secondary-hash %q (supported: crc32c)", secondaryHash)
+			}
+			if readRetries < 0 {
+				return fmt.Errorf("invalid --read-retries %d (must be 0 or positive)", readRetries)
+			}
+			if strings.ContainsRune(nohashMarker, '/') {
+				return fmt.Errorf("invalid --nohash-marker %q (must be a file name)", nohashMarker)
+			}
+			if chunked && secondaryHash != "" {
+				return fmt.Errorf("--chunked cannot be combined with --secondary-hash")
+			}
+			if chunked && (xattrName != "" || shatag) {
+				return fmt.Errorf("--xattr and --shatag cannot be combined with --chunked (a chunked hash isn't the file's checksum)")
 			}
 
-			if autoDetect {
-				detected, err := scanner.DetectUnraidDisks()
-				if err != nil {
-					return fmt.Errorf("auto-detect disks: %w", err)
-				}
-				if len(detected) == 0 {
-					return fmt.Errorf("no Unraid disks detected under /mnt/")
+			var disks []scanner.DiskInfo
+			if fromStdin {
+				if autoDetect || len(args) > 0 {
+					return fmt.Errorf("--stdin cannot be combined with --auto or path arguments")
 				}
-				disks = detected
-				if overrideType != nil {
-					for i := range disks {
-						disks[i].Type = *overrideType
-					}
+				if reconcile {
+					return fmt.Errorf("--reconcile needs scan roots and cannot be used with --stdin")
 				}
-				for _, d := range disks {
-					fmt.Printf("Detected: %s (%s, %s, %d workers)\n",
-						d.Name, d.Path, d.Type, d.Type.DefaultWorkers())
+				// One pipeline for the whole list; each file still gets its own
+				// disk name from ResolveDisk.
+				dt, err := parseDiskType(diskTypeOverride)
+				if err != nil {
+					return err
 				}
+				disks = []scanner.DiskInfo{{Name: "stdin", Type: dt}}
 			} else {
-				if len(args) == 0 {
-					return fmt.Errorf("no paths specified; use --auto or provide paths as arguments")
+				disks, err = resolveTargets(autoDetect, diskTypeOverride, args)
+				if err != nil {
+					return err
 				}
-				for _, p := range args {
-					absPath, err := filepath.Abs(p)
-					if err != nil {
-						return fmt.Errorf("resolve path %s: %w", p, err)
+			}
+
+			// Catalog path form: absolute, or relative to --path-base (portable
+			// across mount layouts; verify joins them back onto its --path-base).
+			toStored := func(p string) string { return p }
+			switch pathMode {
+			case "absolute":
+			case "relative":
+				for _, d := range disks {
+					if fromStdin {
+						break // listed paths outside the base are kept absolute
 					}
-					name := scanner.ResolveDisk(absPath, absPath)
-					dt := scanner.DiskTypeUnknown
-					if overrideType != nil {
-						dt = *overrideType
+					if _, err := db.RelativePath(d.Path, pathBase); err != nil {
+						return fmt.Errorf("--path-mode relative: %w", err)
 					}
-					disks = append(disks, scanner.DiskInfo{
-						Name: name,
-						Path: absPath,
-						Type: dt,
-					})
 				}
+				toStored = func(p string) string {
+					if rel, err := db.RelativePath(p, pathBase); err == nil {
+						return rel
+					}
+					return p
+				}
+			default:
+				return fmt.Errorf("invalid --path-mode %q (expected absolute|relative)", pathMode)
+			}
+
+			if dryRun {
+				sc, err := scanner.New(buildExcludePatterns(excludeSimple, excludeAppdata))
+				if err != nil {
+					return err
+				}
+				sc.MaxDepth = maxDepth
+				sc.MinAge = minAge
+				sc.CrossFilesystems = crossFS
+				sc.OneFilesystem = oneFS
+				sc.NohashMarker = nohashMarker
+				sc.CacheDirTag = !noCacheDirTag
+				sc.ExcludeFSTypes = excludeFSTypes
+				return printScanPlan(sc, disks, fromStdin)
+			}
+
+			if err := lockCatalog("scan"); err != nil {
+				return err
 			}
+			defer unlockCatalog()
 
 			// Open database
-			database, err := db.Open(dbPath)
+			database, err := db.Open(dbPath, dbOptions)
 			if err != nil {
 				return fmt.Errorf("open database: %w", err)
 			}
 			defer database.Close()
 
+			// Remember detected disks so verify and the dashboard can reuse
+			// their types without probing /sys again
+			if autoDetect {
+				for _, d := range disks {
+					if err := database.UpsertDisk(diskRecord(d)); err != nil {
+						logx.Warnf("record disk %s: %v\n", d.Name, err)
+					}
+				}
+			}
+
 			// Load existing file index for incremental scan
 			var lookupMap map[string]*db.QuickLookup
 			if !fullScan {
@@ -187,42 +415,39 @@ counts tuned to the disk type (1 worker for HDDs, 4 for SSDs).`,
 					return fmt.Errorf("load lookup map: %w", err)
 				}
 				if !jsonOut {
-					fmt.Printf("Loaded %d existing file records for incremental comparison\n", len(lookupMap))
+					logx.Infof("Loaded %d existing file records for incremental comparison\n", len(lookupMap))
 				}
 			}
 
-			// Build exclude patterns
-			excludePatterns := append([]string{}, excludes...)
-			for _, p := range excludeSimple {
-				p = strings.TrimSpace(p)
-				if p == "" {
-					continue
-				}
-				// Substring match via regex-quoted pattern
-				excludePatterns = append(excludePatterns, regexp.QuoteMeta(p))
-			}
-			if excludeAppdata {
-				// Covers /mnt/cache/appdata, /mnt/user/appdata, nested .../appdata/... etc.
-				excludePatterns = append(excludePatterns, `(^|/)(appdata)(/|$)`)
-			}
+			excludePatterns := buildExcludePatterns(excludeSimple, excludeAppdata)
 
 			// Create scanner
 			sc, err := scanner.New(excludePatterns)
 			if err != nil {
 				return err
 			}
+			sc.MaxDepth = maxDepth
+			sc.MinAge = minAge
+			sc.CrossFilesystems = crossFS
+			sc.OneFilesystem = oneFS
+			sc.NohashMarker = nohashMarker
+			sc.CacheDirTag = !noCacheDirTag
+			sc.ExcludeFSTypes = excludeFSTypes
+			sc.TrackPerms = trackPerms
 
 			// Record scan history
 			var pathNames []string
 			for _, d := range disks {
 				pathNames = append(pathNames, d.Name)
 			}
-			scanID, err := database.InsertScanHistory("scan", strings.Join(pathNames, ","))
+			scanAlgo, err := database.CatalogAlgo()
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "warning: failed to record scan history: %v\n", err)
+				return fmt.Errorf("get catalog algorithm: %w", err)
+			}
+			scanID, err := database.InsertScanHistory("scan", strings.Join(pathNames, ","), version, scanAlgo)
+			if err != nil {
+				logx.Warnf("failed to record scan history: %v\n", err)
 			}
-
-			start := time.Now()
 
 			// Aggregate result channel — all disk pipelines feed into this
 			results := make(chan hasher.Result, 256)
@@ -231,23 +456,15 @@ counts tuned to the disk type (1 worker for HDDs, 4 for SSDs).`,
 			var scanErrors []string
 			var scanErrMu sync.Mutex
 
-			// Counters
-			var skipped int64
-			var totalProcessed int64
-			var totalErrors int64
-			var eligibleBytes int64
-			var eligibleFiles int64
-			eligibleBytesByDisk := map[string]*atomic.Int64{}
-			eligibleFilesByDisk := map[string]*atomic.Int64{}
+			// Counters for the whole run, and per disk for the hash bars
+			tracker := progress.New()
+			diskTrackers := map[string]*progress.Tracker{}
 			for _, d := range disks {
-				var b atomic.Int64
-				var f atomic.Int64
-				eligibleBytesByDisk[d.Name] = &b
-				eligibleFilesByDisk[d.Name] = &f
+				diskTrackers[d.Name] = progress.New()
 			}
 
-			// Progress bars (TTY only, disabled for --json)
-			useProgress := !jsonOut && isatty.IsTerminal(os.Stderr.Fd())
+			// Progress bars (TTY only, disabled for --json, --quiet and --verbose)
+			useProgress := showProgressBars()
 			var p *mpb.Progress
 			type diskBars struct {
 				walk *mpb.Bar
@@ -258,6 +475,7 @@ counts tuned to the disk type (1 worker for HDDs, 4 for SSDs).`,
 				p = mpb.New(mpb.WithOutput(os.Stderr), mpb.WithWidth(64))
 				for _, d := range disks {
 					name := d.Name
+					dt := diskTrackers[name]
 					w := p.AddSpinner(0,
 						mpb.PrependDecorators(
 							decor.Name(fmt.Sprintf("%s walk ", name), decor.WC{W: 16, C: decor.DindentRight}),
@@ -270,43 +488,197 @@ counts tuned to the disk type (1 worker for HDDs, 4 for SSDs).`,
 					h := p.AddBar(0,
 						mpb.PrependDecorators(
 							decor.Name(fmt.Sprintf("%s hash ", name), decor.WC{W: 16, C: decor.DindentRight}),
-							decor.CountersKibiByte("% .1f / % .1f", decor.WC{W: 18, C: decor.DindentRight}),
+							decor.Any(func(decor.Statistics) string {
+								s := dt.Snapshot()
+								return fmt.Sprintf("%d / %d files", s.Processed, s.FilesTotal)
+							}, decor.WC{W: 22, C: decor.DindentRight}),
+							decor.CountersKibiByte("% .1f / % .1f", decor.WC{W: 24, C: decor.DindentRight}),
 							decor.AverageSpeed(decor.SizeB1024(0), "% .1f", decor.WC{W: 12, C: decor.DindentRight}),
 						),
 						mpb.AppendDecorators(
 							decor.Percentage(decor.WC{W: 6}),
-							decor.AverageETA(decor.ET_STYLE_GO, decor.WC{W: 12}),
+							decor.Name(" ETA "),
+							decor.AverageETA(decor.ET_STYLE_HHMMSS, decor.WC{W: 8}),
 						),
 					)
 					diskProgress[name] = diskBars{walk: w, hash: h}
 				}
 			}
 
-			// Thread-safe log helper: writes through mpb when progress is active
-			// (so the cursor math stays correct) and also collects messages
-			// for a summary after progress bars finish.
+			// Warnings and errors go through logx; while the bars are drawn its
+			// stderr is routed through mpb (so the cursor math stays correct).
+			// Text messages are also collected for a summary after the bars
+			// finish.
+			if useProgress {
+				routeThroughBars(p)
+				defer logx.SetOutput(os.Stdout, os.Stderr)
+			}
 			var progressMsgsMu sync.Mutex
 			var progressMsgs []string
-			logProgress := func(format string, args ...interface{}) {
+			logProgress := func(lvl, path, format string, args ...interface{}) {
 				msg := fmt.Sprintf(format, args...)
+				if path != "" {
+					msg = path + ": " + msg
+				}
 				progressMsgsMu.Lock()
-				progressMsgs = append(progressMsgs, msg)
+				progressMsgs = append(progressMsgs, lvl+": "+msg)
 				progressMsgsMu.Unlock()
-				if useProgress {
-					fmt.Fprint(p, msg)
+				if lvl == "warning" {
+					logx.PathWarnf(path, format, args...)
 				} else {
-					fmt.Fprint(os.Stderr, msg)
+					logx.PathErrorf(path, format, args...)
+				}
+			}
+
+			// Incremental check: skip files whose size and mtime haven't changed
+			// since the last scan. With --tag, skipped files that don't carry
+			// the tag yet are collected and tagged once the scan is done, and
+			// with --track-perms those whose owner or mode changed (which
+			// leaves the mtime alone) get the new ones recorded.
+			var retagMu sync.Mutex
+			retag := map[string]struct{}{}
+			reperm := map[string]db.Perms{}
+			var xattrs *xattrTagger
+			if xattrName != "" || shatag {
+				xattrs = newXattrTagger(xattrName, shatag)
+			}
+			unchanged := func(fi hasher.FileInfo) bool {
+				if lookupMap == nil {
+					return false
+				}
+				stored := toStored(fi.Path)
+				existing, ok := lookupMap[stored]
+				if !ok || existing.Size != fi.Size || !db.SameMtime(existing.Mtime, existing.MtimeNsec, fi.Mtime, fi.MtimeNsec) {
+					return false
+				}
+				if tag != "" && existing.Tag != tag {
+					retagMu.Lock()
+					retag[stored] = struct{}{}
+					retagMu.Unlock()
+				}
+				if fi.Perms != nil && (existing.Perms == nil || *existing.Perms != db.Perms(*fi.Perms)) {
+					retagMu.Lock()
+					reperm[stored] = db.Perms(*fi.Perms)
+					retagMu.Unlock()
+				}
+				if xattrs != nil && existing.ChunkSize == 0 {
+					xattrs.refresh(fi.Path, existing.Algo, existing.SHA256, existing.Mtime, existing.MtimeNsec)
+				}
+				return true
+			}
+
+			// New files are hashed with the catalog's algorithm and changed
+			// ones with their own, so a scan after migrate-hash doesn't move
+			// files back to sha256 and moves can still be matched.
+			withAlgo := func(fi *hasher.FileInfo) {
+				fi.Algo = scanAlgo
+				if lookupMap == nil {
+					return
+				}
+				if existing, ok := lookupMap[toStored(fi.Path)]; ok {
+					fi.Algo = existing.Algo
+				}
+			}
+
+			// --chunked: hash in pieces, and for a file that has only grown since
+			// its last chunked hash, pass the old chunks along so the hasher can
+			// skip to the new tail.
+			var appended int64
+			var inFlight int64 // files written to while they were hashed
+			withChunks := func(fi *hasher.FileInfo) {
+				if !chunked {
+					return
+				}
+				fi.ChunkSize = hasher.DefaultChunkSize
+				if lookupMap == nil {
+					return
+				}
+				stored := toStored(fi.Path)
+				existing, ok := lookupMap[stored]
+				if !ok || existing.ChunkSize != fi.ChunkSize || fi.Size <= existing.Size {
+					return
+				}
+				chunks, err := database.GetChunks(stored)
+				if err != nil {
+					logProgress("warning", fi.Path, "load chunks: %v\n", err)
+					return
+				}
+				fi.PrevChunks, fi.PrevSize = chunks, existing.Size
+			}
+
+			// Writes go through a single writer goroutine (see db.Writer) so the
+			// dashboard can keep reading.
+			writer := database.NewWriter(1000, 2*time.Second, func(err error) {
+				tracker.AddErrors(1)
+				logProgress("error", "", "%v\n", err)
+			})
+			defer writer.Close()
+
+			// With --shatag, a file the catalog doesn't know yet and that has
+			// current cshatag attributes (stamped with its present mtime) is
+			// cataloged with their hash instead of being read, as cshatag
+			// itself would trust it. Files that might be moves of tracked ones
+			// are hashed, so move detection still sees them.
+			adopt := func(fi hasher.FileInfo) bool {
+				if xattrs == nil || lookupMap == nil || secondaryHash != "" {
+					return false
+				}
+				stored := toStored(fi.Path)
+				if _, ok := lookupMap[stored]; ok {
+					return false
+				}
+				hash, ok := xattrs.current(fi.Path, fi.Mtime, fi.MtimeNsec)
+				if !ok {
+					return false
+				}
+				if cands, err := database.FindMoveCandidates(filepath.Base(fi.Path), fi.Size, scanAlgo, 0, 1); err != nil || len(cands) > 0 {
+					return false
+				}
+				now := time.Now()
+				record := &db.FileRecord{
+					Path:         stored,
+					Disk:         fi.Disk,
+					Size:         fi.Size,
+					Mtime:        fi.Mtime,
+					MtimeNsec:    fi.MtimeNsec,
+					SHA256:       hash,
+					Algo:         "sha256",
+					FirstSeen:    now,
+					LastVerified: now,
+					Status:       "new",
+					Tag:          tag,
+				}
+				if fi.Perms != nil {
+					record.Perms = (*db.Perms)(fi.Perms)
+				}
+				writer.Upsert(record)
+				xattrs.adopted.Add(1)
+				return true
+			}
+
+			// Errors are also stored with the run, so the History page can
+			// show which paths failed long after stderr is gone
+			recordError := func(path string, err error) {
+				if scanID > 0 {
+					writer.ScanError(scanID, path, err.Error())
 				}
 			}
+			sc.OnError = recordError
+
+			// Per-disk sets of stored paths seen by the walk, for --reconcile.
+			// Indexed like disks, since several scan roots may share a disk name.
+			seenByDisk := make([]map[string]struct{}, len(disks))
+			walkFailed := make([]bool, len(disks))
 
 			// Launch per-disk pipelines
 			var pipelineWg sync.WaitGroup
-			for _, d := range disks {
-				workers := d.Type.DefaultWorkers()
+			for i, d := range disks {
+				workers := workerDefaults.For(d.Type)
 				diskInput := make(chan hasher.FileInfo, workers*4)
 				output := make(chan hasher.Result, workers*4)
 
 				h := hasher.New(workers)
+				h.ReadRetries = readRetries
 
 				// Forward disk pipeline output to aggregate results channel
 				pipelineWg.Add(1)
@@ -323,49 +695,70 @@ counts tuned to the disk type (1 worker for HDDs, 4 for SSDs).`,
 				// Start scanner goroutine for this disk.
 				// In incremental mode, filter out unchanged files before hashing.
 				disk := d // capture loop variable
+				var seen map[string]struct{}
+				if reconcile {
+					seen = make(map[string]struct{})
+					seenByDisk[i] = seen
+				}
 				go func() {
 					defer close(diskInput)
 
+					// Streaming disks only learn their total as the walk goes, so
+					// optionally pre-walk them to give the hash bar a real total and ETA.
+					twoPhase := hddTwoPhase && disk.Type == scanner.DiskTypeHDD
+					var preWalkTotal int64
+					if useProgress && preWalk && !twoPhase && !fromStdin {
+						preWalkTotal = preWalkBytes(sc, disk, unchanged)
+						if bars, ok := diskProgress[disk.Name]; ok {
+							bars.hash.SetTotal(preWalkTotal, false)
+							bars.hash.DecoratorAverageAdjust(time.Now())
+						}
+					}
+
 					// Intermediate channel: scanner writes here, we filter before sending to hasher
 					scanned := make(chan hasher.FileInfo, workers*4)
 					go func() {
 						defer close(scanned)
-						err := sc.Walk(disk.Path, disk.Name, scanned)
+						var err error
+						if fromStdin {
+							err = sc.WalkList(os.Stdin, scanned)
+						} else {
+							err = sc.Walk(disk.Path, disk.Name, scanned)
+						}
 						if err != nil {
+							walkFailed[i] = true
 							scanErrMu.Lock()
 							scanErrors = append(scanErrors, fmt.Sprintf("%s: %v", disk.Name, err))
 							scanErrMu.Unlock()
-							logProgress("error scanning %s: %v\n", disk.Path, err)
+							logProgress("error", disk.Path, "walk: %v\n", err)
+							recordError(disk.Path, fmt.Errorf("walk: %w", err))
 						}
 					}()
 
 					// HDD two-phase: collect eligible files first, then hash.
-					if hddTwoPhase && disk.Type == scanner.DiskTypeHDD {
+					if twoPhase {
 						var list []hasher.FileInfo
 						for fi := range scanned {
+							fi.Secondary = secondaryHash
+							withAlgo(&fi)
+							withChunks(&fi)
 							if useProgress {
 								if bars, ok := diskProgress[disk.Name]; ok {
 									bars.walk.Increment()
 								}
 							}
-
-							// Incremental check: skip if file hasn't changed since last scan
-							if lookupMap != nil {
-								if existing, ok := lookupMap[fi.Path]; ok {
-									if existing.Size == fi.Size && existing.Mtime == fi.Mtime {
-										atomic.AddInt64(&skipped, 1)
-										continue
-									}
-								}
+							if seen != nil {
+								seen[toStored(fi.Path)] = struct{}{}
 							}
 
-							atomic.AddInt64(&eligibleFiles, 1)
-							atomic.AddInt64(&eligibleBytes, fi.Size)
-							if bf := eligibleFilesByDisk[disk.Name]; bf != nil {
-								bf.Add(1)
+							if unchanged(fi) || adopt(fi) {
+								tracker.AddSkipped(1)
+								continue
 							}
-							if bb := eligibleBytesByDisk[disk.Name]; bb != nil {
-								bb.Add(fi.Size)
+
+							tracker.AddTotal(1, fi.Size)
+							if dt := diskTrackers[disk.Name]; dt != nil {
+								dt.AddTotal(1, fi.Size)
 							}
 
 							list = append(list, fi)
@@ -378,12 +771,14 @@ counts tuned to the disk type (1 worker for HDDs, 4 for SSDs).`,
 							}
 						}
 
-						// Set total bytes once, then hash sequentially.
+						// Set total bytes once, then hash sequentially. Rate and ETA
+						// are measured from here, not from the start of the walk.
 						if useProgress {
 							if bars, ok := diskProgress[disk.Name]; ok {
-								if bb := eligibleBytesByDisk[disk.Name]; bb != nil {
-									bars.hash.SetTotal(bb.Load(), false)
+								if dt := diskTrackers[disk.Name]; dt != nil {
+									bars.hash.SetTotal(dt.Snapshot().BytesTotal, false)
 								}
+								bars.hash.DecoratorAverageAdjust(time.Now())
 							}
 						}
 						for _, fi := range list {
@@ -394,32 +789,29 @@ counts tuned to the disk type (1 worker for HDDs, 4 for SSDs).`,
 
 					// Default (SSD/cache): stream walk -> hash pipeline.
 					for fi := range scanned {
+						fi.Secondary = secondaryHash
+						withAlgo(&fi)
+						withChunks(&fi)
 						if useProgress {
 							if bars, ok := diskProgress[disk.Name]; ok {
 								bars.walk.Increment()
 							}
 						}
-
-						// Incremental check: skip if file hasn't changed since last scan
-						if lookupMap != nil {
-							if existing, ok := lookupMap[fi.Path]; ok {
-								if existing.Size == fi.Size && existing.Mtime == fi.Mtime {
-									atomic.AddInt64(&skipped, 1)
-									continue
-								}
-							}
+						if seen != nil {
+							seen[toStored(fi.Path)] = struct{}{}
 						}
 
-						atomic.AddInt64(&eligibleFiles, 1)
-						atomic.AddInt64(&eligibleBytes, fi.Size)
-						if bf := eligibleFilesByDisk[disk.Name]; bf != nil {
-							bf.Add(1)
+						if unchanged(fi) || adopt(fi) {
+							tracker.AddSkipped(1)
+							continue
 						}
-						if bb := eligibleBytesByDisk[disk.Name]; bb != nil {
-							newDiskTotal := bb.Add(fi.Size)
+
+						tracker.AddTotal(1, fi.Size)
+						if dt := diskTrackers[disk.Name]; dt != nil {
+							newDiskTotal := dt.AddTotal(1, fi.Size)
 							if useProgress {
 								if bars, ok := diskProgress[disk.Name]; ok {
-									bars.hash.SetTotal(newDiskTotal, false)
+									bars.hash.SetTotal(max(newDiskTotal, preWalkTotal), false)
 								}
 							}
 						}
@@ -442,111 +834,139 @@ counts tuned to the disk type (1 worker for HDDs, 4 for SSDs).`,
 			}()
 
 			// Process results from all disks
-			tx, txErr := database.BeginBatch()
-			if txErr != nil {
-				return fmt.Errorf("begin transaction: %w", txErr)
-			}
-			defer func() { tx.Rollback() }() // closure captures tx by reference; rolls back whichever tx is current
-
-			batchSize := 1000
-			batchCount := 0
-
 			for result := range results {
-				atomic.AddInt64(&totalProcessed, 1)
-				processed := atomic.LoadInt64(&totalProcessed)
+				if err := writer.Err(); err != nil {
+					return err
+				}
+				tracker.AddProcessed(1)
+				processed := tracker.Processed()
+				barKey := result.Disk
+				if fromStdin {
+					barKey = disks[0].Name
+				}
+				if dt := diskTrackers[barKey]; dt != nil {
+					dt.AddProcessed(1)
+				}
 				if useProgress {
-					if bars, ok := diskProgress[result.Disk]; ok {
+					if bars, ok := diskProgress[barKey]; ok {
 						bars.hash.IncrBy(int(result.Size))
 					}
 				}
 
 				if result.Err != nil {
-					atomic.AddInt64(&totalErrors, 1)
-					logProgress("error: %s: %v\n", result.Path, result.Err)
+					tracker.AddErrors(1)
+					logProgress("error", result.Path, "%v\n", result.Err)
+					recordError(result.Path, result.Err)
+					lines.write(fileLine{Path: result.Path, Status: "error", Size: result.Size, Error: result.Err.Error()})
+					continue
+				}
+				tracker.AddBytes(result.BytesRead)
+				if result.InFlight {
+					// Storing a hash of a file that is still being written
+					// would make the next verify report it corrupted. Its
+					// record keeps the old mtime, so the next scan hashes it
+					// again.
+					inFlight++
+					logProgress("warning", result.Path, "changed while being hashed (still being written?); not stored, the next scan hashes it again\n")
+					lines.write(fileLine{Path: result.Path, Status: "in_flight", Size: result.Size})
 					continue
 				}
+				var chunkSize int64
+				if result.Chunks != nil {
+					chunkSize = hasher.DefaultChunkSize
+					if result.BytesRead < result.Size {
+						appended++
+					}
+				}
 
 				now := time.Now()
+				storedPath := toStored(result.Path)
 				record := &db.FileRecord{
-					Path:         result.Path,
-					Disk:         result.Disk,
-					Size:         result.Size,
-					Mtime:        result.Mtime,
-					SHA256:       result.SHA256,
-					FirstSeen:    now,
-					LastVerified: now,
-					Status:       "ok",
+					Path:          storedPath,
+					Disk:          result.Disk,
+					Size:          result.Size,
+					Mtime:         result.Mtime,
+					MtimeNsec:     result.MtimeNsec,
+					SHA256:        result.SHA256,
+					Algo:          result.Algo,
+					SecondaryAlgo: secondaryHash,
+					SecondaryHash: result.Secondary,
+					ChunkSize:     chunkSize,
+					Chunks:        result.Chunks,
+					FirstSeen:     now,
+					LastVerified:  now,
+					Status:        "ok",
+					Tag:           tag,
+				}
+				if result.Perms != nil {
+					record.Perms = (*db.Perms)(result.Perms)
 				}
 
 				// Safe move detection (helps with rebalancing):
 				// If this looks like a new path, try to find an older record with the same basename+size.
 				// If the old path is gone and the SHA matches, re-key the DB entry to the new path.
+				// Full scans have no lookup map, so ask the database directly.
+				var known bool
 				if lookupMap != nil {
-					if _, ok := lookupMap[result.Path]; !ok {
-						base := filepath.Base(result.Path)
-						cands, err := database.FindMoveCandidates(base, result.Size, 20)
-						if err == nil {
-							for _, cand := range cands {
-								if cand.Path == result.Path {
-									continue
-								}
-								// Only treat as moved if the old path is actually gone
-								_, statErr := os.Stat(cand.Path)
-								if statErr == nil {
-									continue
-								}
-								if !os.IsNotExist(statErr) {
-									continue
-								}
-
-								if cand.SHA256 == result.SHA256 {
-									if err := database.MovePathTx(tx, cand.Path, result.Path, result.Disk, result.Size, result.Mtime); err != nil {
-										atomic.AddInt64(&totalErrors, 1)
-										logProgress("error moving record %s -> %s: %v\n", cand.Path, result.Path, err)
-									} else {
-										// Re-keyed successfully; skip normal upsert
-										record = nil
-									}
-									break
-								}
-
-								// Likely moved-but-changed: basename+size match, old path missing, but SHA differs.
-								// Flag the new path as corrupted and log a loud warning.
-								logProgress("warning: possible move corruption: %s -> %s (size=%d, oldSHA=%s..., newSHA=%s...)\n",
-									cand.Path, result.Path, result.Size, cand.SHA256[:12], result.SHA256[:12])
-								record.Status = "corrupted"
-								break
-							}
+					_, known = lookupMap[storedPath]
+				} else {
+					existing, err := database.GetFileByPath(storedPath)
+					known = err != nil || existing != nil // on error, don't risk a bogus move
+				}
+				if !known {
+					// First time seen, unless it turns out to be a move below;
+					// the first verify promotes it to ok
+					record.Status = "new"
+					base := filepath.Base(result.Path)
+					cands, err := database.FindMoveCandidates(base, result.Size, result.Algo, chunkSize, 20)
+					if err == nil {
+						// Only treat as moved if the old path is actually gone
+						gone := func(p string) bool {
+							_, statErr := os.Stat(db.AbsolutePath(p, pathBase))
+							return os.IsNotExist(statErr)
+						}
+						moved, suspect := db.PickMoveSource(cands, storedPath, result.SHA256, gone)
+						switch {
+						case moved != nil:
+							// Re-key instead of upserting a duplicate
+							writer.Move(moved.Path, record)
+							record = nil
+						case suspect != nil:
+							// Likely moved-but-changed: basename+size match, old path missing, but no
+							// missing candidate has the same SHA. Flag the new path as corrupted and
+							// log a loud warning.
+							logProgress("warning", result.Path, "possible move corruption from %s (size=%d, oldSHA=%s..., newSHA=%s...)\n",
+								suspect.Path, result.Size, suspect.SHA256[:12], result.SHA256[:12])
+							record.Status = "corrupted"
 						}
 					}
 				}
 				// If record was re-keyed, do not upsert a duplicate.
+				status := "ok"
 				if record != nil {
-					if err := database.UpsertFileTx(tx, record); err != nil {
-						atomic.AddInt64(&totalErrors, 1)
-						logProgress("error storing %s: %v\n", result.Path, err)
-					}
+					writer.Upsert(record)
+					status = record.Status
 				}
-
-				batchCount++
-				if batchCount >= batchSize {
-					if err := tx.Commit(); err != nil {
-						return fmt.Errorf("commit batch: %w", err)
-					}
-					tx, txErr = database.BeginBatch()
-					if txErr != nil {
-						return fmt.Errorf("begin new batch: %w", txErr)
-					}
-					batchCount = 0
+				if xattrs != nil {
+					xattrs.store(result.Path, result.Algo, result.SHA256, result.Mtime, result.MtimeNsec)
 				}
+				lines.write(fileLine{Path: result.Path, SHA256: result.SHA256, Status: status, Size: result.Size})
 
-				_ = processed
+				logx.Verbosef("  [%d] %s\n", processed, result.Path)
 			}
 
 			// Commit remaining
-			if batchCount > 0 {
-				if err := tx.Commit(); err != nil {
-					return fmt.Errorf("commit final batch: %w", err)
+			if err := writer.Close(); err != nil {
+				return err
+			}
+			if len(retag) > 0 {
+				if err := tagUnchanged(database, tag, retag); err != nil {
+					return fmt.Errorf("tag unchanged files: %w", err)
+				}
+			}
+			if len(reperm) > 0 {
+				if err := permsUnchanged(database, reperm); err != nil {
+					return fmt.Errorf("record owner and mode of unchanged files: %w", err)
 				}
 			}
 
@@ -557,11 +977,13 @@ counts tuned to the disk type (1 worker for HDDs, 4 for SSDs).`,
 					bars.hash.SetTotal(bars.hash.Current(), true)
 				}
 				p.Wait()
+				logx.SetOutput(os.Stdout, os.Stderr)
 				fmt.Fprintln(os.Stderr)
 			}
 
-			// Print collected warnings/errors summary
-			if len(progressMsgs) > 0 {
+			// Print collected warnings/errors summary (JSON log lines already
+			// stand on their own)
+			if len(progressMsgs) > 0 && !logx.JSON() {
 				fmt.Fprintln(os.Stderr, "--- Warnings/Errors during scan ---")
 				for _, msg := range progressMsgs {
 					fmt.Fprint(os.Stderr, msg)
@@ -569,19 +991,57 @@ counts tuned to the disk type (1 worker for HDDs, 4 for SSDs).`,
 				fmt.Fprintln(os.Stderr, "---")
 			}
 
-			elapsed := time.Since(start)
-			finalProcessed := int(atomic.LoadInt64(&totalProcessed))
-			finalErrors := int(atomic.LoadInt64(&totalErrors))
-			finalSkipped := int(atomic.LoadInt64(&skipped))
-			finalEligibleFiles := int(atomic.LoadInt64(&eligibleFiles))
-			finalEligibleBytes := atomic.LoadInt64(&eligibleBytes)
+			// Reconcile: mark tracked files the walk didn't find as missing.
+			// Disks whose walk failed are skipped, their seen set is incomplete.
+			var markedMissing []string
+			if reconcile {
+				for i, d := range disks {
+					if walkFailed[i] {
+						logx.Warnf("not reconciling %s: walk failed\n", d.Name)
+						continue
+					}
+					gone, err := reconcileMissing(database, d, seenByDisk[i], pathBase)
+					if err != nil {
+						return fmt.Errorf("reconcile %s: %w", d.Name, err)
+					}
+					markedMissing = append(markedMissing, gone...)
+				}
+				for _, path := range markedMissing {
+					switch {
+					case lines != nil:
+						lines.write(fileLine{Path: path, Status: "missing"})
+					case !jsonOut:
+						fmt.Printf("  %s   %s\n", format.Yellow("MISSING:"), path)
+					}
+				}
+			}
+
+			final := tracker.Snapshot()
+			elapsed := final.Elapsed
+			finalProcessed := int(final.Processed)
+			finalErrors := int(final.Errors)
+			finalSkipped := int(final.Skipped)
+			finalEligibleFiles := int(final.FilesTotal)
+			finalEligibleBytes := final.BytesTotal
+			finalBytes := final.BytesDone
 
 			// Update scan history
 			if scanID > 0 {
-				if err := database.CompleteScanHistory(scanID, finalProcessed, finalErrors); err != nil {
-					fmt.Fprintf(os.Stderr, "warning: complete scan history: %v\n", err)
+				if err := database.CompleteScanHistory(scanID, finalProcessed, finalErrors, finalBytes, elapsed); err != nil {
+					logx.Warnf("complete scan history: %v\n", err)
 				}
 			}
+			if err := database.InsertStatsSnapshot(); err != nil {
+				logx.Warnf("record stats snapshot: %v\n", err)
+			}
+			logx.Event("scan complete", logx.Fields{
+				"disks":           pathNames,
+				"files_processed": finalProcessed,
+				"files_skipped":   finalSkipped,
+				"bytes_processed": finalBytes,
+				"errors":          finalErrors,
+				"duration":        elapsed.String(),
+			})
 
 			if jsonOut {
 				out := map[string]interface{}{
@@ -589,29 +1049,80 @@ counts tuned to the disk type (1 worker for HDDs, 4 for SSDs).`,
 					"files_skipped":   finalSkipped,
 					"eligible_files":  finalEligibleFiles,
 					"eligible_bytes":  finalEligibleBytes,
+					"bytes_processed": finalBytes,
+					"mbps":            format.MBps(finalBytes, elapsed),
 					"errors":          finalErrors,
 					"duration":        elapsed.String(),
 					"full_scan":       fullScan,
+					"path_mode":       pathMode,
 					"disks":           pathNames,
 				}
+				if reconcile {
+					out["marked_missing"] = len(markedMissing)
+				}
+				if chunked {
+					out["appended"] = appended
+				}
+				if inFlight > 0 {
+					out["in_flight"] = inFlight
+				}
+				if minAge > 0 {
+					out["skipped_recent"] = sc.SkippedRecent()
+				}
+				if tag != "" {
+					out["tag"] = tag
+				}
+				if xattrs != nil {
+					out["xattrs_written"] = xattrs.written.Load()
+					out["xattrs_failed"] = xattrs.failed.Load()
+				}
+				if shatag {
+					out["adopted"] = xattrs.adopted.Load()
+				}
 				enc := json.NewEncoder(os.Stdout)
 				enc.SetIndent("", "  ")
 				return enc.Encode(out)
 			}
 
-			fmt.Printf("\n\nScan complete:\n")
-			fmt.Printf("  Files hashed:    %d\n", finalProcessed)
-			fmt.Printf("  Files skipped:   %d (unchanged)\n", finalSkipped)
-			fmt.Printf("  Total files:     %d\n", finalProcessed+finalSkipped)
-			fmt.Printf("  Eligible files:  %d\n", finalEligibleFiles)
-			fmt.Printf("  Eligible bytes:  %s\n", format.Size(finalEligibleBytes))
-			fmt.Printf("  Errors:          %d\n", finalErrors)
-			fmt.Printf("  Duration:        %s\n", elapsed.Round(time.Millisecond))
-			fmt.Printf("  Database:        %s\n", dbPath)
+			logx.Infof("\n\nScan complete:\n")
+			logx.Infof("  Files hashed:    %d\n", finalProcessed)
+			logx.Infof("  Files skipped:   %d (unchanged)\n", finalSkipped)
+			logx.Infof("  Total files:     %d\n", finalProcessed+finalSkipped)
+			logx.Infof("  Eligible files:  %d\n", finalEligibleFiles)
+			logx.Infof("  Eligible bytes:  %s\n", format.Bytes(finalEligibleBytes))
+			logx.Infof("  Bytes hashed:    %s (%s)\n", format.Bytes(finalBytes), format.Rate(finalBytes, elapsed))
+			logx.Infof("  Errors:          %d\n", finalErrors)
+			if appended > 0 {
+				logx.Infof("  Appended:        %d (only the new tail hashed)\n", appended)
+			}
+			if inFlight > 0 {
+				logx.Infof("  In flight:       %d (changed while hashed; not stored)\n", inFlight)
+			}
+			if n := sc.SkippedRecent(); n > 0 {
+				logx.Infof("  Too recent:      %d (modified within --min-age %s; next scan)\n", n, minAge)
+			}
+			if reconcile {
+				logx.Infof("  Marked missing:  %d\n", len(markedMissing))
+			}
+			if tag != "" {
+				logx.Infof("  Tag:             %s\n", tag)
+			}
+			if xattrs != nil {
+				logx.Infof("  Xattrs written:  %d to %s (%d failed)\n", xattrs.written.Load(), xattrs.name, xattrs.failed.Load())
+			}
+			if shatag && xattrs.adopted.Load() > 0 {
+				logx.Infof("  Adopted:         %d (hash taken from current shatag attributes)\n", xattrs.adopted.Load())
+			}
+			logx.Infof("  Duration:        %s\n", elapsed.Round(time.Millisecond))
+			if ephemeral {
+				logx.Infof("  Database:        ephemeral (deleted on exit)\n")
+			} else {
+				logx.Infof("  Database:        %s\n", dbPath)
+			}
 			if !fullScan {
-				fmt.Printf("  Mode:            incremental (use --full to re-hash all)\n")
+				logx.Infof("  Mode:            incremental (use --full to re-hash all)\n")
 			} else {
-				fmt.Printf("  Mode:            full\n")
+				logx.Infof("  Mode:            full\n")
 			}
 
 			scanErrMu.Lock()
@@ -625,72 +1136,626 @@ counts tuned to the disk type (1 worker for HDDs, 4 for SSDs).`,
 
 	cmd.Flags().BoolVar(&autoDetect, "auto", false, "auto-detect Unraid array disks and cache")
 	cmd.Flags().BoolVar(&fullScan, "full", false, "force re-hash all files (skip incremental comparison)")
+	cmd.Flags().BoolVar(&chunked, "chunked", false, "hash files in 64 MiB chunks so files that only grew re-hash just the new tail")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "only walk and print the files and bytes per disk that would be scanned; no hashing, no database writes")
 	cmd.Flags().StringVar(&diskTypeOverride, "disk-type", "auto", "force disk type for scan targets: auto|hdd|ssd")
 	cmd.Flags().StringArrayVar(&excludeSimple, "exclude-simple", nil, "simple exclude (substring match on full path); repeatable")
 	cmd.Flags().BoolVar(&excludeAppdata, "exclude-appdata", false, "exclude Unraid appdata folders (recommended for large/docker-heavy systems)")
 	cmd.Flags().BoolVar(&hddTwoPhase, "hdd-two-phase", true, "for HDDs: walk first, then hash (reduces seek thrashing; uses more RAM)")
+	cmd.Flags().StringVar(&pathMode, "path-mode", "absolute", "how paths are stored: absolute|relative (relative to --path-base, portable across servers)")
+	cmd.Flags().StringVar(&pathBase, "path-base", db.DefaultPathBase, "base directory for --path-mode relative")
+	cmd.Flags().IntVar(&readRetries, "read-retries", hasher.DefaultReadRetries, "retry a file this many times after a transient read error (EIO) before reporting it")
+	cmd.Flags().BoolVar(&jsonlOut, "jsonl", false, "stream one JSON object per hashed file to stdout as it completes (path, sha256, status, size); other output goes to stderr")
+	cmd.Flags().StringVar(&secondaryHash, "secondary-hash", "", "also store a cheap second checksum computed in the same read (crc32c), which verify checks too")
+	cmd.Flags().BoolVar(&fromStdin, "stdin", false, "hash exactly the file paths read from stdin (one per line) instead of walking directories")
+	cmd.Flags().BoolVar(&preWalk, "pre-walk", false, "with progress bars, walk streaming (SSD) disks once before hashing so the ETA is accurate from the start")
+	cmd.Flags().BoolVar(&reconcile, "reconcile", false, "mark tracked files under the scanned roots that no longer exist as missing")
+	cmd.Flags().DurationVar(&minAge, "min-age", 0, "skip files modified less than this long ago (e.g. 60s), such as downloads still being written; the next scan picks them up")
+	cmd.Flags().StringVar(&tag, "tag", "", "tag every scanned file with this label (e.g. backups) for report, verify and dashboard filters")
+	cmd.Flags().StringVar(&xattrName, "xattr", "", "also write each file's hash to this extended attribute (e.g. user.sha256), so it travels with copies of the file; verify --xattr checks it")
+	cmd.Flags().BoolVar(&shatag, "shatag", false, "keep hashes in cshatag's user.shatag.sha256 and user.shatag.ts attributes, and catalog new files that already have current ones without reading them")
+	cmd.MarkFlagsMutuallyExclusive("xattr", "shatag")
+	cmd.Flags().BoolVar(&trackPerms, "track-perms", false, "also record each file's owner (uid, gid) and permission bits, for report --perms and verify --check-perms")
+	cmd.Flags().IntVar(&maxDepth, "max-depth", scanner.NoMaxDepth, "only descend N directory levels below each scan root (0 = only files directly in the root; -1 = unlimited)")
+	cmd.Flags().BoolVar(&crossFS, "cross-filesystems", false, "also walk into other filesystems mounted below a scan root (by default they are skipped with a warning)")
+	cmd.Flags().BoolVar(&oneFS, "one-filesystem", false, "like find -xdev: skip every directory on a device other than the scan root's, including ZFS datasets and btrfs subvolumes")
+	cmd.Flags().StringVar(&nohashMarker, "nohash-marker", scanner.DefaultNohashMarker, "skip every directory containing a file of this name, with everything below it (empty to disable)")
+	cmd.Flags().BoolVar(&noCacheDirTag, "no-cachedir-tag", false, "also scan directories tagged with a CACHEDIR.TAG file (skipped by default)")
+	cmd.MarkFlagsMutuallyExclusive("cross-filesystems", "one-filesystem")
+	cmd.Flags().StringSliceVar(&excludeFSTypes, "exclude-fstype", nil, "never walk mounts of this filesystem type, as in /proc/mounts, e.g. nfs4 or fuse.sshfs (repeatable; proc, sysfs and other kernel filesystems are always skipped)")
 	return cmd
 }
 
-func verifyCmd() *cobra.Command {
-	var quick bool
-	var disk string
-	var workers int
+// preWalkBytes walks disk once without hashing and sums the sizes of the files
+// a scan would hash (those skip doesn't reject). Walk errors are left for the
+// real walk to report.
+func preWalkBytes(sc *scanner.Scanner, disk scanner.DiskInfo, skip func(hasher.FileInfo) bool) int64 {
+	files := make(chan hasher.FileInfo, 256)
+	go func() {
+		defer close(files)
+		sc.Walk(disk.Path, disk.Name, files)
+	}()
+
+	var total int64
+	for fi := range files {
+		if !skip(fi) {
+			total += fi.Size
+		}
+	}
+	return total
+}
 
-	cmd := &cobra.Command{
-		Use:   "verify",
-		Short: "Verify file integrity against stored hashes",
-		Long:  "Re-hash files and compare against the stored SHA-256 hashes to detect corruption or missing files.",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			database, err := db.Open(dbPath)
-			if err != nil {
-				return fmt.Errorf("open database: %w", err)
-			}
-			defer database.Close()
+// reconcileMissing marks tracked files under disk.Path that the walk didn't
+// see as missing, and returns their stored paths. Each unseen file is stat'ed
+// first, so files skipped by excludes or --max-depth keep their status.
+func reconcileMissing(database *db.DB, disk scanner.DiskInfo, seen map[string]struct{}, pathBase string) ([]string, error) {
+	records, err := database.GetFilesByDisk(disk.Name)
+	if err != nil {
+		return nil, err
+	}
 
-			scanID, _ := database.InsertScanHistory("verify", disk)
+	root := strings.TrimSuffix(filepath.Clean(disk.Path), "/") + "/"
+	var gone []string
+	for _, f := range records {
+		if f.Status == "missing" {
+			continue
+		}
+		if _, ok := seen[f.Path]; ok {
+			continue
+		}
+		abs := db.AbsolutePath(f.Path, pathBase)
+		if !strings.HasPrefix(abs, root) {
+			continue
+		}
+		if _, err := os.Stat(abs); !os.IsNotExist(err) {
+			continue
+		}
+		gone = append(gone, f.Path)
+	}
+	if len(gone) == 0 {
+		return nil, nil
+	}
 
-			v := verifier.New(database, workers, quick)
+	tx, err := database.BeginBatch()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	for _, p := range gone {
+		if err := database.UpdateStatusTx(tx, p, "missing"); err != nil {
+			return nil, err
+		}
+	}
+	return gone, tx.Commit()
+}
 
-			corrupted := 0
-			missing := 0
+// tagUnchanged tags the files an incremental scan --tag skipped as
+// unchanged, in one transaction.
+func tagUnchanged(database *db.DB, tag string, paths map[string]struct{}) error {
+	list := make([]string, 0, len(paths))
+	for p := range paths {
+		list = append(list, p)
+	}
+	sort.Strings(list)
+	tx, err := database.BeginBatch()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := database.SetTagTx(tx, tag, list...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
-			resultCb := func(r verifier.VerifyResult) {
-				switch r.Status {
-				case "corrupted":
-					corrupted++
-					if jsonOut {
-						return
-					}
-					fmt.Printf("  CORRUPTED: %s\n", r.Path)
-					if r.OldHash != "" && r.NewHash != "" {
-						fmt.Printf("    expected: %s\n", r.OldHash)
-						fmt.Printf("    got:      %s\n", r.NewHash)
-					}
-				case "missing":
-					missing++
-					if !jsonOut {
-						fmt.Printf("  MISSING:   %s\n", r.Path)
-					}
-				}
-			}
+// permsUnchanged records the new owner and mode of files an incremental
+// scan --track-perms skipped as unchanged, in one transaction.
+func permsUnchanged(database *db.DB, perms map[string]db.Perms) error {
+	tx, err := database.BeginBatch()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for p, perm := range perms {
+		if err := database.SetPermsTx(tx, p, perm); err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	return tx.Commit()
+}
 
-			// Progress bar (TTY only, disabled for --json)
-			useProgress := !jsonOut && isatty.IsTerminal(os.Stderr.Fd())
-			var p *mpb.Progress
-			var bar *mpb.Bar
-			if useProgress {
-				p = mpb.New(mpb.WithOutput(os.Stderr), mpb.WithWidth(64))
-				bar = p.AddBar(0,
-					mpb.PrependDecorators(
-						decor.Name("Verify ", decor.WC{W: 8, C: decor.DindentRight}),
-						decor.CountersNoUnit("%d / %d", decor.WC{W: 18, C: decor.DindentRight}),
-					),
-					mpb.AppendDecorators(
-						decor.Percentage(decor.WC{W: 6}),
-						decor.AverageETA(decor.ET_STYLE_GO, decor.WC{W: 12}),
-					),
-				)
+func detectCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "detect",
+		Short: "Show auto-detected Unraid disks without scanning",
+		Long: `Run the same disk detection as "scan --auto" and print each disk's name, path,
+type, and the worker count scan would use. On Unraid the disks come from
+` + scanner.DisksIniPath + `, which also gives the device and drive id
+and lists configured disks that aren't mounted; elsewhere array disk and pool
+directories under /mnt are used.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			det, err := scanner.DetectDisks()
+			if err != nil {
+				return fmt.Errorf("auto-detect disks: %w", err)
+			}
+
+			if jsonOut {
+				type diskOut struct {
+					Name    string `json:"name"`
+					Path    string `json:"path"`
+					Type    string `json:"type"`
+					Workers int    `json:"workers"`
+					Device  string `json:"device,omitempty"`
+					ID      string `json:"id,omitempty"`
+					Mounted bool   `json:"mounted"`
+					Reason  string `json:"reason,omitempty"`
+				}
+				out := make([]diskOut, 0, len(det.Disks)+len(det.Unmounted))
+				for _, d := range det.Disks {
+					out = append(out, diskOut{
+						Name:    d.Name,
+						Path:    d.Path,
+						Type:    d.Type.String(),
+						Workers: workerDefaults.For(d.Type),
+						Device:  d.Device,
+						ID:      d.ID,
+						Mounted: true,
+					})
+				}
+				for _, d := range det.Unmounted {
+					out = append(out, diskOut{
+						Name:    d.Name,
+						Path:    d.Path,
+						Type:    d.Type.String(),
+						Workers: workerDefaults.For(d.Type),
+						Device:  d.Device,
+						ID:      d.ID,
+						Reason:  d.Reason,
+					})
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(out)
+			}
+
+			if len(det.Disks) == 0 && len(det.Unmounted) == 0 {
+				fmt.Printf("No Unraid disks detected (from %s)\n", det.Source)
+				return nil
+			}
+			fmt.Printf("Disks from %s:\n", det.Source)
+			tbl := format.NewTable("NAME", "PATH", "TYPE", "WORKERS", "DEVICE", "ID").AlignRight(3)
+			tbl.Indent = "  "
+			for _, d := range det.Disks {
+				tbl.Row(d.Name, d.Path, d.Type.String(), strconv.Itoa(workerDefaults.For(d.Type)), orDash(d.Device), orDash(d.ID))
+			}
+			if err := tbl.Write(os.Stdout); err != nil {
+				return err
+			}
+			for _, d := range det.Unmounted {
+				fmt.Printf("  %s %s (%s): %s; not scanned\n", format.Yellow("NOT MOUNTED:"), d.Name, orDash(d.Device), d.Reason)
+			}
+			return nil
+		},
+	}
+}
+
+func disksCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "disks",
+		Short: "List the disks recorded by auto-detecting scans",
+		Long:  "Show the disks saved by \"scan --auto\": name, mount path, type, the worker count verify uses for it, and when a scan last saw it. Use \"detect\" to probe the disks afresh.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := db.Open(dbPath, dbOptions)
+			if err != nil {
+				return fmt.Errorf("open database: %w", err)
+			}
+			defer database.Close()
+
+			disks, err := database.GetDisks()
+			if err != nil {
+				return fmt.Errorf("get disks: %w", err)
+			}
+
+			if jsonOut {
+				type diskOut struct {
+					Name     string    `json:"name"`
+					Path     string    `json:"path"`
+					Type     string    `json:"type"`
+					Workers  int       `json:"workers"`
+					LastSeen time.Time `json:"last_seen"`
+				}
+				out := make([]diskOut, 0, len(disks))
+				for _, d := range disks {
+					out = append(out, diskOut{
+						Name:     d.Name,
+						Path:     d.Path,
+						Type:     d.Type,
+						Workers:  workerDefaults.For(scanner.ParseDiskType(d.Type)),
+						LastSeen: d.LastSeen,
+					})
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(out)
+			}
+
+			if len(disks) == 0 {
+				fmt.Println("No disks recorded yet; run \"scan --auto\" first")
+				return nil
+			}
+			fmt.Printf("  %-12s %-24s %-8s %8s  %s\n", "NAME", "PATH", "TYPE", "WORKERS", "LAST SEEN")
+			for _, d := range disks {
+				fmt.Printf("  %-12s %-24s %-8s %8d  %s\n", d.Name, d.Path, d.Type, workerDefaults.For(scanner.ParseDiskType(d.Type)),
+					d.LastSeen.Format("2006-01-02 15:04:05"))
+			}
+			return nil
+		},
+	}
+}
+
+// orDash returns s, or "-" when it is empty, for table cells.
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// diskRecord converts a detected disk into its catalog row.
+func diskRecord(d scanner.DiskInfo) *db.Disk {
+	return &db.Disk{
+		Name: d.Name,
+		Path: d.Path,
+		Type: d.Type.String(),
+	}
+}
+
+// diskWorkers sizes verify's per-disk pipelines by the disk types recorded
+// by earlier --auto scans, probing /sys only when none are recorded.
+func diskWorkers(database *db.DB) map[string]int {
+	if types, err := database.DiskTypes(); err == nil && len(types) > 0 {
+		return workerDefaults.ByType(types)
+	}
+	if disks, err := scanner.DetectUnraidDisks(); err == nil {
+		return workerDefaults.ByDisk(disks)
+	}
+	return nil
+}
+
+// parseDiskType parses --disk-type; "auto" (or empty) gives DiskTypeUnknown,
+// meaning no override.
+func parseDiskType(s string) (scanner.DiskType, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "auto":
+		return scanner.DiskTypeUnknown, nil
+	case "hdd":
+		return scanner.DiskTypeHDD, nil
+	case "ssd":
+		return scanner.DiskTypeSSD, nil
+	default:
+		return scanner.DiskTypeUnknown, fmt.Errorf("invalid --disk-type %q (expected auto|hdd|ssd)", s)
+	}
+}
+
+// resolveTargets turns --auto/--disk-type/positional paths into the disks to scan.
+func resolveTargets(autoDetect bool, diskTypeOverride string, args []string) ([]scanner.DiskInfo, error) {
+	var disks []scanner.DiskInfo
+
+	// Optional disk type override (useful when /sys detection is wrong or unavailable)
+	var overrideType *scanner.DiskType
+	ot, err := parseDiskType(diskTypeOverride)
+	if err != nil {
+		return nil, err
+	}
+	if ot != scanner.DiskTypeUnknown {
+		overrideType = &ot
+	}
+
+	if autoDetect {
+		det, err := scanner.DetectDisks()
+		if err != nil {
+			return nil, fmt.Errorf("auto-detect disks: %w", err)
+		}
+		for _, d := range det.Unmounted {
+			logx.Warnf("%s is configured but not mounted (%s); not scanned\n", d.Name, d.Reason)
+		}
+		if len(det.Disks) == 0 {
+			return nil, fmt.Errorf("no mounted Unraid disks detected (from %s)", det.Source)
+		}
+		disks = det.Disks
+		if overrideType != nil {
+			for i := range disks {
+				disks[i].Type = *overrideType
+			}
+		}
+		for _, d := range disks {
+			logx.Infof("Detected: %s (%s, %s, %d workers)\n",
+				d.Name, d.Path, d.Type, workerDefaults.For(d.Type))
+		}
+		return disks, nil
+	}
+
+	if len(args) == 0 {
+		return nil, fmt.Errorf("no paths specified; use --auto or provide paths as arguments")
+	}
+	for _, p := range args {
+		absPath, err := filepath.Abs(p)
+		if err != nil {
+			return nil, fmt.Errorf("resolve path %s: %w", p, err)
+		}
+		name := scanner.ResolveDisk(absPath, absPath)
+		dt := scanner.DiskTypeUnknown
+		if overrideType != nil {
+			dt = *overrideType
+		}
+		disks = append(disks, scanner.DiskInfo{
+			Name: name,
+			Path: absPath,
+			Type: dt,
+		})
+	}
+
+	// Shell globs easily pass a directory along with its parent; walk it once
+	disks, dropped := scanner.DedupeRoots(disks)
+	paths := make([]string, 0, len(dropped))
+	for p := range dropped {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if dropped[p] == p {
+			logx.PathWarnf(p, "skipping, listed more than once\n")
+		} else {
+			logx.PathWarnf(p, "skipping, already inside %s\n", dropped[p])
+		}
+	}
+	return disks, nil
+}
+
+// buildExcludePatterns combines --exclude regexes with the simple/appdata shortcuts.
+func buildExcludePatterns(excludeSimple []string, excludeAppdata bool) []string {
+	excludePatterns := append([]string{}, excludes...)
+	for _, p := range excludeSimple {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		// Substring match via regex-quoted pattern
+		excludePatterns = append(excludePatterns, regexp.QuoteMeta(p))
+	}
+	if excludeAppdata {
+		// Covers /mnt/cache/appdata, /mnt/user/appdata, nested .../appdata/... etc.
+		excludePatterns = append(excludePatterns, `(^|/)(appdata)(/|$)`)
+	}
+	return excludePatterns
+}
+
+func verifyCmd() *cobra.Command {
+	var quick bool
+	var disk string
+	var workers int
+	var samplePercent float64
+	var maxDuration time.Duration
+	var pathBase string
+	var fastSizeCheck bool
+	var readRetries int
+	var failOn []string
+	var modifiedSince string
+	var tag string
+	var checkPerms bool
+	var xattrName string
+	var shatag bool
+	var touchVerified bool
+
+	cmd := &cobra.Command{
+		Use:   "verify [path-or-glob...]",
+		Short: "Verify file integrity against stored hashes",
+		Long: `Re-hash files and compare against the stored SHA-256 hashes to detect corruption or missing files.
+
+With arguments, only those files are verified, for a quick spot check:
+exact paths, directories (everything tracked beneath them), or glob patterns
+matched against catalog paths, where * also matches "/". Quote globs so the
+shell doesn't expand them. --disk narrows the match further.
+
+--modified-since verifies only the files whose stored mtime is at or after a
+date or age, e.g. what changed since the last backup.
+
+--tag verifies only the files scanned with that scan --tag; combined with
+arguments or --modified-since it narrows their matches.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if samplePercent < 0 || samplePercent > 100 {
+				return fmt.Errorf("invalid --sample-percent %v (expected 0-100)", samplePercent)
+			}
+			lines, err := newLineWriter()
+			if err != nil {
+				return err
+			}
+			if workers < 0 {
+				return fmt.Errorf("invalid --workers %d (must be positive)", workers)
+			}
+			if len(args) > 0 && samplePercent > 0 {
+				return fmt.Errorf("--sample-percent cannot be combined with path arguments")
+			}
+			var since time.Time
+			if modifiedSince != "" {
+				if len(args) > 0 || samplePercent > 0 {
+					return fmt.Errorf("--modified-since cannot be combined with path arguments or --sample-percent")
+				}
+				if since, err = format.ParseSince(modifiedSince, time.Now()); err != nil {
+					return fmt.Errorf("invalid --modified-since: %w", err)
+				}
+			}
+			if tag != "" && samplePercent > 0 {
+				return fmt.Errorf("--tag cannot be combined with --sample-percent")
+			}
+			if readRetries < 0 {
+				return fmt.Errorf("invalid --read-retries %d (must be 0 or positive)", readRetries)
+			}
+			// Both pick the least recently verified files, which without
+			// touching last_verified would be the same ones every run
+			if !touchVerified && (samplePercent > 0 || maxDuration > 0) {
+				return fmt.Errorf("--touch-verified=false cannot be combined with --sample-percent or --max-duration")
+			}
+			for _, c := range failOn {
+				if verifyExitCodes[c] == 0 {
+					return fmt.Errorf("invalid --fail-on %q (expected corrupted, unreadable, missing, changed, perms)", c)
+				}
+				if c == "perms" && !checkPerms {
+					return fmt.Errorf("--fail-on perms requires --check-perms")
+				}
+			}
+
+			if err := lockCatalog("verify"); err != nil {
+				return err
+			}
+			defer unlockCatalog()
+
+			database, err := db.Open(dbPath, dbOptions)
+			if err != nil {
+				return fmt.Errorf("open database: %w", err)
+			}
+			defer database.Close()
+
+			// Spot check: only the records matching the arguments
+			var records []*db.FileRecord
+			if len(args) > 0 {
+				paths, globs, err := pathArgs(args)
+				if err != nil {
+					return err
+				}
+				records, err = database.GetFilesMatching(paths, globs, disk, "")
+				if err != nil {
+					return fmt.Errorf("find files: %w", err)
+				}
+				records = filterTag(records, tag)
+				if len(records) == 0 {
+					return fmt.Errorf("no matching tracked files")
+				}
+			}
+			if !since.IsZero() {
+				records, err = database.GetFilesModifiedSince(since, disk)
+				if err != nil {
+					return fmt.Errorf("find files: %w", err)
+				}
+				records = filterTag(records, tag)
+			}
+			if tag != "" && len(args) == 0 && since.IsZero() {
+				records, err = database.GetFilesByTag(tag, disk)
+				if err != nil {
+					return fmt.Errorf("find files: %w", err)
+				}
+				if len(records) == 0 {
+					return fmt.Errorf("no tracked files tagged %q", tag)
+				}
+			}
+
+			algos, _ := database.HashAlgorithms()
+			scanID, _ := database.InsertScanHistory("verify", disk, version, strings.Join(algos, ","))
+
+			// Each disk gets its own pipeline; without --workers its size
+			// follows the disk type, as in scan --auto.
+			v := verifier.New(database, workers, quick)
+			if workers == 0 {
+				v.DiskWorkers = diskWorkers(database)
+			}
+			v.MaxDuration = maxDuration
+			v.PathBase = pathBase
+			v.FastSizeCheck = fastSizeCheck
+			v.ReadRetries = readRetries
+			v.CheckPerms = checkPerms
+			v.Xattr = xattrName
+			v.Shatag = shatag
+			v.TouchVerified = touchVerified
+			if shatag {
+				xattrName = xattr.ShatagSHA256
+			}
+
+			corrupted := 0
+			missing := 0
+			var verifiedBytes atomic.Int64
+			// Per-file lines; printed above the progress bar while it is drawn
+			var out io.Writer = os.Stdout
+
+			resultCb := func(r verifier.VerifyResult) {
+				verifiedBytes.Add(r.Size)
+				if lines != nil {
+					line := fileLine{Path: r.Path, SHA256: r.NewHash, Expected: r.OldHash, Status: r.Status, Size: r.Size}
+					if r.Err != nil {
+						line.Error = r.Err.Error()
+					}
+					lines.write(line)
+				}
+				// Records replace the per-file lines on stdout
+				quietFiles := jsonOut || lines != nil
+				switch r.Status {
+				case "ok":
+					if !quietFiles {
+						logx.Verbosef("  %s        %s\n", format.Green("OK:"), r.Path)
+					}
+				case "corrupted":
+					corrupted++
+					if quietFiles {
+						return
+					}
+					fmt.Fprintf(out, "  %s %s\n", format.Red("CORRUPTED:"), r.Path)
+					if r.Truncated {
+						fmt.Fprintf(out, "    truncated to 0 bytes (was %s)\n", format.Bytes(r.OldSize))
+					} else if r.SizeChanged {
+						fmt.Fprintf(out, "    size changed: %s -> %s (not hashed)\n", format.Bytes(r.OldSize), format.Bytes(r.NewSize))
+					}
+					if r.OldHash != "" && r.NewHash != "" {
+						fmt.Fprintf(out, "    expected: %s\n", r.OldHash)
+						fmt.Fprintf(out, "    got:      %s\n", r.NewHash)
+					}
+				case "changed":
+					if !quietFiles {
+						fmt.Fprintf(out, "  CHANGED:   %s (modified since last hashed)\n", r.Path)
+					}
+				case "error":
+					if !quietFiles {
+						fmt.Fprintf(out, "  UNREADABLE: %s (%v)\n", r.Path, r.Err)
+					}
+				case "missing":
+					missing++
+					if !quietFiles {
+						fmt.Fprintf(out, "  %s   %s\n", format.Yellow("MISSING:"), r.Path)
+					}
+				}
+				if note := xattrNote(r); note != "" && !quietFiles {
+					if r.Status == "ok" {
+						fmt.Fprintf(out, "  %s     %s\n", format.Yellow("XATTR:"), r.Path)
+					}
+					fmt.Fprintf(out, "    %s: %s\n", xattrName, r.XattrHash)
+					fmt.Fprintf(out, "    %s\n", note)
+				}
+			}
+
+			// Progress bar (TTY only, disabled for --json, --quiet and --verbose)
+			useProgress := showProgressBars()
+			var p *mpb.Progress
+			var bar *mpb.Bar
+			if useProgress {
+				p = mpb.New(mpb.WithOutput(os.Stderr), mpb.WithWidth(64))
+				barStart := time.Now()
+				bar = p.AddBar(0,
+					mpb.PrependDecorators(
+						decor.Name("Verify ", decor.WC{W: 8, C: decor.DindentRight}),
+						decor.CountersNoUnit("%d / %d files", decor.WC{W: 24, C: decor.DindentRight}),
+						decor.Any(func(decor.Statistics) string {
+							return format.Bytes(verifiedBytes.Load())
+						}, decor.WC{W: 12, C: decor.DindentRight}),
+						decor.Any(func(decor.Statistics) string {
+							return format.Rate(verifiedBytes.Load(), time.Since(barStart))
+						}, decor.WC{W: 12, C: decor.DindentRight}),
+					),
+					mpb.AppendDecorators(
+						decor.Percentage(decor.WC{W: 6}),
+						decor.Name(" ETA "),
+						decor.AverageETA(decor.ET_STYLE_HHMMSS, decor.WC{W: 8}),
+					),
+				)
+				out = routeThroughBars(p)
+				defer logx.SetOutput(os.Stdout, os.Stderr)
 			}
 
 			progressCb := func(done, total int) {
@@ -702,11 +1767,27 @@ func verifyCmd() *cobra.Command {
 			}
 
 			var summary *verifier.Summary
-			if disk != "" {
-				fmt.Printf("Verifying files on disk: %s\n", disk)
+			if !since.IsZero() {
+				logx.Infof("Verifying %d files modified since %s...\n", len(records), since.Format("2006-01-02 15:04:05"))
+				summary, err = v.VerifyFiles(records, resultCb, progressCb)
+			} else if tag != "" && len(args) == 0 {
+				logx.Infof("Verifying %d files tagged %s...\n", len(records), tag)
+				summary, err = v.VerifyFiles(records, resultCb, progressCb)
+			} else if records != nil {
+				logx.Infof("Verifying %d matching files...\n", len(records))
+				summary, err = v.VerifyFiles(records, resultCb, progressCb)
+			} else if samplePercent > 0 {
+				if disk != "" {
+					logx.Infof("Verifying a %.1f%% sample of files on disk: %s\n", samplePercent, disk)
+				} else {
+					logx.Infof("Verifying a %.1f%% sample of tracked files...\n", samplePercent)
+				}
+				summary, err = v.VerifySample(disk, samplePercent, resultCb, progressCb)
+			} else if disk != "" {
+				logx.Infof("Verifying files on disk: %s\n", disk)
 				summary, err = v.VerifyDisk(disk, resultCb, progressCb)
 			} else {
-				fmt.Printf("Verifying all tracked files...\n")
+				logx.Infof("Verifying all tracked files...\n")
 				summary, err = v.VerifyAll(resultCb, progressCb)
 			}
 			if err != nil {
@@ -715,83 +1796,410 @@ func verifyCmd() *cobra.Command {
 			if useProgress {
 				bar.SetTotal(bar.Current(), true)
 				p.Wait()
+				out = os.Stdout
+				logx.SetOutput(os.Stdout, os.Stderr)
 				fmt.Fprintln(os.Stderr)
 			}
 
 			if scanID > 0 {
-				if err := database.CompleteScanHistory(scanID, summary.TotalChecked, summary.Errors); err != nil {
-					fmt.Fprintf(os.Stderr, "warning: complete scan history: %v\n", err)
+				if err := database.CompleteScanHistory(scanID, summary.TotalChecked, summary.Errors, summary.BytesHashed, summary.Duration); err != nil {
+					logx.Warnf("complete scan history: %v\n", err)
 				}
 			}
+			if err := database.InsertStatsSnapshot(); err != nil {
+				logx.Warnf("record stats snapshot: %v\n", err)
+			}
+			logx.Event("verify complete", logx.Fields{
+				"disk":            disk,
+				"total_checked":   summary.TotalChecked,
+				"corrupted":       summary.Corrupted,
+				"changed":         summary.Changed,
+				"unreadable":      summary.Unreadable,
+				"missing":         summary.Missing,
+				"errors":          summary.Errors,
+				"bytes_processed": summary.BytesHashed,
+				"duration":        summary.Duration.String(),
+			})
 
 			if jsonOut {
 				out := map[string]interface{}{
-					"total_checked": summary.TotalChecked,
-					"ok":            summary.OK,
-					"corrupted":     summary.Corrupted,
-					"missing":       summary.Missing,
-					"skipped":       summary.Skipped,
-					"errors":        summary.Errors,
-					"duration":      summary.Duration.String(),
+					"total_checked":   summary.TotalChecked,
+					"ok":              summary.OK,
+					"corrupted":       summary.Corrupted,
+					"changed":         summary.Changed,
+					"unreadable":      summary.Unreadable,
+					"missing":         summary.Missing,
+					"skipped":         summary.Skipped,
+					"status_updates":  summary.StatusUpdates,
+					"errors":          summary.Errors,
+					"bytes_processed": summary.BytesHashed,
+					"mbps":            format.MBps(summary.BytesHashed, summary.Duration),
+					"duration":        summary.Duration.String(),
+				}
+				if len(summary.AbortedDisks) > 0 {
+					out["aborted_disks"] = summary.AbortedDisks
+				}
+				if len(summary.DisksLikelyOffline) > 0 {
+					out["disks_likely_offline"] = summary.DisksLikelyOffline
+				}
+				if xattrName != "" {
+					out["xattr_mismatches"] = summary.XattrMismatches
+				}
+				if shatag {
+					out["xattr_stale"] = summary.XattrStale
+				}
+				if checkPerms {
+					changes := make([]map[string]string, len(summary.PermsChanged))
+					for i, c := range summary.PermsChanged {
+						changes[i] = map[string]string{"path": c.Path, "old": c.Old.String(), "new": c.New.String()}
+					}
+					out["perms_changed"] = changes
+				}
+				if summary.TimeBounded {
+					out["time_bounded"] = true
+					out["remaining"] = summary.Remaining
+				}
+				if samplePercent > 0 {
+					out["sample_percent"] = samplePercent
+					out["sampled_from"] = summary.SampledFrom
 				}
 				enc := json.NewEncoder(os.Stdout)
 				enc.SetIndent("", "  ")
-				return enc.Encode(out)
+				if err := enc.Encode(out); err != nil {
+					return err
+				}
+				// JSON consumers read the counts; only exit non-zero if asked to
+				if failOn != nil {
+					if code := verifyExitCode(summary, failOn); code != 0 {
+						exit(code)
+					}
+				}
+				return nil
 			}
 
-			fmt.Printf("\nVerification complete:\n")
-			fmt.Printf("  Total checked: %d\n", summary.TotalChecked)
-			fmt.Printf("  OK:            %d\n", summary.OK)
-			fmt.Printf("  Corrupted:     %d\n", summary.Corrupted)
-			fmt.Printf("  Missing:       %d\n", summary.Missing)
+			logx.Infof("\nVerification complete:\n")
+			logx.Infof("  Total checked: %d\n", summary.TotalChecked)
+			logx.Infof("  OK:            %s\n", format.Count("ok", int64(summary.OK)))
+			logx.Infof("  Corrupted:     %s\n", format.Count("corrupted", int64(summary.Corrupted)))
+			if summary.Changed > 0 {
+				logx.Infof("  Changed:       %d (modified since last hashed; rehash --status changed to accept)\n", summary.Changed)
+			}
+			if summary.Unreadable > 0 {
+				logx.Infof("  Unreadable:    %d (could not be read; check permissions and disk health)\n", summary.Unreadable)
+			}
+			logx.Infof("  Missing:       %s\n", format.Count("missing", int64(summary.Missing)))
 			if summary.Skipped > 0 {
-				fmt.Printf("  Skipped:       %d (unchanged)\n", summary.Skipped)
+				logx.Infof("  Skipped:       %d (unchanged)\n", summary.Skipped)
+			}
+			if checkPerms {
+				logx.Infof("  Perms changed: %d\n", len(summary.PermsChanged))
+			}
+			if xattrName != "" {
+				logx.Infof("  Xattr differs: %d (%s)\n", summary.XattrMismatches, xattrName)
+			}
+			if summary.XattrStale > 0 {
+				logx.Infof("  Xattr stale:   %d (modified since tagged; scan --shatag updates them)\n", summary.XattrStale)
+			}
+			logx.Infof("  Updated:       %d (status changed in the catalog)\n", summary.StatusUpdates)
+			logx.Infof("  Errors:        %d\n", summary.Errors)
+			logx.Infof("  Bytes hashed:  %s (%s)\n", format.Bytes(summary.BytesHashed), format.Rate(summary.BytesHashed, summary.Duration))
+			logx.Infof("  Duration:      %s\n", summary.Duration.Round(time.Millisecond))
+			if summary.TimeBounded {
+				logx.Infof("  Time-bounded:  stopped after %s, %d files not yet verified\n", maxDuration, summary.Remaining)
+			}
+			if samplePercent > 0 && summary.SampledFrom > 0 {
+				logx.Infof("  Sample:        %d of %d files (%.1f%%)\n",
+					summary.TotalChecked, summary.SampledFrom,
+					float64(summary.TotalChecked)*100/float64(summary.SampledFrom))
 			}
-			fmt.Printf("  Errors:        %d\n", summary.Errors)
-			fmt.Printf("  Duration:      %s\n", summary.Duration.Round(time.Millisecond))
 
-			if summary.Corrupted > 0 || summary.Missing > 0 {
-				os.Exit(2) // non-zero exit for cron alerting
+			for _, c := range summary.PermsChanged {
+				logx.Infof("  %s %s (%s -> %s)\n", format.Yellow("PERMS:"), c.Path, c.Old, c.New)
+			}
+			for _, d := range summary.AbortedDisks {
+				logx.Alertf("%s appears offline/unreadable (nearly every file failed verification); statuses left unchanged\n", d)
+			}
+			for _, d := range summary.DisksLikelyOffline {
+				logx.Alertf("%s is likely offline (nearly every file is missing); statuses left unchanged\n", d)
+			}
+
+			if code := verifyExitCode(summary, failOn); code != 0 {
+				exit(code) // non-zero exit for cron alerting
 			}
 			return nil
 		},
 	}
 
 	cmd.Flags().BoolVar(&quick, "quick", false, "skip files whose mtime and size haven't changed")
+	cmd.Flags().BoolVar(&fastSizeCheck, "fast-size-check", false, "report files whose size changed without hashing them (changed if the mtime moved, corrupted if not)")
+	cmd.Flags().IntVar(&readRetries, "read-retries", hasher.DefaultReadRetries, "retry a file this many times after a transient read error (EIO) before reporting it")
 	cmd.Flags().StringVar(&disk, "disk", "", "only verify files on a specific disk")
-	cmd.Flags().IntVarP(&workers, "workers", "w", 4, "number of parallel hash workers")
+	cmd.Flags().StringVar(&modifiedSince, "modified-since", "", "only verify files whose stored mtime is at or after this date or age (e.g. 2024-01-31, \"2024-01-31 18:00\", 7d)")
+	cmd.Flags().StringVar(&tag, "tag", "", "only verify files scanned with this scan --tag")
+	cmd.Flags().StringVar(&xattrName, "xattr", "", "also compare each file's hash with the checksum in this extended attribute (as written by scan --xattr)")
+	cmd.Flags().BoolVar(&shatag, "shatag", false, "also compare each file's hash with its cshatag attributes, which only count when stamped with the file's current mtime")
+	cmd.MarkFlagsMutuallyExclusive("xattr", "shatag")
+	cmd.Flags().BoolVar(&checkPerms, "check-perms", false, "also list files whose owner or mode differs from the one scan --track-perms recorded")
+	cmd.Flags().IntVarP(&workers, "workers", "w", 0, "hash workers per disk (default: by disk type, 1 per HDD and 4 per SSD)")
+	cmd.Flags().Float64Var(&samplePercent, "sample-percent", 0, "only verify this percentage of files, least-recently-verified first")
+	cmd.Flags().StringVar(&pathBase, "path-base", db.DefaultPathBase, "where relative catalog paths (scan --path-mode relative) are found")
+	cmd.Flags().DurationVar(&maxDuration, "max-duration", 0, "stop queueing files after this long (e.g. 2h), oldest-verified first; results so far are saved")
+	cmd.Flags().BoolVar(&touchVerified, "touch-verified", true, "record the verify time of files whose status is unchanged; false writes only status changes, so last-verified times and the verified coverage stop advancing for intact files")
+	cmd.Flags().BoolVar(&jsonlOut, "jsonl", false, "stream one JSON object per checked file to stdout as it completes (path, sha256, expected_sha256, status, size); other output goes to stderr")
+	cmd.Flags().StringSliceVar(&failOn, "fail-on", nil, "conditions that cause a non-zero exit, each with its own code: corrupted (2), unreadable (3), missing (4), changed (5), perms (6, with --check-perms) (default: corrupted or missing exit 2, unreadable 3)")
+	return cmd
+}
+
+// verifyExitCodes maps each --fail-on condition to its exit code. A disk
+// that safe mode found offline counts as corrupted, one whose files are all
+// missing as missing.
+var verifyExitCodes = map[string]int{
+	"corrupted":  2,
+	"unreadable": 3,
+	"missing":    4,
+	"changed":    5,
+	"perms":      6,
+}
+
+// verifyExitCode picks verify's exit status. With no --fail-on it keeps the
+// original behaviour: 2 for corruption, missing files or an offline disk,
+// 3 if files were only unreadable. Otherwise it returns the code of the most
+// severe selected condition that occurred, or 0.
+func verifyExitCode(summary *verifier.Summary, failOn []string) int {
+	counts := map[string]int{
+		"corrupted":  summary.Corrupted + len(summary.AbortedDisks),
+		"unreadable": summary.Unreadable,
+		"missing":    summary.Missing + len(summary.DisksLikelyOffline),
+		"changed":    summary.Changed,
+		"perms":      len(summary.PermsChanged),
+	}
+	if failOn == nil {
+		if counts["corrupted"] > 0 || counts["missing"] > 0 {
+			return 2
+		}
+		if counts["unreadable"] > 0 {
+			return 3
+		}
+		return 0
+	}
+	code := 0
+	for _, c := range failOn {
+		if counts[c] > 0 && (code == 0 || verifyExitCodes[c] < code) {
+			code = verifyExitCodes[c]
+		}
+	}
+	return code
+}
+
+func verifySharesCmd() *cobra.Command {
+	var workers int
+
+	cmd := &cobra.Command{
+		Use:   "verify-shares",
+		Short: "Check user share records against the disk files behind them",
+		Long: `For every tracked /mnt/user/... (and /mnt/user0/...) path, find the file on the
+array disk or pool that holds it and confirm both have the same hash. This
+catches FUSE-layer problems where the share view and the disk disagree.
+
+When the disk path is tracked as well, the stored hashes are compared without
+reading any data; untracked disk files are hashed. Statuses are not changed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := db.Open(dbPath, dbOptions)
+			if err != nil {
+				return fmt.Errorf("open database: %w", err)
+			}
+			defer database.Close()
+
+			disks, err := scanner.DetectUnraidDisks()
+			if err != nil {
+				return fmt.Errorf("auto-detect disks: %w", err)
+			}
+			resolve := func(p string) (string, bool) {
+				return scanner.ResolveShareFile(p, disks)
+			}
+
+			var mismatches []verifier.ShareResult
+			resultCb := func(r verifier.ShareResult) {
+				switch r.Status {
+				case "mismatch":
+					mismatches = append(mismatches, r)
+					if !jsonOut {
+						fmt.Printf("  MISMATCH:   %s\n", r.SharePath)
+						fmt.Printf("    disk file: %s\n", r.DiskPath)
+						fmt.Printf("    share:     %s\n", r.ShareHash)
+						fmt.Printf("    disk:      %s\n", r.DiskHash)
+					}
+				case "unresolved":
+					if !jsonOut {
+						fmt.Printf("  UNRESOLVED: %s (not found on any disk)\n", r.SharePath)
+					}
+				case "error":
+					logx.PathErrorf(r.SharePath, "%v\n", r.Err)
+				}
+			}
+
+			v := verifier.New(database, workers, false)
+			summary, err := v.VerifyShares(resolve, resultCb)
+			if err != nil {
+				return err
+			}
+
+			if jsonOut {
+				var mm []map[string]string
+				for _, r := range mismatches {
+					mm = append(mm, map[string]string{
+						"share_path": r.SharePath,
+						"disk_path":  r.DiskPath,
+						"share_hash": r.ShareHash,
+						"disk_hash":  r.DiskHash,
+					})
+				}
+				out := map[string]interface{}{
+					"checked":    summary.Checked,
+					"matched":    summary.Matched,
+					"mismatched": summary.Mismatched,
+					"unresolved": summary.Unresolved,
+					"hashed":     summary.Hashed,
+					"errors":     summary.Errors,
+					"duration":   summary.Duration.String(),
+					"mismatches": mm,
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				return enc.Encode(out)
+			}
+
+			logx.Infof("\nShare check complete:\n")
+			logx.Infof("  Share files:   %d\n", summary.Checked)
+			logx.Infof("  Matched:       %d\n", summary.Matched)
+			logx.Infof("  Mismatched:    %d\n", summary.Mismatched)
+			logx.Infof("  Unresolved:    %d\n", summary.Unresolved)
+			logx.Infof("  Re-hashed:     %d (disk file not tracked)\n", summary.Hashed)
+			logx.Infof("  Errors:        %d\n", summary.Errors)
+			logx.Infof("  Duration:      %s\n", summary.Duration.Round(time.Millisecond))
+
+			if summary.Mismatched > 0 {
+				exit(2) // non-zero exit for cron alerting
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().IntVarP(&workers, "workers", "w", 4, "number of parallel hash workers for untracked disk files")
 	return cmd
 }
 
 func reportCmd() *cobra.Command {
 	var disk string
 	var status string
+	var trend bool
+	var days int
+	var stale string
+	var byExtension bool
+	var top int
+	var outFormat string
+	var nullSep bool
+	var pathBase string
+	var tag string
+	var largest int
+	var smallest int
+	var templatePath string
+	var perms bool
+	var mail summaryMail
 
 	cmd := &cobra.Command{
 		Use:   "report",
 		Short: "Show file integrity reports",
 		Long:  "Display reports on file inventory, per-disk stats, and corruption status.",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			database, err := db.Open(dbPath)
+			database, err := db.Open(dbPath, dbOptions)
 			if err != nil {
 				return fmt.Errorf("open database: %w", err)
 			}
 			defer database.Close()
 
-			// If a specific status is requested, show those files
+			if largest < 0 || smallest < 0 {
+				return fmt.Errorf("invalid --largest/--smallest (must be positive)")
+			}
+			if largest > 0 && smallest > 0 {
+				return fmt.Errorf("--largest cannot be combined with --smallest")
+			}
+			if tag != "" && (outFormat != "text" || trend || stale != "" || byExtension || largest > 0 || smallest > 0 || perms) {
+				return fmt.Errorf("--tag applies to the overview, --status and --disk only")
+			}
+			if len(mail.to) > 0 {
+				if outFormat != "text" || templatePath != "" || tag != "" || status != "" || disk != "" ||
+					trend || stale != "" || byExtension || largest > 0 || smallest > 0 || perms {
+					return fmt.Errorf("--email mails the overview; it can't be combined with other report options")
+				}
+				return mail.send(database)
+			}
+			if mail.always {
+				return fmt.Errorf("--email-always requires --email")
+			}
+			var tmpl *template.Template
+			if templatePath != "" {
+				if jsonOut || outFormat != "text" || trend || byExtension {
+					return fmt.Errorf("--template applies to the overview and file listings only, without --json or --format")
+				}
+				if tmpl, err = loadReportTemplate(templatePath); err != nil {
+					return err
+				}
+			}
+
+			switch outFormat {
+			case "text":
+				if nullSep {
+					return fmt.Errorf("--null requires --format paths")
+				}
+			case "paths":
+				return printPaths(database, status, disk, pathBase, nullSep)
+			default:
+				return fmt.Errorf("invalid --format %q (expected text or paths)", outFormat)
+			}
+
+			if trend {
+				return printTrend(database, days)
+			}
+			if stale != "" {
+				return printStale(database, stale, disk, tmpl)
+			}
+			if byExtension {
+				return printExtensions(database, top)
+			}
+			if largest > 0 {
+				return printBySize(database, "desc", largest, disk, tmpl)
+			}
+			if smallest > 0 {
+				return printBySize(database, "asc", smallest, disk, tmpl)
+			}
+			if perms {
+				return printPerms(database, disk, tmpl)
+			}
+
+			// If a specific status is requested, show those files, on one
+			// disk if --disk is given too
 			if status != "" {
-				files, err := database.GetFilesByStatus(status)
+				files, err := database.ListFiles(status, disk, 0)
 				if err != nil {
 					return fmt.Errorf("get files: %w", err)
 				}
+				files = filterTag(files, tag)
+				if tmpl != nil {
+					return renderReport(tmpl, reportData{Files: files})
+				}
 				if jsonOut {
 					return json.NewEncoder(os.Stdout).Encode(files)
 				}
-				fmt.Printf("Files with status '%s': %d\n\n", status, len(files))
+				where := ""
+				if disk != "" {
+					where = " on " + disk
+				}
+				fmt.Printf("Files with status '%s'%s: %d\n\n", status, where, len(files))
 				for _, f := range files {
 					fmt.Printf("  %s\n", f.Path)
 					fmt.Printf("    disk: %s  size: %s  sha256: %s\n",
-						f.Disk, format.Size(f.Size), f.SHA256[:16]+"...")
+						f.Disk, format.Bytes(f.Size), f.SHA256[:16]+"...")
 				}
 				return nil
 			}
@@ -802,27 +2210,34 @@ func reportCmd() *cobra.Command {
 				if err != nil {
 					return fmt.Errorf("get files: %w", err)
 				}
+				files = filterTag(files, tag)
+				if tmpl != nil {
+					return renderReport(tmpl, reportData{Files: files})
+				}
 				if jsonOut {
 					return json.NewEncoder(os.Stdout).Encode(files)
 				}
 				fmt.Printf("Files on disk '%s': %d\n\n", disk, len(files))
 				for _, f := range files {
-					fmt.Printf("  [%s] %s (%s)\n", f.Status, f.Path, format.Size(f.Size))
+					fmt.Printf("  [%s] %s (%s)\n", f.Status, f.Path, format.Bytes(f.Size))
 				}
 				return nil
 			}
 
 			// Default: show overview
-			stats, err := database.GetStats()
+			stats, err := database.GetStats(db.StatsOptions{Duplicates: true, Tag: tag})
 			if err != nil {
 				return fmt.Errorf("get stats: %w", err)
 			}
 
-			diskStats, err := database.GetDiskStats()
+			diskStats, err := database.GetDiskStats(db.StatsOptions{Tag: tag})
 			if err != nil {
 				return fmt.Errorf("get disk stats: %w", err)
 			}
 
+			if tmpl != nil {
+				return renderReport(tmpl, reportData{Stats: stats, Disks: diskStats})
+			}
 			if jsonOut {
 				out := map[string]interface{}{
 					"overview": stats,
@@ -833,29 +2248,59 @@ func reportCmd() *cobra.Command {
 				return enc.Encode(out)
 			}
 
-			fmt.Println("=== File Integrity Report ===")
+			if tag != "" {
+				fmt.Printf("=== File Integrity Report (tag %s) ===\n", tag)
+			} else {
+				fmt.Println("=== File Integrity Report ===")
+			}
 			fmt.Println()
 			fmt.Printf("  Total files:     %d\n", stats.TotalFiles)
-			fmt.Printf("  Total size:      %s\n", format.Size(stats.TotalSize))
-			fmt.Printf("  OK:              %d\n", stats.OKFiles)
-			fmt.Printf("  Corrupted:       %d\n", stats.CorruptedFiles)
-			fmt.Printf("  Missing:         %d\n", stats.MissingFiles)
+			fmt.Printf("  Total size:      %s\n", format.Bytes(stats.TotalSize))
+			fmt.Printf("  OK:              %s\n", format.Count("ok", stats.OKFiles))
+			fmt.Printf("  Corrupted:       %s\n", format.Count("corrupted", stats.CorruptedFiles))
+			if stats.ChangedFiles > 0 {
+				fmt.Printf("  Changed:         %d\n", stats.ChangedFiles)
+			}
+			if stats.ErrorFiles > 0 {
+				fmt.Printf("  Unreadable:      %d\n", stats.ErrorFiles)
+			}
+			fmt.Printf("  Missing:         %s\n", format.Count("missing", stats.MissingFiles))
+			if stats.AckedFiles > 0 {
+				fmt.Printf("  Acknowledged:    %d\n", stats.AckedFiles)
+			}
+			if stats.NewFiles > 0 {
+				fmt.Printf("  New:             %d (not verified since first scanned)\n", stats.NewFiles)
+			}
+			if stats.DuplicateFiles > 0 {
+				fmt.Printf("  Reclaimable:     %s in %d duplicate copies\n", format.Bytes(stats.ReclaimableBytes), stats.DuplicateFiles)
+			}
 			if stats.LastScan != nil {
 				fmt.Printf("  Last scan:       %s\n", stats.LastScan.Format(time.RFC3339))
 			}
 			if stats.LastVerify != nil {
 				fmt.Printf("  Last verify:     %s\n", stats.LastVerify.Format(time.RFC3339))
 			}
+			if stats.OldestVerified != nil {
+				fmt.Printf("  Coverage:        %.1f%% verified in 30 days, %.1f%% in 90 days\n", stats.Coverage30d, stats.Coverage90d)
+				fmt.Printf("  Oldest verify:   %s\n", stats.OldestVerified.Format(time.RFC3339))
+			}
 
 			if len(diskStats) > 0 {
 				fmt.Println()
 				fmt.Println("  Per-disk breakdown:")
-				fmt.Printf("  %-12s %10s %12s %10s %10s\n",
-					"DISK", "FILES", "SIZE", "CORRUPT", "MISSING")
+				tbl := format.NewTable("DISK", "TYPE", "FILES", "SIZE", "CORRUPT", "MISSING", "HEALTH").AlignRight(2, 3, 4, 5)
+				tbl.Indent = "  "
 				for _, ds := range diskStats {
-					fmt.Printf("  %-12s %10d %12s %10d %10d\n",
-						ds.Disk, ds.TotalFiles, format.Size(ds.TotalSize),
-						ds.CorruptedFiles, ds.MissingFiles)
+					diskType := ds.DiskType
+					if diskType == "" {
+						diskType = "-"
+					}
+					tbl.Row(ds.Disk, diskType, strconv.FormatInt(ds.TotalFiles, 10), format.Bytes(ds.TotalSize),
+						format.Count("corrupted", ds.CorruptedFiles), format.Count("missing", ds.MissingFiles),
+						healthColor(ds.Health))
+				}
+				if err := tbl.Write(os.Stdout); err != nil {
+					return err
 				}
 			}
 
@@ -864,32 +2309,411 @@ func reportCmd() *cobra.Command {
 	}
 
 	cmd.Flags().StringVar(&disk, "disk", "", "show files on a specific disk")
-	cmd.Flags().StringVar(&status, "status", "", "show files with a specific status (ok, corrupted, missing)")
+	cmd.Flags().StringVar(&status, "status", "", "show files with a specific status (ok, new, corrupted, changed, error, acknowledged, missing)")
+	cmd.Flags().StringVar(&outFormat, "format", "text", "output format: text, or paths (bare absolute paths of the --status/--disk files, for scripts)")
+	cmd.Flags().BoolVar(&nullSep, "null", false, "with --format paths, end each path with NUL instead of newline (for xargs -0)")
+	cmd.Flags().StringVar(&pathBase, "path-base", db.DefaultPathBase, "where relative catalog paths (scan --path-mode relative) are found")
+	cmd.Flags().BoolVar(&trend, "trend", false, "show how catalog totals changed over recent scans/verifies")
+	cmd.Flags().IntVar(&days, "days", 30, "number of days of history for --trend")
+	cmd.Flags().StringVar(&stale, "stale", "", "list files not verified within this age (e.g. 90d, 2w), oldest first; combine with --disk")
+	cmd.Flags().BoolVar(&byExtension, "by-extension", false, "show file count and size per file extension")
+	cmd.Flags().IntVar(&top, "top", 20, "number of extensions to show with --by-extension (0 = all)")
+	cmd.Flags().IntVar(&largest, "largest", 0, "list the N largest files, biggest first; combine with --disk")
+	cmd.Flags().IntVar(&smallest, "smallest", 0, "list the N smallest files, smallest first; combine with --disk")
+	cmd.Flags().BoolVar(&perms, "perms", false, "list the owner and mode scan --track-perms recorded for each file; combine with --disk")
+	cmd.Flags().StringVar(&tag, "tag", "", "only count and list files scanned with this scan --tag")
+	cmd.Flags().StringSliceVar(&mail.to, "email", nil, "mail an HTML summary of the overview to this address instead of printing it (repeatable); only when something needs attention unless --email-always")
+	cmd.Flags().BoolVar(&mail.always, "email-always", false, "with --email, also send the summary when all is clear")
+	cmd.Flags().StringVar(&mail.since, "email-since", "24h", "with --email, list files that turned corrupted since this age or time (e.g. 24h, 7d, 2024-01-31)")
+	cmd.Flags().StringVar(&mail.smtp.Host, "smtp-host", "", "SMTP server for --email (also smtp_host in the config file)")
+	cmd.Flags().IntVar(&mail.smtp.Port, "smtp-port", notify.DefaultPort, "SMTP port: 587 uses STARTTLS when offered, 465 TLS from the start")
+	cmd.Flags().StringVar(&mail.smtp.Username, "smtp-user", "", "SMTP login, if the server needs one")
+	cmd.Flags().StringVar(&mail.smtp.Password, "smtp-password", "", "SMTP password (also FILEHASHER_SMTP_PASSWORD)")
+	cmd.Flags().StringVar(&mail.smtp.From, "smtp-from", "", "sender address for --email (default: --smtp-user)")
+	cmd.Flags().StringVar(&templatePath, "template", "", "render the overview or file listing with this Go text/template file (see README)")
 	return cmd
 }
 
+// filterTag keeps the files carrying tag, or all of them when tag is empty.
+func filterTag(files []*db.FileRecord, tag string) []*db.FileRecord {
+	if tag == "" {
+		return files
+	}
+	kept := files[:0]
+	for _, f := range files {
+		if f.Tag == tag {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// healthColor colors a disk health from GetDiskStats: good green, degraded
+// yellow and failing red.
+func healthColor(health string) string {
+	switch health {
+	case "good":
+		return format.Green(health)
+	case "degraded":
+		return format.Yellow(health)
+	case "failing":
+		return format.Red(health)
+	}
+	return health
+}
+
+// printExtensions shows the top extensions by total size.
+func printExtensions(database *db.DB, top int) error {
+	if top < 0 {
+		return fmt.Errorf("invalid --top %d (must be 0 or positive)", top)
+	}
+	stats, err := database.GetExtensionStats()
+	if err != nil {
+		return fmt.Errorf("get extension stats: %w", err)
+	}
+	total := len(stats)
+	if top > 0 && len(stats) > top {
+		stats = stats[:top]
+	}
+
+	if jsonOut {
+		out := map[string]interface{}{
+			"extensions":       stats,
+			"total_extensions": total,
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+
+	fmt.Printf("=== Files by extension (top %d of %d) ===\n\n", len(stats), total)
+	fmt.Printf("  %-12s %10s %12s\n", "EXTENSION", "FILES", "SIZE")
+	for _, st := range stats {
+		ext := st.Ext
+		if ext == "" {
+			ext = "(none)"
+		}
+		fmt.Printf("  %-12s %10d %12s\n", ext, st.Files, format.Bytes(st.TotalSize))
+	}
+	return nil
+}
+
+// printPaths writes the absolute paths of files matching status and disk, one
+// per line (or NUL-terminated), with nothing else, for xargs or
+// rsync --files-from.
+func printPaths(database *db.DB, status, disk, pathBase string, nullSep bool) error {
+	if status == "" && disk == "" {
+		return fmt.Errorf("--format paths requires --status or --disk")
+	}
+	if jsonOut {
+		return fmt.Errorf("--format paths cannot be combined with --json")
+	}
+	files, err := database.ListFiles(status, disk, 0)
+	if err != nil {
+		return fmt.Errorf("get files: %w", err)
+	}
+	sep := "\n"
+	if nullSep {
+		sep = "\x00"
+	}
+	w := bufio.NewWriter(os.Stdout)
+	for _, f := range files {
+		w.WriteString(db.AbsolutePath(f.Path, pathBase))
+		w.WriteString(sep)
+	}
+	return w.Flush()
+}
+
+// printStale lists files whose last verification is older than age, the
+// ones sampled verifies haven't reached yet.
+func printStale(database *db.DB, age, disk string, tmpl *template.Template) error {
+	olderThan, err := format.ParseAge(age)
+	if err != nil {
+		return fmt.Errorf("invalid --stale: %w", err)
+	}
+	files, err := database.GetStaleFiles(olderThan, disk, 0)
+	if err != nil {
+		return fmt.Errorf("get stale files: %w", err)
+	}
+	if tmpl != nil {
+		return renderReport(tmpl, reportData{Files: files})
+	}
+	if jsonOut {
+		if files == nil {
+			files = []*db.FileRecord{}
+		}
+		return json.NewEncoder(os.Stdout).Encode(files)
+	}
+
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	fmt.Printf("Files not verified in %s: %d (%s)\n\n", age, len(files), format.Bytes(total))
+	for _, f := range files {
+		fmt.Printf("  %s  [%s] %s (%s)\n", f.LastVerified.Format("2006-01-02"), f.Disk, f.Path, format.Bytes(f.Size))
+	}
+	return nil
+}
+
+// printBySize lists the n largest (order "desc") or smallest ("asc") files.
+func printBySize(database *db.DB, order string, n int, disk string, tmpl *template.Template) error {
+	files, err := database.GetFilesBySize(order, n, disk)
+	if err != nil {
+		return fmt.Errorf("get files: %w", err)
+	}
+	if tmpl != nil {
+		return renderReport(tmpl, reportData{Files: files})
+	}
+	if jsonOut {
+		if files == nil {
+			files = []*db.FileRecord{}
+		}
+		return json.NewEncoder(os.Stdout).Encode(files)
+	}
+
+	which := "Largest"
+	if order == "asc" {
+		which = "Smallest"
+	}
+	var total int64
+	for _, f := range files {
+		total += f.Size
+	}
+	where := ""
+	if disk != "" {
+		where = " on " + disk
+	}
+	fmt.Printf("%s %d files%s (%s together)\n\n", which, len(files), where, format.Bytes(total))
+	if len(files) == 0 {
+		return nil
+	}
+	tbl := format.NewTable("SIZE", "DISK", "PATH").AlignRight(0)
+	tbl.Indent = "  "
+	for _, f := range files {
+		tbl.Row(format.Bytes(f.Size), f.Disk, f.Path)
+	}
+	return tbl.Write(os.Stdout)
+}
+
+// printPerms lists the owner and mode scan --track-perms recorded for each
+// file, as a manifest to restore them from.
+func printPerms(database *db.DB, disk string, tmpl *template.Template) error {
+	files, err := database.GetFilesWithPerms(disk)
+	if err != nil {
+		return fmt.Errorf("get files: %w", err)
+	}
+	if tmpl != nil {
+		return renderReport(tmpl, reportData{Files: files})
+	}
+	if jsonOut {
+		if files == nil {
+			files = []*db.FileRecord{}
+		}
+		return json.NewEncoder(os.Stdout).Encode(files)
+	}
+
+	where := ""
+	if disk != "" {
+		where = " on " + disk
+	}
+	fmt.Printf("Files with recorded owner and mode%s: %d\n\n", where, len(files))
+	if len(files) == 0 {
+		fmt.Println("  (none; scan --track-perms records them)")
+		return nil
+	}
+	tbl := format.NewTable("MODE", "UID", "GID", "PATH")
+	tbl.Indent = "  "
+	for _, f := range files {
+		tbl.Row(fmt.Sprintf("%04o", f.Perms.Mode), strconv.FormatUint(uint64(f.Perms.UID), 10), strconv.FormatUint(uint64(f.Perms.GID), 10), f.Path)
+	}
+	return tbl.Write(os.Stdout)
+}
+
+// printTrend summarizes stats snapshots from the last days days.
+func printTrend(database *db.DB, days int) error {
+	if days <= 0 {
+		return fmt.Errorf("invalid --days %d (must be positive)", days)
+	}
+	snaps, err := database.GetStatsSnapshots(time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return fmt.Errorf("get stats snapshots: %w", err)
+	}
+
+	var delta db.StatsSnapshot
+	if len(snaps) > 0 {
+		first, last := snaps[0], snaps[len(snaps)-1]
+		delta = db.StatsSnapshot{
+			TakenAt:    last.TakenAt,
+			TotalFiles: last.TotalFiles - first.TotalFiles,
+			TotalSize:  last.TotalSize - first.TotalSize,
+			Corrupted:  last.Corrupted - first.Corrupted,
+			Missing:    last.Missing - first.Missing,
+		}
+	}
+
+	if jsonOut {
+		out := map[string]interface{}{
+			"days":      days,
+			"snapshots": snaps,
+			"delta":     delta,
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+
+	fmt.Printf("=== Trend (last %d days) ===\n\n", days)
+	if len(snaps) == 0 {
+		fmt.Println("  No snapshots yet. They are recorded at the end of every scan and verify.")
+		return nil
+	}
+	fmt.Printf("  %-20s %10s %12s %10s %10s\n", "TAKEN", "FILES", "SIZE", "CORRUPT", "MISSING")
+	for _, sn := range snaps {
+		fmt.Printf("  %-20s %10d %12s %10d %10d\n",
+			sn.TakenAt.Local().Format("2006-01-02 15:04:05"), sn.TotalFiles, format.Bytes(sn.TotalSize),
+			sn.Corrupted, sn.Missing)
+	}
+	fmt.Println()
+	fmt.Printf("  Change over %d snapshots:\n", len(snaps))
+	fmt.Printf("    Files:     %+d\n", delta.TotalFiles)
+	sign := "+"
+	size := delta.TotalSize
+	if size < 0 {
+		sign = "-"
+		size = -size
+	}
+	fmt.Printf("    Size:      %s%s\n", sign, format.Bytes(size))
+	fmt.Printf("    Corrupted: %+d\n", delta.Corrupted)
+	fmt.Printf("    Missing:   %+d\n", delta.Missing)
+	return nil
+}
+
+func ackCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "ack <path>...",
+		Short: "Acknowledge reviewed corrupted files",
+		Long: `Mark corrupted files as acknowledged (e.g. after restoring them from backup)
+so they no longer count as corrupted. Acknowledged files stay in the catalog;
+the next verify that hashes one correctly sets it back to ok.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			database, err := db.Open(dbPath, dbOptions)
+			if err != nil {
+				return fmt.Errorf("open database: %w", err)
+			}
+			defer database.Close()
+
+			var failed []string
+			for _, p := range args {
+				absPath, err := filepath.Abs(p)
+				if err != nil {
+					return fmt.Errorf("resolve path %s: %w", p, err)
+				}
+				if err := database.AcknowledgeFile(absPath); err != nil {
+					logx.Errorf("%v\n", err)
+					failed = append(failed, absPath)
+					continue
+				}
+				if !jsonOut {
+					fmt.Printf("  ACKNOWLEDGED: %s\n", absPath)
+				}
+			}
+
+			if jsonOut {
+				out := map[string]interface{}{
+					"acknowledged": len(args) - len(failed),
+					"failed":       failed,
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(out); err != nil {
+					return err
+				}
+			}
+			if len(failed) > 0 {
+				return fmt.Errorf("%d of %d paths not acknowledged", len(failed), len(args))
+			}
+			return nil
+		},
+	}
+}
+
 func serverCmd() *cobra.Command {
 	var port int
+	var bind string
+	var tlsCert, tlsKey string
+	var tlsSelfSigned bool
+	var apiToken string
+	var basePath string
 
 	cmd := &cobra.Command{
 		Use:   "server",
 		Short: "Start the web dashboard",
 		Long:  "Launch a web server that displays file integrity status, per-disk stats, and corruption reports.",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			database, err := db.Open(dbPath)
+			if ephemeral {
+				return fmt.Errorf("the dashboard needs a lasting catalog; it can't serve an --ephemeral one")
+			}
+			if bind != "" && net.ParseIP(bind) == nil {
+				return fmt.Errorf("invalid --bind %q (expected an IP address, e.g. 127.0.0.1 or ::)", bind)
+			}
+			if port < 0 || port > 65535 {
+				return fmt.Errorf("invalid --port %d", port)
+			}
+			if (tlsCert == "") != (tlsKey == "") {
+				return fmt.Errorf("--tls-cert and --tls-key must be given together")
+			}
+			base, err := web.CleanBasePath(basePath)
+			if err != nil {
+				return fmt.Errorf("invalid --base-path: %w", err)
+			}
+			tlsConfig, fingerprint, err := web.TLSConfig(tlsCert, tlsKey, tlsSelfSigned, []string{bind})
+			if err != nil {
+				return fmt.Errorf("tls: %w", err)
+			}
+
+			database, err := db.Open(dbPath, dbOptions)
 			if err != nil {
 				return fmt.Errorf("open database: %w", err)
 			}
 			defer database.Close()
 
-			runner := web.NewRunner(database)
+			runner := web.NewRunner(database, workerDefaults)
+
+			// Stop serving cleanly on Ctrl-C or SIGTERM (docker stop)
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
 
-			addr := fmt.Sprintf(":%d", port)
-			fmt.Printf("Starting filehasher dashboard at http://0.0.0.0%s\n", addr)
-			return web.Serve(database, addr, version, runner)
+			ln, err := net.Listen("tcp", net.JoinHostPort(bind, strconv.Itoa(port)))
+			if err != nil {
+				return fmt.Errorf("listen: %w", err)
+			}
+			scheme := "http"
+			if tlsConfig != nil {
+				ln = tls.NewListener(ln, tlsConfig)
+				scheme = "https"
+			}
+			fmt.Printf("Starting filehasher dashboard at %s://%s%s/\n", scheme, ln.Addr(), base)
+			if tlsSelfSigned {
+				fmt.Printf("Self-signed certificate, SHA-256 fingerprint %s\n", fingerprint)
+			}
+			if err := web.Serve(ctx, database, ln, version, runner, apiToken, base); err != nil {
+				return err
+			}
+			fmt.Println("Dashboard stopped")
+			return nil
 		},
 	}
 
 	cmd.Flags().IntVarP(&port, "port", "p", 8787, "port to listen on")
+	cmd.Flags().StringVar(&bind, "bind", "", "IP address to listen on (e.g. 127.0.0.1, or a VPN interface's address; default: all IPv4 and IPv6 addresses)")
+	cmd.Flags().StringVar(&tlsCert, "tls-cert", "", "serve HTTPS with this PEM certificate (needs --tls-key)")
+	cmd.Flags().StringVar(&tlsKey, "tls-key", "", "PEM private key for --tls-cert")
+	cmd.Flags().BoolVar(&tlsSelfSigned, "tls-self-signed", false, "serve HTTPS with a certificate generated at startup (browsers will warn; compare the printed fingerprint)")
+	cmd.Flags().StringVar(&apiToken, "api-token", "", "require this bearer token to start or stop scans and verifies, acknowledge files and save settings (also FILEHASHER_API_TOKEN)")
+	cmd.Flags().StringVar(&basePath, "base-path", "", "serve the dashboard below this URL path, e.g. /filehasher behind a reverse proxy")
+	cmd.MarkFlagsMutuallyExclusive("tls-self-signed", "tls-cert")
+	cmd.MarkFlagsMutuallyExclusive("tls-self-signed", "tls-key")
 	return cmd
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain lets tests run the filehasher command itself: with
+// FILEHASHER_TEST_MAIN set, the test binary acts as filehasher.
+func TestMain(m *testing.M) {
+	if os.Getenv("FILEHASHER_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// run executes filehasher with args and returns its combined output.
+func run(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "FILEHASHER_TEST_MAIN=1", "NO_COLOR=1")
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func TestScanWithoutXattrs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+
+	out, err := run(t, "scan", "--db", dbPath, dir)
+	if err != nil {
+		t.Fatalf("scan: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "Scan complete") || strings.Contains(out, "panic") {
+		t.Errorf("scan output:\n%s", out)
+	}
+}
+
+func TestVersionShorthand(t *testing.T) {
+	out, err := run(t, "-v")
+	if err != nil {
+		t.Fatalf("-v: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "version") {
+		t.Errorf("-v output = %q, want the version", out)
+	}
+}
+
+func TestVerifyBracketedPath(t *testing.T) {
+	dir := t.TempDir()
+	film := filepath.Join(dir, "Film [1080p].mkv")
+	// "Film [1080p].mkv" read as a glob would match this one instead
+	other := filepath.Join(dir, "Film 1.mkv")
+	for _, p := range []string{film, other} {
+		if err := os.WriteFile(p, []byte(filepath.Base(p)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	if out, err := run(t, "scan", "--db", dbPath, dir); err != nil {
+		t.Fatalf("scan: %v\n%s", err, out)
+	}
+
+	out, err := run(t, "verify", "--db", dbPath, film)
+	if err != nil {
+		t.Fatalf("verify: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "Verifying 1 matching files") || !strings.Contains(out, "Total checked: 1") {
+		t.Errorf("verify %q:\n%s", film, out)
+	}
+}
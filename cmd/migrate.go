@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/maisi/unraid-filehasher/internal/db"
+	"github.com/maisi/unraid-filehasher/internal/format"
+	"github.com/maisi/unraid-filehasher/internal/hasher"
+	"github.com/maisi/unraid-filehasher/internal/logx"
+	"github.com/spf13/cobra"
+	"github.com/vbauerster/mpb/v8"
+	"github.com/vbauerster/mpb/v8/decor"
+)
+
+func migrateHashCmd() *cobra.Command {
+	var to string
+	var disk string
+	var rate float64
+	var maxDuration time.Duration
+	var workers int
+	var pathBase string
+	var backup bool
+
+	cmd := &cobra.Command{
+		Use:   "migrate-hash --to ALGO",
+		Short: "Move the catalog to another hash algorithm in place",
+		Long: `Re-read tracked files, least-recently-verified first, and switch each record
+to a new hash algorithm. Every file is read once: the old and the new digest
+are computed together, and the new one is only stored if the old one still
+matches the catalog. Files that don't match are reported and left alone
+(run verify on them).
+
+Each file is committed as soon as it is migrated, so an interrupted run (or
+one stopped by --max-duration) picks up where it left off. --rate caps the
+read throughput so a migration can run alongside normal use.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if to == "" || !hasher.Supported(to) {
+				return fmt.Errorf("invalid --to %q (supported: sha256, sha512, blake3)", to)
+			}
+			if rate < 0 {
+				return fmt.Errorf("invalid --rate %v (must be 0 or positive)", rate)
+			}
+			if workers <= 0 {
+				return fmt.Errorf("invalid --workers %d (must be positive)", workers)
+			}
+
+			database, err := db.Open(dbPath, dbOptions)
+			if err != nil {
+				return fmt.Errorf("open database: %w", err)
+			}
+			defer database.Close()
+
+			all, err := database.GetFilesByLastVerified(disk)
+			if err != nil {
+				return fmt.Errorf("get files: %w", err)
+			}
+			var todo []*db.FileRecord
+			var todoBytes int64
+			var chunkedSkipped int
+			for _, f := range all {
+				if f.Algo == to || f.Status == "missing" {
+					continue
+				}
+				// A chunked hash can't be checked in the same pass that
+				// computes a whole-file one; those need a scan --full.
+				if f.ChunkSize > 0 {
+					chunkedSkipped++
+					continue
+				}
+				todo = append(todo, f)
+				todoBytes += f.Size
+			}
+			if !jsonOut {
+				logx.Infof("%d of %d files to migrate to %s (%s)\n", len(todo), len(all), to, format.Bytes(todoBytes))
+				if chunkedSkipped > 0 {
+					logx.Infof("Skipping %d files with chunked hashes (re-hash them with scan --full)\n", chunkedSkipped)
+				}
+			}
+
+			if backup && len(todo) > 0 {
+				if err := backupFirst(database); err != nil {
+					return err
+				}
+			}
+
+			ctx := context.Background()
+			if maxDuration > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, maxDuration)
+				defer cancel()
+			}
+
+			start := time.Now()
+			byPath := make(map[string]*db.FileRecord, len(todo))
+			input := make(chan hasher.FileInfo, workers*2)
+			output := make(chan hasher.Result, workers*2)
+			for _, f := range todo {
+				byPath[db.AbsolutePath(f.Path, pathBase)] = f
+			}
+			go func() {
+				defer close(input)
+				var fed int64
+				for _, f := range todo {
+					// Throttle: don't get ahead of rate MiB/s
+					if rate > 0 {
+						due := time.Duration(float64(fed) / (rate * (1 << 20)) * float64(time.Second))
+						if wait := due - time.Since(start); wait > 0 {
+							select {
+							case <-ctx.Done():
+								return
+							case <-time.After(wait):
+							}
+						}
+					}
+					select {
+					case <-ctx.Done():
+						return
+					default:
+					}
+					input <- hasher.FileInfo{Path: db.AbsolutePath(f.Path, pathBase), Disk: f.Disk, Algo: f.Algo, Extra: []string{to}}
+					fed += f.Size
+				}
+			}()
+			go hasher.New(workers).HashFiles(input, output)
+
+			// Progress bar over the bytes to read (TTY only, disabled for
+			// --json, --quiet and --verbose); messages print above it
+			var p *mpb.Progress
+			var bar *mpb.Bar
+			var filesDone atomic.Int64
+			out := io.Writer(os.Stdout)
+			if showProgressBars() {
+				p = mpb.New(mpb.WithOutput(os.Stderr), mpb.WithWidth(64))
+				bar = p.AddBar(todoBytes,
+					mpb.PrependDecorators(
+						decor.Name("Migrate ", decor.WC{W: 9, C: decor.DindentRight}),
+						decor.Any(func(decor.Statistics) string {
+							return fmt.Sprintf("%d / %d files", filesDone.Load(), len(todo))
+						}, decor.WC{W: 22, C: decor.DindentRight}),
+						decor.CountersKibiByte("% .1f / % .1f", decor.WC{W: 24, C: decor.DindentRight}),
+						decor.AverageSpeed(decor.SizeB1024(0), "% .1f", decor.WC{W: 12, C: decor.DindentRight}),
+					),
+					mpb.AppendDecorators(
+						decor.Percentage(decor.WC{W: 6}),
+						decor.Name(" ETA "),
+						decor.AverageETA(decor.ET_STYLE_HHMMSS, decor.WC{W: 8}),
+					),
+				)
+				out = routeThroughBars(p)
+				defer logx.SetOutput(os.Stdout, os.Stderr)
+			}
+
+			var migrated, mismatched, errors int
+			var bytesDone int64
+			var mismatches []string
+			for result := range output {
+				if bar != nil {
+					filesDone.Add(1)
+					bar.IncrBy(int(result.Size))
+				}
+				f := byPath[result.Path]
+				switch {
+				case result.Err != nil:
+					errors++
+					logx.PathErrorf(result.Path, "%v\n", result.Err)
+				case result.SHA256 != f.SHA256:
+					mismatched++
+					mismatches = append(mismatches, result.Path)
+					if !jsonOut {
+						fmt.Fprintf(out, "  MISMATCH:  %s (doesn't match its %s hash; left unchanged, run verify)\n", result.Path, f.Algo)
+					}
+				default:
+					if err := database.MigrateHash(f.Path, f.Algo, f.SHA256, to, result.Digests[to]); err != nil {
+						errors++
+						logx.PathErrorf(result.Path, "store: %v\n", err)
+						break
+					}
+					migrated++
+					bytesDone += result.Size
+				}
+			}
+			if bar != nil {
+				// Stopped early by --max-duration: end the bar where it is
+				bar.SetTotal(bar.Current(), true)
+				p.Wait()
+				logx.SetOutput(os.Stdout, os.Stderr)
+				fmt.Fprintln(os.Stderr)
+			}
+			elapsed := time.Since(start)
+			remaining := len(todo) - migrated - mismatched - errors
+
+			if jsonOut {
+				if mismatches == nil {
+					mismatches = []string{}
+				}
+				out := map[string]interface{}{
+					"to":              to,
+					"migrated":        migrated,
+					"mismatched":      mismatched,
+					"mismatches":      mismatches,
+					"errors":          errors,
+					"remaining":       remaining,
+					"bytes_processed": bytesDone,
+					"mbps":            format.MBps(bytesDone, elapsed),
+					"duration":        elapsed.String(),
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(out); err != nil {
+					return err
+				}
+			} else {
+				logx.Infof("\nMigration to %s:\n", to)
+				logx.Infof("  Migrated:    %d\n", migrated)
+				logx.Infof("  Mismatched:  %d\n", mismatched)
+				logx.Infof("  Errors:      %d\n", errors)
+				logx.Infof("  Remaining:   %d\n", remaining)
+				logx.Infof("  Bytes read:  %s (%s)\n", format.Bytes(bytesDone), format.Rate(bytesDone, elapsed))
+				logx.Infof("  Duration:    %s\n", elapsed.Round(time.Millisecond))
+				if remaining > 0 {
+					logx.Infof("  Run the same command again to continue.\n")
+				}
+			}
+
+			if mismatched > 0 {
+				exit(2) // non-zero exit for cron alerting
+			}
+			if errors > 0 {
+				return fmt.Errorf("%d files could not be migrated", errors)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&to, "to", "", "algorithm to migrate to (sha256, sha512, blake3)")
+	cmd.Flags().StringVar(&disk, "disk", "", "only migrate files on a specific disk")
+	cmd.Flags().Float64Var(&rate, "rate", 0, "maximum read rate in MiB/s (0 = unlimited)")
+	cmd.Flags().DurationVar(&maxDuration, "max-duration", 0, "stop queueing files after this long (e.g. 2h); rerun to continue")
+	cmd.Flags().IntVarP(&workers, "workers", "w", 1, "number of parallel hash workers")
+	cmd.Flags().BoolVar(&backup, "backup-first", false, "back up the catalog before migrating (see db backup)")
+	cmd.Flags().StringVar(&pathBase, "path-base", db.DefaultPathBase, "where relative catalog paths (scan --path-mode relative) are found")
+	_ = cmd.MarkFlagRequired("to")
+	return cmd
+}
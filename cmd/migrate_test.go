@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/maisi/unraid-filehasher/internal/db"
+	"lukechampine.com/blake3"
+)
+
+func TestMigrateHashToBlake3(t *testing.T) {
+	dir := t.TempDir()
+	contents := map[string]string{"a.txt": "first file\n", "b.txt": "second file\n"}
+	for name, content := range contents {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+
+	if out, err := run(t, "scan", "--db", dbPath, dir); err != nil {
+		t.Fatalf("scan: %v\n%s", err, out)
+	}
+	out, err := run(t, "migrate-hash", "--db", dbPath, "--to", "blake3")
+	if err != nil {
+		t.Fatalf("migrate-hash: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "Migrated:    2") {
+		t.Errorf("migrate-hash output:\n%s", out)
+	}
+
+	database, err := db.Open(dbPath, db.Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	for name, content := range contents {
+		path := filepath.Join(dir, name)
+		f, err := database.GetFileByPath(path)
+		if err != nil || f == nil {
+			t.Fatalf("GetFileByPath(%s): %v", path, err)
+		}
+		sum := blake3.Sum256([]byte(content))
+		if f.Algo != "blake3" || f.SHA256 != hex.EncodeToString(sum[:]) {
+			t.Errorf("%s: %s/%s, want blake3/%x", name, f.Algo, f.SHA256, sum)
+		}
+	}
+	database.Close()
+
+	// The migrated catalog verifies clean with the new algorithm
+	out, err = run(t, "verify", "--db", dbPath)
+	if err != nil {
+		t.Fatalf("verify: %v\n%s", err, out)
+	}
+	if strings.Contains(out, "CORRUPTED") {
+		t.Errorf("verify after migration:\n%s", out)
+	}
+}
+
+func TestScanAfterMigrationKeepsAlgo(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"a.txt": "moved later\n", "b.txt": "edited later\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	if out, err := run(t, "scan", "--db", dbPath, dir); err != nil {
+		t.Fatalf("scan: %v\n%s", err, out)
+	}
+	if out, err := run(t, "migrate-hash", "--db", dbPath, "--to", "blake3"); err != nil {
+		t.Fatalf("migrate-hash: %v\n%s", err, out)
+	}
+
+	// Move one file, edit another and add a third
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(dir, "a.txt"), filepath.Join(dir, "sub", "a.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.txt"), []byte("edited, and longer now\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "c.txt"), []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := run(t, "scan", "--db", dbPath, dir)
+	if err != nil {
+		t.Fatalf("second scan: %v\n%s", err, out)
+	}
+	if strings.Contains(out, "possible move corruption") {
+		t.Errorf("move of a migrated file flagged as corruption:\n%s", out)
+	}
+
+	database, err := db.Open(dbPath, db.Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer database.Close()
+	if old, _ := database.GetFileByPath(filepath.Join(dir, "a.txt")); old != nil {
+		t.Errorf("a.txt still tracked at its old path; the move wasn't re-keyed")
+	}
+	for _, name := range []string{"sub/a.txt", "b.txt", "c.txt"} {
+		f, err := database.GetFileByPath(filepath.Join(dir, name))
+		if err != nil || f == nil {
+			t.Fatalf("GetFileByPath(%s): %v", name, err)
+		}
+		if f.Algo != "blake3" || f.Status == "corrupted" {
+			t.Errorf("%s: algo %s, status %s; want blake3 and not corrupted", name, f.Algo, f.Status)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"github.com/maisi/unraid-filehasher/internal/logx"
+	"github.com/maisi/unraid-filehasher/internal/oplock"
+)
+
+// catalogLock is held by a running scan or verify; exit releases it too.
+var catalogLock *oplock.Lock
+
+// lockCatalog takes the catalog's operation lock for op, so a cron verify
+// and a manual scan (or one started from the dashboard) never run at once.
+func lockCatalog(op string) error {
+	l, err := oplock.Acquire(oplock.Path(dbPath), op)
+	if err != nil {
+		return err
+	}
+	catalogLock = l
+	return nil
+}
+
+// unlockCatalog releases the lock taken by lockCatalog, if any.
+func unlockCatalog() {
+	if err := catalogLock.Release(); err != nil {
+		logx.Warnf("release catalog lock: %v\n", err)
+	}
+	catalogLock = nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/maisi/unraid-filehasher/internal/db"
+	"github.com/maisi/unraid-filehasher/internal/hasher"
+	"github.com/maisi/unraid-filehasher/internal/logx"
+	"github.com/spf13/cobra"
+)
+
+func rehashCmd() *cobra.Command {
+	var disk string
+	var status string
+	var workers int
+	var backup bool
+
+	cmd := &cobra.Command{
+		Use:   "rehash [path-or-glob...]",
+		Short: "Re-baseline tracked files from their current contents",
+		Long: `Re-read tracked files and store their current hash, size and mtime as the new
+truth, setting their status back to ok. Use this after intentionally changing
+a file (e.g. re-encoding a movie) that verify would otherwise keep reporting
+as corrupted. Hash changes are recorded in the file history.
+
+Arguments are exact paths, directories (everything tracked beneath them), or
+glob patterns matched against catalog paths, where * also matches "/".
+Quote globs so the shell doesn't expand them. With --status and no
+arguments, every file with that status is re-baselined.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && status == "" {
+				return fmt.Errorf("no paths specified; provide paths/globs or use --status")
+			}
+			if workers <= 0 {
+				return fmt.Errorf("invalid --workers %d (must be positive)", workers)
+			}
+
+			paths, globs, err := pathArgs(args)
+			if err != nil {
+				return err
+			}
+
+			database, err := db.Open(dbPath, dbOptions)
+			if err != nil {
+				return fmt.Errorf("open database: %w", err)
+			}
+			defer database.Close()
+
+			records, err := database.GetFilesMatching(paths, globs, disk, status)
+			if err != nil {
+				return fmt.Errorf("find files: %w", err)
+			}
+			if len(records) == 0 {
+				if !jsonOut {
+					fmt.Println("No matching tracked files.")
+				}
+				return nil
+			}
+
+			if backup {
+				if err := backupFirst(database); err != nil {
+					return err
+				}
+			}
+
+			byPath := make(map[string]*db.FileRecord, len(records))
+			input := make(chan hasher.FileInfo, workers*4)
+			output := make(chan hasher.Result, workers*4)
+			for _, r := range records {
+				byPath[r.Path] = r
+			}
+			go func() {
+				defer close(input)
+				for _, r := range records {
+					// Size/Mtime left zero so the hasher re-stats the current file
+					// A chunked record is hashed the same way again, from scratch
+					input <- hasher.FileInfo{Path: r.Path, Disk: r.Disk, Algo: r.Algo, Secondary: r.SecondaryAlgo, ChunkSize: r.ChunkSize}
+				}
+			}()
+			go hasher.New(workers).HashFiles(input, output)
+
+			tx, err := database.BeginBatch()
+			if err != nil {
+				return fmt.Errorf("begin transaction: %w", err)
+			}
+			defer tx.Rollback()
+
+			var rehashed, changed, errors int
+			for result := range output {
+				if result.Err != nil {
+					errors++
+					logx.PathErrorf(result.Path, "%v\n", result.Err)
+					continue
+				}
+				old := byPath[result.Path]
+				if err := database.RebaselineFileTx(tx, old, result.SHA256, result.Secondary, result.Chunks, result.Size, result.Mtime, result.MtimeNsec); err != nil {
+					errors++
+					logx.PathErrorf(result.Path, "store: %v\n", err)
+					continue
+				}
+				rehashed++
+				if result.SHA256 != old.SHA256 {
+					changed++
+					if !jsonOut {
+						fmt.Printf("  CHANGED:   %s\n", result.Path)
+					}
+				} else if !jsonOut {
+					fmt.Printf("  UNCHANGED: %s\n", result.Path)
+				}
+			}
+
+			if err := tx.Commit(); err != nil {
+				return fmt.Errorf("commit: %w", err)
+			}
+
+			if jsonOut {
+				out := map[string]interface{}{
+					"matched":  len(records),
+					"rehashed": rehashed,
+					"changed":  changed,
+					"errors":   errors,
+				}
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(out); err != nil {
+					return err
+				}
+			} else {
+				logx.Infof("\nRehash complete:\n")
+				logx.Infof("  Matched:   %d\n", len(records))
+				logx.Infof("  Rehashed:  %d (%d with a new hash)\n", rehashed, changed)
+				logx.Infof("  Errors:    %d\n", errors)
+			}
+
+			if errors > 0 {
+				return fmt.Errorf("%d files could not be rehashed", errors)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&disk, "disk", "", "only rehash matching files on a specific disk")
+	cmd.Flags().StringVar(&status, "status", "", "only rehash files with this status (e.g. corrupted)")
+	cmd.Flags().IntVarP(&workers, "workers", "w", 4, "number of parallel hash workers")
+	cmd.Flags().BoolVar(&backup, "backup-first", false, "back up the catalog before rehashing (see db backup)")
+	return cmd
+}
+
+// pathArgs sorts path arguments into exact catalog paths and GLOB patterns
+// for GetFilesMatching: an existing directory matches everything tracked
+// beneath it, and other arguments containing * or ? are globs. Brackets are
+// always literal, as in names like "Film [1080p].mkv".
+func pathArgs(args []string) (paths, globs []string, err error) {
+	for _, a := range args {
+		absPath, err := filepath.Abs(a)
+		if err != nil {
+			return nil, nil, fmt.Errorf("resolve path %s: %w", a, err)
+		}
+		fi, statErr := os.Stat(absPath)
+		switch {
+		case statErr == nil && fi.IsDir():
+			globs = append(globs, filepath.Join(globEscaper.Replace(absPath), "*"))
+		case statErr == nil || !strings.ContainsAny(a, "*?"):
+			paths = append(paths, absPath)
+		default:
+			globs = append(globs, strings.ReplaceAll(absPath, "[", "[[]"))
+		}
+	}
+	return paths, globs, nil
+}
+
+// globEscaper makes a path match only itself in a GLOB pattern.
+var globEscaper = strings.NewReplacer("[", "[[]", "*", "[*]", "?", "[?]")
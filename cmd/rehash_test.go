@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maisi/unraid-filehasher/internal/db"
+	"github.com/maisi/unraid-filehasher/internal/hasher"
+)
+
+func TestPathArgsBrackets(t *testing.T) {
+	dir := t.TempDir()
+	season := filepath.Join(dir, "Season [2019]")
+	if err := os.Mkdir(season, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := []string{
+		filepath.Join(dir, "Film [1080p].mkv"),
+		filepath.Join(dir, "Film 1.mkv"),
+		filepath.Join(season, "e01.mkv"),
+		filepath.Join(dir, "Season 2", "e01.mkv"),
+	}
+
+	database, err := db.Open(filepath.Join(t.TempDir(), "test.db"), db.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.Close()
+	tx, err := database.BeginBatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, p := range files {
+		if err := database.UpsertFileTx(tx, &db.FileRecord{Path: p, Disk: "disk1", Size: 1, Mtime: now.Unix(),
+			SHA256: "00", FirstSeen: now, LastVerified: now, Status: "ok"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(files[0], nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	match := func(args ...string) []string {
+		t.Helper()
+		paths, globs, err := pathArgs(args)
+		if err != nil {
+			t.Fatalf("pathArgs(%q): %v", args, err)
+		}
+		recs, err := database.GetFilesMatching(paths, globs, "", "")
+		if err != nil {
+			t.Fatalf("GetFilesMatching: %v", err)
+		}
+		var got []string
+		for _, r := range recs {
+			got = append(got, r.Path)
+		}
+		return got
+	}
+
+	for _, tt := range []struct {
+		args []string
+		want []string
+	}{
+		{[]string{files[0]}, []string{files[0]}},                                           // existing file
+		{[]string{filepath.Join(dir, "Film [720p].mkv")}, nil},                             // gone: still a literal path
+		{[]string{season}, []string{files[2]}},                                             // directory
+		{[]string{filepath.Join(dir, "Film [1080p]*")}, []string{files[0]}},                // glob with brackets
+		{[]string{filepath.Join(dir, "Film ?.mkv")}, []string{files[1]}},                   // ? wildcard
+		{[]string{filepath.Join(dir, "Season*", "e01.mkv")}, []string{files[3], files[2]}}, // * crosses directories
+	} {
+		got := match(tt.args...)
+		if len(got) != len(tt.want) {
+			t.Errorf("%q matched %q, want %q", tt.args, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%q matched %q, want %q", tt.args, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestRehashChunked(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	if err := os.WriteFile(path, []byte("line one\nline two\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+
+	if out, err := run(t, "scan", "--db", dbPath, "--chunked", dir); err != nil {
+		t.Fatalf("scan: %v\n%s", err, out)
+	}
+	out, err := run(t, "rehash", "--db", dbPath, path)
+	if err != nil {
+		t.Fatalf("rehash: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "UNCHANGED: "+path) {
+		t.Errorf("rehash of an unchanged chunked file:\n%s", out)
+	}
+
+	database, err := db.Open(dbPath, db.Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer database.Close()
+	f, err := database.GetFileByPath(path)
+	if err != nil || f == nil {
+		t.Fatalf("GetFileByPath: %v", err)
+	}
+	if f.ChunkSize != hasher.DefaultChunkSize {
+		t.Errorf("ChunkSize = %d, want %d", f.ChunkSize, hasher.DefaultChunkSize)
+	}
+	if chunks, _ := database.GetChunks(path); len(chunks) != 1 {
+		t.Errorf("chunks = %v, want one", chunks)
+	}
+	if history, _ := database.GetFileHistory(path); len(history) != 0 {
+		t.Errorf("history = %+v, want none for an unchanged file", history)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/maisi/unraid-filehasher/internal/db"
+	"github.com/maisi/unraid-filehasher/internal/web"
+)
+
+// reportData is what a report --template renders. Files is set for file
+// listings (--status, --disk, --stale, --largest, --smallest), Stats and
+// Disks for the overview.
+type reportData struct {
+	Files []*db.FileRecord
+	Stats *db.Stats
+	Disks []*db.DiskStats
+}
+
+// loadReportTemplate parses a report --template file as a text/template with
+// the dashboard's functions (formatBytes, formatTime, truncHash, ...).
+func loadReportTemplate(path string) (*template.Template, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read --template: %w", err)
+	}
+	tmpl, err := template.New(filepath.Base(path)).Funcs(web.TemplateFuncs()).Parse(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("parse --template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderReport executes tmpl over data to stdout.
+func renderReport(tmpl *template.Template, data reportData) error {
+	w := bufio.NewWriter(os.Stdout)
+	if err := tmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("render --template: %w", err)
+	}
+	return w.Flush()
+}
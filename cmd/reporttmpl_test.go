@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTemplate(t *testing.T, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "report.tmpl")
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadReportTemplateErrors(t *testing.T) {
+	if _, err := loadReportTemplate(filepath.Join(t.TempDir(), "missing.tmpl")); err == nil || !strings.Contains(err.Error(), "read --template") {
+		t.Errorf("missing file: err = %v, want a read error", err)
+	}
+	if _, err := loadReportTemplate(writeTemplate(t, "{{range .Files}}")); err == nil || !strings.Contains(err.Error(), "parse --template") {
+		t.Errorf("unclosed range: err = %v, want a parse error", err)
+	}
+	if _, err := loadReportTemplate(writeTemplate(t, "{{noSuchFunc .Files}}")); err == nil {
+		t.Error("unknown function accepted")
+	}
+	if _, err := loadReportTemplate(writeTemplate(t, "{{range .Files}}{{.Path}} {{formatBytes .Size}}\n{{end}}")); err != nil {
+		t.Errorf("valid template: %v", err)
+	}
+}
+
+func TestReportTemplate(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"a.txt": "aaaa", "b.txt": "bb"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dbPath := filepath.Join(t.TempDir(), "catalog.db")
+	if out, err := run(t, "scan", "--db", dbPath, dir); err != nil {
+		t.Fatalf("scan: %v\n%s", err, out)
+	}
+
+	listing := writeTemplate(t, "{{range .Files}}{{.Path}}|{{.Size}}|{{truncHash .SHA256}}\n{{end}}")
+	out, err := run(t, "report", "--db", dbPath, "--largest", "10", "--template", listing)
+	if err != nil {
+		t.Fatalf("report --largest --template: %v\n%s", err, out)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], filepath.Join(dir, "a.txt")+"|4|") ||
+		!strings.HasPrefix(lines[1], filepath.Join(dir, "b.txt")+"|2|") {
+		t.Errorf("listing:\n%s", out)
+	}
+
+	overview := writeTemplate(t, "files={{.Stats.TotalFiles}} disks={{len .Disks}}\n")
+	out, err = run(t, "report", "--db", dbPath, "--template", overview)
+	if err != nil {
+		t.Fatalf("report --template: %v\n%s", err, out)
+	}
+	if strings.TrimSpace(out) != "files=2 disks=1" {
+		t.Errorf("overview = %q, want files=2 disks=1", out)
+	}
+
+	// Fields that don't exist fail at render time, naming the template
+	bad := writeTemplate(t, "{{.Stats.NoSuchField}}\n")
+	out, err = run(t, "report", "--db", dbPath, "--template", bad)
+	if err == nil || !strings.Contains(out, "render --template") {
+		t.Errorf("bad field: err = %v, output:\n%s", err, out)
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/maisi/unraid-filehasher/internal/verifier"
+)
+
+func TestVerifyExitCode(t *testing.T) {
+	all := []string{"corrupted", "unreadable", "missing", "changed", "perms"}
+	tests := []struct {
+		name    string
+		summary verifier.Summary
+		failOn  []string
+		want    int
+	}{
+		{"default clean", verifier.Summary{OK: 5}, nil, 0},
+		{"default corrupted", verifier.Summary{Corrupted: 1}, nil, 2},
+		{"default missing", verifier.Summary{Missing: 1}, nil, 2},
+		{"default unreadable only", verifier.Summary{Unreadable: 1}, nil, 3},
+		{"default corrupted beats unreadable", verifier.Summary{Corrupted: 1, Unreadable: 1}, nil, 2},
+		{"default ignores changed and perms", verifier.Summary{Changed: 1, PermsChanged: []verifier.PermsChange{{}}}, nil, 0},
+		{"default aborted disk", verifier.Summary{AbortedDisks: []string{"disk1"}}, nil, 2},
+		{"default offline disk", verifier.Summary{DisksLikelyOffline: []string{"disk2"}}, nil, 2},
+
+		{"fail-on corrupted", verifier.Summary{Corrupted: 1}, []string{"corrupted"}, 2},
+		{"fail-on unreadable", verifier.Summary{Unreadable: 1}, []string{"unreadable"}, 3},
+		{"fail-on missing", verifier.Summary{Missing: 1}, []string{"missing"}, 4},
+		{"fail-on changed", verifier.Summary{Changed: 1}, []string{"changed"}, 5},
+		{"fail-on perms", verifier.Summary{PermsChanged: []verifier.PermsChange{{}}}, []string{"perms"}, 6},
+		{"fail-on unlisted condition", verifier.Summary{Corrupted: 1}, []string{"changed"}, 0},
+		{"fail-on nothing", verifier.Summary{Corrupted: 1}, []string{}, 0},
+
+		{"most severe wins", verifier.Summary{Missing: 1, Changed: 1, PermsChanged: []verifier.PermsChange{{}}}, all, 4},
+		{"most severe wins regardless of order", verifier.Summary{Corrupted: 1, Changed: 1}, []string{"changed", "corrupted"}, 2},
+
+		{"fail-on aborted disk counts as corrupted", verifier.Summary{AbortedDisks: []string{"disk1"}}, []string{"corrupted"}, 2},
+		{"fail-on offline disk counts as missing", verifier.Summary{DisksLikelyOffline: []string{"disk2"}}, []string{"missing"}, 4},
+		{"offline disk is not corrupted", verifier.Summary{DisksLikelyOffline: []string{"disk2"}}, []string{"corrupted"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyExitCode(&tt.summary, tt.failOn); got != tt.want {
+				t.Errorf("verifyExitCode(%+v, %q) = %d, want %d", tt.summary, tt.failOn, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/maisi/unraid-filehasher/internal/logx"
+	"github.com/maisi/unraid-filehasher/internal/verifier"
+	"github.com/maisi/unraid-filehasher/internal/xattr"
+)
+
+// xattrTagger writes each file's hash to extended attributes for scan
+// --xattr, or in cshatag's format for scan --shatag. It is called from the
+// walk and the result loop at once.
+type xattrTagger struct {
+	name        string // the attribute holding the hash
+	shatag      bool
+	written     atomic.Int64
+	failed      atomic.Int64
+	adopted     atomic.Int64
+	unsupported sync.Once
+}
+
+func newXattrTagger(name string, shatag bool) *xattrTagger {
+	if shatag {
+		name = xattr.ShatagSHA256
+	}
+	return &xattrTagger{name: name, shatag: shatag}
+}
+
+// store writes the hash of path, computed by algo when it had the given
+// mtime. cshatag attributes only hold SHA-256, so other algorithms' hashes
+// aren't written in that format.
+func (x *xattrTagger) store(path, algo, hash string, mtime, mtimeNsec int64) {
+	var err error
+	if x.shatag {
+		if algo != "sha256" {
+			return
+		}
+		err = xattr.SetShatag(path, xattr.Shatag{SHA256: hash, Mtime: mtime, MtimeNsec: mtimeNsec})
+	} else {
+		err = xattr.Set(path, x.name, hash)
+	}
+	if err != nil {
+		x.fail(path, err)
+		return
+	}
+	x.written.Add(1)
+}
+
+// refresh is store for files the scan skipped as unchanged: it writes only
+// if the attributes don't hold the hash already.
+func (x *xattrTagger) refresh(path, algo, hash string, mtime, mtimeNsec int64) {
+	var err error
+	if x.shatag {
+		var t xattr.Shatag
+		t, err = xattr.GetShatag(path)
+		if err == nil && t.SHA256 == hash && t.Current(mtime, mtimeNsec) {
+			return
+		}
+	} else {
+		var v string
+		v, err = xattr.Get(path, x.name)
+		if err == nil && strings.EqualFold(strings.TrimSpace(v), hash) {
+			return
+		}
+	}
+	if err != nil && !errors.Is(err, xattr.ErrNotSet) {
+		x.fail(path, err)
+		return
+	}
+	x.store(path, algo, hash, mtime, mtimeNsec)
+}
+
+// current returns the SHA-256 in path's cshatag attributes if they were
+// computed at the file's present mtime, which cshatag takes as proof that
+// they still describe its contents.
+func (x *xattrTagger) current(path string, mtime, mtimeNsec int64) (string, bool) {
+	if !x.shatag {
+		return "", false
+	}
+	t, err := xattr.GetShatag(path)
+	if err != nil || len(t.SHA256) != 64 || !t.Current(mtime, mtimeNsec) {
+		return "", false
+	}
+	return t.SHA256, true
+}
+
+// fail warns about a write that failed. A filesystem without extended
+// attributes is only mentioned once, not for each of its files.
+func (x *xattrTagger) fail(path string, err error) {
+	x.failed.Add(1)
+	if errors.Is(err, xattr.ErrUnsupported) {
+		x.unsupported.Do(func() {
+			logx.PathWarnf(path, "cannot store hash in %s: %v (further files on such filesystems are skipped silently)\n", x.name, err)
+		})
+		return
+	}
+	logx.PathWarnf(path, "store hash in %s: %v\n", x.name, err)
+}
+
+// xattrNote describes how a verified file's xattr checksum compares with
+// the catalog and the contents, or returns "" when there is nothing to say.
+func xattrNote(r verifier.VerifyResult) string {
+	switch {
+	case r.XattrHash == "" || r.XattrStale:
+		return ""
+	case !r.XattrMismatch:
+		if r.NewHash != "" && r.NewHash != r.OldHash {
+			return "the xattr agrees with the contents, not with the catalog"
+		}
+		return ""
+	case strings.EqualFold(r.XattrHash, r.OldHash):
+		return "the xattr agrees with the catalog, not with the contents"
+	case r.OldHash == r.NewHash:
+		return "the xattr disagrees with the catalog and the contents (stale or damaged xattr)"
+	default:
+		return "the xattr matches neither the catalog nor the contents"
+	}
+}
@@ -1,12 +1,20 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"math"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
+
+	"github.com/maisi/unraid-filehasher/internal/logx"
 )
 
 // FileRecord represents a single file entry in the catalog.
@@ -15,23 +23,97 @@ type FileRecord struct {
 	Path         string
 	Disk         string
 	Size         int64
-	Mtime        int64
+	Mtime        int64 // seconds
+	MtimeNsec    int64 // sub-second part; 0 for rows cataloged before it was tracked
 	SHA256       string
 	FirstSeen    time.Time
 	LastVerified time.Time
-	Status       string // ok, corrupted, missing, new, moved
+	Status       string // ok, corrupted, changed, error, acknowledged, missing, new, moved
+	Algo         string // algorithm that produced SHA256; the column name predates other algorithms
+
+	// Optional second checksum (e.g. crc32c) from the same read, checked by
+	// verify alongside SHA256. Both empty when none was stored.
+	SecondaryAlgo string
+	SecondaryHash string
+
+	// ChunkSize is non-zero for a chunked hash (scan --chunked): SHA256 is
+	// then the hash of the digests of each ChunkSize piece, listed in
+	// Chunks. Chunks is written by UpsertFileTx but not loaded by queries
+	// returning many records; use GetChunks.
+	ChunkSize int64
+	Chunks    []string
+
+	// Tag groups records by purpose in a shared catalog, e.g. "media" and
+	// "backups" (scan --tag). Empty when untagged.
+	Tag string
+
+	// Perms is the owner and mode recorded by scan --track-perms; nil for
+	// files scanned without it.
+	Perms *Perms
+}
+
+// Perms is a file's owner and permission bits, as stat(2) reports them.
+type Perms struct {
+	UID  uint32
+	GID  uint32
+	Mode uint32 // permission bits including setuid, setgid and sticky
 }
 
+// String formats p like "0644 1000:100".
+func (p Perms) String() string {
+	return fmt.Sprintf("%04o %d:%d", p.Mode, p.UID, p.GID)
+}
+
+// fileColumns is the column list scanFileRows expects, in order.
+const fileColumns = "id, path, disk, size, mtime, sha256, first_seen, last_verified, status, algo, mtime_nsec, secondary_algo, secondary_hash, chunk_size, tag, uid, gid, mode"
+
 // Stats holds aggregate statistics for the catalog.
 type Stats struct {
 	TotalFiles     int64
 	TotalSize      int64
 	OKFiles        int64
-	CorruptedFiles int64
+	CorruptedFiles int64 // excludes acknowledged files
+	ChangedFiles   int64 // hash mismatch with a newer mtime, most likely edited on purpose
+	ErrorFiles     int64 // could not be read on the last verify (status 'error')
 	MissingFiles   int64
 	NewFiles       int64
+	AckedFiles     int64 // corrupted files a user has reviewed (status 'acknowledged')
 	LastScan       *time.Time
 	LastVerify     *time.Time
+
+	// Verify coverage over present (non-missing) files: how many were
+	// verified in the last 30/90 days, as counts and percentages, and the
+	// least recent verification.
+	Verified30d    int64
+	Verified90d    int64
+	Coverage30d    float64
+	Coverage90d    float64
+	OldestVerified *time.Time
+
+	// Space taken by extra copies of duplicate files (every copy in a set
+	// of identical non-missing files but one) and how many such copies
+	// there are. Only filled in with StatsOptions.Duplicates.
+	DuplicateFiles   int64
+	ReclaimableBytes int64
+}
+
+// StatsOptions selects the optional, more expensive parts of GetStats, and
+// which files GetStats and GetDiskStats count.
+type StatsOptions struct {
+	// Duplicates fills in DuplicateFiles and ReclaimableBytes, which
+	// groups the whole catalog by hash.
+	Duplicates bool
+	// Tag counts only files with this tag; empty counts all.
+	Tag string
+}
+
+// fileFilter returns a WHERE condition on files (aliased as prefix, if
+// any) selecting the files o counts, and its arguments.
+func (o StatsOptions) fileFilter(prefix string) (string, []interface{}) {
+	if o.Tag == "" {
+		return "1 = 1", nil
+	}
+	return prefix + "tag = ?", []interface{}{o.Tag}
 }
 
 // DiskStats holds per-disk statistics.
@@ -42,35 +124,190 @@ type DiskStats struct {
 	CorruptedFiles int64
 	MissingFiles   int64
 	LastVerified   *time.Time
+
+	// DiskType is the type recorded by the last --auto scan ("HDD", "SSD"),
+	// or "" if the disk was never auto-detected.
+	DiskType string
+	// CorruptedPct is the share of present (non-missing) files that are
+	// corrupted, and Health summarises it: "good" with no corruption,
+	// "degraded" below HealthFailingPct, "failing" at or above it.
+	CorruptedPct float64
+	Health       string
+}
+
+// Disk is an auto-detected disk as last seen by a scan.
+type Disk struct {
+	Name     string
+	Path     string
+	Type     string // "HDD", "SSD" or "unknown"
+	LastSeen time.Time
+}
+
+// HealthFailingPct is the corrupted-file percentage at which a disk's health
+// is reported as "failing" rather than "degraded".
+const HealthFailingPct = 1.0
+
+// StatsSnapshot is a point-in-time copy of the catalog totals, recorded at the
+// end of each scan/verify so corruption can be graphed over time.
+type StatsSnapshot struct {
+	TakenAt    time.Time
+	TotalFiles int64
+	TotalSize  int64
+	Corrupted  int64
+	Missing    int64
+}
+
+// ExtStat aggregates tracked files sharing one extension.
+type ExtStat struct {
+	Ext       string // lowercased, with the dot (".mkv"); "" for files without one
+	Files     int64
+	TotalSize int64
+}
+
+// FileChange is one entry in a file's hash history.
+type FileChange struct {
+	Path      string
+	ChangedAt time.Time
+	Reason    string // e.g. "rehash" or "corrupted"
+	OldSHA256 string
+	NewSHA256 string
+	OldSize   int64
+	NewSize   int64
+}
+
+// SameMtime reports whether a stored mtime matches a freshly stat'ed one.
+// Rows cataloged before sub-second precision was tracked have a stored
+// nanosecond part of 0 and compare on seconds alone, so upgrading doesn't
+// force a full re-hash; they gain precision the next time they are hashed.
+func SameMtime(storedSec, storedNsec, sec, nsec int64) bool {
+	return storedSec == sec && (storedNsec == 0 || storedNsec == nsec)
+}
+
+// MtimeAfter reports whether a freshly stat'ed mtime is later than the stored
+// one. Like SameMtime, a stored nanosecond part of 0 compares on seconds only.
+func MtimeAfter(storedSec, storedNsec, sec, nsec int64) bool {
+	if sec != storedSec {
+		return sec > storedSec
+	}
+	return storedNsec != 0 && nsec > storedNsec
+}
+
+// DefaultPathBase is the base that relative catalog paths are resolved
+// against when none is configured. On Unraid, a relative path therefore
+// starts with the disk name: "disk1/Movies/a.mkv".
+const DefaultPathBase = "/mnt"
+
+// RelativePath converts an absolute file path to the relative form stored
+// by --path-mode relative, i.e. its path below base.
+func RelativePath(abs, base string) (string, error) {
+	rel, err := filepath.Rel(base, abs)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("%s is not under path base %s", abs, base)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// AbsolutePath returns the on-disk location of a catalog path. Absolute
+// paths are returned unchanged; relative ones are joined onto base.
+func AbsolutePath(stored, base string) string {
+	if filepath.IsAbs(stored) {
+		return stored
+	}
+	return filepath.Join(base, filepath.FromSlash(stored))
 }
 
 // DB wraps the SQLite database connection.
 type DB struct {
 	conn *sql.DB
+	path string
 }
 
-// Open opens or creates the SQLite database at the given path.
-func Open(path string) (*DB, error) {
-	conn, err := sql.Open("sqlite", path+"?_journal_mode=WAL&_busy_timeout=5000")
-	if err != nil {
-		return nil, fmt.Errorf("open database: %w", err)
+// Options tunes the SQLite connection. Zero fields take the defaults.
+type Options struct {
+	// BusyTimeout is how long a statement waits for a lock held by another
+	// connection (e.g. the dashboard during a scan) before failing with
+	// "database is locked".
+	BusyTimeout time.Duration
+	// CacheSizeKB is the page cache size per connection, in KiB.
+	CacheSizeKB int
+	// Synchronous is SQLite's synchronous setting: OFF, NORMAL or FULL.
+	// NORMAL is safe with WAL; FULL also survives power loss of the last
+	// commits.
+	Synchronous string
+}
+
+// Default connection settings.
+const (
+	DefaultBusyTimeout = 5 * time.Second
+	DefaultCacheSizeKB = 64000
+	DefaultSynchronous = "NORMAL"
+)
+
+// withDefaults fills zero fields and validates the rest.
+func (o Options) withDefaults() (Options, error) {
+	if o.BusyTimeout == 0 {
+		o.BusyTimeout = DefaultBusyTimeout
+	}
+	if o.CacheSizeKB == 0 {
+		o.CacheSizeKB = DefaultCacheSizeKB
+	}
+	if o.Synchronous == "" {
+		o.Synchronous = DefaultSynchronous
 	}
+	o.Synchronous = strings.ToUpper(o.Synchronous)
+	switch {
+	case o.BusyTimeout < 0:
+		return o, fmt.Errorf("invalid busy timeout %s (must be positive)", o.BusyTimeout)
+	case o.CacheSizeKB < 0:
+		return o, fmt.Errorf("invalid cache size %d KiB (must be positive)", o.CacheSizeKB)
+	case o.Synchronous != "OFF" && o.Synchronous != "NORMAL" && o.Synchronous != "FULL":
+		return o, fmt.Errorf("invalid synchronous mode %q (expected off|normal|full)", o.Synchronous)
+	}
+	return o, nil
+}
+
+// MemoryPath opens a private in-memory database instead of a file, e.g. for
+// tests. It lives on a single connection, so a write transaction makes every
+// other query wait until it commits.
+const MemoryPath = ":memory:"
 
-	// Set pragmas for performance
+// Open opens or creates the SQLite database at the given path, or an
+// in-memory one for MemoryPath.
+func Open(path string, opts Options) (*DB, error) {
+	opts, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	// Pragmas are per connection, so they go in the DSN where the driver
+	// applies them to every connection in the pool, not just the first one.
+	// busy_timeout makes a writer wait for the lock instead of failing with
+	// "database is locked"; _txlock=immediate takes the write lock at BEGIN,
+	// where the busy timeout applies, rather than on the first write.
 	pragmas := []string{
-		"PRAGMA journal_mode=WAL",
-		"PRAGMA synchronous=NORMAL",
-		"PRAGMA cache_size=-64000", // 64MB cache
-		"PRAGMA foreign_keys=ON",
+		fmt.Sprintf("busy_timeout(%d)", opts.BusyTimeout.Milliseconds()),
+		"journal_mode(WAL)",
+		"synchronous(" + opts.Synchronous + ")",
+		fmt.Sprintf("cache_size(-%d)", opts.CacheSizeKB), // negative: KiB, not pages
+		"foreign_keys(ON)",
 	}
+	dsn := path + "?_txlock=immediate"
 	for _, p := range pragmas {
-		if _, err := conn.Exec(p); err != nil {
-			conn.Close()
-			return nil, fmt.Errorf("set pragma %q: %w", p, err)
-		}
+		dsn += "&_pragma=" + p
+	}
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	if path == MemoryPath {
+		// Each connection to :memory: gets a database of its own
+		conn.SetMaxOpenConns(1)
+	}
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("open database: %w", err)
 	}
 
-	db := &DB{conn: conn}
+	db := &DB{conn: conn, path: path}
 	if err := db.migrate(); err != nil {
 		conn.Close()
 		return nil, fmt.Errorf("migrate: %w", err)
@@ -79,12 +316,83 @@ func Open(path string) (*DB, error) {
 	return db, nil
 }
 
+// Path returns the path the database was opened with (MemoryPath for an
+// in-memory one).
+func (db *DB) Path() string {
+	return db.path
+}
+
 // Close closes the database connection.
 func (db *DB) Close() error {
 	return db.conn.Close()
 }
 
-func (db *DB) migrate() error {
+// Ping checks that the database answers a trivial query.
+func (db *DB) Ping(ctx context.Context) error {
+	var one int
+	return db.conn.QueryRowContext(ctx, `SELECT 1`).Scan(&one)
+}
+
+// Vacuum rebuilds the database file to reclaim space left by deleted rows,
+// refreshes query planner statistics, and truncates the WAL. VACUUM holds an
+// exclusive lock for its whole run and needs free space about the size of
+// the database for the rebuilt copy.
+func (db *DB) Vacuum() error {
+	for _, stmt := range []string{
+		"VACUUM",
+		"PRAGMA optimize",
+		"PRAGMA wal_checkpoint(TRUNCATE)",
+	} {
+		if _, err := db.conn.Exec(stmt); err != nil {
+			return fmt.Errorf("%s: %w", stmt, err)
+		}
+	}
+	return nil
+}
+
+// BackupTo writes a consistent copy of the database to path with VACUUM INTO,
+// while other connections keep reading and writing. The copy is compacted
+// and has no WAL of its own, so it is a single self-contained file. path
+// must not exist yet.
+func (db *DB) BackupTo(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if _, err := db.conn.Exec(`VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("backup to %s: %w", path, err)
+	}
+	return nil
+}
+
+// IntegrityCheck runs PRAGMA integrity_check and returns the problems it
+// reports. An empty result means the database is intact.
+func (db *DB) IntegrityCheck() ([]string, error) {
+	rows, err := db.conn.Query("PRAGMA integrity_check")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var msg string
+		if err := rows.Scan(&msg); err != nil {
+			return nil, err
+		}
+		if msg != "ok" {
+			problems = append(problems, msg)
+		}
+	}
+	return problems, rows.Err()
+}
+
+// baseSchema is migration 1: the tables and columns from before the schema
+// was versioned, with hashes stored as bytes. Catalogs of that era report
+// user_version 0 whatever columns they had gained, hence the IF NOT EXISTS
+// and addColumnIfMissing throughout.
+func baseSchema(tx *sql.Tx) error {
 	schema := `
 	CREATE TABLE IF NOT EXISTS files (
 		id            INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -101,6 +409,31 @@ func (db *DB) migrate() error {
 	CREATE INDEX IF NOT EXISTS idx_files_disk ON files(disk);
 	CREATE INDEX IF NOT EXISTS idx_files_status ON files(status);
 	CREATE INDEX IF NOT EXISTS idx_files_sha256 ON files(sha256);
+	CREATE INDEX IF NOT EXISTS idx_files_size ON files(size);
+
+	CREATE TABLE IF NOT EXISTS stats_snapshots (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		taken_at    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		total_files INTEGER NOT NULL,
+		total_size  INTEGER NOT NULL,
+		corrupted   INTEGER NOT NULL,
+		missing     INTEGER NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_stats_snapshots_taken_at ON stats_snapshots(taken_at);
+
+	CREATE TABLE IF NOT EXISTS file_history (
+		id         INTEGER PRIMARY KEY AUTOINCREMENT,
+		path       TEXT NOT NULL,
+		changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		reason     TEXT NOT NULL,
+		old_sha256 TEXT NOT NULL,
+		new_sha256 TEXT NOT NULL,
+		old_size   INTEGER NOT NULL,
+		new_size   INTEGER NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_file_history_path ON file_history(path);
 
 	CREATE TABLE IF NOT EXISTS scan_history (
 		id         INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -112,9 +445,98 @@ func (db *DB) migrate() error {
 		errors     INTEGER DEFAULT 0,
 		status     TEXT NOT NULL DEFAULT 'running'
 	);
+
+	CREATE TABLE IF NOT EXISTS scan_errors (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		scan_id     INTEGER NOT NULL REFERENCES scan_history(id) ON DELETE CASCADE,
+		path        TEXT NOT NULL,
+		message     TEXT NOT NULL,
+		occurred_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_scan_errors_scan_id ON scan_errors(scan_id);
+
+	CREATE TABLE IF NOT EXISTS disks (
+		name            TEXT PRIMARY KEY,
+		path            TEXT NOT NULL,
+		disk_type       TEXT NOT NULL,
+		last_seen       TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
 	`
-	_, err := db.conn.Exec(schema)
-	return err
+	if _, err := tx.Exec(schema); err != nil {
+		return err
+	}
+
+	// Columns added after the initial schema. ALTER TABLE has no IF NOT EXISTS,
+	// so check table_info first to keep the step idempotent.
+	if err := addColumnIfMissing(tx, "files", "algo", "TEXT NOT NULL DEFAULT 'sha256'"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(tx, "files", "mtime_nsec", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(tx, "files", "secondary_algo", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(tx, "files", "secondary_hash", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(tx, "files", "chunk_size", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	// Comma-separated hex digests of each chunk; empty unless chunk_size > 0.
+	if err := addColumnIfMissing(tx, "files", "chunks", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	// NULL for untagged files.
+	if err := addColumnIfMissing(tx, "files", "tag", "TEXT"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_files_tag ON files(tag)`); err != nil {
+		return err
+	}
+	// Older history rows keep 0 here: throughput unknown.
+	if err := addColumnIfMissing(tx, "scan_history", "bytes_processed", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(tx, "scan_history", "duration_ms", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	// Empty for runs recorded before they were tracked.
+	if err := addColumnIfMissing(tx, "scan_history", "version", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if err := addColumnIfMissing(tx, "scan_history", "algo", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	return hashesToBinary(tx)
+}
+
+// addColumnIfMissing adds a column to an existing table unless it is already present.
+func addColumnIfMissing(tx *sql.Tx, table, column, decl string) error {
+	rows, err := tx.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return fmt.Errorf("inspect %s: %w", table, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, decl)); err != nil {
+		return fmt.Errorf("add column %s.%s: %w", table, column, err)
+	}
+	return nil
 }
 
 // BeginBatch starts a transaction for batch operations.
@@ -122,140 +544,747 @@ func (db *DB) BeginBatch() (*sql.Tx, error) {
 	return db.conn.Begin()
 }
 
-// UpsertFileTx inserts or updates a file record within a transaction.
+// UpsertFileTx inserts or updates a file record within a transaction. A
+// file still "new" stays new when re-hashed as ok; only verify promotes it.
+// An empty Tag keeps the tag the file already has, and nil Perms the
+// recorded owner and mode.
 func (db *DB) UpsertFileTx(tx *sql.Tx, f *FileRecord) error {
+	uid, gid, mode := permsArgs(f.Perms)
 	_, err := tx.Exec(`
-		INSERT INTO files (path, disk, size, mtime, sha256, first_seen, last_verified, status)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO files (path, disk, size, mtime, sha256, first_seen, last_verified, status, algo, mtime_nsec,
+			secondary_algo, secondary_hash, chunk_size, chunks, tag, uid, gid, mode)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(path) DO UPDATE SET
 			disk = excluded.disk,
 			size = excluded.size,
 			mtime = excluded.mtime,
+			mtime_nsec = excluded.mtime_nsec,
 			sha256 = excluded.sha256,
 			last_verified = excluded.last_verified,
-			status = excluded.status
-	`, f.Path, f.Disk, f.Size, f.Mtime, f.SHA256, f.FirstSeen, f.LastVerified, f.Status)
+			status = CASE WHEN files.status = 'new' AND excluded.status = 'ok' THEN 'new' ELSE excluded.status END,
+			algo = excluded.algo,
+			secondary_algo = excluded.secondary_algo,
+			secondary_hash = excluded.secondary_hash,
+			chunk_size = excluded.chunk_size,
+			chunks = excluded.chunks,
+			tag = COALESCE(excluded.tag, files.tag),
+			uid = COALESCE(excluded.uid, files.uid),
+			gid = COALESCE(excluded.gid, files.gid),
+			mode = COALESCE(excluded.mode, files.mode)
+	`, f.Path, f.Disk, f.Size, f.Mtime, hashToDB(f.SHA256), f.FirstSeen, f.LastVerified, f.Status, algoOrDefault(f.Algo), f.MtimeNsec,
+		f.SecondaryAlgo, f.SecondaryHash, f.ChunkSize, strings.Join(f.Chunks, ","), nullIfEmpty(f.Tag), uid, gid, mode)
+	return err
+}
+
+// permsArgs maps p to the uid, gid and mode column values, all NULL for nil.
+func permsArgs(p *Perms) (uid, gid, mode interface{}) {
+	if p == nil {
+		return nil, nil, nil
+	}
+	return p.UID, p.GID, p.Mode
+}
+
+// SetPermsTx records the owner and mode of a tracked file, for files a scan
+// --track-perms skipped because their contents hadn't changed.
+func (db *DB) SetPermsTx(tx *sql.Tx, path string, p Perms) error {
+	_, err := tx.Exec(`UPDATE files SET uid = ?, gid = ?, mode = ? WHERE path = ?`, p.UID, p.GID, p.Mode, path)
+	return err
+}
+
+// GetFilesWithPerms returns the records with a recorded owner and mode,
+// optionally on one disk, ordered by path.
+func (db *DB) GetFilesWithPerms(disk string) ([]*FileRecord, error) {
+	rows, err := db.conn.Query(`
+		SELECT `+fileColumns+`
+		FROM files
+		WHERE mode IS NOT NULL AND (? = '' OR disk = ?)
+		ORDER BY path
+	`, disk, disk)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanFileRows(rows)
+}
+
+// nullIfEmpty maps "" to SQL NULL.
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// SetTagTx tags the tracked files at paths, for files a tagged scan skipped
+// because they hadn't changed.
+func (db *DB) SetTagTx(tx *sql.Tx, tag string, paths ...string) error {
+	stmt, err := tx.Prepare(`UPDATE files SET tag = ? WHERE path = ?`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, p := range paths {
+		if _, err := stmt.Exec(nullIfEmpty(tag), p); err != nil {
+			return fmt.Errorf("tag %s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// GetTags returns the distinct tags in use, sorted.
+func (db *DB) GetTags() ([]string, error) {
+	rows, err := db.conn.Query(`SELECT DISTINCT tag FROM files WHERE tag IS NOT NULL ORDER BY tag`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tags []string
+	for rows.Next() {
+		var t string
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// GetFilesByTag returns the records tagged tag, optionally on one disk,
+// ordered least-recently-verified first like GetFilesByLastVerified.
+func (db *DB) GetFilesByTag(tag, disk string) ([]*FileRecord, error) {
+	rows, err := db.conn.Query(`
+		SELECT `+fileColumns+`
+		FROM files
+		WHERE tag = ? AND (? = '' OR disk = ?)
+		ORDER BY last_verified ASC, path
+	`, tag, disk, disk)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanFileRows(rows)
+}
+
+// GetChunks returns the chunk digests of path's chunked hash, or nil if it
+// isn't tracked or wasn't hashed in chunks.
+func (db *DB) GetChunks(path string) ([]string, error) {
+	var chunks string
+	err := db.conn.QueryRow(`SELECT chunks FROM files WHERE path = ?`, path).Scan(&chunks)
+	if err == sql.ErrNoRows || chunks == "" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(chunks, ","), nil
+}
+
+// algoOrDefault maps an empty algorithm to sha256, the catalog's original algorithm.
+func algoOrDefault(algo string) string {
+	if algo == "" {
+		return "sha256"
+	}
+	return algo
+}
+
+// QuickLookup holds minimal file info for incremental scan comparison.
+type QuickLookup struct {
+	Size      int64
+	Mtime     int64
+	MtimeNsec int64
+	SHA256    string
+	Algo      string
+	ChunkSize int64
+	Tag       string
+	Perms     *Perms // nil if none was recorded
+}
+
+// LoadQuickLookupMap loads all file records into a map for fast path-based lookups.
+// This is much more efficient than per-file queries when scanning large directories.
+func (db *DB) LoadQuickLookupMap() (map[string]*QuickLookup, error) {
+	rows, err := db.conn.Query(`SELECT path, size, mtime, mtime_nsec, sha256, algo, chunk_size, COALESCE(tag, ''), uid, gid, mode FROM files`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	m := make(map[string]*QuickLookup)
+	for rows.Next() {
+		var path string
+		var ql QuickLookup
+		var perms nullPerms
+		if err := rows.Scan(&path, &ql.Size, &ql.Mtime, &ql.MtimeNsec, hexHash{&ql.SHA256}, &ql.Algo, &ql.ChunkSize, &ql.Tag,
+			&perms.uid, &perms.gid, &perms.mode); err != nil {
+			return nil, err
+		}
+		ql.Perms = perms.get()
+		m[path] = &ql
+	}
+	return m, rows.Err()
+}
+
+// GetFilesByDisk returns all file records on a given disk.
+func (db *DB) GetFilesByDisk(disk string) ([]*FileRecord, error) {
+	rows, err := db.conn.Query(`
+		SELECT `+fileColumns+`
+		FROM files WHERE disk = ?
+		ORDER BY path
+	`, disk)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFileRows(rows)
+}
+
+// GetFilesByStatus returns all file records with a given status.
+func (db *DB) GetFilesByStatus(status string) ([]*FileRecord, error) {
+	rows, err := db.conn.Query(`
+		SELECT `+fileColumns+`
+		FROM files WHERE status = ?
+		ORDER BY path
+	`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFileRows(rows)
+}
+
+// GetNewlyCorrupted returns the files a verify found corrupted at or after
+// since that are still corrupted, most recently verified first. Files that
+// were already corrupted before since are left out, however often a verify
+// has found them corrupted again since.
+func (db *DB) GetNewlyCorrupted(since time.Time) ([]*FileRecord, error) {
+	rows, err := db.conn.Query(`
+		SELECT `+fileColumns+`
+		FROM files WHERE status = 'corrupted' AND path IN (
+			SELECT path FROM file_history WHERE reason = 'corrupted' AND changed_at >= ?
+		)
+		ORDER BY last_verified DESC, path
+	`, since.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFileRows(rows)
+}
+
+// ListFiles returns records ordered by path, optionally narrowed to a status
+// and/or disk (empty means any) and capped at limit rows (0 means no cap).
+func (db *DB) ListFiles(status, disk string, limit int) ([]*FileRecord, error) {
+	query, args := listFilesQuery(status, disk, limit)
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFileRows(rows)
+}
+
+// listFilesQuery builds ListFiles' query. Only the filters given become
+// conditions, so SQLite can use idx_files_disk_status; guards that let an
+// empty filter match everything would keep it from using any index.
+func listFilesQuery(status, disk string, limit int) (string, []interface{}) {
+	if limit <= 0 {
+		limit = -1 // SQLite: no limit
+	}
+	where := "1 = 1"
+	var args []interface{}
+	if status != "" {
+		where += " AND status = ?"
+		args = append(args, status)
+	}
+	if disk != "" {
+		where += " AND disk = ?"
+		args = append(args, disk)
+	}
+	return `SELECT ` + fileColumns + ` FROM files WHERE ` + where + ` ORDER BY path LIMIT ?`, append(args, limit)
+}
+
+// GetAllFiles returns all file records for verification.
+func (db *DB) GetAllFiles() ([]*FileRecord, error) {
+	rows, err := db.conn.Query(`
+		SELECT ` + fileColumns + `
+		FROM files
+		ORDER BY path
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFileRows(rows)
+}
+
+// GetFilesByLastVerified returns file records (optionally limited to one disk)
+// ordered least-recently-verified first.
+func (db *DB) GetFilesByLastVerified(disk string) ([]*FileRecord, error) {
+	rows, err := db.conn.Query(`
+		SELECT `+fileColumns+`
+		FROM files
+		WHERE (? = '' OR disk = ?)
+		ORDER BY last_verified ASC, path
+	`, disk, disk)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFileRows(rows)
+}
+
+// GetFilesBySize returns the limit largest files (order "desc") or smallest
+// ("asc"), optionally on one disk, ties broken by path. Missing files take no
+// space and are left out.
+func (db *DB) GetFilesBySize(order string, limit int, disk string) ([]*FileRecord, error) {
+	if order != "asc" && order != "desc" {
+		return nil, fmt.Errorf("invalid size order %q (expected asc or desc)", order)
+	}
+	if limit <= 0 {
+		limit = -1 // SQLite: no limit
+	}
+	rows, err := db.conn.Query(`
+		SELECT `+fileColumns+`
+		FROM files
+		WHERE status != 'missing' AND (? = '' OR disk = ?)
+		ORDER BY size `+order+`, path
+		LIMIT ?
+	`, disk, disk, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFileRows(rows)
+}
+
+// GetFilesModifiedSince returns files (optionally limited to one disk)
+// whose stored mtime is at or after t, ordered by path.
+func (db *DB) GetFilesModifiedSince(t time.Time, disk string) ([]*FileRecord, error) {
+	rows, err := db.conn.Query(`
+		SELECT `+fileColumns+`
+		FROM files
+		WHERE mtime >= ? AND (? = '' OR disk = ?)
+		ORDER BY path
+	`, t.Unix(), disk, disk)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFileRows(rows)
+}
+
+// GetStaleFiles returns files (optionally limited to one disk) whose
+// last_verified is older than olderThan, oldest first, capped at limit rows
+// (0 means no cap). Missing files are left out: there is nothing to verify.
+func (db *DB) GetStaleFiles(olderThan time.Duration, disk string, limit int) ([]*FileRecord, error) {
+	if limit <= 0 {
+		limit = -1 // SQLite: no limit
+	}
+	cutoff := time.Now().Add(-olderThan).UTC().Format("2006-01-02 15:04:05")
+	rows, err := db.conn.Query(`
+		SELECT `+fileColumns+`
+		FROM files
+		WHERE last_verified < ? AND status != 'missing' AND (? = '' OR disk = ?)
+		ORDER BY last_verified ASC, path
+		LIMIT ?
+	`, cutoff, disk, disk, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFileRows(rows)
+}
+
+// CountFiles returns the number of tracked files, optionally limited to one disk.
+func (db *DB) CountFiles(disk string) (int64, error) {
+	var n int64
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM files WHERE (? = '' OR disk = ?)`, disk, disk).Scan(&n)
+	return n, err
+}
+
+// iteratePageSize is how many records IterateFiles reads per query.
+const iteratePageSize = 1000
+
+// IterateAllFiles calls fn for every tracked file, in insertion order,
+// without loading the whole catalog: only one page of records is held at a
+// time. An error from fn stops the iteration and is returned.
+func (db *DB) IterateAllFiles(fn func(*FileRecord) error) error {
+	return db.IterateFiles("", fn)
+}
+
+// IterateFiles is IterateAllFiles for the files on disk, or on every disk
+// when disk is empty. Each page is a query of its own, so no read
+// transaction stays open while fn runs; a record changed meanwhile is seen
+// as it was when its page was read.
+func (db *DB) IterateFiles(disk string, fn func(*FileRecord) error) error {
+	// "+disk" keeps SQLite walking the rowid from the last page on, rather
+	// than reading (and sorting) the disk's whole index range for every page
+	query := `SELECT ` + fileColumns + ` FROM files WHERE id > ?`
+	if disk != "" {
+		query += ` AND +disk = ?`
+	}
+	query += ` ORDER BY id LIMIT ?`
+
+	var after int64
+	for {
+		args := []interface{}{after}
+		if disk != "" {
+			args = append(args, disk)
+		}
+		rows, err := db.conn.Query(query, append(args, iteratePageSize)...)
+		if err != nil {
+			return err
+		}
+		page, err := scanFileRows(rows)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+		for _, f := range page {
+			if err := fn(f); err != nil {
+				return err
+			}
+		}
+		if len(page) < iteratePageSize {
+			return nil
+		}
+		after = page[len(page)-1].ID
+	}
+}
+
+// GetTrackedDisks returns the names of the disks that have tracked files.
+func (db *DB) GetTrackedDisks() ([]string, error) {
+	rows, err := db.conn.Query(`SELECT DISTINCT disk FROM files ORDER BY disk`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var disks []string
+	for rows.Next() {
+		var disk string
+		if err := rows.Scan(&disk); err != nil {
+			return nil, err
+		}
+		disks = append(disks, disk)
+	}
+	return disks, rows.Err()
+}
+
+// GetFilesForSampledVerify returns roughly percent% of the tracked files
+// (optionally limited to one disk), least-recently-verified first.
+// Rows sharing the same last_verified time are shuffled with a seed derived
+// from the current date, so repeated daily runs over a freshly scanned catalog
+// still walk through different files.
+func (db *DB) GetFilesForSampledVerify(disk string, percent float64) ([]*FileRecord, error) {
+	if percent <= 0 || percent > 100 {
+		return nil, fmt.Errorf("sample percent %.2f out of range (0, 100]", percent)
+	}
+	total, err := db.CountFiles(disk)
+	if err != nil {
+		return nil, fmt.Errorf("count files: %w", err)
+	}
+	limit := int64(math.Ceil(float64(total) * percent / 100))
+	if limit == 0 {
+		return nil, nil
+	}
+
+	seed, _ := strconv.ParseInt(time.Now().Format("20060102"), 10, 64)
+	rows, err := db.conn.Query(`
+		SELECT `+fileColumns+`
+		FROM files
+		WHERE (? = '' OR disk = ?)
+		ORDER BY last_verified ASC, (id * ?) % 1000003
+		LIMIT ?
+	`, disk, disk, seed, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanFileRows(rows)
+}
+
+// GetAllFilesPaginated returns a page of file records with total count.
+func (db *DB) GetAllFilesPaginated(limit, offset int) ([]*FileRecord, int64, error) {
+	var total int64
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM files`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := db.conn.Query(`
+		SELECT `+fileColumns+`
+		FROM files
+		ORDER BY path
+		LIMIT ? OFFSET ?
+	`, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	files, err := scanFileRows(rows)
+	return files, total, err
+}
+
+// UpdateStatusTx updates the status and last_verified time within a
+// transaction. A file turning corrupted is recorded in file_history.
+func (db *DB) UpdateStatusTx(tx *sql.Tx, path, status string) error {
+	if status == "corrupted" {
+		if _, err := tx.Exec(`
+			INSERT INTO file_history (path, reason, old_sha256, new_sha256, old_size, new_size)
+			SELECT path, 'corrupted', sha256, sha256, size, size FROM files WHERE path = ? AND status != 'corrupted'
+		`, path); err != nil {
+			return err
+		}
+	}
+	_, err := tx.Exec(`
+		UPDATE files SET status = ?, last_verified = CURRENT_TIMESTAMP
+		WHERE path = ?
+	`, status, path)
 	return err
 }
 
-// QuickLookup holds minimal file info for incremental scan comparison.
-type QuickLookup struct {
-	Size   int64
-	Mtime  int64
-	SHA256 string
+// TouchVerifiedTx sets only the last_verified time, for a file verified
+// without a change of status.
+func (db *DB) TouchVerifiedTx(tx *sql.Tx, path string) error {
+	_, err := tx.Exec(`UPDATE files SET last_verified = CURRENT_TIMESTAMP WHERE path = ?`, path)
+	return err
 }
 
-// LoadQuickLookupMap loads all file records into a map for fast path-based lookups.
-// This is much more efficient than per-file queries when scanning large directories.
-func (db *DB) LoadQuickLookupMap() (map[string]*QuickLookup, error) {
-	rows, err := db.conn.Query(`SELECT path, size, mtime, sha256 FROM files`)
+// GetFileByPath returns the record for path, or nil if it isn't tracked.
+func (db *DB) GetFileByPath(path string) (*FileRecord, error) {
+	rows, err := db.conn.Query(`
+		SELECT `+fileColumns+`
+		FROM files WHERE path = ?
+	`, path)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-
-	m := make(map[string]*QuickLookup)
-	for rows.Next() {
-		var path string
-		var ql QuickLookup
-		if err := rows.Scan(&path, &ql.Size, &ql.Mtime, &ql.SHA256); err != nil {
-			return nil, err
-		}
-		m[path] = &ql
+	files, err := scanFileRows(rows)
+	if err != nil || len(files) == 0 {
+		return nil, err
 	}
-	return m, rows.Err()
+	return files[0], nil
 }
 
-// GetFilesByDisk returns all file records on a given disk.
-func (db *DB) GetFilesByDisk(disk string) ([]*FileRecord, error) {
+// GetFilesMatching returns records whose path equals one of paths or matches
+// one of globs (SQLite GLOB syntax, where * also matches /), optionally
+// narrowed to a disk and/or status. Empty disk or status means any.
+func (db *DB) GetFilesMatching(paths, globs []string, disk, status string) ([]*FileRecord, error) {
+	var conds []string
+	var args []interface{}
+	for _, p := range paths {
+		conds = append(conds, "path = ?")
+		args = append(args, p)
+	}
+	for _, g := range globs {
+		conds = append(conds, "path GLOB ?")
+		args = append(args, g)
+	}
+	where := "1 = 1"
+	if len(conds) > 0 {
+		where = "(" + strings.Join(conds, " OR ") + ")"
+	}
+	if disk != "" {
+		where += " AND disk = ?"
+		args = append(args, disk)
+	}
+	if status != "" {
+		where += " AND status = ?"
+		args = append(args, status)
+	}
+
 	rows, err := db.conn.Query(`
-		SELECT id, path, disk, size, mtime, sha256, first_seen, last_verified, status
-		FROM files WHERE disk = ?
+		SELECT `+fileColumns+`
+		FROM files WHERE `+where+`
 		ORDER BY path
-	`, disk)
+	`, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-
 	return scanFileRows(rows)
 }
 
-// GetFilesByStatus returns all file records with a given status.
-func (db *DB) GetFilesByStatus(status string) ([]*FileRecord, error) {
-	rows, err := db.conn.Query(`
-		SELECT id, path, disk, size, mtime, sha256, first_seen, last_verified, status
-		FROM files WHERE status = ?
-		ORDER BY path
-	`, status)
+// RebaselineFileTx trusts the file's current contents: it stores the new
+// hash, secondary checksum, size and mtime, resets status to ok, and records
+// the change in file_history when the hash or size differs. newChunks are
+// the chunk digests when the new hash is a chunked one of old.ChunkSize
+// pieces; nil stores a whole-file hash and drops any chunk list.
+func (db *DB) RebaselineFileTx(tx *sql.Tx, old *FileRecord, newSHA256, newSecondary string, newChunks []string, newSize, newMtime, newMtimeNsec int64) error {
+	var chunkSize int64
+	if newChunks != nil {
+		chunkSize = old.ChunkSize
+	}
+	if _, err := tx.Exec(`
+		UPDATE files
+		SET sha256 = ?, secondary_hash = ?, size = ?, mtime = ?, mtime_nsec = ?, status = 'ok', last_verified = CURRENT_TIMESTAMP,
+			chunk_size = ?, chunks = ?
+		WHERE path = ?
+	`, hashToDB(newSHA256), newSecondary, newSize, newMtime, newMtimeNsec, chunkSize, strings.Join(newChunks, ","), old.Path); err != nil {
+		return err
+	}
+	if newSHA256 == old.SHA256 && newSize == old.Size {
+		return nil
+	}
+	_, err := tx.Exec(`
+		INSERT INTO file_history (path, reason, old_sha256, new_sha256, old_size, new_size)
+		VALUES (?, 'rehash', ?, ?, ?, ?)
+	`, old.Path, hashToDB(old.SHA256), hashToDB(newSHA256), old.Size, newSize)
+	return err
+}
+
+// MigrateHash swaps path's stored digest for one from another algorithm. The
+// caller must have confirmed the file still matches oldHash; the update only
+// applies while the record still holds oldAlgo/oldHash, so a concurrent
+// change is never overwritten. The swap is recorded in file_history.
+func (db *DB) MigrateHash(path, oldAlgo, oldHash, newAlgo, newHash string) error {
+	tx, err := db.conn.Begin()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
+	defer tx.Rollback()
 
-	return scanFileRows(rows)
+	res, err := tx.Exec(`
+		UPDATE files
+		SET algo = ?, sha256 = ?, last_verified = CURRENT_TIMESTAMP
+		WHERE path = ? AND algo = ? AND sha256 = ?
+	`, newAlgo, hashToDB(newHash), path, oldAlgo, hashToDB(oldHash))
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%s changed in the catalog during migration", path)
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO file_history (path, reason, old_sha256, new_sha256, old_size, new_size)
+		SELECT path, 'migrate:' || ? || '->' || ?, ?, ?, size, size FROM files WHERE path = ?
+	`, oldAlgo, newAlgo, hashToDB(oldHash), hashToDB(newHash), path); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-// GetAllFiles returns all file records for verification.
-func (db *DB) GetAllFiles() ([]*FileRecord, error) {
+// GetFileHistory returns the recorded hash changes for path, oldest first.
+func (db *DB) GetFileHistory(path string) ([]*FileChange, error) {
 	rows, err := db.conn.Query(`
-		SELECT id, path, disk, size, mtime, sha256, first_seen, last_verified, status
-		FROM files
-		ORDER BY path
-	`)
+		SELECT path, changed_at, reason, old_sha256, new_sha256, old_size, new_size
+		FROM file_history WHERE path = ?
+		ORDER BY changed_at, id
+	`, path)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	return scanFileRows(rows)
+	var changes []*FileChange
+	for rows.Next() {
+		c := &FileChange{}
+		var changedAt string
+		if err := rows.Scan(&c.Path, &changedAt, &c.Reason, hexHash{&c.OldSHA256}, hexHash{&c.NewSHA256}, &c.OldSize, &c.NewSize); err != nil {
+			return nil, err
+		}
+		if t, err := parseTime(changedAt); err == nil {
+			c.ChangedAt = t
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
 }
 
-// GetAllFilesPaginated returns a page of file records with total count.
-func (db *DB) GetAllFilesPaginated(limit, offset int) ([]*FileRecord, int64, error) {
-	var total int64
-	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM files`).Scan(&total); err != nil {
-		return nil, 0, err
+// FindDuplicates returns sets of at least minCount files that share a hash
+// and size, ignoring missing files and files smaller than minSize. Sets are
+// ordered by wasted space (size times extra copies), largest first; files
+// within a set are ordered by path.
+func (db *DB) FindDuplicates(minCount int, minSize int64) ([][]*FileRecord, error) {
+	if minCount < 2 {
+		minCount = 2
 	}
-
 	rows, err := db.conn.Query(`
-		SELECT id, path, disk, size, mtime, sha256, first_seen, last_verified, status
+		SELECT `+fileColumns+`
 		FROM files
-		ORDER BY path
-		LIMIT ? OFFSET ?
-	`, limit, offset)
+		WHERE status != 'missing' AND (sha256, size) IN (
+			SELECT sha256, size FROM files
+			WHERE status != 'missing' AND size >= ?
+			GROUP BY sha256, size
+			HAVING COUNT(*) >= ?
+		)
+		ORDER BY sha256, size, path
+	`, minSize, minCount)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 	defer rows.Close()
-
 	files, err := scanFileRows(rows)
-	return files, total, err
+	if err != nil {
+		return nil, err
+	}
+
+	var sets [][]*FileRecord
+	for i, f := range files {
+		if i == 0 || f.SHA256 != files[i-1].SHA256 || f.Size != files[i-1].Size {
+			sets = append(sets, nil)
+		}
+		sets[len(sets)-1] = append(sets[len(sets)-1], f)
+	}
+	sort.SliceStable(sets, func(i, j int) bool {
+		wi := sets[i][0].Size * int64(len(sets[i])-1)
+		wj := sets[j][0].Size * int64(len(sets[j])-1)
+		return wi > wj
+	})
+	return sets, nil
 }
 
-// UpdateStatusTx updates the status and last_verified time within a transaction.
-func (db *DB) UpdateStatusTx(tx *sql.Tx, path, status string) error {
-	_, err := tx.Exec(`
-		UPDATE files SET status = ?, last_verified = CURRENT_TIMESTAMP
-		WHERE path = ?
-	`, status, path)
-	return err
+// AcknowledgeFile marks a corrupted file as reviewed so it drops out of the
+// corrupted count. A later verify that hashes it correctly flips it back to ok.
+func (db *DB) AcknowledgeFile(path string) error {
+	res, err := db.conn.Exec(`
+		UPDATE files SET status = 'acknowledged'
+		WHERE path = ? AND status = 'corrupted'
+	`, path)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("%s is not a tracked corrupted file", path)
+	}
+	return nil
 }
 
 // FindMoveCandidates looks up existing records that could correspond to a moved file.
 // It matches by file basename (path suffix) + size, which is a reasonably strong heuristic
-// without needing to hash the whole catalog.
-func (db *DB) FindMoveCandidates(baseName string, size int64, limit int) ([]*FileRecord, error) {
+// without needing to hash the whole catalog. Only records hashed with the same
+// algo and chunkSize (0 for whole-file hashes) qualify, since other hashes can't
+// be compared.
+func (db *DB) FindMoveCandidates(baseName string, size int64, algo string, chunkSize int64, limit int) ([]*FileRecord, error) {
 	if limit <= 0 {
 		limit = 20
 	}
 	rows, err := db.conn.Query(`
-		SELECT id, path, disk, size, mtime, sha256, first_seen, last_verified, status
+		SELECT `+fileColumns+`
 		FROM files
-		WHERE size = ? AND path LIKE ?
+		WHERE size = ? AND path LIKE ? AND algo = ? AND chunk_size = ?
 		ORDER BY last_verified DESC
 		LIMIT ?
-	`, size, "%/"+baseName, limit)
+	`, size, "%/"+baseName, algoOrDefault(algo), chunkSize, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -263,52 +1292,71 @@ func (db *DB) FindMoveCandidates(baseName string, size int64, limit int) ([]*Fil
 	return scanFileRows(rows)
 }
 
+// PickMoveSource decides which of cands (from FindMoveCandidates) a newly
+// seen file at newPath was moved from. Only candidates whose old path is gone
+// qualify. A gone candidate with the same hash is returned as moved, however
+// far down the list it is; failing that, the first gone candidate is returned
+// as suspect (probably moved and changed on the way). Both are nil when no
+// candidate's old path is gone.
+func PickMoveSource(cands []*FileRecord, newPath, newSHA256 string, gone func(path string) bool) (moved, suspect *FileRecord) {
+	for _, cand := range cands {
+		if cand.Path == newPath || !gone(cand.Path) {
+			continue
+		}
+		if cand.SHA256 == newSHA256 {
+			return cand, nil
+		}
+		if suspect == nil {
+			suspect = cand
+		}
+	}
+	return nil, suspect
+}
+
 // MovePathTx re-keys a record from oldPath to newPath.
 // This is used when a scan determines a file was moved but content stayed identical.
-func (db *DB) MovePathTx(tx *sql.Tx, oldPath, newPath, newDisk string, newSize int64, newMtime int64) error {
+func (db *DB) MovePathTx(tx *sql.Tx, oldPath, newPath, newDisk string, newSize int64, newMtime, newMtimeNsec int64) error {
 	// If the destination path already exists (e.g., partial previous scan), remove it.
 	if _, err := tx.Exec(`DELETE FROM files WHERE path = ?`, newPath); err != nil {
 		return err
 	}
 	_, err := tx.Exec(`
 		UPDATE files
-		SET path = ?, disk = ?, size = ?, mtime = ?, last_verified = CURRENT_TIMESTAMP, status = 'ok'
+		SET path = ?, disk = ?, size = ?, mtime = ?, mtime_nsec = ?, last_verified = CURRENT_TIMESTAMP, status = 'ok'
 		WHERE path = ?
-	`, newPath, newDisk, newSize, newMtime, oldPath)
+	`, newPath, newDisk, newSize, newMtime, newMtimeNsec, oldPath)
 	return err
 }
 
 // GetStats returns aggregate statistics.
-func (db *DB) GetStats() (*Stats, error) {
+func (db *DB) GetStats(opts StatsOptions) (*Stats, error) {
 	s := &Stats{}
+	where, args := opts.fileFilter("")
 
-	err := db.conn.QueryRow(`SELECT COUNT(*), COALESCE(SUM(size),0) FROM files`).
-		Scan(&s.TotalFiles, &s.TotalSize)
-	if err != nil {
+	if err := db.countByStatus(s, where, args); err != nil {
 		return nil, err
 	}
-
-	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM files WHERE status = 'ok'`).Scan(&s.OKFiles); err != nil {
-		return nil, fmt.Errorf("count ok files: %w", err)
-	}
-	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM files WHERE status = 'corrupted'`).Scan(&s.CorruptedFiles); err != nil {
-		return nil, fmt.Errorf("count corrupted files: %w", err)
-	}
-	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM files WHERE status = 'missing'`).Scan(&s.MissingFiles); err != nil {
-		return nil, fmt.Errorf("count missing files: %w", err)
-	}
-	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM files WHERE status = 'new'`).Scan(&s.NewFiles); err != nil {
-		return nil, fmt.Errorf("count new files: %w", err)
+	if opts.Duplicates {
+		if err := db.conn.QueryRow(`
+			SELECT COALESCE(SUM(n - 1), 0), COALESCE(SUM((n - 1) * size), 0)
+			FROM (
+				SELECT COUNT(*) AS n, size FROM files
+				WHERE status != 'missing' AND `+where+`
+				GROUP BY sha256, size
+				HAVING COUNT(*) > 1
+			)
+		`, args...).Scan(&s.DuplicateFiles, &s.ReclaimableBytes); err != nil {
+			return nil, fmt.Errorf("sum duplicate space: %w", err)
+		}
 	}
 
 	var lastScan, lastVerify sql.NullString
-	if err := db.conn.QueryRow(`SELECT MAX(ended_at) FROM scan_history WHERE scan_type = 'scan' AND status = 'completed'`).
-		Scan(&lastScan); err != nil {
-		return nil, fmt.Errorf("query last scan: %w", err)
-	}
-	if err := db.conn.QueryRow(`SELECT MAX(ended_at) FROM scan_history WHERE scan_type = 'verify' AND status = 'completed'`).
-		Scan(&lastVerify); err != nil {
-		return nil, fmt.Errorf("query last verify: %w", err)
+	if err := db.conn.QueryRow(`
+		SELECT MAX(CASE WHEN scan_type = 'scan' THEN ended_at END),
+			MAX(CASE WHEN scan_type = 'verify' THEN ended_at END)
+		FROM scan_history WHERE status = 'completed'
+	`).Scan(&lastScan, &lastVerify); err != nil {
+		return nil, fmt.Errorf("query last scan and verify: %w", err)
 	}
 
 	if lastScan.Valid {
@@ -325,20 +1373,91 @@ func (db *DB) GetStats() (*Stats, error) {
 	return s, nil
 }
 
-// GetDiskStats returns per-disk statistics.
-func (db *DB) GetDiskStats() ([]*DiskStats, error) {
+// countByStatus fills in the totals, per-status counts and verify coverage
+// of s from one pass over the files where selects, so the overview costs a
+// single table scan however large the catalog. Being one statement, it also
+// sees a consistent snapshot while a scan or verify is writing.
+func (db *DB) countByStatus(s *Stats, where string, args []interface{}) error {
+	cutoff := func(days int) string {
+		return time.Now().AddDate(0, 0, -days).UTC().Format("2006-01-02 15:04:05")
+	}
+	rows, err := db.conn.Query(`
+		SELECT status, COUNT(*), COALESCE(SUM(size), 0),
+			COALESCE(SUM(CASE WHEN last_verified >= ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN last_verified >= ? THEN 1 ELSE 0 END), 0),
+			MIN(last_verified)
+		FROM files WHERE `+where+`
+		GROUP BY status
+	`, append([]interface{}{cutoff(30), cutoff(90)}, args...)...)
+	if err != nil {
+		return fmt.Errorf("count files: %w", err)
+	}
+	defer rows.Close()
+
+	counts := map[string]*int64{
+		"ok":           &s.OKFiles,
+		"corrupted":    &s.CorruptedFiles,
+		"changed":      &s.ChangedFiles,
+		"error":        &s.ErrorFiles,
+		"missing":      &s.MissingFiles,
+		"new":          &s.NewFiles,
+		"acknowledged": &s.AckedFiles,
+	}
+	var present int64
+	for rows.Next() {
+		var status string
+		var n, size, verified30, verified90 int64
+		var oldest sql.NullString
+		if err := rows.Scan(&status, &n, &size, &verified30, &verified90, &oldest); err != nil {
+			return fmt.Errorf("count files: %w", err)
+		}
+		s.TotalFiles += n
+		s.TotalSize += size
+		if dst := counts[status]; dst != nil {
+			*dst = n
+		}
+		// Coverage is over present (non-missing) files
+		if status == "missing" {
+			continue
+		}
+		present += n
+		s.Verified30d += verified30
+		s.Verified90d += verified90
+		if !oldest.Valid {
+			continue
+		}
+		if t, err := parseTime(oldest.String); err == nil && (s.OldestVerified == nil || t.Before(*s.OldestVerified)) {
+			s.OldestVerified = &t
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("count files: %w", err)
+	}
+	if present > 0 {
+		s.Coverage30d = float64(s.Verified30d) * 100 / float64(present)
+		s.Coverage90d = float64(s.Verified90d) * 100 / float64(present)
+	}
+	return nil
+}
+
+// GetDiskStats returns per-disk statistics. Only opts.Tag applies.
+func (db *DB) GetDiskStats(opts StatsOptions) ([]*DiskStats, error) {
+	where, args := opts.fileFilter("f.")
 	rows, err := db.conn.Query(`
 		SELECT
-			disk,
+			f.disk,
 			COUNT(*) as total_files,
-			COALESCE(SUM(size), 0) as total_size,
-			COALESCE(SUM(CASE WHEN status = 'corrupted' THEN 1 ELSE 0 END), 0) as corrupted,
-			COALESCE(SUM(CASE WHEN status = 'missing' THEN 1 ELSE 0 END), 0) as missing,
-			MAX(last_verified) as last_verified
-		FROM files
-		GROUP BY disk
-		ORDER BY disk
-	`)
+			COALESCE(SUM(f.size), 0) as total_size,
+			COALESCE(SUM(CASE WHEN f.status = 'corrupted' THEN 1 ELSE 0 END), 0) as corrupted,
+			COALESCE(SUM(CASE WHEN f.status = 'missing' THEN 1 ELSE 0 END), 0) as missing,
+			MAX(f.last_verified) as last_verified,
+			COALESCE(NULLIF(d.disk_type, 'unknown'), '') as disk_type
+		FROM files f
+		LEFT JOIN disks d ON d.name = f.disk
+		WHERE `+where+`
+		GROUP BY f.disk
+		ORDER BY f.disk
+	`, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -349,7 +1468,7 @@ func (db *DB) GetDiskStats() ([]*DiskStats, error) {
 		ds := &DiskStats{}
 		var lastVerified sql.NullString
 		if err := rows.Scan(&ds.Disk, &ds.TotalFiles, &ds.TotalSize,
-			&ds.CorruptedFiles, &ds.MissingFiles, &lastVerified); err != nil {
+			&ds.CorruptedFiles, &ds.MissingFiles, &lastVerified, &ds.DiskType); err != nil {
 			return nil, err
 		}
 		if lastVerified.Valid {
@@ -357,17 +1476,185 @@ func (db *DB) GetDiskStats() ([]*DiskStats, error) {
 				ds.LastVerified = &t
 			}
 		}
+		if present := ds.TotalFiles - ds.MissingFiles; present > 0 {
+			ds.CorruptedPct = float64(ds.CorruptedFiles) * 100 / float64(present)
+		}
+		switch {
+		case ds.CorruptedFiles == 0:
+			ds.Health = "good"
+		case ds.CorruptedPct < HealthFailingPct:
+			ds.Health = "degraded"
+		default:
+			ds.Health = "failing"
+		}
 		stats = append(stats, ds)
 	}
 	return stats, rows.Err()
 }
 
-// InsertScanHistory records a scan/verify operation.
-func (db *DB) InsertScanHistory(scanType, disks string) (int64, error) {
+// UpsertDisk records a disk found by auto-detection, so verify and the
+// dashboard can reuse its type without probing /sys again. An "unknown" type
+// never overwrites an earlier successful detection.
+func (db *DB) UpsertDisk(d *Disk) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO disks (name, path, disk_type, last_seen)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(name) DO UPDATE SET
+			path = excluded.path,
+			disk_type = CASE WHEN excluded.disk_type = 'unknown' THEN disks.disk_type ELSE excluded.disk_type END,
+			last_seen = excluded.last_seen
+	`, d.Name, d.Path, d.Type)
+	return err
+}
+
+// GetDisks returns the recorded disks ordered by name.
+func (db *DB) GetDisks() ([]*Disk, error) {
+	rows, err := db.conn.Query(`
+		SELECT name, path, disk_type, last_seen
+		FROM disks
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var disks []*Disk
+	for rows.Next() {
+		d := &Disk{}
+		var lastSeen string
+		if err := rows.Scan(&d.Name, &d.Path, &d.Type, &lastSeen); err != nil {
+			return nil, err
+		}
+		if t, err := parseTime(lastSeen); err == nil {
+			d.LastSeen = t
+		}
+		disks = append(disks, d)
+	}
+	return disks, rows.Err()
+}
+
+// DiskTypes maps each recorded disk's name to its detected type.
+func (db *DB) DiskTypes() (map[string]string, error) {
+	disks, err := db.GetDisks()
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]string, len(disks))
+	for _, d := range disks {
+		m[d.Name] = d.Type
+	}
+	return m, nil
+}
+
+// GetExtensionStats groups tracked files by lowercased extension, largest
+// total size first. Missing files are left out. Dotfiles such as ".bashrc"
+// count as having no extension.
+func (db *DB) GetExtensionStats() ([]ExtStat, error) {
+	rows, err := db.conn.Query(`SELECT path, size FROM files WHERE status != 'missing'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	byExt := make(map[string]*ExtStat)
+	for rows.Next() {
+		var path string
+		var size int64
+		if err := rows.Scan(&path, &size); err != nil {
+			return nil, err
+		}
+		ext := FileExt(path)
+		st := byExt[ext]
+		if st == nil {
+			st = &ExtStat{Ext: ext}
+			byExt[ext] = st
+		}
+		st.Files++
+		st.TotalSize += size
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	stats := make([]ExtStat, 0, len(byExt))
+	for _, st := range byExt {
+		stats = append(stats, *st)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].TotalSize != stats[j].TotalSize {
+			return stats[i].TotalSize > stats[j].TotalSize
+		}
+		if stats[i].Files != stats[j].Files {
+			return stats[i].Files > stats[j].Files
+		}
+		return stats[i].Ext < stats[j].Ext
+	})
+	return stats, nil
+}
+
+// FileExt returns the lowercased extension of a catalog path, or "" if the
+// file name has none. A leading dot alone (".bashrc") is not an extension.
+func FileExt(path string) string {
+	name := strings.TrimPrefix(filepath.Base(path), ".")
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext == "." {
+		return ""
+	}
+	return ext
+}
+
+// InsertStatsSnapshot records the current catalog totals in stats_snapshots.
+func (db *DB) InsertStatsSnapshot() error {
+	_, err := db.conn.Exec(`
+		INSERT INTO stats_snapshots (taken_at, total_files, total_size, corrupted, missing)
+		SELECT
+			CURRENT_TIMESTAMP,
+			COUNT(*),
+			COALESCE(SUM(size), 0),
+			COALESCE(SUM(CASE WHEN status = 'corrupted' THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = 'missing' THEN 1 ELSE 0 END), 0)
+		FROM files
+	`)
+	return err
+}
+
+// GetStatsSnapshots returns snapshots taken at or after since, oldest first.
+func (db *DB) GetStatsSnapshots(since time.Time) ([]*StatsSnapshot, error) {
+	rows, err := db.conn.Query(`
+		SELECT taken_at, total_files, total_size, corrupted, missing
+		FROM stats_snapshots
+		WHERE taken_at >= ?
+		ORDER BY taken_at ASC, id ASC
+	`, since.UTC().Format("2006-01-02 15:04:05"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snaps []*StatsSnapshot
+	for rows.Next() {
+		s := &StatsSnapshot{}
+		var takenAt string
+		if err := rows.Scan(&takenAt, &s.TotalFiles, &s.TotalSize, &s.Corrupted, &s.Missing); err != nil {
+			return nil, err
+		}
+		s.TakenAt, err = parseTime(takenAt)
+		if err != nil {
+			logx.Warnf("parse taken_at for stats snapshot: %v\n", err)
+		}
+		snaps = append(snaps, s)
+	}
+	return snaps, rows.Err()
+}
+
+// InsertScanHistory records a scan/verify operation, along with the
+// filehasher version that ran it and the hash algorithm(s) it used.
+func (db *DB) InsertScanHistory(scanType, disks, version, algo string) (int64, error) {
 	res, err := db.conn.Exec(`
-		INSERT INTO scan_history (scan_type, started_at, disks, status)
-		VALUES (?, CURRENT_TIMESTAMP, ?, 'running')
-	`, scanType, disks)
+		INSERT INTO scan_history (scan_type, started_at, disks, status, version, algo)
+		VALUES (?, CURRENT_TIMESTAMP, ?, 'running', ?, ?)
+	`, scanType, disks, version, algo)
 	if err != nil {
 		return 0, err
 	}
@@ -375,22 +1662,129 @@ func (db *DB) InsertScanHistory(scanType, disks string) (int64, error) {
 }
 
 // CompleteScanHistory marks a scan as completed.
-func (db *DB) CompleteScanHistory(id int64, filesProcessed, errors int) error {
+func (db *DB) CompleteScanHistory(id int64, filesProcessed, errors int, bytesProcessed int64, duration time.Duration) error {
 	_, err := db.conn.Exec(`
 		UPDATE scan_history
-		SET ended_at = CURRENT_TIMESTAMP, files_processed = ?, errors = ?, status = 'completed'
+		SET ended_at = CURRENT_TIMESTAMP, files_processed = ?, errors = ?, status = 'completed',
+			bytes_processed = ?, duration_ms = ?
 		WHERE id = ?
-	`, filesProcessed, errors, id)
+	`, filesProcessed, errors, bytesProcessed, duration.Milliseconds(), id)
+	return err
+}
+
+// ScanError is a problem a scan ran into with one path, e.g. a directory it
+// wasn't allowed to read.
+type ScanError struct {
+	ScanID     int64     `json:"scan_id"`
+	Path       string    `json:"path"`
+	Message    string    `json:"message"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+const insertScanErrorSQL = `INSERT INTO scan_errors (scan_id, path, message) VALUES (?, ?, ?)`
+
+// InsertScanError records an error of the scan_history run scanID. During a
+// scan, use Writer.ScanError instead so it doesn't wait for the batch lock.
+func (db *DB) InsertScanError(scanID int64, path, message string) error {
+	_, err := db.conn.Exec(insertScanErrorSQL, scanID, path, message)
 	return err
 }
 
+// InsertScanErrorTx is InsertScanError within a transaction.
+func (db *DB) InsertScanErrorTx(tx *sql.Tx, scanID int64, path, message string) error {
+	_, err := tx.Exec(insertScanErrorSQL, scanID, path, message)
+	return err
+}
+
+// GetScanErrors returns up to limit errors of run scanID in the order they
+// occurred (0 means no cap).
+func (db *DB) GetScanErrors(scanID int64, limit int) ([]*ScanError, error) {
+	if limit <= 0 {
+		limit = -1 // SQLite: no limit
+	}
+	rows, err := db.conn.Query(`
+		SELECT scan_id, path, message, occurred_at
+		FROM scan_errors
+		WHERE scan_id = ?
+		ORDER BY id
+		LIMIT ?
+	`, scanID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var errs []*ScanError
+	for rows.Next() {
+		e := &ScanError{}
+		var occurredAt string
+		if err := rows.Scan(&e.ScanID, &e.Path, &e.Message, &occurredAt); err != nil {
+			return nil, err
+		}
+		e.OccurredAt, err = parseTime(occurredAt)
+		if err != nil {
+			logx.Warnf("parse occurred_at for scan error: %v\n", err)
+		}
+		errs = append(errs, e)
+	}
+	return errs, rows.Err()
+}
+
+// CatalogAlgo returns the hash algorithm most of the catalog's records use,
+// which scans hash new files with, so a catalog moved to another algorithm
+// by migrate-hash stays on it. An empty catalog gives sha256.
+func (db *DB) CatalogAlgo() (string, error) {
+	var algo string
+	err := db.conn.QueryRow(`SELECT algo FROM files GROUP BY algo ORDER BY COUNT(*) DESC, algo LIMIT 1`).Scan(&algo)
+	if err == sql.ErrNoRows {
+		return algoOrDefault(""), nil
+	}
+	return algo, err
+}
+
+// HashAlgorithms returns the distinct hash algorithms of the catalog's
+// records, sorted, e.g. to note what a verify run checked.
+func (db *DB) HashAlgorithms() ([]string, error) {
+	rows, err := db.conn.Query(`SELECT DISTINCT algo FROM files ORDER BY algo`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var algos []string
+	for rows.Next() {
+		var a string
+		if err := rows.Scan(&a); err != nil {
+			return nil, err
+		}
+		algos = append(algos, a)
+	}
+	return algos, rows.Err()
+}
+
+// AverageThroughput returns the bytes hashed and time spent over the last
+// limit completed runs that recorded them, for an overall MB/s figure.
+func (db *DB) AverageThroughput(limit int) (int64, time.Duration, error) {
+	var bytes, ms int64
+	err := db.conn.QueryRow(`
+		SELECT COALESCE(SUM(bytes_processed), 0), COALESCE(SUM(duration_ms), 0)
+		FROM (
+			SELECT bytes_processed, duration_ms FROM scan_history
+			WHERE status = 'completed' AND duration_ms > 0 AND bytes_processed > 0
+			ORDER BY started_at DESC
+			LIMIT ?
+		)
+	`, limit).Scan(&bytes, &ms)
+	return bytes, time.Duration(ms) * time.Millisecond, err
+}
+
 // SearchFiles searches for files by path pattern.
 func (db *DB) SearchFiles(pattern string, limit int) ([]*FileRecord, error) {
 	if limit <= 0 {
 		limit = 100
 	}
 	rows, err := db.conn.Query(`
-		SELECT id, path, disk, size, mtime, sha256, first_seen, last_verified, status
+		SELECT `+fileColumns+`
 		FROM files WHERE path LIKE ?
 		ORDER BY path
 		LIMIT ?
@@ -403,13 +1797,21 @@ func (db *DB) SearchFiles(pattern string, limit int) ([]*FileRecord, error) {
 	return scanFileRows(rows)
 }
 
-// GetScanHistory returns recent scan history entries.
+// GetScanHistory returns recent scan history entries, newest first. Finished
+// entries include "ended_at", a human-readable "duration" and, for graphing,
+// "duration_seconds"; unfinished ones have an empty duration. "bytes_processed"
+// is 0 for runs recorded before it was tracked; "mbps" (MiB/s) is set when
+// both bytes and the run's measured duration are known. "version" and "algo"
+// are empty for runs recorded before they were tracked. "logged_errors"
+// counts the run's entries in scan_errors (see GetScanErrors).
 func (db *DB) GetScanHistory(limit int) ([]map[string]interface{}, error) {
 	if limit <= 0 {
 		limit = 50
 	}
 	rows, err := db.conn.Query(`
-		SELECT id, scan_type, started_at, ended_at, disks, files_processed, errors, status
+		SELECT id, scan_type, started_at, ended_at, disks, files_processed, errors, status,
+			bytes_processed, duration_ms, version, algo,
+			(SELECT COUNT(*) FROM scan_errors WHERE scan_id = scan_history.id)
 		FROM scan_history
 		ORDER BY started_at DESC
 		LIMIT ?
@@ -422,17 +1824,19 @@ func (db *DB) GetScanHistory(limit int) ([]map[string]interface{}, error) {
 	var history []map[string]interface{}
 	for rows.Next() {
 		var id int64
-		var filesProcessed, errCount int
-		var scanType, disks, status string
+		var filesProcessed, errCount, loggedErrors int
+		var bytesProcessed, durationMs int64
+		var scanType, disks, status, version, algo string
 		var startedAtStr string
 		var endedAtStr sql.NullString
 
-		if err := rows.Scan(&id, &scanType, &startedAtStr, &endedAtStr, &disks, &filesProcessed, &errCount, &status); err != nil {
+		if err := rows.Scan(&id, &scanType, &startedAtStr, &endedAtStr, &disks, &filesProcessed, &errCount, &status,
+			&bytesProcessed, &durationMs, &version, &algo, &loggedErrors); err != nil {
 			return nil, err
 		}
 		startedAt, err := parseTime(startedAtStr)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "warning: parse started_at for scan %d: %v\n", id, err)
+			logx.Warnf("parse started_at for scan %d: %v\n", id, err)
 		}
 		entry := map[string]interface{}{
 			"id":              id,
@@ -441,20 +1845,30 @@ func (db *DB) GetScanHistory(limit int) ([]map[string]interface{}, error) {
 			"disks":           disks,
 			"files_processed": filesProcessed,
 			"errors":          errCount,
+			"logged_errors":   loggedErrors,
 			"status":          status,
 			"duration":        "",
+			"bytes_processed": bytesProcessed,
+			"version":         version,
+			"algo":            algo,
 		}
 		if endedAtStr.Valid {
 			if t, err := parseTime(endedAtStr.String); err == nil {
 				entry["ended_at"] = t.Format("2006-01-02 15:04:05")
 				dur := t.Sub(startedAt)
+				if durationMs > 0 {
+					// Measured by the run itself; the timestamps only have second precision
+					dur = time.Duration(durationMs) * time.Millisecond
+					if bytesProcessed > 0 {
+						entry["mbps"] = float64(bytesProcessed) / (1 << 20) / dur.Seconds()
+					}
+				}
 				if dur < time.Second {
 					entry["duration"] = dur.Round(time.Millisecond).String()
-				} else if dur < time.Minute {
-					entry["duration"] = dur.Round(time.Second).String()
 				} else {
 					entry["duration"] = dur.Round(time.Second).String()
 				}
+				entry["duration_seconds"] = dur.Seconds()
 			}
 		}
 		history = append(history, entry)
@@ -467,27 +1881,50 @@ func scanFileRows(rows *sql.Rows) ([]*FileRecord, error) {
 	for rows.Next() {
 		f := &FileRecord{}
 		var firstSeen, lastVerified string
-		if err := rows.Scan(&f.ID, &f.Path, &f.Disk, &f.Size, &f.Mtime, &f.SHA256,
-			&firstSeen, &lastVerified, &f.Status); err != nil {
+		var tag sql.NullString
+		var perms nullPerms
+		if err := rows.Scan(&f.ID, &f.Path, &f.Disk, &f.Size, &f.Mtime, hexHash{&f.SHA256},
+			&firstSeen, &lastVerified, &f.Status, &f.Algo, &f.MtimeNsec, &f.SecondaryAlgo, &f.SecondaryHash, &f.ChunkSize, &tag,
+			&perms.uid, &perms.gid, &perms.mode); err != nil {
 			return nil, err
 		}
+		f.Tag = tag.String
+		f.Perms = perms.get()
 		var err error
 		f.FirstSeen, err = parseTime(firstSeen)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "warning: parse first_seen for %s: %v\n", f.Path, err)
+			logx.PathWarnf(f.Path, "parse first_seen: %v\n", err)
 		}
 		f.LastVerified, err = parseTime(lastVerified)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "warning: parse last_verified for %s: %v\n", f.Path, err)
+			logx.PathWarnf(f.Path, "parse last_verified: %v\n", err)
 		}
 		files = append(files, f)
 	}
 	return files, rows.Err()
 }
 
-// parseTime tries multiple formats that SQLite might return.
+// nullPerms scans the nullable uid, gid and mode columns.
+type nullPerms struct {
+	uid, gid, mode sql.NullInt64
+}
+
+func (n nullPerms) get() *Perms {
+	if !n.mode.Valid {
+		return nil
+	}
+	return &Perms{UID: uint32(n.uid.Int64), GID: uint32(n.gid.Int64), Mode: uint32(n.mode.Int64)}
+}
+
+// parseTime tries multiple formats that SQLite might return. Aggregates
+// such as MIN() return the stored text, which for times the driver wrote is
+// Go's time.String() form, monotonic clock reading and all.
 func parseTime(s string) (time.Time, error) {
+	if i := strings.Index(s, " m="); i >= 0 {
+		s = s[:i]
+	}
 	formats := []string{
+		"2006-01-02 15:04:05.999999999 -0700 MST",
 		"2006-01-02 15:04:05",
 		"2006-01-02T15:04:05Z",
 		"2006-01-02T15:04:05",
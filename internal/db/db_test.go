@@ -1,7 +1,12 @@
 package db
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -10,7 +15,7 @@ func openTestDB(t *testing.T) *DB {
 	t.Helper()
 	dir := t.TempDir()
 	path := filepath.Join(dir, "test.db")
-	database, err := Open(path)
+	database, err := Open(path, Options{})
 	if err != nil {
 		t.Fatalf("Open: %v", err)
 	}
@@ -21,7 +26,7 @@ func openTestDB(t *testing.T) *DB {
 func TestOpenAndClose(t *testing.T) {
 	database := openTestDB(t)
 	// Verify the database is functional by running a simple query
-	stats, err := database.GetStats()
+	stats, err := database.GetStats(StatsOptions{})
 	if err != nil {
 		t.Fatalf("GetStats: %v", err)
 	}
@@ -123,6 +128,47 @@ func TestUpsertFileTxUpdate(t *testing.T) {
 	}
 }
 
+func TestUpsertKeepsNewStatus(t *testing.T) {
+	database := openTestDB(t)
+
+	now := time.Now()
+	record := &FileRecord{Path: "/mnt/disk1/a", Disk: "disk1", Size: 1, SHA256: "h1", FirstSeen: now, LastVerified: now, Status: "new"}
+	upsert := func() {
+		tx, _ := database.BeginBatch()
+		database.UpsertFileTx(tx, record)
+		tx.Commit()
+	}
+	status := func() string {
+		f, _ := database.GetFileByPath(record.Path)
+		return f.Status
+	}
+
+	upsert()
+	// Re-hashed by a later scan before any verify: still new
+	record.Status, record.SHA256 = "ok", "h2"
+	upsert()
+	if got := status(); got != "new" {
+		t.Errorf("status after rescan = %q, want new", got)
+	}
+	// Anything but ok replaces it
+	record.Status = "corrupted"
+	upsert()
+	if got := status(); got != "corrupted" {
+		t.Errorf("status = %q, want corrupted", got)
+	}
+}
+
+func TestPing(t *testing.T) {
+	database := openTestDB(t)
+	if err := database.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping on open database: %v", err)
+	}
+	database.Close()
+	if err := database.Ping(context.Background()); err == nil {
+		t.Error("Ping on closed database: expected error")
+	}
+}
+
 func TestGetStats(t *testing.T) {
 	database := openTestDB(t)
 
@@ -145,7 +191,7 @@ func TestGetStats(t *testing.T) {
 	}
 	tx.Commit()
 
-	stats, err := database.GetStats()
+	stats, err := database.GetStats(StatsOptions{})
 	if err != nil {
 		t.Fatalf("GetStats: %v", err)
 	}
@@ -166,6 +212,57 @@ func TestGetStats(t *testing.T) {
 	if stats.TotalSize != 1000 {
 		t.Errorf("TotalSize = %d, want 1000", stats.TotalSize)
 	}
+	if stats.LastScan != nil || stats.LastVerify != nil {
+		t.Errorf("LastScan = %v, LastVerify = %v before any run", stats.LastScan, stats.LastVerify)
+	}
+
+	id, err := database.InsertScanHistory("verify", "", "test", "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.CompleteScanHistory(id, 4, 0, 1000, time.Second)
+	stats, _ = database.GetStats(StatsOptions{})
+	if stats.LastScan != nil || stats.LastVerify == nil {
+		t.Errorf("after a verify: LastScan = %v, LastVerify = %v", stats.LastScan, stats.LastVerify)
+	}
+}
+
+func TestStatsCoverage(t *testing.T) {
+	database := openTestDB(t)
+
+	now := time.Now().UTC()
+	day := 24 * time.Hour
+	tx, _ := database.BeginBatch()
+	for i, f := range []struct {
+		status string
+		age    time.Duration
+	}{
+		{"ok", day},
+		{"ok", 10 * day},
+		{"ok", 60 * day},
+		{"corrupted", 120 * day},
+		{"missing", 400 * day}, // not counted
+	} {
+		database.UpsertFileTx(tx, &FileRecord{
+			Path: fmt.Sprintf("/mnt/disk1/f%d", i), Disk: "disk1", Size: 1, SHA256: "h",
+			FirstSeen: now.Add(-f.age), LastVerified: now.Add(-f.age), Status: f.status,
+		})
+	}
+	tx.Commit()
+
+	stats, err := database.GetStats(StatsOptions{})
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+	if stats.Verified30d != 2 || stats.Verified90d != 3 {
+		t.Errorf("verified 30d/90d = %d/%d, want 2/3", stats.Verified30d, stats.Verified90d)
+	}
+	if stats.Coverage30d != 50 || stats.Coverage90d != 75 {
+		t.Errorf("coverage 30d/90d = %.1f/%.1f, want 50/75", stats.Coverage30d, stats.Coverage90d)
+	}
+	if stats.OldestVerified == nil || now.Sub(*stats.OldestVerified).Round(day) != 120*day {
+		t.Errorf("OldestVerified = %v, want about 120 days ago", stats.OldestVerified)
+	}
 }
 
 func TestGetDiskStats(t *testing.T) {
@@ -187,7 +284,7 @@ func TestGetDiskStats(t *testing.T) {
 	})
 	tx.Commit()
 
-	diskStats, err := database.GetDiskStats()
+	diskStats, err := database.GetDiskStats(StatsOptions{})
 	if err != nil {
 		t.Fatalf("GetDiskStats: %v", err)
 	}
@@ -211,6 +308,99 @@ func TestGetDiskStats(t *testing.T) {
 	}
 }
 
+func TestDiskTypeAndHealth(t *testing.T) {
+	database := openTestDB(t)
+
+	now := time.Now()
+	tx, _ := database.BeginBatch()
+	for i := 0; i < 200; i++ {
+		status := "ok"
+		if i == 0 {
+			status = "corrupted"
+		}
+		database.UpsertFileTx(tx, &FileRecord{
+			Path: fmt.Sprintf("/mnt/disk1/%d", i), Disk: "disk1", Size: 1,
+			Mtime: now.Unix(), SHA256: "h", FirstSeen: now, LastVerified: now, Status: status,
+		})
+	}
+	database.UpsertFileTx(tx, &FileRecord{
+		Path: "/mnt/disk2/a", Disk: "disk2", Size: 1,
+		Mtime: now.Unix(), SHA256: "h", FirstSeen: now, LastVerified: now, Status: "corrupted",
+	})
+	database.UpsertFileTx(tx, &FileRecord{
+		Path: "/mnt/cache/a", Disk: "cache", Size: 1,
+		Mtime: now.Unix(), SHA256: "h", FirstSeen: now, LastVerified: now, Status: "ok",
+	})
+	tx.Commit()
+
+	if err := database.UpsertDisk(&Disk{Name: "disk1", Path: "/mnt/disk1", Type: "HDD"}); err != nil {
+		t.Fatalf("UpsertDisk: %v", err)
+	}
+	database.UpsertDisk(&Disk{Name: "cache", Path: "/mnt/cache", Type: "SSD"})
+
+	diskStats, err := database.GetDiskStats(StatsOptions{})
+	if err != nil {
+		t.Fatalf("GetDiskStats: %v", err)
+	}
+	got := make(map[string]*DiskStats)
+	for _, ds := range diskStats {
+		got[ds.Disk] = ds
+	}
+
+	tests := []struct {
+		disk, diskType, health string
+		pct                    float64
+	}{
+		{"cache", "SSD", "good", 0},
+		{"disk1", "HDD", "degraded", 0.5},
+		{"disk2", "", "failing", 100},
+	}
+	for _, tt := range tests {
+		ds := got[tt.disk]
+		if ds == nil {
+			t.Fatalf("no stats for %s", tt.disk)
+		}
+		if ds.DiskType != tt.diskType || ds.Health != tt.health || ds.CorruptedPct != tt.pct {
+			t.Errorf("%s: type=%q health=%q pct=%v, want %q %q %v",
+				tt.disk, ds.DiskType, ds.Health, ds.CorruptedPct, tt.diskType, tt.health, tt.pct)
+		}
+	}
+}
+
+func TestUpsertDisk(t *testing.T) {
+	database := openTestDB(t)
+
+	if err := database.UpsertDisk(&Disk{Name: "disk1", Path: "/mnt/disk1", Type: "HDD"}); err != nil {
+		t.Fatalf("UpsertDisk: %v", err)
+	}
+	database.UpsertDisk(&Disk{Name: "cache", Path: "/mnt/cache", Type: "SSD"})
+	// A failed probe later must not clobber what was detected before
+	database.UpsertDisk(&Disk{Name: "cache", Path: "/mnt/cache2", Type: "unknown"})
+
+	disks, err := database.GetDisks()
+	if err != nil {
+		t.Fatalf("GetDisks: %v", err)
+	}
+	if len(disks) != 2 {
+		t.Fatalf("got %d disks, want 2", len(disks))
+	}
+	cache := disks[0]
+	if cache.Name != "cache" || cache.Path != "/mnt/cache2" || cache.Type != "SSD" {
+		t.Errorf("cache = %+v, want path /mnt/cache2, SSD", cache)
+	}
+	if cache.LastSeen.IsZero() {
+		t.Error("cache LastSeen not set")
+	}
+
+	types, err := database.DiskTypes()
+	if err != nil {
+		t.Fatalf("DiskTypes: %v", err)
+	}
+	if fmt.Sprint(types) != "map[cache:SSD disk1:HDD]" {
+		t.Errorf("DiskTypes = %v", types)
+	}
+}
+
 func TestLoadQuickLookupMap(t *testing.T) {
 	database := openTestDB(t)
 
@@ -357,7 +547,7 @@ func TestSearchFiles(t *testing.T) {
 func TestScanHistory(t *testing.T) {
 	database := openTestDB(t)
 
-	id, err := database.InsertScanHistory("scan", "disk1,disk2")
+	id, err := database.InsertScanHistory("scan", "disk1,disk2", "v1.2.3", "sha256")
 	if err != nil {
 		t.Fatalf("InsertScanHistory: %v", err)
 	}
@@ -365,7 +555,7 @@ func TestScanHistory(t *testing.T) {
 		t.Errorf("scan ID = %d, want > 0", id)
 	}
 
-	if err := database.CompleteScanHistory(id, 100, 2); err != nil {
+	if err := database.CompleteScanHistory(id, 100, 2, 300<<20, 2*time.Second); err != nil {
 		t.Fatalf("CompleteScanHistory: %v", err)
 	}
 
@@ -390,6 +580,29 @@ func TestScanHistory(t *testing.T) {
 	if entry["status"] != "completed" {
 		t.Errorf("status = %v, want completed", entry["status"])
 	}
+	if entry["duration"] == "" {
+		t.Error("duration is empty for a completed entry")
+	}
+	if secs, ok := entry["duration_seconds"].(float64); !ok || secs != 2 {
+		t.Errorf("duration_seconds = %v, want 2", entry["duration_seconds"])
+	}
+	if entry["bytes_processed"] != int64(300<<20) {
+		t.Errorf("bytes_processed = %v, want %d", entry["bytes_processed"], 300<<20)
+	}
+	if entry["mbps"] != 150.0 {
+		t.Errorf("mbps = %v, want 150", entry["mbps"])
+	}
+	if entry["version"] != "v1.2.3" || entry["algo"] != "sha256" {
+		t.Errorf("version, algo = %v, %v, want v1.2.3, sha256", entry["version"], entry["algo"])
+	}
+
+	bytes, dur, err := database.AverageThroughput(20)
+	if err != nil {
+		t.Fatalf("AverageThroughput: %v", err)
+	}
+	if bytes != 300<<20 || dur != 2*time.Second {
+		t.Errorf("AverageThroughput = %d, %v, want %d, 2s", bytes, dur, 300<<20)
+	}
 }
 
 func TestParseTime(t *testing.T) {
@@ -402,6 +615,7 @@ func TestParseTime(t *testing.T) {
 		{"2025-01-15T10:30:45", true},
 		{"2025-01-15 10:30:45.000", true},
 		{"2025-01-15T10:30:45+00:00", true},
+		{"2025-01-15 10:30:45.123456789 +0000 UTC m=+0.015013587", true}, // MIN() over a driver-written time
 		{"invalid", false},
 		{"", false},
 	}
@@ -439,3 +653,1472 @@ func TestGetAllFiles(t *testing.T) {
 		t.Errorf("got %d files, want 3", len(files))
 	}
 }
+
+func TestIterateFiles(t *testing.T) {
+	database := openTestDB(t)
+
+	// More than one page, spread over two disks
+	now := time.Now()
+	n := iteratePageSize*2 + 10
+	tx, _ := database.BeginBatch()
+	for i := 0; i < n; i++ {
+		database.UpsertFileTx(tx, &FileRecord{
+			Path: fmt.Sprintf("/mnt/disk%d/f%05d", i%2+1, i), Disk: fmt.Sprintf("disk%d", i%2+1), Size: 1,
+			Mtime: now.Unix(), SHA256: fmt.Sprintf("%064x", i),
+			FirstSeen: now, LastVerified: now, Status: "ok",
+		})
+	}
+	tx.Commit()
+
+	seen := make(map[string]bool)
+	var lastID int64
+	err := database.IterateAllFiles(func(f *FileRecord) error {
+		if f.ID <= lastID {
+			t.Fatalf("id %d after %d", f.ID, lastID)
+		}
+		lastID = f.ID
+		seen[f.Path] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("IterateAllFiles: %v", err)
+	}
+	if len(seen) != n {
+		t.Errorf("IterateAllFiles saw %d files, want %d", len(seen), n)
+	}
+
+	count := 0
+	err = database.IterateFiles("disk2", func(f *FileRecord) error {
+		if f.Disk != "disk2" {
+			t.Fatalf("IterateFiles(disk2) returned %s on %s", f.Path, f.Disk)
+		}
+		count++
+		return nil
+	})
+	if err != nil || count != n/2 {
+		t.Errorf("IterateFiles(disk2) = %d files, %v; want %d", count, err, n/2)
+	}
+
+	// An error from fn stops the iteration
+	stop := errors.New("stop")
+	count = 0
+	err = database.IterateAllFiles(func(*FileRecord) error {
+		count++
+		if count == 5 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || count != 5 {
+		t.Errorf("stopped iteration: %d files, %v; want 5 and stop", count, err)
+	}
+
+	disks, err := database.GetTrackedDisks()
+	if err != nil || strings.Join(disks, ",") != "disk1,disk2" {
+		t.Errorf("GetTrackedDisks = %v, %v; want disk1, disk2", disks, err)
+	}
+}
+
+func TestGetFilesForSampledVerify(t *testing.T) {
+	database := openTestDB(t)
+
+	base := time.Now().Add(-48 * time.Hour)
+	tx, _ := database.BeginBatch()
+	for i := 0; i < 10; i++ {
+		database.UpsertFileTx(tx, &FileRecord{
+			Path: "/mnt/disk1/file" + string(rune('0'+i)), Disk: "disk1", Size: 100,
+			Mtime: base.Unix(), SHA256: "h" + string(rune('0'+i)),
+			FirstSeen: base, LastVerified: base.Add(time.Duration(i) * time.Hour), Status: "ok",
+		})
+	}
+	database.UpsertFileTx(tx, &FileRecord{
+		Path: "/mnt/disk2/other", Disk: "disk2", Size: 100,
+		Mtime: base.Unix(), SHA256: "hx",
+		FirstSeen: base, LastVerified: base.Add(-time.Hour), Status: "ok",
+	})
+	tx.Commit()
+
+	// 25% of 10 files on disk1 rounds up to 3, oldest first
+	files, err := database.GetFilesForSampledVerify("disk1", 25)
+	if err != nil {
+		t.Fatalf("GetFilesForSampledVerify: %v", err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("got %d files, want 3", len(files))
+	}
+	for i, f := range files {
+		want := "/mnt/disk1/file" + string(rune('0'+i))
+		if f.Path != want {
+			t.Errorf("files[%d] = %q, want %q", i, f.Path, want)
+		}
+	}
+
+	// No disk filter: the disk2 file is the oldest overall
+	files, err = database.GetFilesForSampledVerify("", 10)
+	if err != nil {
+		t.Fatalf("GetFilesForSampledVerify: %v", err)
+	}
+	if len(files) != 2 || files[0].Path != "/mnt/disk2/other" {
+		t.Errorf("unexpected sample across all disks: %v", files)
+	}
+
+	if _, err := database.GetFilesForSampledVerify("", 0); err == nil {
+		t.Error("expected error for 0 percent")
+	}
+}
+
+func TestGetFilesModifiedSince(t *testing.T) {
+	database := openTestDB(t)
+
+	cutoff := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := time.Now()
+	tx, _ := database.BeginBatch()
+	for _, f := range []struct {
+		path, disk string
+		mtime      time.Time
+	}{
+		{"/mnt/disk1/before", "disk1", cutoff.Add(-time.Second)},
+		{"/mnt/disk1/at", "disk1", cutoff},
+		{"/mnt/disk1/after", "disk1", cutoff.Add(48 * time.Hour)},
+		{"/mnt/disk2/after", "disk2", cutoff.Add(time.Hour)},
+	} {
+		database.UpsertFileTx(tx, &FileRecord{
+			Path: f.path, Disk: f.disk, Size: 1, SHA256: "h", Mtime: f.mtime.Unix(),
+			FirstSeen: now, LastVerified: now, Status: "ok",
+		})
+	}
+	tx.Commit()
+
+	paths := func(files []*FileRecord) []string {
+		var out []string
+		for _, f := range files {
+			out = append(out, f.Path)
+		}
+		return out
+	}
+
+	files, err := database.GetFilesModifiedSince(cutoff, "")
+	if err != nil {
+		t.Fatalf("GetFilesModifiedSince: %v", err)
+	}
+	want := []string{"/mnt/disk1/after", "/mnt/disk1/at", "/mnt/disk2/after"}
+	if got := paths(files); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("modified since = %v, want %v", got, want)
+	}
+
+	files, _ = database.GetFilesModifiedSince(cutoff, "disk2")
+	if got := paths(files); fmt.Sprint(got) != "[/mnt/disk2/after]" {
+		t.Errorf("modified since on disk2 = %v, want [/mnt/disk2/after]", got)
+	}
+}
+
+func TestGetStaleFiles(t *testing.T) {
+	database := openTestDB(t)
+
+	now := time.Now().UTC()
+	day := 24 * time.Hour
+	tx, _ := database.BeginBatch()
+	for _, f := range []struct {
+		path, disk, status string
+		age                time.Duration
+	}{
+		{"/mnt/disk1/fresh", "disk1", "ok", day},
+		{"/mnt/disk1/old", "disk1", "ok", 100 * day},
+		{"/mnt/disk1/older", "disk1", "ok", 200 * day},
+		{"/mnt/disk1/gone", "disk1", "missing", 300 * day},
+		{"/mnt/disk2/old", "disk2", "ok", 150 * day},
+	} {
+		database.UpsertFileTx(tx, &FileRecord{
+			Path: f.path, Disk: f.disk, Size: 1, SHA256: "h",
+			FirstSeen: now.Add(-f.age), LastVerified: now.Add(-f.age), Status: f.status,
+		})
+	}
+	tx.Commit()
+
+	paths := func(files []*FileRecord) []string {
+		var out []string
+		for _, f := range files {
+			out = append(out, f.Path)
+		}
+		return out
+	}
+
+	files, err := database.GetStaleFiles(90*day, "", 0)
+	if err != nil {
+		t.Fatalf("GetStaleFiles: %v", err)
+	}
+	want := []string{"/mnt/disk1/older", "/mnt/disk2/old", "/mnt/disk1/old"}
+	if got := paths(files); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("stale files = %v, want %v (oldest first, missing left out)", got, want)
+	}
+
+	files, _ = database.GetStaleFiles(90*day, "disk1", 1)
+	if got := paths(files); fmt.Sprint(got) != "[/mnt/disk1/older]" {
+		t.Errorf("disk1 with limit 1 = %v", got)
+	}
+}
+
+func TestStatsSnapshots(t *testing.T) {
+	database := openTestDB(t)
+
+	now := time.Now()
+	tx, _ := database.BeginBatch()
+	database.UpsertFileTx(tx, &FileRecord{
+		Path: "/mnt/disk1/a.txt", Disk: "disk1", Size: 100, Mtime: 1000,
+		SHA256: "h1", FirstSeen: now, LastVerified: now, Status: "ok",
+	})
+	database.UpsertFileTx(tx, &FileRecord{
+		Path: "/mnt/disk1/b.txt", Disk: "disk1", Size: 200, Mtime: 1000,
+		SHA256: "h2", FirstSeen: now, LastVerified: now, Status: "corrupted",
+	})
+	tx.Commit()
+
+	if err := database.InsertStatsSnapshot(); err != nil {
+		t.Fatalf("InsertStatsSnapshot: %v", err)
+	}
+
+	snaps, err := database.GetStatsSnapshots(now.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("GetStatsSnapshots: %v", err)
+	}
+	if len(snaps) != 1 {
+		t.Fatalf("got %d snapshots, want 1", len(snaps))
+	}
+	s := snaps[0]
+	if s.TotalFiles != 2 || s.TotalSize != 300 || s.Corrupted != 1 || s.Missing != 0 {
+		t.Errorf("snapshot = %+v, want 2 files / 300 bytes / 1 corrupted / 0 missing", s)
+	}
+
+	// Nothing newer than an hour from now
+	snaps, err = database.GetStatsSnapshots(now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("GetStatsSnapshots: %v", err)
+	}
+	if len(snaps) != 0 {
+		t.Errorf("got %d snapshots in the future, want 0", len(snaps))
+	}
+}
+
+func TestOpenOptions(t *testing.T) {
+	pragmas := func(database *DB) string {
+		var busy, cache, sync int
+		conn := database.conn
+		conn.QueryRow(`PRAGMA busy_timeout`).Scan(&busy)
+		conn.QueryRow(`PRAGMA cache_size`).Scan(&cache)
+		conn.QueryRow(`PRAGMA synchronous`).Scan(&sync)
+		return fmt.Sprint(busy, cache, sync)
+	}
+	dir := t.TempDir()
+
+	database, err := Open(filepath.Join(dir, "default.db"), Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	// synchronous: 1 = NORMAL, 2 = FULL
+	if got := pragmas(database); got != "5000 -64000 1" {
+		t.Errorf("default pragmas = %s, want 5000 -64000 1", got)
+	}
+	database.Close()
+
+	database, err = Open(filepath.Join(dir, "tuned.db"), Options{BusyTimeout: 30 * time.Second, CacheSizeKB: 8000, Synchronous: "full"})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if got := pragmas(database); got != "30000 -8000 2" {
+		t.Errorf("tuned pragmas = %s, want 30000 -8000 2", got)
+	}
+	database.Close()
+
+	if _, err := Open(filepath.Join(dir, "bad.db"), Options{Synchronous: "sometimes"}); err == nil {
+		t.Error("Open accepted synchronous mode \"sometimes\"")
+	}
+}
+
+func TestOpenMemory(t *testing.T) {
+	database, err := Open(MemoryPath, Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer database.Close()
+
+	now := time.Now()
+	tx, err := database.BeginBatch()
+	if err != nil {
+		t.Fatalf("BeginBatch: %v", err)
+	}
+	database.UpsertFileTx(tx, &FileRecord{Path: "/mnt/disk1/a", Disk: "disk1", Size: 5, SHA256: "a", FirstSeen: now, LastVerified: now, Status: "ok"})
+	database.UpsertFileTx(tx, &FileRecord{Path: "/mnt/disk2/b", Disk: "disk2", Size: 7, SHA256: "b", FirstSeen: now, LastVerified: now, Status: "corrupted"})
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	// Every query must see the same database, not a fresh one per connection
+	stats, err := database.GetStats(StatsOptions{})
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+	if stats.TotalFiles != 2 || stats.TotalSize != 12 {
+		t.Errorf("stats = %d files, %d bytes; want 2, 12", stats.TotalFiles, stats.TotalSize)
+	}
+	corrupted, err := database.GetFilesByStatus("corrupted")
+	if err != nil || len(corrupted) != 1 || corrupted[0].Path != "/mnt/disk2/b" {
+		t.Errorf("GetFilesByStatus(corrupted) = %v, %v", corrupted, err)
+	}
+	disks, err := database.GetDiskStats(StatsOptions{})
+	if err != nil || len(disks) != 2 {
+		t.Errorf("GetDiskStats = %v, %v; want 2 disks", disks, err)
+	}
+}
+
+func TestFileAlgoColumn(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+	database, err := Open(path, Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	now := time.Now()
+	tx, _ := database.BeginBatch()
+	database.UpsertFileTx(tx, &FileRecord{Path: "/mnt/disk1/a", Disk: "disk1", SHA256: "a", FirstSeen: now, LastVerified: now, Status: "ok"})
+	database.UpsertFileTx(tx, &FileRecord{Path: "/mnt/disk1/b", Disk: "disk1", SHA256: "b", FirstSeen: now, LastVerified: now, Status: "ok", Algo: "sha512"})
+	tx.Commit()
+	database.Close()
+
+	// Reopening must not try to add the column a second time
+	database, err = Open(path, Options{})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer database.Close()
+
+	files, err := database.GetAllFiles()
+	if err != nil {
+		t.Fatalf("GetAllFiles: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("got %d files, want 2", len(files))
+	}
+	if files[0].Algo != "sha256" {
+		t.Errorf("a: Algo = %q, want sha256 (default)", files[0].Algo)
+	}
+	if files[1].Algo != "sha512" {
+		t.Errorf("b: Algo = %q, want sha512", files[1].Algo)
+	}
+}
+
+func TestAcknowledgeFile(t *testing.T) {
+	database := openTestDB(t)
+	now := time.Now()
+
+	tx, _ := database.BeginBatch()
+	database.UpsertFileTx(tx, &FileRecord{Path: "/mnt/disk1/bad", Disk: "disk1", SHA256: "a", FirstSeen: now, LastVerified: now, Status: "corrupted"})
+	database.UpsertFileTx(tx, &FileRecord{Path: "/mnt/disk1/good", Disk: "disk1", SHA256: "b", FirstSeen: now, LastVerified: now, Status: "ok"})
+	tx.Commit()
+
+	if err := database.AcknowledgeFile("/mnt/disk1/good"); err == nil {
+		t.Error("expected error acknowledging an ok file")
+	}
+	if err := database.AcknowledgeFile("/mnt/disk1/nope"); err == nil {
+		t.Error("expected error acknowledging an untracked file")
+	}
+	if err := database.AcknowledgeFile("/mnt/disk1/bad"); err != nil {
+		t.Fatalf("AcknowledgeFile: %v", err)
+	}
+
+	stats, err := database.GetStats(StatsOptions{})
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+	if stats.CorruptedFiles != 0 {
+		t.Errorf("CorruptedFiles = %d, want 0", stats.CorruptedFiles)
+	}
+	if stats.AckedFiles != 1 {
+		t.Errorf("AckedFiles = %d, want 1", stats.AckedFiles)
+	}
+}
+
+func TestGetFilesMatching(t *testing.T) {
+	database := openTestDB(t)
+	now := time.Now()
+
+	tx, _ := database.BeginBatch()
+	for _, f := range []*FileRecord{
+		{Path: "/mnt/disk1/movies/a.mkv", Disk: "disk1", Status: "corrupted"},
+		{Path: "/mnt/disk1/movies/sub/b.mkv", Disk: "disk1", Status: "ok"},
+		{Path: "/mnt/disk2/movies/c.mkv", Disk: "disk2", Status: "corrupted"},
+		{Path: "/mnt/disk2/photos/d.jpg", Disk: "disk2", Status: "ok"},
+	} {
+		f.SHA256, f.FirstSeen, f.LastVerified = "x", now, now
+		database.UpsertFileTx(tx, f)
+	}
+	tx.Commit()
+
+	tests := []struct {
+		name   string
+		paths  []string
+		globs  []string
+		disk   string
+		status string
+		want   int
+	}{
+		{"exact path", []string{"/mnt/disk2/photos/d.jpg"}, nil, "", "", 1},
+		{"glob crosses directories", nil, []string{"/mnt/disk1/movies/*"}, "", "", 2},
+		{"glob with disk", nil, []string{"/mnt/*.mkv"}, "disk2", "", 1},
+		{"status only", nil, nil, "", "corrupted", 2},
+		{"status and disk", nil, nil, "disk1", "corrupted", 1},
+	}
+	for _, tt := range tests {
+		files, err := database.GetFilesMatching(tt.paths, tt.globs, tt.disk, tt.status)
+		if err != nil {
+			t.Fatalf("%s: GetFilesMatching: %v", tt.name, err)
+		}
+		if len(files) != tt.want {
+			t.Errorf("%s: got %d files, want %d", tt.name, len(files), tt.want)
+		}
+	}
+}
+
+func TestRebaselineFileTx(t *testing.T) {
+	database := openTestDB(t)
+	now := time.Now()
+
+	old := &FileRecord{Path: "/mnt/disk1/movie.mkv", Disk: "disk1", Size: 100, Mtime: 1, SHA256: "old", FirstSeen: now, LastVerified: now, Status: "corrupted"}
+	tx, _ := database.BeginBatch()
+	database.UpsertFileTx(tx, old)
+	tx.Commit()
+
+	tx, _ = database.BeginBatch()
+	if err := database.RebaselineFileTx(tx, old, "new", "", nil, 80, 2, 0); err != nil {
+		t.Fatalf("RebaselineFileTx: %v", err)
+	}
+	tx.Commit()
+
+	files, _ := database.GetAllFiles()
+	if len(files) != 1 {
+		t.Fatalf("got %d files, want 1", len(files))
+	}
+	f := files[0]
+	if f.SHA256 != "new" || f.Size != 80 || f.Mtime != 2 || f.Status != "ok" {
+		t.Errorf("record = %+v, want new hash/size/mtime and status ok", f)
+	}
+
+	history, err := database.GetFileHistory(old.Path)
+	if err != nil {
+		t.Fatalf("GetFileHistory: %v", err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("got %d history entries, want 1", len(history))
+	}
+	if h := history[0]; h.Reason != "rehash" || h.OldSHA256 != "old" || h.NewSHA256 != "new" || h.OldSize != 100 || h.NewSize != 80 {
+		t.Errorf("history = %+v", h)
+	}
+
+	// Re-baselining unchanged content adds no history
+	tx, _ = database.BeginBatch()
+	database.RebaselineFileTx(tx, f, "new", "", nil, 80, 2, 0)
+	tx.Commit()
+	history, _ = database.GetFileHistory(old.Path)
+	if len(history) != 1 {
+		t.Errorf("got %d history entries after no-op rehash, want 1", len(history))
+	}
+}
+
+func TestChunkedRecord(t *testing.T) {
+	database := openTestDB(t)
+	now := time.Now()
+
+	rec := &FileRecord{Path: "/mnt/disk1/app.log", Disk: "disk1", Size: 100, SHA256: "root", FirstSeen: now, LastVerified: now, Status: "ok",
+		ChunkSize: 64, Chunks: []string{"c0", "c1"}}
+	tx, _ := database.BeginBatch()
+	database.UpsertFileTx(tx, rec)
+	tx.Commit()
+
+	got, _ := database.GetFileByPath(rec.Path)
+	if got.ChunkSize != 64 {
+		t.Errorf("ChunkSize = %d, want 64", got.ChunkSize)
+	}
+	chunks, err := database.GetChunks(rec.Path)
+	if err != nil {
+		t.Fatalf("GetChunks: %v", err)
+	}
+	if len(chunks) != 2 || chunks[0] != "c0" || chunks[1] != "c1" {
+		t.Errorf("chunks = %v, want [c0 c1]", chunks)
+	}
+	lookup, _ := database.LoadQuickLookupMap()
+	if lookup[rec.Path].ChunkSize != 64 {
+		t.Errorf("lookup ChunkSize = %d, want 64", lookup[rec.Path].ChunkSize)
+	}
+	if cands, _ := database.FindMoveCandidates("app.log", 100, "", 0, 20); len(cands) != 0 {
+		t.Errorf("whole-file move lookup found chunked record %v", cands)
+	}
+
+	// A chunked rehash replaces the chunk list
+	tx, _ = database.BeginBatch()
+	if err := database.RebaselineFileTx(tx, got, "root2", "", []string{"c0", "c1", "c2"}, 150, 1, 0); err != nil {
+		t.Fatalf("RebaselineFileTx: %v", err)
+	}
+	tx.Commit()
+	got, _ = database.GetFileByPath(rec.Path)
+	chunks, _ = database.GetChunks(rec.Path)
+	if got.ChunkSize != 64 || len(chunks) != 3 || chunks[2] != "c2" {
+		t.Errorf("after chunked rehash: ChunkSize = %d, chunks = %v, want 64, [c0 c1 c2]", got.ChunkSize, chunks)
+	}
+
+	// A whole-file rehash drops the chunks
+	tx, _ = database.BeginBatch()
+	database.RebaselineFileTx(tx, got, "whole", "", nil, 150, 1, 0)
+	tx.Commit()
+	got, _ = database.GetFileByPath(rec.Path)
+	chunks, _ = database.GetChunks(rec.Path)
+	if got.ChunkSize != 0 || chunks != nil {
+		t.Errorf("after rehash: ChunkSize = %d, chunks = %v, want none", got.ChunkSize, chunks)
+	}
+}
+
+func TestMigrateHash(t *testing.T) {
+	database := openTestDB(t)
+	now := time.Now()
+
+	tx, _ := database.BeginBatch()
+	database.UpsertFileTx(tx, &FileRecord{Path: "/mnt/disk1/a", Disk: "disk1", Size: 10, SHA256: "old", FirstSeen: now, LastVerified: now, Status: "ok"})
+	tx.Commit()
+
+	// Stale expectations are refused
+	if err := database.MigrateHash("/mnt/disk1/a", "sha256", "other", "sha512", "new"); err == nil {
+		t.Error("MigrateHash with a stale hash: expected error")
+	}
+	if err := database.MigrateHash("/mnt/disk1/a", "sha256", "old", "sha512", "new"); err != nil {
+		t.Fatalf("MigrateHash: %v", err)
+	}
+
+	f, _ := database.GetFileByPath("/mnt/disk1/a")
+	if f.Algo != "sha512" || f.SHA256 != "new" {
+		t.Errorf("record = %s/%s, want sha512/new", f.Algo, f.SHA256)
+	}
+	history, _ := database.GetFileHistory("/mnt/disk1/a")
+	if len(history) != 1 || history[0].Reason != "migrate:sha256->sha512" || history[0].OldSHA256 != "old" || history[0].NewSize != 10 {
+		t.Errorf("history = %+v", history)
+	}
+}
+
+func TestFindDuplicates(t *testing.T) {
+	database := openTestDB(t)
+	now := time.Now()
+
+	tx, _ := database.BeginBatch()
+	for _, f := range []*FileRecord{
+		{Path: "/mnt/disk1/iso", Disk: "disk1", Size: 8000, SHA256: "iso", Status: "ok"},
+		{Path: "/mnt/disk2/iso", Disk: "disk2", Size: 8000, SHA256: "iso", Status: "ok"},
+		{Path: "/mnt/disk3/iso", Disk: "disk3", Size: 8000, SHA256: "iso", Status: "ok"},
+		{Path: "/mnt/disk1/a.txt", Disk: "disk1", Size: 10, SHA256: "txt", Status: "ok"},
+		{Path: "/mnt/disk2/a.txt", Disk: "disk2", Size: 10, SHA256: "txt", Status: "ok"},
+		{Path: "/mnt/disk1/gone", Disk: "disk1", Size: 500, SHA256: "gone", Status: "ok"},
+		{Path: "/mnt/disk2/gone", Disk: "disk2", Size: 500, SHA256: "gone", Status: "missing"},
+		{Path: "/mnt/disk1/unique", Disk: "disk1", Size: 99, SHA256: "unique", Status: "ok"},
+	} {
+		f.FirstSeen, f.LastVerified = now, now
+		database.UpsertFileTx(tx, f)
+	}
+	tx.Commit()
+
+	sets, err := database.FindDuplicates(2, 0)
+	if err != nil {
+		t.Fatalf("FindDuplicates: %v", err)
+	}
+	if len(sets) != 2 {
+		t.Fatalf("got %d sets, want 2", len(sets))
+	}
+	if len(sets[0]) != 3 || sets[0][0].SHA256 != "iso" {
+		t.Errorf("first set = %d x %q, want 3 x iso (most wasted space)", len(sets[0]), sets[0][0].SHA256)
+	}
+	if len(sets[1]) != 2 || sets[1][0].SHA256 != "txt" {
+		t.Errorf("second set = %d x %q, want 2 x txt", len(sets[1]), sets[1][0].SHA256)
+	}
+
+	sets, _ = database.FindDuplicates(2, 100)
+	if len(sets) != 1 {
+		t.Errorf("with minSize 100: got %d sets, want 1", len(sets))
+	}
+	sets, _ = database.FindDuplicates(3, 0)
+	if len(sets) != 1 {
+		t.Errorf("with minCount 3: got %d sets, want 1", len(sets))
+	}
+
+	stats, err := database.GetStats(StatsOptions{Duplicates: true})
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+	// Two extra isos and one extra a.txt; the missing copy of gone doesn't count
+	if stats.DuplicateFiles != 3 || stats.ReclaimableBytes != 2*8000+10 {
+		t.Errorf("duplicates = %d files, %d bytes; want 3 files, %d bytes", stats.DuplicateFiles, stats.ReclaimableBytes, 2*8000+10)
+	}
+	stats, _ = database.GetStats(StatsOptions{})
+	if stats.DuplicateFiles != 0 || stats.ReclaimableBytes != 0 {
+		t.Errorf("without Duplicates: got %d files, %d bytes; want zeros", stats.DuplicateFiles, stats.ReclaimableBytes)
+	}
+}
+
+func TestRelativeAndAbsolutePath(t *testing.T) {
+	rel, err := RelativePath("/mnt/disk1/Movies/a.mkv", "/mnt")
+	if err != nil {
+		t.Fatalf("RelativePath: %v", err)
+	}
+	if rel != "disk1/Movies/a.mkv" {
+		t.Errorf("RelativePath = %q, want disk1/Movies/a.mkv", rel)
+	}
+	for _, abs := range []string{"/mnt", "/srv/disk1/a"} {
+		if _, err := RelativePath(abs, "/mnt"); err == nil {
+			t.Errorf("RelativePath(%q, /mnt): expected error", abs)
+		}
+	}
+
+	if got := AbsolutePath("disk1/Movies/a.mkv", "/media/array"); got != "/media/array/disk1/Movies/a.mkv" {
+		t.Errorf("AbsolutePath(relative) = %q", got)
+	}
+	if got := AbsolutePath("/mnt/disk1/a", "/media/array"); got != "/mnt/disk1/a" {
+		t.Errorf("AbsolutePath(absolute) = %q, want unchanged", got)
+	}
+}
+
+func TestSameMtime(t *testing.T) {
+	tests := []struct {
+		storedSec, storedNsec, sec, nsec int64
+		want                             bool
+	}{
+		{100, 500, 100, 500, true},
+		{100, 500, 100, 900, false}, // rewritten within the same second
+		{100, 0, 100, 900, true},    // legacy row: seconds only
+		{100, 500, 101, 500, false},
+		{100, 0, 101, 0, false},
+	}
+	for _, tt := range tests {
+		if got := SameMtime(tt.storedSec, tt.storedNsec, tt.sec, tt.nsec); got != tt.want {
+			t.Errorf("SameMtime(%d, %d, %d, %d) = %v, want %v",
+				tt.storedSec, tt.storedNsec, tt.sec, tt.nsec, got, tt.want)
+		}
+	}
+}
+
+func TestMtimeAfter(t *testing.T) {
+	tests := []struct {
+		storedSec, storedNsec, sec, nsec int64
+		want                             bool
+	}{
+		{100, 500, 101, 0, true},
+		{100, 500, 100, 900, true},
+		{100, 500, 100, 500, false},
+		{100, 500, 99, 900, false},
+		{100, 0, 100, 900, false}, // legacy row: seconds only
+	}
+	for _, tt := range tests {
+		if got := MtimeAfter(tt.storedSec, tt.storedNsec, tt.sec, tt.nsec); got != tt.want {
+			t.Errorf("MtimeAfter(%d, %d, %d, %d) = %v, want %v",
+				tt.storedSec, tt.storedNsec, tt.sec, tt.nsec, got, tt.want)
+		}
+	}
+}
+
+func TestPickMoveSourceMultipleCandidates(t *testing.T) {
+	database := openTestDB(t)
+
+	now := time.Now().Truncate(time.Second)
+	tx, err := database.BeginBatch()
+	if err != nil {
+		t.Fatalf("BeginBatch: %v", err)
+	}
+	// Two same-named files of equal size; only the second has the new file's hash.
+	for _, r := range []*FileRecord{
+		{Path: "/mnt/disk1/a/movie.mkv", Disk: "disk1", Size: 4096, Mtime: now.Unix(), SHA256: "aaaa1111aaaa1111", FirstSeen: now, LastVerified: now, Status: "ok"},
+		{Path: "/mnt/disk1/b/movie.mkv", Disk: "disk1", Size: 4096, Mtime: now.Unix(), SHA256: "bbbb2222bbbb2222", FirstSeen: now, LastVerified: now.Add(-time.Hour), Status: "ok"},
+	} {
+		if err := database.UpsertFileTx(tx, r); err != nil {
+			tx.Rollback()
+			t.Fatalf("UpsertFileTx: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	cands, err := database.FindMoveCandidates("movie.mkv", 4096, "", 0, 20)
+	if err != nil {
+		t.Fatalf("FindMoveCandidates: %v", err)
+	}
+	if len(cands) != 2 || cands[0].Path != "/mnt/disk1/a/movie.mkv" {
+		t.Fatalf("expected a/movie.mkv first of 2 candidates, got %d", len(cands))
+	}
+
+	allGone := func(string) bool { return true }
+	newPath := "/mnt/disk2/c/movie.mkv"
+
+	// The hash match further down the list wins over the first gone candidate.
+	moved, suspect := PickMoveSource(cands, newPath, "bbbb2222bbbb2222", allGone)
+	if moved == nil || moved.Path != "/mnt/disk1/b/movie.mkv" || suspect != nil {
+		t.Errorf("expected move from b/movie.mkv, got moved=%v suspect=%v", moved, suspect)
+	}
+
+	// No hash match among gone candidates: the first gone one is the suspect.
+	moved, suspect = PickMoveSource(cands, newPath, "cccc3333cccc3333", allGone)
+	if moved != nil || suspect == nil || suspect.Path != "/mnt/disk1/a/movie.mkv" {
+		t.Errorf("expected suspect a/movie.mkv, got moved=%v suspect=%v", moved, suspect)
+	}
+
+	// A matching candidate whose old path still exists is a copy, not a move.
+	onlyA := func(p string) bool { return p == "/mnt/disk1/a/movie.mkv" }
+	moved, suspect = PickMoveSource(cands, newPath, "bbbb2222bbbb2222", onlyA)
+	if moved != nil || suspect == nil || suspect.Path != "/mnt/disk1/a/movie.mkv" {
+		t.Errorf("expected suspect a/movie.mkv, got moved=%v suspect=%v", moved, suspect)
+	}
+
+	// Nothing gone: neither.
+	moved, suspect = PickMoveSource(cands, newPath, "bbbb2222bbbb2222", func(string) bool { return false })
+	if moved != nil || suspect != nil {
+		t.Errorf("expected no move, got moved=%v suspect=%v", moved, suspect)
+	}
+}
+
+func TestWriter(t *testing.T) {
+	database := openTestDB(t)
+
+	now := time.Now().Truncate(time.Second)
+	rec := func(path string) *FileRecord {
+		return &FileRecord{Path: path, Disk: "disk1", Size: 10, Mtime: now.Unix(), SHA256: "abc", FirstSeen: now, LastVerified: now, Status: "ok"}
+	}
+
+	var errs []error
+	w := database.NewWriter(2, 0, func(err error) { errs = append(errs, err) })
+	w.Upsert(rec("/mnt/disk1/a"))
+	w.Upsert(rec("/mnt/disk1/b"))
+	w.Upsert(rec("/mnt/disk1/c"))
+	w.Move("/mnt/disk1/c", rec("/mnt/disk1/sub/c"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+
+	files, err := database.GetFilesByDisk("disk1")
+	if err != nil {
+		t.Fatalf("GetFilesByDisk: %v", err)
+	}
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	want := []string{"/mnt/disk1/a", "/mnt/disk1/b", "/mnt/disk1/sub/c"}
+	if fmt.Sprint(paths) != fmt.Sprint(want) {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+	if len(errs) != 0 {
+		t.Errorf("unexpected write errors: %v", errs)
+	}
+}
+
+func TestScanErrors(t *testing.T) {
+	database := openTestDB(t)
+
+	id, err := database.InsertScanHistory("scan", "disk1", "v1", "sha256")
+	if err != nil {
+		t.Fatalf("InsertScanHistory: %v", err)
+	}
+	other, _ := database.InsertScanHistory("scan", "disk2", "v1", "sha256")
+
+	if err := database.InsertScanError(id, "/mnt/disk1/private", "open /mnt/disk1/private: permission denied"); err != nil {
+		t.Fatalf("InsertScanError: %v", err)
+	}
+	w := database.NewWriter(10, 0, func(err error) { t.Errorf("write error: %v", err) })
+	w.ScanError(id, "/mnt/disk1/bad.iso", "read: input/output error")
+	w.ScanError(other, "/mnt/disk2/x", "elsewhere")
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	errs, err := database.GetScanErrors(id, 0)
+	if err != nil {
+		t.Fatalf("GetScanErrors: %v", err)
+	}
+	if len(errs) != 2 || errs[0].Path != "/mnt/disk1/private" || errs[1].Message != "read: input/output error" {
+		t.Fatalf("errors = %+v, want the two of run %d in order", errs, id)
+	}
+	if errs[0].ScanID != id || errs[0].OccurredAt.IsZero() {
+		t.Errorf("first error = %+v, want scan ID %d and a time", errs[0], id)
+	}
+	if errs, _ := database.GetScanErrors(id, 1); len(errs) != 1 {
+		t.Errorf("with limit 1: got %d errors", len(errs))
+	}
+
+	history, _ := database.GetScanHistory(10)
+	for _, h := range history {
+		want := 2
+		if h["id"] == other {
+			want = 1
+		}
+		if h["logged_errors"] != want {
+			t.Errorf("run %v logged_errors = %v, want %d", h["id"], h["logged_errors"], want)
+		}
+	}
+}
+
+func TestWriterFlushesOnInterval(t *testing.T) {
+	database := openTestDB(t)
+
+	now := time.Now().Truncate(time.Second)
+	w := database.NewWriter(1000, 20*time.Millisecond, nil)
+	defer w.Close()
+	w.Upsert(&FileRecord{Path: "/mnt/disk1/a", Disk: "disk1", Size: 1, Mtime: now.Unix(), SHA256: "x", FirstSeen: now, LastVerified: now, Status: "ok"})
+
+	// Far below the batch size, so only the timer can make it visible to readers.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		f, err := database.GetFileByPath("/mnt/disk1/a")
+		if err != nil {
+			t.Fatalf("GetFileByPath: %v", err)
+		}
+		if f != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("write was not flushed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestBusyTimeoutOnEveryConnection(t *testing.T) {
+	database := openTestDB(t)
+
+	// Hold one connection in a transaction so the query below uses another.
+	tx, err := database.BeginBatch()
+	if err != nil {
+		t.Fatalf("BeginBatch: %v", err)
+	}
+	defer tx.Rollback()
+
+	var timeout int
+	if err := database.conn.QueryRow("PRAGMA busy_timeout").Scan(&timeout); err != nil {
+		t.Fatalf("busy_timeout: %v", err)
+	}
+	if timeout != 5000 {
+		t.Errorf("busy_timeout = %d, want 5000", timeout)
+	}
+}
+
+func TestVacuumAndIntegrityCheck(t *testing.T) {
+	database := openTestDB(t)
+
+	now := time.Now().Truncate(time.Second)
+	tx, err := database.BeginBatch()
+	if err != nil {
+		t.Fatalf("BeginBatch: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		r := &FileRecord{Path: fmt.Sprintf("/mnt/disk1/f%03d", i), Disk: "disk1", Size: 1, Mtime: now.Unix(), SHA256: "x", FirstSeen: now, LastVerified: now, Status: "ok"}
+		if err := database.UpsertFileTx(tx, r); err != nil {
+			tx.Rollback()
+			t.Fatalf("UpsertFileTx: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if _, err := database.conn.Exec("DELETE FROM files WHERE path > '/mnt/disk1/f010'"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	if err := database.Vacuum(); err != nil {
+		t.Fatalf("Vacuum: %v", err)
+	}
+	n, err := database.CountFiles("")
+	if err != nil {
+		t.Fatalf("CountFiles: %v", err)
+	}
+	if n != 11 {
+		t.Errorf("CountFiles after vacuum = %d, want 11", n)
+	}
+
+	problems, err := database.IntegrityCheck()
+	if err != nil {
+		t.Fatalf("IntegrityCheck: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("IntegrityCheck on a healthy database = %v, want none", problems)
+	}
+}
+
+func TestBackupTo(t *testing.T) {
+	database := openTestDB(t)
+
+	now := time.Now()
+	tx, _ := database.BeginBatch()
+	database.UpsertFileTx(tx, &FileRecord{Path: "/mnt/disk1/a", Disk: "disk1", Size: 1, SHA256: "a", FirstSeen: now, LastVerified: now, Status: "ok"})
+	tx.Commit()
+
+	out := filepath.Join(t.TempDir(), "backup.db")
+	if err := database.BackupTo(out); err != nil {
+		t.Fatalf("BackupTo: %v", err)
+	}
+	// Never overwrite an existing file, e.g. an older backup
+	if err := database.BackupTo(out); err == nil {
+		t.Error("BackupTo overwrote an existing file")
+	}
+
+	backup, err := Open(out, Options{})
+	if err != nil {
+		t.Fatalf("open backup: %v", err)
+	}
+	defer backup.Close()
+	f, err := backup.GetFileByPath("/mnt/disk1/a")
+	if err != nil || f == nil || f.SHA256 != "a" {
+		t.Errorf("backup record = %+v, %v; want the original", f, err)
+	}
+}
+
+func TestGetExtensionStats(t *testing.T) {
+	database := openTestDB(t)
+
+	now := time.Now().Truncate(time.Second)
+	tx, err := database.BeginBatch()
+	if err != nil {
+		t.Fatalf("BeginBatch: %v", err)
+	}
+	for _, r := range []struct {
+		path   string
+		size   int64
+		status string
+	}{
+		{"/mnt/disk1/a.MKV", 1000, "ok"},
+		{"/mnt/disk1/b.mkv", 2000, "ok"},
+		{"/mnt/disk1/c.jpg", 10, "ok"},
+		{"/mnt/disk1/d.jpg", 10, "corrupted"},
+		{"/mnt/disk1/e.jpg", 10, "missing"},
+		{"/mnt/disk1/README", 5, "ok"},
+		{"/mnt/disk1/.bashrc", 5, "ok"},
+	} {
+		rec := &FileRecord{Path: r.path, Disk: "disk1", Size: r.size, Mtime: now.Unix(), SHA256: "x", FirstSeen: now, LastVerified: now, Status: r.status}
+		if err := database.UpsertFileTx(tx, rec); err != nil {
+			tx.Rollback()
+			t.Fatalf("UpsertFileTx: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	stats, err := database.GetExtensionStats()
+	if err != nil {
+		t.Fatalf("GetExtensionStats: %v", err)
+	}
+	want := []ExtStat{
+		{Ext: ".mkv", Files: 2, TotalSize: 3000},
+		{Ext: ".jpg", Files: 2, TotalSize: 20},
+		{Ext: "", Files: 2, TotalSize: 10},
+	}
+	if fmt.Sprint(stats) != fmt.Sprint(want) {
+		t.Errorf("GetExtensionStats = %v, want %v", stats, want)
+	}
+}
+
+func TestFileExt(t *testing.T) {
+	tests := map[string]string{
+		"/mnt/disk1/Movies/a.MKV": ".mkv",
+		"disk1/archive.tar.gz":    ".gz",
+		"/mnt/disk1/.bashrc":      "",
+		"/mnt/disk1/.config.json": ".json",
+		"/mnt/disk1/Makefile":     "",
+		"/mnt/disk1/trailing.":    "",
+		"/mnt/disk1/dir.d/noext":  "",
+	}
+	for path, want := range tests {
+		if got := FileExt(path); got != want {
+			t.Errorf("FileExt(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestListFiles(t *testing.T) {
+	database := openTestDB(t)
+
+	now := time.Now().Truncate(time.Second)
+	tx, err := database.BeginBatch()
+	if err != nil {
+		t.Fatalf("BeginBatch: %v", err)
+	}
+	for _, r := range []struct{ path, disk, status string }{
+		{"/mnt/disk1/a", "disk1", "corrupted"},
+		{"/mnt/disk1/b", "disk1", "ok"},
+		{"/mnt/disk2/c", "disk2", "corrupted"},
+		{"/mnt/disk2/d", "disk2", "corrupted"},
+	} {
+		rec := &FileRecord{Path: r.path, Disk: r.disk, Size: 1, Mtime: now.Unix(), SHA256: "x", FirstSeen: now, LastVerified: now, Status: r.status}
+		if err := database.UpsertFileTx(tx, rec); err != nil {
+			tx.Rollback()
+			t.Fatalf("UpsertFileTx: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	tests := []struct {
+		status, disk string
+		limit        int
+		want         []string
+	}{
+		{"", "", 0, []string{"/mnt/disk1/a", "/mnt/disk1/b", "/mnt/disk2/c", "/mnt/disk2/d"}},
+		{"corrupted", "", 0, []string{"/mnt/disk1/a", "/mnt/disk2/c", "/mnt/disk2/d"}},
+		{"corrupted", "disk2", 0, []string{"/mnt/disk2/c", "/mnt/disk2/d"}},
+		{"corrupted", "", 2, []string{"/mnt/disk1/a", "/mnt/disk2/c"}},
+		{"missing", "", 0, nil},
+	}
+	for _, tt := range tests {
+		files, err := database.ListFiles(tt.status, tt.disk, tt.limit)
+		if err != nil {
+			t.Fatalf("ListFiles(%q, %q, %d): %v", tt.status, tt.disk, tt.limit, err)
+		}
+		var got []string
+		for _, f := range files {
+			got = append(got, f.Path)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("ListFiles(%q, %q, %d) = %v, want %v", tt.status, tt.disk, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestFileTags(t *testing.T) {
+	database := openTestDB(t)
+
+	now := time.Now()
+	tx, _ := database.BeginBatch()
+	database.UpsertFileTx(tx, &FileRecord{
+		Path: "/mnt/disk1/backups/a.tar", Disk: "disk1", Size: 100,
+		Mtime: now.Unix(), SHA256: "h1", FirstSeen: now, LastVerified: now, Status: "ok", Tag: "backups",
+	})
+	database.UpsertFileTx(tx, &FileRecord{
+		Path: "/mnt/disk2/backups/b.tar", Disk: "disk2", Size: 200,
+		Mtime: now.Unix(), SHA256: "h2", FirstSeen: now, LastVerified: now, Status: "corrupted", Tag: "backups",
+	})
+	database.UpsertFileTx(tx, &FileRecord{
+		Path: "/mnt/disk1/media/c.mkv", Disk: "disk1", Size: 300,
+		Mtime: now.Unix(), SHA256: "h3", FirstSeen: now, LastVerified: now, Status: "ok",
+	})
+	// Re-hashed without a tag: keeps "backups"
+	database.UpsertFileTx(tx, &FileRecord{
+		Path: "/mnt/disk1/backups/a.tar", Disk: "disk1", Size: 100,
+		Mtime: now.Unix(), SHA256: "h1", FirstSeen: now, LastVerified: now, Status: "ok",
+	})
+	if err := database.SetTagTx(tx, "media", "/mnt/disk1/media/c.mkv"); err != nil {
+		t.Fatalf("SetTagTx: %v", err)
+	}
+	tx.Commit()
+
+	if f, _ := database.GetFileByPath("/mnt/disk1/backups/a.tar"); f == nil || f.Tag != "backups" {
+		t.Errorf("a.tar tag = %+v, want backups", f)
+	}
+	tags, err := database.GetTags()
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	if len(tags) != 2 || tags[0] != "backups" || tags[1] != "media" {
+		t.Errorf("GetTags = %v, want [backups media]", tags)
+	}
+
+	files, err := database.GetFilesByTag("backups", "")
+	if err != nil {
+		t.Fatalf("GetFilesByTag: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("GetFilesByTag(backups) = %d files, want 2", len(files))
+	}
+	if files, _ := database.GetFilesByTag("backups", "disk2"); len(files) != 1 || files[0].Path != "/mnt/disk2/backups/b.tar" {
+		t.Errorf("GetFilesByTag(backups, disk2) = %v, want b.tar", files)
+	}
+
+	stats, err := database.GetStats(StatsOptions{Tag: "backups"})
+	if err != nil {
+		t.Fatalf("GetStats: %v", err)
+	}
+	if stats.TotalFiles != 2 || stats.TotalSize != 300 || stats.CorruptedFiles != 1 {
+		t.Errorf("GetStats(backups) = %d files, %d bytes, %d corrupted; want 2, 300, 1",
+			stats.TotalFiles, stats.TotalSize, stats.CorruptedFiles)
+	}
+	diskStats, err := database.GetDiskStats(StatsOptions{Tag: "media"})
+	if err != nil {
+		t.Fatalf("GetDiskStats: %v", err)
+	}
+	if len(diskStats) != 1 || diskStats[0].Disk != "disk1" || diskStats[0].TotalFiles != 1 {
+		t.Errorf("GetDiskStats(media) = %+v, want disk1 with 1 file", diskStats)
+	}
+}
+
+func TestGetFilesBySize(t *testing.T) {
+	database := openTestDB(t)
+
+	now := time.Now()
+	tx, _ := database.BeginBatch()
+	for _, f := range []struct {
+		path, disk, status string
+		size               int64
+	}{
+		{"/mnt/disk1/a", "disk1", "ok", 300},
+		{"/mnt/disk1/b", "disk1", "ok", 100},
+		{"/mnt/disk2/c", "disk2", "ok", 200},
+		{"/mnt/disk2/gone", "disk2", "missing", 900},
+	} {
+		database.UpsertFileTx(tx, &FileRecord{
+			Path: f.path, Disk: f.disk, Size: f.size,
+			Mtime: now.Unix(), SHA256: "h" + f.path, FirstSeen: now, LastVerified: now, Status: f.status,
+		})
+	}
+	tx.Commit()
+
+	paths := func(files []*FileRecord) string {
+		var p []string
+		for _, f := range files {
+			p = append(p, f.Path)
+		}
+		return strings.Join(p, ",")
+	}
+	for _, tc := range []struct {
+		order, disk string
+		limit       int
+		want        string
+	}{
+		{"desc", "", 2, "/mnt/disk1/a,/mnt/disk2/c"},
+		{"asc", "", 0, "/mnt/disk1/b,/mnt/disk2/c,/mnt/disk1/a"},
+		{"desc", "disk2", 5, "/mnt/disk2/c"},
+	} {
+		files, err := database.GetFilesBySize(tc.order, tc.limit, tc.disk)
+		if err != nil {
+			t.Fatalf("GetFilesBySize(%s, %d, %q): %v", tc.order, tc.limit, tc.disk, err)
+		}
+		if got := paths(files); got != tc.want {
+			t.Errorf("GetFilesBySize(%s, %d, %q) = %s, want %s", tc.order, tc.limit, tc.disk, got, tc.want)
+		}
+	}
+	if _, err := database.GetFilesBySize("size; DROP TABLE files", 1, ""); err == nil {
+		t.Error("GetFilesBySize accepted an invalid order")
+	}
+}
+
+func TestHashesStoredAsBytes(t *testing.T) {
+	database := openTestDB(t)
+
+	digest := strings.Repeat("0123456789abcdef", 4)
+	now := time.Now()
+	tx, _ := database.BeginBatch()
+	database.UpsertFileTx(tx, &FileRecord{
+		Path: "/mnt/disk1/hex", Disk: "disk1", Size: 1,
+		Mtime: now.Unix(), SHA256: digest, FirstSeen: now, LastVerified: now, Status: "ok",
+	})
+	database.UpsertFileTx(tx, &FileRecord{
+		Path: "/mnt/disk1/odd", Disk: "disk1", Size: 1,
+		Mtime: now.Unix(), SHA256: "NotHex", FirstSeen: now, LastVerified: now, Status: "ok",
+	})
+	tx.Commit()
+
+	typeOf := func(path string) string {
+		var typ string
+		if err := database.conn.QueryRow(`SELECT typeof(sha256) FROM files WHERE path = ?`, path).Scan(&typ); err != nil {
+			t.Fatalf("typeof %s: %v", path, err)
+		}
+		return typ
+	}
+	if typ := typeOf("/mnt/disk1/hex"); typ != "blob" {
+		t.Errorf("hex digest stored as %s, want blob", typ)
+	}
+	if f, _ := database.GetFileByPath("/mnt/disk1/hex"); f == nil || f.SHA256 != digest {
+		t.Errorf("hex digest read back as %+v, want %s", f, digest)
+	}
+	if f, _ := database.GetFileByPath("/mnt/disk1/odd"); f == nil || f.SHA256 != "NotHex" {
+		t.Errorf("non-hex value read back as %+v, want NotHex", f)
+	}
+	lookup, err := database.LoadQuickLookupMap()
+	if err != nil {
+		t.Fatalf("LoadQuickLookupMap: %v", err)
+	}
+	if ql := lookup["/mnt/disk1/hex"]; ql == nil || ql.SHA256 != digest {
+		t.Errorf("quick lookup = %+v, want %s", ql, digest)
+	}
+
+	// A catalog written by an older version holds hex text
+	if _, err := database.conn.Exec(`UPDATE files SET sha256 = ? WHERE path = ?`, digest, "/mnt/disk1/hex"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.conn.Exec(`PRAGMA user_version = 0`); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if typ := typeOf("/mnt/disk1/hex"); typ != "blob" {
+		t.Errorf("after migration hex digest is %s, want blob", typ)
+	}
+	if typ := typeOf("/mnt/disk1/odd"); typ != "text" {
+		t.Errorf("after migration non-hex value is %s, want text", typ)
+	}
+	if f, _ := database.GetFileByPath("/mnt/disk1/hex"); f == nil || f.SHA256 != digest {
+		t.Errorf("migrated digest read back as %+v, want %s", f, digest)
+	}
+}
+
+func TestMigrateVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "old.db")
+
+	// A catalog from before versioning: user_version 0 and only the first
+	// columns of files.
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec(`
+		CREATE TABLE files (
+			id            INTEGER PRIMARY KEY AUTOINCREMENT,
+			path          TEXT NOT NULL UNIQUE,
+			disk          TEXT NOT NULL,
+			size          INTEGER NOT NULL,
+			mtime         INTEGER NOT NULL,
+			sha256        TEXT NOT NULL,
+			first_seen    TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			last_verified TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			status        TEXT NOT NULL DEFAULT 'ok'
+		);
+		INSERT INTO files (path, disk, size, mtime, sha256) VALUES ('/mnt/disk1/a', 'disk1', 1, 0, 'ab');
+	`); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	database, err := Open(path, Options{})
+	if err != nil {
+		t.Fatalf("Open old catalog: %v", err)
+	}
+	if v, err := database.userVersion(); err != nil || v != schemaVersion {
+		t.Errorf("user_version = %d, %v; want %d", v, err, schemaVersion)
+	}
+	f, err := database.GetFileByPath("/mnt/disk1/a")
+	if err != nil || f == nil || f.SHA256 != "ab" || f.Algo != "sha256" {
+		t.Errorf("upgraded record = %+v, %v", f, err)
+	}
+	database.Close()
+
+	// A catalog written by a newer build is refused
+	database, err = Open(path, Options{})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if _, err := database.conn.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, schemaVersion+1)); err != nil {
+		t.Fatal(err)
+	}
+	database.Close()
+
+	if database, err := Open(path, Options{}); err == nil {
+		database.Close()
+		t.Error("Open accepted a catalog from a newer version")
+	}
+}
+
+func TestGetNewlyCorrupted(t *testing.T) {
+	database := openTestDB(t)
+
+	now := time.Now().UTC()
+	tx, err := database.BeginBatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []struct{ path, status string }{
+		{"/mnt/disk1/today", "ok"},
+		{"/mnt/disk1/last-week", "corrupted"},
+		{"/mnt/disk1/repaired", "ok"},
+		{"/mnt/disk1/fine", "ok"},
+	} {
+		if err := database.UpsertFileTx(tx, &FileRecord{
+			Path: f.path, Disk: "disk1", Size: 1, SHA256: "h",
+			FirstSeen: now, LastVerified: now, Status: f.status,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// last-week turned corrupted a week ago and is found corrupted again today
+	if _, err := tx.Exec(`INSERT INTO file_history (path, changed_at, reason, old_sha256, new_sha256, old_size, new_size)
+		VALUES ('/mnt/disk1/last-week', ?, 'corrupted', '', '', 1, 1)`, now.Add(-7*24*time.Hour).Format("2006-01-02 15:04:05")); err != nil {
+		t.Fatal(err)
+	}
+	for _, u := range []struct{ path, status string }{
+		{"/mnt/disk1/today", "corrupted"},
+		{"/mnt/disk1/last-week", "corrupted"},
+		{"/mnt/disk1/repaired", "corrupted"},
+		{"/mnt/disk1/repaired", "ok"},
+		{"/mnt/disk1/fine", "ok"},
+	} {
+		if err := database.UpdateStatusTx(tx, u.path, u.status); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := database.GetNewlyCorrupted(now.Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("GetNewlyCorrupted: %v", err)
+	}
+	var got []string
+	for _, f := range files {
+		got = append(got, f.Path)
+	}
+	want := []string{"/mnt/disk1/today"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("newly corrupted since yesterday = %v, want %v", got, want)
+	}
+
+	changes, err := database.GetFileHistory("/mnt/disk1/last-week")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 {
+		t.Errorf("history of a file found corrupted again has %d rows, want only the first transition", len(changes))
+	}
+	changes, err = database.GetFileHistory("/mnt/disk1/today")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Reason != "corrupted" || changes[0].OldSHA256 != "h" {
+		t.Errorf("history of a newly corrupted file = %+v, want one corrupted row with the stored hash", changes)
+	}
+}
+
+func TestFilePerms(t *testing.T) {
+	database := openTestDB(t)
+
+	now := time.Now()
+	tx, _ := database.BeginBatch()
+	database.UpsertFileTx(tx, &FileRecord{
+		Path: "/mnt/disk1/a", Disk: "disk1", Size: 100, Mtime: now.Unix(), SHA256: "h1",
+		FirstSeen: now, LastVerified: now, Status: "ok", Perms: &Perms{UID: 99, GID: 100, Mode: 0o644},
+	})
+	database.UpsertFileTx(tx, &FileRecord{
+		Path: "/mnt/disk2/b", Disk: "disk2", Size: 200, Mtime: now.Unix(), SHA256: "h2",
+		FirstSeen: now, LastVerified: now, Status: "ok",
+	})
+	// Re-hashed without --track-perms: keeps the recorded owner and mode
+	database.UpsertFileTx(tx, &FileRecord{
+		Path: "/mnt/disk1/a", Disk: "disk1", Size: 100, Mtime: now.Unix(), SHA256: "h1",
+		FirstSeen: now, LastVerified: now, Status: "ok",
+	})
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := database.GetFileByPath("/mnt/disk1/a")
+	if err != nil || f.Perms == nil || *f.Perms != (Perms{UID: 99, GID: 100, Mode: 0o644}) {
+		t.Fatalf("perms of a = %+v, %v", f, err)
+	}
+	if got := f.Perms.String(); got != "0644 99:100" {
+		t.Errorf("Perms.String() = %q", got)
+	}
+	if f, _ := database.GetFileByPath("/mnt/disk2/b"); f.Perms != nil {
+		t.Errorf("untracked b has perms %+v", f.Perms)
+	}
+
+	tx, _ = database.BeginBatch()
+	if err := database.SetPermsTx(tx, "/mnt/disk2/b", Perms{UID: 0, GID: 0, Mode: 0o4755}); err != nil {
+		t.Fatalf("SetPermsTx: %v", err)
+	}
+	tx.Commit()
+
+	lookup, err := database.LoadQuickLookupMap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := lookup["/mnt/disk2/b"].Perms; p == nil || p.Mode != 0o4755 {
+		t.Errorf("lookup perms of b = %+v", p)
+	}
+
+	files, err := database.GetFilesWithPerms("")
+	if err != nil || len(files) != 2 {
+		t.Fatalf("GetFilesWithPerms = %d files, %v; want 2", len(files), err)
+	}
+	files, _ = database.GetFilesWithPerms("disk2")
+	if len(files) != 1 || files[0].Path != "/mnt/disk2/b" {
+		t.Errorf("GetFilesWithPerms(disk2) = %v", files)
+	}
+}
+
+// TestDiskStatusIndex guards against queries narrowed to a disk and status
+// falling back to a table scan or a single-column index.
+func TestDiskStatusIndex(t *testing.T) {
+	database := openTestDB(t)
+
+	plan := func(query string, args ...interface{}) string {
+		t.Helper()
+		rows, err := database.conn.Query("EXPLAIN QUERY PLAN "+query, args...)
+		if err != nil {
+			t.Fatalf("explain %s: %v", query, err)
+		}
+		defer rows.Close()
+		var steps []string
+		for rows.Next() {
+			var id, parent, notused int
+			var detail string
+			if err := rows.Scan(&id, &parent, &notused, &detail); err != nil {
+				t.Fatal(err)
+			}
+			steps = append(steps, detail)
+		}
+		return strings.Join(steps, "; ")
+	}
+
+	query, args := listFilesQuery("corrupted", "disk1", 0)
+	if got := plan(query, args...); !strings.Contains(got, "idx_files_disk_status (disk=? AND status=?)") {
+		t.Errorf("ListFiles(status, disk) plan = %q, want a search of idx_files_disk_status on both columns", got)
+	}
+	query, args = listFilesQuery("", "disk1", 0)
+	if got := plan(query, args...); !strings.Contains(got, "idx_files_disk_status (disk=?)") {
+		t.Errorf("ListFiles(disk) plan = %q, want a search of idx_files_disk_status", got)
+	}
+	if got := plan(`SELECT COUNT(*) FROM files WHERE disk = ? AND status = ?`, "disk1", "corrupted"); !strings.Contains(got, "idx_files_disk_status") {
+		t.Errorf("count by disk and status plan = %q, want idx_files_disk_status", got)
+	}
+}
+
+func TestCatalogAlgo(t *testing.T) {
+	database := openTestDB(t)
+
+	if algo, err := database.CatalogAlgo(); err != nil || algo != "sha256" {
+		t.Errorf("CatalogAlgo of an empty catalog = %q, %v; want sha256", algo, err)
+	}
+
+	now := time.Now()
+	tx, err := database.BeginBatch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []*FileRecord{
+		{Path: "/mnt/disk1/a/movie.mkv", Algo: "blake3"},
+		{Path: "/mnt/disk1/b/movie.mkv", Algo: "blake3"},
+		{Path: "/mnt/disk1/c/movie.mkv", Algo: "sha256"},
+	} {
+		r.Disk, r.Size, r.SHA256, r.FirstSeen, r.LastVerified, r.Status = "disk1", 4096, "aa", now, now, "ok"
+		if err := database.UpsertFileTx(tx, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if algo, err := database.CatalogAlgo(); err != nil || algo != "blake3" {
+		t.Errorf("CatalogAlgo = %q, %v; want blake3, the algorithm of most records", algo, err)
+	}
+	// A hash of one algorithm can't be compared with another's
+	cands, err := database.FindMoveCandidates("movie.mkv", 4096, "blake3", 0, 20)
+	if err != nil {
+		t.Fatalf("FindMoveCandidates: %v", err)
+	}
+	if len(cands) != 2 {
+		t.Errorf("FindMoveCandidates(blake3) found %d records, want the 2 blake3 ones", len(cands))
+	}
+}
@@ -0,0 +1,76 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+)
+
+// Digests are stored as raw bytes rather than hex text, which halves the
+// sha256 column and its index. At the Go boundary they stay lowercase hex
+// strings: pass them through hashToDB on the way in and scan them with
+// hexHash on the way out.
+
+// hashToDB returns the value to store for a hex digest: its bytes when h is
+// lowercase hex, otherwise h unchanged, so anything that isn't a canonical
+// digest still reads back exactly as written.
+func hashToDB(h string) interface{} {
+	if b, ok := hashBytes(h); ok {
+		return b
+	}
+	return h
+}
+
+// hashBytes decodes h if it is non-empty lowercase hex.
+func hashBytes(h string) ([]byte, bool) {
+	if h == "" || len(h)%2 != 0 {
+		return nil, false
+	}
+	for i := 0; i < len(h); i++ {
+		c := h[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return nil, false
+		}
+	}
+	b, err := hex.DecodeString(h)
+	return b, err == nil
+}
+
+// hexHash scans a stored digest, bytes or text, into a hex string.
+type hexHash struct{ s *string }
+
+func (h hexHash) Scan(v interface{}) error {
+	switch v := v.(type) {
+	case []byte:
+		*h.s = hex.EncodeToString(v)
+	case string:
+		*h.s = v
+	case nil:
+		*h.s = ""
+	default:
+		return fmt.Errorf("unexpected hash value of type %T", v)
+	}
+	return nil
+}
+
+// hashesToBinary converts hex digests written by older versions to bytes.
+// It is part of the base schema step, so it runs once per catalog. The freed
+// pages are only returned to the file system by a vacuum (filehasher db
+// vacuum).
+func hashesToBinary(tx *sql.Tx) error {
+	for _, col := range []struct{ table, column string }{
+		{"files", "sha256"},
+		{"file_history", "old_sha256"},
+		{"file_history", "new_sha256"},
+	} {
+		// Same rule as hashBytes: non-empty, even-length lowercase hex
+		if _, err := tx.Exec(fmt.Sprintf(`
+			UPDATE %[1]s SET %[2]s = unhex(%[2]s)
+			WHERE typeof(%[2]s) = 'text' AND %[2]s != '' AND length(%[2]s) %% 2 = 0
+				AND %[2]s NOT GLOB '*[^0-9a-f]*'
+		`, col.table, col.column)); err != nil {
+			return fmt.Errorf("convert %s.%s to binary: %w", col.table, col.column, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// The catalog schema evolves through numbered migrations. PRAGMA
+// user_version holds the number of the last one applied, and Open applies
+// any that follow in order, each in its own transaction together with the
+// version bump, so an interrupted upgrade resumes at the step that failed.
+// Add changes as a new step at the end; never edit or renumber a released
+// one.
+
+type migration struct {
+	version int
+	name    string
+	apply   func(tx *sql.Tx) error
+}
+
+var migrations = []migration{
+	{1, "base schema", baseSchema},
+	{2, "file owner and mode", addPerms},
+	{3, "disk and status index", addDiskStatusIndex},
+}
+
+// addPerms adds the columns scan --track-perms fills; NULL for files scanned
+// without it.
+func addPerms(tx *sql.Tx) error {
+	for _, col := range []string{"uid", "gid", "mode"} {
+		if err := addColumnIfMissing(tx, "files", col, "INTEGER"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addDiskStatusIndex indexes files by disk and status together, for
+// listings narrowed to both. It replaces the disk-only index, which is its
+// prefix.
+func addDiskStatusIndex(tx *sql.Tx) error {
+	if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_files_disk_status ON files(disk, status)`); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DROP INDEX IF EXISTS idx_files_disk`)
+	return err
+}
+
+// schemaVersion is the schema version this build creates and understands.
+var schemaVersion = migrations[len(migrations)-1].version
+
+// userVersion returns the catalog's schema version.
+func (db *DB) userVersion() (int, error) {
+	var version int
+	err := db.conn.QueryRow(`PRAGMA user_version`).Scan(&version)
+	return version, err
+}
+
+// migrate brings the catalog up to schemaVersion. A catalog written by a
+// newer build is refused rather than opened with a schema this one doesn't
+// know.
+func (db *DB) migrate() error {
+	version, err := db.userVersion()
+	if err != nil {
+		return err
+	}
+	if version > schemaVersion {
+		return fmt.Errorf("catalog schema version %d is newer than this filehasher supports (%d); upgrade filehasher", version, schemaVersion)
+	}
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		if err := db.applyMigration(m); err != nil {
+			return fmt.Errorf("schema version %d (%s): %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
+
+// applyMigration runs one step unless another process applied it while
+// this one waited for the write lock.
+func (db *DB) applyMigration(m migration) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var version int
+	if err := tx.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	if version >= m.version {
+		return nil
+	}
+	if err := m.apply(tx); err != nil {
+		return err
+	}
+	// PRAGMA takes no bound parameters
+	if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, m.version)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
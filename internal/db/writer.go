@@ -0,0 +1,207 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Concurrency model: SQLite in WAL mode allows any number of readers next to
+// a single writer. Every pooled connection gets a busy timeout (see Open), so
+// a short write from the dashboard (e.g. acknowledging a file) waits for the
+// current write transaction instead of failing with "database is locked".
+//
+// Long jobs such as scans and verifies send their writes to a Writer, whose
+// goroutine is the only one holding a transaction. It begins a transaction
+// lazily and commits after batchSize writes or after flushEvery, whichever
+// comes first, so the write lock is never held for long while hashing a slow
+// disk. Reads never go through the Writer and only see committed batches.
+
+// Writer applies file upserts, moves, status updates and scan error records
+// on a single goroutine.
+type Writer struct {
+	db      *DB
+	ops     chan writeOp
+	done    chan struct{}
+	onError func(error)
+
+	closeOnce sync.Once
+	mu        sync.Mutex
+	err       error
+}
+
+type writeOp struct {
+	record    *FileRecord
+	movedFrom string     // when set, re-key this path to record instead of inserting
+	scanErr   *ScanError // when set, record this instead
+	verified  *verifiedOp
+}
+
+// verifiedOp records a verify outcome. An empty status only refreshes
+// last_verified.
+type verifiedOp struct {
+	path   string
+	status string
+}
+
+// NewWriter starts a writer goroutine. onError is called on that goroutine for
+// each write that fails; the write is skipped and the writer carries on.
+// Failing to begin or commit a transaction is fatal and reported by Err and
+// Close.
+func (db *DB) NewWriter(batchSize int, flushEvery time.Duration, onError func(error)) *Writer {
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+	w := &Writer{
+		db:      db,
+		ops:     make(chan writeOp, batchSize),
+		done:    make(chan struct{}),
+		onError: onError,
+	}
+	go w.run(batchSize, flushEvery)
+	return w
+}
+
+// Upsert queues an insert or update of f.
+func (w *Writer) Upsert(f *FileRecord) {
+	w.ops <- writeOp{record: f}
+}
+
+// Move queues re-keying the record at oldPath to f's path, disk, size and
+// mtime. If the move fails, f is upserted instead so the new path is tracked.
+func (w *Writer) Move(oldPath string, f *FileRecord) {
+	w.ops <- writeOp{record: f, movedFrom: oldPath}
+}
+
+// ScanError queues recording an error of the scan_history run scanID.
+func (w *Writer) ScanError(scanID int64, path, message string) {
+	w.ops <- writeOp{scanErr: &ScanError{ScanID: scanID, Path: path, Message: message}}
+}
+
+// SetStatus queues setting path's status and last_verified time.
+func (w *Writer) SetStatus(path, status string) {
+	w.ops <- writeOp{verified: &verifiedOp{path: path, status: status}}
+}
+
+// TouchVerified queues refreshing path's last_verified time, for a file
+// whose status stays as it is.
+func (w *Writer) TouchVerified(path string) {
+	w.ops <- writeOp{verified: &verifiedOp{path: path}}
+}
+
+// Err returns the fatal error that stopped the writer, if any. Once set,
+// further writes are discarded.
+func (w *Writer) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Close commits queued writes, stops the goroutine and returns Err. It is
+// safe to call more than once.
+func (w *Writer) Close() error {
+	w.closeOnce.Do(func() { close(w.ops) })
+	<-w.done
+	return w.Err()
+}
+
+func (w *Writer) fail(err error) {
+	w.mu.Lock()
+	if w.err == nil {
+		w.err = err
+	}
+	w.mu.Unlock()
+}
+
+func (w *Writer) run(batchSize int, flushEvery time.Duration) {
+	defer close(w.done)
+
+	var tx *sql.Tx
+	pending := 0
+	commit := func() {
+		if tx == nil {
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			w.fail(fmt.Errorf("commit batch: %w", err))
+		}
+		tx = nil
+		pending = 0
+	}
+
+	var tick <-chan time.Time
+	if flushEvery > 0 {
+		ticker := time.NewTicker(flushEvery)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case op, ok := <-w.ops:
+			if !ok {
+				commit()
+				return
+			}
+			if w.Err() != nil {
+				continue // drain so senders don't block
+			}
+			if tx == nil {
+				var err error
+				if tx, err = w.db.BeginBatch(); err != nil {
+					w.fail(fmt.Errorf("begin batch: %w", err))
+					continue
+				}
+			}
+			if err := w.apply(tx, op); err != nil && w.onError != nil {
+				w.onError(err)
+			}
+			pending++
+			if pending >= batchSize {
+				commit()
+			}
+		case <-tick:
+			commit()
+		}
+	}
+}
+
+func (w *Writer) apply(tx *sql.Tx, op writeOp) error {
+	if v := op.verified; v != nil {
+		var err error
+		if v.status == "" {
+			err = w.db.TouchVerifiedTx(tx, v.path)
+		} else {
+			err = w.db.UpdateStatusTx(tx, v.path, v.status)
+		}
+		if err != nil {
+			return fmt.Errorf("update status of %s: %w", v.path, err)
+		}
+		return nil
+	}
+	if e := op.scanErr; e != nil {
+		if err := w.db.InsertScanErrorTx(tx, e.ScanID, e.Path, e.Message); err != nil {
+			return fmt.Errorf("record scan error for %s: %w", e.Path, err)
+		}
+		return nil
+	}
+	f := op.record
+	if op.movedFrom != "" {
+		err := w.db.MovePathTx(tx, op.movedFrom, f.Path, f.Disk, f.Size, f.Mtime, f.MtimeNsec)
+		if err == nil && f.Tag != "" {
+			err = w.db.SetTagTx(tx, f.Tag, f.Path)
+		}
+		if err == nil {
+			return nil
+		}
+		if upErr := w.db.UpsertFileTx(tx, f); upErr != nil {
+			return fmt.Errorf("move record %s -> %s: %v; store: %w", op.movedFrom, f.Path, err, upErr)
+		}
+		return fmt.Errorf("move record %s -> %s: %w (stored as a new record)", op.movedFrom, f.Path, err)
+	}
+	if err := w.db.UpsertFileTx(tx, f); err != nil {
+		return fmt.Errorf("store %s: %w", f.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package format
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ANSI SGR sequences used for terminal output.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+)
+
+var color bool
+
+// SetColor turns ANSI colors in the helpers below on or off. Colors are off
+// by default; the CLI turns them on when stdout is a terminal and neither
+// --json nor --no-color was given.
+func SetColor(on bool) {
+	color = on
+}
+
+// Color reports whether colors are on.
+func Color() bool {
+	return color
+}
+
+func paint(code, s string) string {
+	if !color || s == "" {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+// Red colors s red when colors are on.
+func Red(s string) string { return paint(ansiRed, s) }
+
+// Yellow colors s yellow when colors are on.
+func Yellow(s string) string { return paint(ansiYellow, s) }
+
+// Green colors s green when colors are on.
+func Green(s string) string { return paint(ansiGreen, s) }
+
+// Bold makes s bold when colors are on.
+func Bold(s string) string { return paint(ansiBold, s) }
+
+// Status colors s by the file status it stands for: corrupted red, missing
+// yellow and ok green. Other statuses stay plain.
+func Status(status, s string) string {
+	switch status {
+	case "corrupted":
+		return Red(s)
+	case "missing":
+		return Yellow(s)
+	case "ok":
+		return Green(s)
+	}
+	return s
+}
+
+// Count formats n files of status, colored like Status unless n is zero so
+// that "Corrupted: 0" doesn't look alarming.
+func Count(status string, n int64) string {
+	s := strconv.FormatInt(n, 10)
+	if n == 0 {
+		return s
+	}
+	return Status(status, s)
+}
+
+// Width returns how many columns s takes on a terminal, not counting ANSI
+// escape sequences.
+func Width(s string) int {
+	n := 0
+	for i := 0; i < len(s); {
+		if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '[' {
+			// Skip to the final byte of the sequence
+			j := i + 2
+			for j < len(s) && (s[j] < 0x40 || s[j] > 0x7e) {
+				j++
+			}
+			i = j + 1
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		n++
+	}
+	return n
+}
+
+// pad fills s with spaces to width columns, on the left when right is set.
+func pad(s string, width int, right bool) string {
+	fill := width - Width(s)
+	if fill <= 0 {
+		return s
+	}
+	if right {
+		return strings.Repeat(" ", fill) + s
+	}
+	return s + strings.Repeat(" ", fill)
+}
@@ -1,25 +1,184 @@
 package format
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
 
-// Size formats a byte count into a human-readable string.
-func Size(bytes int64) string {
-	const (
-		KB = 1024
-		MB = KB * 1024
-		GB = MB * 1024
-		TB = GB * 1024
-	)
-	switch {
-	case bytes >= TB:
-		return fmt.Sprintf("%.2f TB", float64(bytes)/float64(TB))
-	case bytes >= GB:
-		return fmt.Sprintf("%.2f GB", float64(bytes)/float64(GB))
-	case bytes >= MB:
-		return fmt.Sprintf("%.2f MB", float64(bytes)/float64(MB))
-	case bytes >= KB:
-		return fmt.Sprintf("%.2f KB", float64(bytes)/float64(KB))
+// Units selects the unit system Bytes and Rate use.
+type Units int
+
+const (
+	UnitsIEC Units = iota // binary: 1 KiB = 1024 B (default)
+	UnitsSI               // decimal: 1 KB = 1000 B
+)
+
+var units = UnitsIEC
+
+// SetUnits selects the unit system by name, "iec" or "si". It is meant to be
+// called once at startup, before anything is formatted.
+func SetUnits(name string) error {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "iec":
+		units = UnitsIEC
+	case "si":
+		units = UnitsSI
 	default:
+		return fmt.Errorf("invalid units %q (expected iec|si)", name)
+	}
+	return nil
+}
+
+// UnitsName returns the name of the current unit system, "iec" or "si".
+func UnitsName() string {
+	if units == UnitsSI {
+		return "si"
+	}
+	return "iec"
+}
+
+// Size formats a byte count into a human-readable string. It divides by
+// 1024 but labels the result KB, MB, ... as it always has; use Bytes for
+// output that follows --units.
+func Size(bytes int64) string {
+	return scaled(bytes, 1024, []string{"KB", "MB", "GB", "TB"})
+}
+
+// Bytes formats a byte count in the units chosen with SetUnits, IEC unless
+// set otherwise. It is what the CLI and dashboard display.
+func Bytes(bytes int64) string {
+	if units == UnitsSI {
+		return SizeSI(bytes)
+	}
+	return SizeIEC(bytes)
+}
+
+// SizeIEC formats a byte count with binary prefixes: 1536 is "1.50 KiB".
+func SizeIEC(bytes int64) string {
+	return scaled(bytes, 1024, []string{"KiB", "MiB", "GiB", "TiB"})
+}
+
+// SizeSI formats a byte count with decimal prefixes: 1500 is "1.50 KB".
+func SizeSI(bytes int64) string {
+	return scaled(bytes, 1000, []string{"KB", "MB", "GB", "TB"})
+}
+
+func scaled(bytes, base int64, names []string) string {
+	if bytes < base {
 		return fmt.Sprintf("%d B", bytes)
 	}
+	div := base
+	i := 0
+	for i < len(names)-1 && bytes >= div*base {
+		div *= base
+		i++
+	}
+	return fmt.Sprintf("%.2f %s", float64(bytes)/float64(div), names[i])
+}
+
+// MBps returns the throughput of bytes over d in binary megabytes (MiB) per
+// second, regardless of SetUnits, so JSON output stays comparable between
+// runs. It returns 0 for a zero or negative duration.
+func MBps(bytes int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(bytes) / (1 << 20) / d.Seconds()
+}
+
+// Rate formats the throughput of bytes over d in the current units, e.g.
+// "142.3 MiB/s", or "149.2 MB/s" with SI units.
+func Rate(bytes int64, d time.Duration) string {
+	if units == UnitsSI {
+		if d <= 0 {
+			return "0.0 MB/s"
+		}
+		return fmt.Sprintf("%.1f MB/s", float64(bytes)/1e6/d.Seconds())
+	}
+	return fmt.Sprintf("%.1f MiB/s", MBps(bytes, d))
+}
+
+// ParseSize parses a byte count such as "4096", "10K", "1.5MB" or "2 GiB",
+// the inverse of SizeIEC. Suffixes B, K, M, G and T may be followed by "B" or
+// "iB" and are case-insensitive; they are always binary (1K = 1024), whatever
+// SetUnits selected. Fractions are rounded down to whole bytes.
+func ParseSize(s string) (int64, error) {
+	str := strings.ToUpper(strings.TrimSpace(s))
+	str = strings.TrimSuffix(strings.TrimSuffix(str, "IB"), "B")
+	shift := 0
+	if n := len(str); n > 0 {
+		switch str[n-1] {
+		case 'K':
+			shift = 10
+		case 'M':
+			shift = 20
+		case 'G':
+			shift = 30
+		case 'T':
+			shift = 40
+		}
+		if shift > 0 {
+			str = str[:n-1]
+		}
+	}
+	str = strings.TrimSpace(str)
+
+	// Plain decimal numbers only: ParseFloat would also take "1e3", "0x10",
+	// "inf" and "nan".
+	if str == "" || strings.Trim(str, "0123456789.") != "" || strings.Count(str, ".") > 1 || str == "." {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	v, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	bytes := v * float64(int64(1)<<shift)
+	if bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("size %q is too large", s)
+	}
+	return int64(bytes), nil
+}
+
+// ParseAge parses an age such as "90d", "2w" or "36h". On top of
+// time.ParseDuration it understands whole days (d) and weeks (w), which is
+// what verify coverage is usually measured in. Ages must be positive.
+func ParseAge(s string) (time.Duration, error) {
+	str := strings.TrimSpace(s)
+	if n := len(str); n > 1 && (str[n-1] == 'd' || str[n-1] == 'w') {
+		days, err := strconv.Atoi(str[:n-1])
+		if err != nil || days <= 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		if str[n-1] == 'w' {
+			days *= 7
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(str)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid age %q (e.g. 90d, 2w, 36h)", s)
+	}
+	return d, nil
+}
+
+// ParseSince parses a point in time given either as a date ("2024-01-31"),
+// a local date and time ("2024-01-31 18:00"), an RFC 3339 timestamp, or an
+// age before now as understood by ParseAge ("7d").
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	str := strings.TrimSpace(s)
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, str, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, str); err == nil {
+		return t, nil
+	}
+	if age, err := ParseAge(str); err == nil {
+		return now.Add(-age), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (e.g. 2024-01-31, \"2024-01-31 18:00\" or an age like 7d)", s)
 }
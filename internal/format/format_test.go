@@ -1,6 +1,10 @@
 package format
 
-import "testing"
+import (
+	"strings"
+	"testing"
+	"time"
+)
 
 func TestSize(t *testing.T) {
 	tests := []struct {
@@ -32,6 +36,93 @@ func TestSize(t *testing.T) {
 	}
 }
 
+func TestSizeIEC(t *testing.T) {
+	tests := []struct {
+		bytes    int64
+		expected string
+	}{
+		{0, "0 B"},
+		{1, "1 B"},
+		{512, "512 B"},
+		{1023, "1023 B"},
+		{1024, "1.00 KiB"},
+		{1536, "1.50 KiB"},
+		{1048575, "1024.00 KiB"}, // 1 MiB - 1 byte (still KiB range)
+		{1048576, "1.00 MiB"},
+		{1073741824, "1.00 GiB"},
+		{1099511627776, "1.00 TiB"},
+		{2199023255552, "2.00 TiB"},
+		{1572864, "1.50 MiB"},
+		{10737418240, "10.00 GiB"},
+		{5497558138880, "5.00 TiB"},
+		{107374182400, "100.00 GiB"},
+		{1099511627775, "1024.00 GiB"},    // 1 TiB - 1 byte (still GiB range)
+		{1125899906842624, "1024.00 TiB"}, // no larger unit
+	}
+
+	for _, tt := range tests {
+		got := SizeIEC(tt.bytes)
+		if got != tt.expected {
+			t.Errorf("SizeIEC(%d) = %q, want %q", tt.bytes, got, tt.expected)
+		}
+	}
+}
+
+func TestSizeSI(t *testing.T) {
+	tests := []struct {
+		bytes    int64
+		expected string
+	}{
+		{0, "0 B"},
+		{999, "999 B"},
+		{1000, "1.00 KB"},
+		{1024, "1.02 KB"},
+		{1500, "1.50 KB"},
+		{999999, "1000.00 KB"}, // 1 MB - 1 byte (still KB range)
+		{1000000, "1.00 MB"},
+		{1000000000, "1.00 GB"},
+		{1000000000000, "1.00 TB"},
+		{4000787030016, "4.00 TB"}, // a "4 TB" drive
+		{1000000000000000, "1000.00 TB"},
+	}
+
+	for _, tt := range tests {
+		got := SizeSI(tt.bytes)
+		if got != tt.expected {
+			t.Errorf("SizeSI(%d) = %q, want %q", tt.bytes, got, tt.expected)
+		}
+	}
+}
+
+func TestBytesFollowsUnits(t *testing.T) {
+	t.Cleanup(func() { SetUnits("iec") })
+
+	if got := Bytes(1536); got != "1.50 KiB" {
+		t.Errorf("default Bytes(1536) = %q, want IEC", got)
+	}
+	if err := SetUnits("si"); err != nil {
+		t.Fatalf("SetUnits(si): %v", err)
+	}
+	if UnitsName() != "si" {
+		t.Errorf("UnitsName = %q, want si", UnitsName())
+	}
+	if got := Bytes(1500); got != "1.50 KB" {
+		t.Errorf("SI Bytes(1500) = %q, want 1.50 KB", got)
+	}
+	if got := Size(1536); got != "1.50 KB" {
+		t.Errorf("Size(1536) = %q with SI units, want its fixed 1.50 KB", got)
+	}
+	if got := Rate(3_000_000, 2*time.Second); got != "1.5 MB/s" {
+		t.Errorf("SI Rate = %q, want 1.5 MB/s", got)
+	}
+	if err := SetUnits("metric"); err == nil {
+		t.Error("SetUnits(metric): expected error")
+	}
+	if err := SetUnits("IEC"); err != nil || UnitsName() != "iec" {
+		t.Errorf("SetUnits(IEC) = %v, units %q", err, UnitsName())
+	}
+}
+
 func TestSizeNegative(t *testing.T) {
 	// Negative values should fall through to the default case
 	got := Size(-1)
@@ -39,3 +130,188 @@ func TestSizeNegative(t *testing.T) {
 		t.Errorf("Size(-1) = %q, want %q", got, "-1 B")
 	}
 }
+
+func TestMBpsAndRate(t *testing.T) {
+	if got := MBps(300<<20, 2*time.Second); got != 150 {
+		t.Errorf("MBps = %v, want 150", got)
+	}
+	if got := MBps(1<<20, 0); got != 0 {
+		t.Errorf("MBps with zero duration = %v, want 0", got)
+	}
+	if got := Rate(3<<20, 2*time.Second); got != "1.5 MiB/s" {
+		t.Errorf("Rate = %q, want %q", got, "1.5 MiB/s")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"4096", 4096},
+		{"512B", 512},
+		{"10K", 10240},
+		{"10kb", 10240},
+		{"1.5M", 1572864},
+		{"2 GiB", 2147483648},
+		{"1T", 1099511627776},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.in)
+		if err != nil {
+			t.Errorf("ParseSize(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "abc", "-1K", "10X", "K", ".", "1.2.3", "1e3", "0x10", "inf", "NaN", "1,5M", "8388608T", "99999999999999999999"} {
+		if _, err := ParseSize(bad); err == nil {
+			t.Errorf("ParseSize(%q): expected error", bad)
+		}
+	}
+}
+
+func TestParseSizeSuffixes(t *testing.T) {
+	for _, in := range []string{"1M", "1m", "1MB", "1mb", "1MiB", "1mib", "1 M", " 1 MiB "} {
+		got, err := ParseSize(in)
+		if err != nil || got != 1<<20 {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", in, got, err, 1<<20)
+		}
+	}
+	if got, err := ParseSize("8191.99T"); err != nil || got <= 0 {
+		t.Errorf("ParseSize(8191.99T) = %d, %v, want a large positive size", got, err)
+	}
+}
+
+func TestParseSizeRoundTrip(t *testing.T) {
+	// SizeIEC rounds to two decimals, so only values it prints exactly round-trip.
+	for _, n := range []int64{0, 1, 512, 1023, 1024, 1536, 1 << 20, 1572864, 5 << 30, 1 << 40, 3 << 40} {
+		got, err := ParseSize(SizeIEC(n))
+		if err != nil {
+			t.Errorf("ParseSize(SizeIEC(%d) = %q): %v", n, SizeIEC(n), err)
+			continue
+		}
+		if got != n {
+			t.Errorf("ParseSize(SizeIEC(%d) = %q) = %d", n, SizeIEC(n), got)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+	}{
+		{"90d", 90 * 24 * time.Hour},
+		{"2w", 14 * 24 * time.Hour},
+		{"36h", 36 * time.Hour},
+		{" 1d ", 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		got, err := ParseAge(tt.in)
+		if err != nil {
+			t.Errorf("ParseAge(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseAge(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "d", "0d", "-3d", "1.5d", "90", "abc", "0h", "-1h"} {
+		if _, err := ParseAge(bad); err == nil {
+			t.Errorf("ParseAge(%q): expected error", bad)
+		}
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"2024-01-31", time.Date(2024, 1, 31, 0, 0, 0, 0, time.Local)},
+		{"2024-01-31 18:00", time.Date(2024, 1, 31, 18, 0, 0, 0, time.Local)},
+		{"2024-01-31T18:00:00Z", time.Date(2024, 1, 31, 18, 0, 0, 0, time.UTC)},
+		{"7d", now.Add(-7 * 24 * time.Hour)},
+		{"36h", now.Add(-36 * time.Hour)},
+	}
+	for _, tt := range tests {
+		got, err := ParseSince(tt.in, now)
+		if err != nil {
+			t.Errorf("ParseSince(%q): %v", tt.in, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("ParseSince(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", "yesterday", "2024-13-01", "0d"} {
+		if _, err := ParseSince(bad, now); err == nil {
+			t.Errorf("ParseSince(%q): expected error", bad)
+		}
+	}
+}
+
+func TestColor(t *testing.T) {
+	t.Cleanup(func() { SetColor(false) })
+
+	if got := Status("corrupted", "CORRUPTED:"); got != "CORRUPTED:" {
+		t.Errorf("Status with colors off = %q, want plain", got)
+	}
+	SetColor(true)
+	if got := Status("corrupted", "x"); got != "\x1b[31mx\x1b[0m" {
+		t.Errorf("Status(corrupted) = %q, want red", got)
+	}
+	if got := Status("changed", "x"); got != "x" {
+		t.Errorf("Status(changed) = %q, want plain", got)
+	}
+	if got := Count("missing", 0); got != "0" {
+		t.Errorf("Count(missing, 0) = %q, want plain 0", got)
+	}
+	if got := Count("ok", 12); got != Green("12") {
+		t.Errorf("Count(ok, 12) = %q, want green", got)
+	}
+	if got := Width(Red("abc") + " é"); got != 5 {
+		t.Errorf("Width = %d, want 5", got)
+	}
+}
+
+func TestTable(t *testing.T) {
+	t.Cleanup(func() { SetColor(false) })
+
+	render := func() string {
+		tbl := NewTable("DISK", "FILES", "HEALTH").AlignRight(1)
+		tbl.Indent = "  "
+		tbl.Row("disk1", "5", "good")
+		tbl.Row("cache", "1200", Red("failing"))
+		var b strings.Builder
+		if err := tbl.Write(&b); err != nil {
+			t.Fatal(err)
+		}
+		return b.String()
+	}
+
+	want := "  DISK   FILES  HEALTH\n" +
+		"  disk1      5  good\n" +
+		"  cache   1200  failing\n"
+	if got := render(); got != want {
+		t.Errorf("plain table:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Colored cells and header must line up like plain ones
+	SetColor(true)
+	lines := strings.Split(strings.TrimSuffix(render(), "\n"), "\n")
+	wantLines := strings.Split(strings.TrimSuffix(want, "\n"), "\n")
+	for i := range lines {
+		if Width(lines[i]) != len(wantLines[i]) {
+			t.Errorf("colored line %d is %d wide, want %d: %q", i, Width(lines[i]), len(wantLines[i]), lines[i])
+		}
+	}
+}
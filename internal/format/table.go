@@ -0,0 +1,82 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Table lays out rows in aligned columns for the terminal. Widths are
+// measured without color escapes, so colored cells line up too. The header
+// is bold when colors are on.
+type Table struct {
+	Indent string // printed before every line, e.g. "  "
+	header []string
+	right  []bool
+	rows   [][]string
+}
+
+// NewTable returns a table with the given column headings.
+func NewTable(header ...string) *Table {
+	return &Table{header: header, right: make([]bool, len(header))}
+}
+
+// AlignRight right-aligns the columns at the given indexes, as for numbers.
+func (t *Table) AlignRight(cols ...int) *Table {
+	for _, c := range cols {
+		if c >= 0 && c < len(t.right) {
+			t.right[c] = true
+		}
+	}
+	return t
+}
+
+// Row adds a row. Missing cells are left empty and extra ones are dropped.
+func (t *Table) Row(cells ...string) {
+	row := make([]string, len(t.header))
+	copy(row, cells)
+	t.rows = append(t.rows, row)
+}
+
+// Len returns the number of rows added.
+func (t *Table) Len() int {
+	return len(t.rows)
+}
+
+// Write prints the header and rows to w, columns two spaces apart.
+func (t *Table) Write(w io.Writer) error {
+	widths := make([]int, len(t.header))
+	for _, row := range append([][]string{t.header}, t.rows...) {
+		for i, cell := range row {
+			widths[i] = max(widths[i], Width(cell))
+		}
+	}
+	line := func(cells []string, header bool) error {
+		var b strings.Builder
+		b.WriteString(t.Indent)
+		for i, cell := range cells {
+			if i > 0 {
+				b.WriteString("  ")
+			}
+			last := i == len(cells)-1
+			if header {
+				cell = Bold(cell)
+			}
+			if !last || t.right[i] {
+				cell = pad(cell, widths[i], t.right[i])
+			}
+			b.WriteString(cell)
+		}
+		_, err := fmt.Fprintln(w, b.String())
+		return err
+	}
+	if err := line(t.header, true); err != nil {
+		return err
+	}
+	for _, row := range t.rows {
+		if err := line(row, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,34 +3,151 @@ package hasher
 import (
 	"context"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
 	"os"
 	"sync"
+	"syscall"
+	"time"
+
+	"lukechampine.com/blake3"
 )
 
-// Result holds the hashing result for a single file.
-type Result struct {
-	Path   string
-	Disk   string
-	Size   int64
-	Mtime  int64
-	SHA256 string
-	Err    error
+// DefaultAlgo is the algorithm used when a FileInfo or record names none.
+const DefaultAlgo = "sha256"
+
+// algorithms maps supported algorithm names to their constructors.
+var algorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+	"blake3": func() hash.Hash { return blake3.New(32, nil) },
 }
 
-// FileInfo is the input to the hasher.
-type FileInfo struct {
+// Supported reports whether algo can be hashed. An empty name means DefaultAlgo.
+func Supported(algo string) bool {
+	if algo == "" {
+		return true
+	}
+	_, ok := algorithms[algo]
+	return ok
+}
+
+// secondaryAlgorithms are cheap checksums computed in the same read pass as
+// the main hash, as an independent cross-check of it.
+var secondaryAlgorithms = map[string]func() hash.Hash{
+	"crc32c": func() hash.Hash { return crc32.New(crc32.MakeTable(crc32.Castagnoli)) },
+}
+
+// SupportedSecondary reports whether algo can be used as a secondary
+// checksum. An empty name means none and is always supported.
+func SupportedSecondary(algo string) bool {
+	if algo == "" {
+		return true
+	}
+	_, ok := secondaryAlgorithms[algo]
+	return ok
+}
+
+// newHash returns a fresh hash for algo, or an error for unknown algorithms.
+func newHash(algo string) (hash.Hash, error) {
+	if algo == "" {
+		algo = DefaultAlgo
+	}
+	ctor, ok := algorithms[algo]
+	if !ok {
+		return nil, fmt.Errorf("unsupported hash algorithm %q", algo)
+	}
+	return ctor(), nil
+}
+
+// Result holds the hashing result for a single file.
+type Result struct {
 	Path  string
 	Disk  string
 	Size  int64
 	Mtime int64
+	// MtimeNsec is the sub-second part of the mtime, so rewrites within the
+	// same second are still noticed.
+	MtimeNsec int64
+	SHA256    string // hex digest; named for the original algorithm, holds whichever Algo produced
+	Algo      string
+	Secondary string // hex digest of the secondary checksum; empty if none was requested
+
+	// Digests holds every digest computed in the read pass, keyed by
+	// algorithm name: Algo, Secondary's algorithm and any Extra ones.
+	Digests map[string]string
+
+	// Chunks holds the digest of each ChunkSize piece for a chunked hash
+	// (see FileInfo.ChunkSize); SHA256 is then the hash of the chunk digests.
+	Chunks []string
+	// BytesRead is how much of the file was read. It is less than Size when
+	// only the appended tail of a chunked file had to be hashed.
+	BytesRead int64
+	// InFlight is set when the file's size or mtime after the read differ
+	// from Size and Mtime, as for a download still being written. The digest
+	// may then mix old and new contents and shouldn't be trusted either way.
+	InFlight bool
+	Perms    *Perms // FileInfo.Perms
+	Err      error
+}
+
+// FileInfo is the input to the hasher.
+type FileInfo struct {
+	Path      string
+	Disk      string
+	Size      int64
+	Mtime     int64
+	MtimeNsec int64
+	Algo      string // empty means DefaultAlgo
+	Secondary string // optional secondary checksum (e.g. "crc32c") computed in the same pass
+
+	// Extra names further algorithms to compute in the same read, e.g. to
+	// migrate a catalog to a new algorithm. Results land in Result.Digests.
+	Extra []string
+
+	// ChunkSize, if positive, hashes the file as a list of ChunkSize pieces
+	// instead of as one stream. PrevChunks and PrevSize describe an earlier
+	// chunked hash of the same file: if it has grown since, the full chunks
+	// before the old end are reused and only the tail is read, after
+	// re-reading the last of them to check the file wasn't rewritten.
+	ChunkSize  int64
+	PrevChunks []string
+	PrevSize   int64
+
+	// Perms, if set, is the owner and mode the walk found, passed through to
+	// Result for scan --track-perms.
+	Perms *Perms
+}
+
+// Perms is a file's owner and permission bits.
+type Perms struct {
+	UID  uint32
+	GID  uint32
+	Mode uint32 // permission bits including setuid, setgid and sticky
 }
 
+// DefaultReadRetries is how often a transient read error is retried unless
+// the caller sets Hasher.ReadRetries.
+const DefaultReadRetries = 2
+
+// DefaultRetryBackoff is the wait before the first retry of a transient
+// read error; it doubles with each further attempt.
+const DefaultRetryBackoff = 500 * time.Millisecond
+
 // Hasher provides parallel file hashing.
 type Hasher struct {
 	workers int
+
+	// ReadRetries is how many times a file is re-hashed after a transient
+	// read error (see Transient) before the error is reported. Permanent
+	// errors such as a missing file are reported straight away.
+	ReadRetries  int
+	RetryBackoff time.Duration
 }
 
 // New creates a Hasher with the given number of workers.
@@ -38,13 +155,236 @@ func New(workers int) *Hasher {
 	if workers <= 0 {
 		workers = 1
 	}
-	return &Hasher{workers: workers}
+	return &Hasher{workers: workers, ReadRetries: DefaultReadRetries, RetryBackoff: DefaultRetryBackoff}
+}
+
+// Transient reports whether err looks like a flaky read (e.g. an I/O error
+// from a USB-attached disk) that may succeed if tried again, as opposed to a
+// permanent condition like a missing file or a permission problem.
+func Transient(err error) bool {
+	return errors.Is(err, syscall.EIO) || errors.Is(err, syscall.EAGAIN) ||
+		errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.ETIMEDOUT)
+}
+
+// withRetries calls hash until it succeeds, fails permanently, or has been
+// retried retries times, sleeping backoff (doubling) between attempts. It
+// gives up early, returning the last error, if ctx is cancelled.
+func withRetries(ctx context.Context, retries int, backoff time.Duration, hash func() (*Result, error)) (*Result, error) {
+	for attempt := 0; ; attempt++ {
+		res, err := hash()
+		if err == nil || attempt >= retries || !Transient(err) {
+			return res, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(backoff << attempt):
+		}
+	}
 }
 
 // HashFile hashes a single file and returns the result.
 // It stats the file to get size and mtime. For callers that already have
 // this info, use hashFileWithInfo instead via HashFiles.
 func HashFile(path string) (*Result, error) {
+	return hashFile(FileInfo{Path: path})
+}
+
+// HashFileAlgo is HashFile with algo instead of DefaultAlgo.
+func HashFileAlgo(path, algo string) (*Result, error) {
+	return hashFile(FileInfo{Path: path, Algo: algo})
+}
+
+// Digest reads r once and returns the hex digest of each named algorithm
+// (main or secondary), keyed by name. Every hash sees the same buffer via
+// io.MultiWriter, so extra algorithms cost CPU but no extra IO.
+func Digest(r io.Reader, algos ...string) (map[string]string, error) {
+	hashes := make(map[string]hash.Hash, len(algos))
+	writers := make([]io.Writer, 0, len(algos))
+	for _, algo := range algos {
+		if _, dup := hashes[algo]; dup {
+			continue
+		}
+		var h hash.Hash
+		if ctor, ok := secondaryAlgorithms[algo]; ok {
+			h = ctor()
+		} else {
+			var err error
+			if h, err = newHash(algo); err != nil {
+				return nil, err
+			}
+		}
+		hashes[algo] = h
+		writers = append(writers, h)
+	}
+
+	buf := make([]byte, 1*1024*1024) // 1MB buffer
+	if _, err := io.CopyBuffer(io.MultiWriter(writers...), r, buf); err != nil {
+		return nil, err
+	}
+	sums := make(map[string]string, len(hashes))
+	for algo, h := range hashes {
+		sums[algo] = hex.EncodeToString(h.Sum(nil))
+	}
+	return sums, nil
+}
+
+// digest hashes r with everything fi asks for and fills the digest fields
+// of res.
+func digest(r io.Reader, fi FileInfo, res *Result) error {
+	algo := algoName(fi.Algo)
+	if !Supported(algo) {
+		return fmt.Errorf("unsupported hash algorithm %q", algo)
+	}
+	if fi.Secondary != "" && !SupportedSecondary(fi.Secondary) {
+		return fmt.Errorf("unsupported secondary checksum %q", fi.Secondary)
+	}
+	algos := append([]string{algo}, fi.Extra...)
+	if fi.Secondary != "" {
+		algos = append(algos, fi.Secondary)
+	}
+	sums, err := Digest(r, algos...)
+	if err != nil {
+		return err
+	}
+	res.Algo = algo
+	res.SHA256 = sums[algo]
+	if fi.Secondary != "" {
+		res.Secondary = sums[fi.Secondary]
+	}
+	res.Digests = sums
+	return nil
+}
+
+// DefaultChunkSize is the piece size for chunked hashes (scan --chunked).
+const DefaultChunkSize = 64 << 20
+
+// digestChunked hashes f in fi.ChunkSize pieces with fi's algorithm and sets
+// res.SHA256 to the hash of the concatenated raw chunk digests.
+func digestChunked(f *os.File, fi FileInfo, res *Result) error {
+	algo := algoName(fi.Algo)
+	if !Supported(algo) {
+		return fmt.Errorf("unsupported hash algorithm %q", algo)
+	}
+	if fi.Secondary != "" || len(fi.Extra) > 0 {
+		return fmt.Errorf("chunked hashing can't be combined with other digests")
+	}
+
+	buf := make([]byte, 1*1024*1024) // 1MB buffer
+	hashChunk := func() (string, int64, error) {
+		h, _ := newHash(algo)
+		n, err := io.CopyBuffer(h, io.LimitReader(f, fi.ChunkSize), buf)
+		res.BytesRead += n
+		return hex.EncodeToString(h.Sum(nil)), n, err
+	}
+
+	// Appended to since the last hash: reuse the old full chunks if the last
+	// one still matches.
+	var chunks []string
+	var start int64
+	keep := fi.PrevSize / fi.ChunkSize
+	if keep > 0 && res.Size > fi.PrevSize && int64(len(fi.PrevChunks)) >= keep {
+		if _, err := f.Seek((keep-1)*fi.ChunkSize, io.SeekStart); err != nil {
+			return err
+		}
+		last, _, err := hashChunk()
+		if err != nil {
+			return err
+		}
+		if last == fi.PrevChunks[keep-1] {
+			chunks = append(chunks, fi.PrevChunks[:keep]...)
+			start = keep * fi.ChunkSize
+		}
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	for {
+		sum, n, err := hashChunk()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			break
+		}
+		chunks = append(chunks, sum)
+		if n < fi.ChunkSize {
+			break
+		}
+	}
+
+	root, _ := newHash(algo)
+	for _, c := range chunks {
+		raw, err := hex.DecodeString(c)
+		if err != nil {
+			return fmt.Errorf("bad chunk digest %q: %w", c, err)
+		}
+		root.Write(raw)
+	}
+	res.Algo = algo
+	res.SHA256 = hex.EncodeToString(root.Sum(nil))
+	res.Digests = map[string]string{algo: res.SHA256}
+	res.Chunks = chunks
+	return nil
+}
+
+// HashChunks hashes path in chunkSize pieces with algo (empty for
+// DefaultAlgo), as scan --chunked does, to compare against stored chunks.
+func HashChunks(path, algo string, chunkSize int64) (*Result, error) {
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("invalid chunk size %d", chunkSize)
+	}
+	return hashFile(FileInfo{Path: path, Algo: algo, ChunkSize: chunkSize})
+}
+
+// Range is the byte range [Start, End) of a file.
+type Range struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+// DiffChunks compares two chunked hashes of one file, a and b, and returns
+// the byte ranges whose chunks differ, adjacent ones merged. A chunk only one
+// of them has counts as differing; size, the larger of the two file sizes,
+// ends the last range.
+func DiffChunks(a, b []string, chunkSize, size int64) []Range {
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+	var ranges []Range
+	for i := 0; i < n; i++ {
+		if i < len(a) && i < len(b) && a[i] == b[i] {
+			continue
+		}
+		start := int64(i) * chunkSize
+		end := start + chunkSize
+		if end > size {
+			end = size
+		}
+		if k := len(ranges) - 1; k >= 0 && ranges[k].End == start {
+			ranges[k].End = end
+			continue
+		}
+		ranges = append(ranges, Range{Start: start, End: end})
+	}
+	return ranges
+}
+
+// digestFile hashes the open file f into res, chunked or as one stream as
+// fi asks.
+func digestFile(f *os.File, fi FileInfo, res *Result) error {
+	if fi.ChunkSize > 0 {
+		return digestChunked(f, fi, res)
+	}
+	res.BytesRead = res.Size
+	return digest(f, fi, res)
+}
+
+// hashFile is HashFile for fi's path, algorithm and secondary checksum; it
+// stats the file itself.
+func hashFile(fi FileInfo) (*Result, error) {
+	path := fi.Path
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, fmt.Errorf("open %s: %w", path, err)
@@ -60,18 +400,19 @@ func HashFile(path string) (*Result, error) {
 		return nil, fmt.Errorf("%s is a directory", path)
 	}
 
-	h := sha256.New()
-	buf := make([]byte, 1*1024*1024) // 1MB buffer
-	if _, err := io.CopyBuffer(h, f, buf); err != nil {
+	res := &Result{
+		Path:      path,
+		Disk:      fi.Disk,
+		Size:      stat.Size(),
+		Mtime:     stat.ModTime().Unix(),
+		MtimeNsec: int64(stat.ModTime().Nanosecond()),
+		Perms:     fi.Perms,
+	}
+	if err := digestFile(f, fi, res); err != nil {
 		return nil, fmt.Errorf("hash %s: %w", path, err)
 	}
-
-	return &Result{
-		Path:   path,
-		Size:   stat.Size(),
-		Mtime:  stat.ModTime().Unix(),
-		SHA256: hex.EncodeToString(h.Sum(nil)),
-	}, nil
+	res.InFlight = changedDuring(f, res)
+	return res, nil
 }
 
 // hashFileWithInfo hashes a file using pre-existing size/mtime from FileInfo,
@@ -83,19 +424,58 @@ func hashFileWithInfo(fi FileInfo) (*Result, error) {
 	}
 	defer f.Close()
 
-	h := sha256.New()
-	buf := make([]byte, 1*1024*1024) // 1MB buffer
-	if _, err := io.CopyBuffer(h, f, buf); err != nil {
+	res := &Result{
+		Path:      fi.Path,
+		Disk:      fi.Disk,
+		Size:      fi.Size,
+		Mtime:     fi.Mtime,
+		MtimeNsec: fi.MtimeNsec,
+		Perms:     fi.Perms,
+	}
+	if err := digestFile(f, fi, res); err != nil {
 		return nil, fmt.Errorf("hash %s: %w", fi.Path, err)
 	}
+	res.InFlight = changedDuring(f, res)
+	return res, nil
+}
 
-	return &Result{
-		Path:   fi.Path,
-		Disk:   fi.Disk,
-		Size:   fi.Size,
-		Mtime:  fi.Mtime,
-		SHA256: hex.EncodeToString(h.Sum(nil)),
-	}, nil
+// changedDuring re-stats the open file f after it was hashed and reports
+// whether it no longer has the size and mtime res was hashed for. A zero
+// MtimeNsec, from a caller that only knew the seconds, compares on seconds.
+func changedDuring(f *os.File, res *Result) bool {
+	stat, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	nsec := int64(stat.ModTime().Nanosecond())
+	return stat.Size() != res.Size || stat.ModTime().Unix() != res.Mtime ||
+		(res.MtimeNsec != 0 && nsec != res.MtimeNsec)
+}
+
+func algoName(algo string) string {
+	if algo == "" {
+		return DefaultAlgo
+	}
+	return algo
+}
+
+// HashPrefix hashes at most limit bytes of path with DefaultAlgo and returns
+// how many bytes were read. It is meant for throughput probes, where hashing
+// a whole multi-GB file would take longer than the measurement is worth.
+func HashPrefix(path string, limit int64) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h, _ := newHash(DefaultAlgo)
+	buf := make([]byte, 1*1024*1024) // 1MB buffer
+	n, err := io.CopyBuffer(h, io.LimitReader(f, limit), buf)
+	if err != nil {
+		return n, fmt.Errorf("hash %s: %w", path, err)
+	}
+	return n, nil
 }
 
 // HashFiles hashes multiple files in parallel and sends results to the results channel.
@@ -124,19 +504,15 @@ func (h *Hasher) HashFilesContext(ctx context.Context, files <-chan FileInfo, re
 				default:
 				}
 
-				var result *Result
-				var err error
-				if fi.Size > 0 || fi.Mtime > 0 {
-					// Pre-existing stat info available — skip redundant stat
-					result, err = hashFileWithInfo(fi)
-				} else {
-					result, err = HashFile(fi.Path)
-					if result != nil {
-						result.Disk = fi.Disk
+				result, err := withRetries(ctx, h.ReadRetries, h.RetryBackoff, func() (*Result, error) {
+					if fi.Size > 0 || fi.Mtime > 0 {
+						// Pre-existing stat info available — skip redundant stat
+						return hashFileWithInfo(fi)
 					}
-				}
+					return hashFile(fi)
+				})
 				if err != nil {
-					results <- Result{Path: fi.Path, Disk: fi.Disk, Err: err}
+					results <- Result{Path: fi.Path, Disk: fi.Disk, Algo: algoName(fi.Algo), Err: err}
 					continue
 				}
 				results <- *result
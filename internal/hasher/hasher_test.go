@@ -1,11 +1,22 @@
 package hasher
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
 	"os"
 	"path/filepath"
+	"syscall"
 	"testing"
+	"time"
+
+	"lukechampine.com/blake3"
 )
 
 func TestHashFile(t *testing.T) {
@@ -176,3 +187,373 @@ func TestHashFilesWithError(t *testing.T) {
 		t.Errorf("Disk = %q, want disk1", r.Disk)
 	}
 }
+
+func TestReadRetries(t *testing.T) {
+	eio := &os.PathError{Op: "read", Path: "/mnt/usb/a", Err: syscall.EIO}
+	enoent := &os.PathError{Op: "open", Path: "/mnt/usb/a", Err: syscall.ENOENT}
+	ctx := context.Background()
+
+	// Transient errors are retried until the read succeeds
+	calls := 0
+	res, err := withRetries(ctx, 2, time.Millisecond, func() (*Result, error) {
+		calls++
+		if calls < 3 {
+			return nil, fmt.Errorf("hash /mnt/usb/a: %w", eio)
+		}
+		return &Result{SHA256: "ok"}, nil
+	})
+	if err != nil || res.SHA256 != "ok" || calls != 3 {
+		t.Errorf("flaky read: res=%v err=%v calls=%d, want success after 3 calls", res, err, calls)
+	}
+
+	// ... but only up to the retry limit
+	calls = 0
+	_, err = withRetries(ctx, 2, time.Millisecond, func() (*Result, error) {
+		calls++
+		return nil, eio
+	})
+	if !errors.Is(err, syscall.EIO) || calls != 3 {
+		t.Errorf("persistent EIO: err=%v calls=%d, want EIO after 3 calls", err, calls)
+	}
+
+	// Permanent errors are reported straight away
+	calls = 0
+	_, err = withRetries(ctx, 2, time.Millisecond, func() (*Result, error) {
+		calls++
+		return nil, enoent
+	})
+	if !errors.Is(err, syscall.ENOENT) || calls != 1 {
+		t.Errorf("ENOENT: err=%v calls=%d, want ENOENT after 1 call", err, calls)
+	}
+}
+
+func TestHashFileWithInfoAlgo(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.txt")
+	content := []byte("hello world\n")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("write temp file: %v", err)
+	}
+
+	result, err := hashFileWithInfo(FileInfo{Path: path, Size: 12, Mtime: 1, Algo: "sha512"})
+	if err != nil {
+		t.Fatalf("hashFileWithInfo: %v", err)
+	}
+	h := sha512.Sum512(content)
+	if want := hex.EncodeToString(h[:]); result.SHA256 != want {
+		t.Errorf("digest = %q, want %q", result.SHA256, want)
+	}
+	if result.Algo != "sha512" {
+		t.Errorf("Algo = %q, want sha512", result.Algo)
+	}
+
+	result, err = hashFileWithInfo(FileInfo{Path: path, Size: 12, Mtime: 1, Algo: "blake3"})
+	if err != nil {
+		t.Fatalf("hashFileWithInfo blake3: %v", err)
+	}
+	b := blake3.Sum256(content)
+	if want := hex.EncodeToString(b[:]); result.SHA256 != want {
+		t.Errorf("blake3 digest = %q, want %q", result.SHA256, want)
+	}
+
+	if _, err := hashFileWithInfo(FileInfo{Path: path, Size: 12, Mtime: 1, Algo: "md5"}); err == nil {
+		t.Error("expected error for unsupported algorithm")
+	}
+	if Supported("md5") || !Supported("") || !Supported("sha256") || !Supported("blake3") {
+		t.Error("Supported returned unexpected results")
+	}
+}
+
+func TestSecondaryChecksum(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.txt")
+	content := []byte("hello world\n")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("write temp file: %v", err)
+	}
+
+	want := fmt.Sprintf("%08x", crc32.Checksum(content, crc32.MakeTable(crc32.Castagnoli)))
+	sha := sha256.Sum256(content)
+	for _, fi := range []FileInfo{
+		{Path: path, Size: 12, Mtime: 1, Secondary: "crc32c"}, // pre-stat'ed
+		{Path: path, Secondary: "crc32c"},                     // hasher stats
+	} {
+		result, err := hashFileWithInfo(fi)
+		if fi.Size == 0 {
+			result, err = hashFile(fi)
+		}
+		if err != nil {
+			t.Fatalf("hash: %v", err)
+		}
+		if result.Secondary != want {
+			t.Errorf("Secondary = %q, want %q", result.Secondary, want)
+		}
+		if result.SHA256 != hex.EncodeToString(sha[:]) {
+			t.Errorf("main digest changed when a secondary was requested: %q", result.SHA256)
+		}
+	}
+
+	result, err := hashFileWithInfo(FileInfo{Path: path, Size: 12, Mtime: 1})
+	if err != nil || result.Secondary != "" {
+		t.Errorf("no secondary requested: Secondary = %q, err %v", result.Secondary, err)
+	}
+	if _, err := hashFileWithInfo(FileInfo{Path: path, Size: 12, Mtime: 1, Secondary: "adler32"}); err == nil {
+		t.Error("expected error for unsupported secondary checksum")
+	}
+	if SupportedSecondary("adler32") || !SupportedSecondary("") || !SupportedSecondary("crc32c") {
+		t.Error("SupportedSecondary returned unexpected results")
+	}
+}
+
+func TestMultiDigestSinglePass(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.txt")
+	content := []byte("hello world\n")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("write temp file: %v", err)
+	}
+
+	result, err := hashFileWithInfo(FileInfo{Path: path, Size: 12, Mtime: 1, Extra: []string{"sha512"}, Secondary: "crc32c"})
+	if err != nil {
+		t.Fatalf("hashFileWithInfo: %v", err)
+	}
+	s256 := sha256.Sum256(content)
+	s512 := sha512.Sum512(content)
+	want := map[string]string{
+		"sha256": hex.EncodeToString(s256[:]),
+		"sha512": hex.EncodeToString(s512[:]),
+		"crc32c": fmt.Sprintf("%08x", crc32.Checksum(content, crc32.MakeTable(crc32.Castagnoli))),
+	}
+	if len(result.Digests) != len(want) {
+		t.Errorf("Digests = %v, want %v", result.Digests, want)
+	}
+	for algo, sum := range want {
+		if result.Digests[algo] != sum {
+			t.Errorf("Digests[%s] = %q, want %q", algo, result.Digests[algo], sum)
+		}
+	}
+	if result.SHA256 != want["sha256"] || result.Secondary != want["crc32c"] {
+		t.Errorf("SHA256/Secondary = %q/%q, want the sha256/crc32c digests", result.SHA256, result.Secondary)
+	}
+
+	// One pass over the reader no matter how many algorithms
+	cr := &countingReader{r: bytes.NewReader(content)}
+	if _, err := Digest(cr, "sha256", "sha512", "crc32c", "sha256"); err != nil {
+		t.Fatalf("Digest: %v", err)
+	}
+	if cr.n != int64(len(content)) {
+		t.Errorf("read %d bytes, want %d (a single pass)", cr.n, len(content))
+	}
+	if _, err := Digest(bytes.NewReader(content), "md5"); err == nil {
+		t.Error("expected error for unsupported algorithm")
+	}
+	if _, err := hashFileWithInfo(FileInfo{Path: path, Size: 12, Mtime: 1, Algo: "crc32c"}); err == nil {
+		t.Error("expected error for a secondary checksum used as the main hash")
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// benchmarkDigests hashes a 16 MiB file with sha256 and sha512, reading it
+// once or once per algorithm, and reports the bytes read from the file per
+// operation next to the timing.
+func benchmarkDigests(b *testing.B, singlePass bool) {
+	path := filepath.Join(b.TempDir(), "bench.bin")
+	data := bytes.Repeat([]byte("0123456789abcdef"), 1<<20)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		b.Fatalf("write: %v", err)
+	}
+	passes := [][]string{{"sha256", "sha512"}}
+	if !singlePass {
+		passes = [][]string{{"sha256"}, {"sha512"}}
+	}
+
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	var read int64
+	for i := 0; i < b.N; i++ {
+		for _, algos := range passes {
+			f, err := os.Open(path)
+			if err != nil {
+				b.Fatalf("open: %v", err)
+			}
+			cr := &countingReader{r: f}
+			if _, err := Digest(cr, algos...); err != nil {
+				b.Fatalf("Digest: %v", err)
+			}
+			f.Close()
+			read += cr.n
+		}
+	}
+	b.ReportMetric(float64(read)/float64(b.N), "read-B/op")
+}
+
+func BenchmarkDigestSinglePass(b *testing.B) { benchmarkDigests(b, true) }
+func BenchmarkDigestTwoPasses(b *testing.B)  { benchmarkDigests(b, false) }
+
+func TestHashPrefix(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.bin")
+	if err := os.WriteFile(path, make([]byte, 4096), 0644); err != nil {
+		t.Fatalf("write temp file: %v", err)
+	}
+
+	n, err := HashPrefix(path, 1000)
+	if err != nil {
+		t.Fatalf("HashPrefix: %v", err)
+	}
+	if n != 1000 {
+		t.Errorf("read %d bytes, want 1000", n)
+	}
+
+	n, err = HashPrefix(path, 1<<20)
+	if err != nil {
+		t.Fatalf("HashPrefix: %v", err)
+	}
+	if n != 4096 {
+		t.Errorf("read %d bytes, want 4096 (whole file)", n)
+	}
+}
+
+func TestChunkedAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	hashChunked := func(prev *Result) *Result {
+		t.Helper()
+		fi := FileInfo{Path: path, ChunkSize: 4}
+		if prev != nil {
+			fi.PrevChunks, fi.PrevSize = prev.Chunks, prev.Size
+		}
+		res, err := hashFile(fi)
+		if err != nil {
+			t.Fatalf("hashFile: %v", err)
+		}
+		return res
+	}
+
+	if err := os.WriteFile(path, []byte("aaaabbbbcc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	first := hashChunked(nil)
+	if len(first.Chunks) != 3 || first.BytesRead != 10 {
+		t.Fatalf("chunks = %d, read = %d, want 3 chunks, 10 bytes", len(first.Chunks), first.BytesRead)
+	}
+	h := sha256.Sum256([]byte("aaaa"))
+	if first.Chunks[0] != hex.EncodeToString(h[:]) {
+		t.Errorf("chunk 0 = %s, want sha256 of aaaa", first.Chunks[0])
+	}
+
+	// Append: the last full chunk is re-checked, then only the tail is read
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString("dddd")
+	f.Close()
+	appended := hashChunked(first)
+	fresh := hashChunked(nil)
+	if appended.SHA256 != fresh.SHA256 {
+		t.Errorf("appended hash %s != fresh hash %s", appended.SHA256, fresh.SHA256)
+	}
+	if appended.BytesRead != 4+6 {
+		t.Errorf("appended read %d bytes, want 10 (last full chunk + tail)", appended.BytesRead)
+	}
+
+	// Rewritten near the old end and grown: the check fails and everything
+	// is re-hashed
+	if err := os.WriteFile(path, []byte("aaaabbbbccXX!!!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rewritten := hashChunked(appended)
+	if rewritten.SHA256 != hashChunked(nil).SHA256 {
+		t.Error("rewritten file: incremental hash differs from a fresh one")
+	}
+	if rewritten.BytesRead != 4+15 {
+		t.Errorf("rewritten read %d bytes, want 19 (check + full re-hash)", rewritten.BytesRead)
+	}
+}
+
+func TestDiffChunks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "video.mkv")
+	if err := os.WriteFile(path, []byte("aaaabbbbccccdd"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := HashChunks(path, "", 4)
+	if err != nil {
+		t.Fatalf("HashChunks: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name, content string
+		want          string
+	}{
+		{"intact", "aaaabbbbccccdd", "[]"},
+		{"one flipped byte", "aaaabbXbccccdd", "[{4 8}]"},
+		{"adjacent chunks merge", "aaaXbbbXccccdd", "[{0 8}]"},
+		{"separate ranges", "Xaaabbbbcccc!d", "[{0 4} {12 14}]"},
+		{"truncated", "aaaabbbbcc", "[{8 14}]"},
+		{"grown", "aaaabbbbccccddEEEEE", "[{12 19}]"},
+	} {
+		if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		now, err := HashChunks(path, "", 4)
+		if err != nil {
+			t.Fatalf("%s: HashChunks: %v", tc.name, err)
+		}
+		size := stored.Size
+		if now.Size > size {
+			size = now.Size
+		}
+		if got := fmt.Sprint(DiffChunks(stored.Chunks, now.Chunks, 4, size)); got != tc.want {
+			t.Errorf("%s: differing ranges = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "download.part")
+	if err := os.WriteFile(path, []byte("first half"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fi := FileInfo{Path: path, Size: stat.Size(), Mtime: stat.ModTime().Unix(), MtimeNsec: int64(stat.ModTime().Nanosecond())}
+
+	res, err := hashFileWithInfo(fi)
+	if err != nil {
+		t.Fatalf("hashFileWithInfo: %v", err)
+	}
+	if res.InFlight {
+		t.Error("unchanged file reported in flight")
+	}
+	if res, err := HashFile(path); err != nil || res.InFlight {
+		t.Errorf("HashFile = %+v, %v; want not in flight", res, err)
+	}
+
+	// Written to after the walk saw it: the hash no longer matches the
+	// size and mtime it would be stored with
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(", second half")
+	f.Close()
+	later := stat.ModTime().Add(time.Second)
+	os.Chtimes(path, later, later)
+
+	res, err = hashFileWithInfo(fi)
+	if err != nil {
+		t.Fatalf("hashFileWithInfo: %v", err)
+	}
+	if !res.InFlight {
+		t.Error("file that grew during hashing not reported in flight")
+	}
+}
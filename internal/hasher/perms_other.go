@@ -0,0 +1,10 @@
+//go:build !unix
+
+package hasher
+
+import "os"
+
+// PermsOf returns nil: this platform has no Unix owner and mode to record.
+func PermsOf(info os.FileInfo) *Perms {
+	return nil
+}
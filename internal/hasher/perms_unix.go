@@ -0,0 +1,18 @@
+//go:build unix
+
+package hasher
+
+import (
+	"os"
+	"syscall"
+)
+
+// PermsOf returns the owner and permission bits of info, or nil where the
+// platform doesn't report them.
+func PermsOf(info os.FileInfo) *Perms {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	return &Perms{UID: st.Uid, GID: st.Gid, Mode: uint32(st.Mode) & 0o7777}
+}
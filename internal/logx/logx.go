@@ -0,0 +1,184 @@
+// Package logx is the CLI's small leveled logger. Regular output (progress,
+// summaries, per-file detail) goes to stdout and is filtered by one global
+// level; warnings and errors always go to stderr, either as text or, with
+// FormatJSON, as one JSON object per line for log collectors.
+package logx
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level controls how much regular output is printed.
+type Level int
+
+const (
+	LevelQuiet   Level = iota // warnings and errors only (cron)
+	LevelNormal               // plus progress and summaries; the default
+	LevelVerbose              // plus per-file detail
+)
+
+// Format selects how warnings, errors and events are written to stderr.
+type Format int
+
+const (
+	FormatText Format = iota // "warning: ..." lines
+	FormatJSON               // {"level","time","msg",...} lines
+)
+
+// ParseFormat parses a --log-format value.
+func ParseFormat(s string) (Format, error) {
+	switch s {
+	case "text", "":
+		return FormatText, nil
+	case "json":
+		return FormatJSON, nil
+	}
+	return FormatText, fmt.Errorf("invalid log format %q (expected text|json)", s)
+}
+
+// Fields are extra key/value pairs attached to a JSON log line.
+type Fields map[string]interface{}
+
+var (
+	mu        sync.Mutex
+	level               = LevelNormal
+	logFormat           = FormatText
+	now                 = time.Now
+	stdout    io.Writer = os.Stdout
+	stderr    io.Writer = os.Stderr
+)
+
+// SetLevel sets the global output level.
+func SetLevel(l Level) {
+	mu.Lock()
+	defer mu.Unlock()
+	level = l
+}
+
+// Enabled reports whether output at level l is printed.
+func Enabled(l Level) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return level >= l
+}
+
+// SetFormat sets how warnings, errors and events are written.
+func SetFormat(f Format) {
+	mu.Lock()
+	defer mu.Unlock()
+	logFormat = f
+}
+
+// JSON reports whether log lines are written as JSON.
+func JSON() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return logFormat == FormatJSON
+}
+
+// SetOutput redirects regular output and warnings/errors, e.g. in tests.
+func SetOutput(out, errOut io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+	stdout, stderr = out, errOut
+}
+
+func printf(l Level, w *io.Writer, prefix, format string, args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	if level < l {
+		return
+	}
+	fmt.Fprintf(*w, prefix+format, args...)
+}
+
+// Infof prints progress and summary output, unless quiet.
+func Infof(format string, args ...interface{}) {
+	printf(LevelNormal, &stdout, "", format, args...)
+}
+
+// Verbosef prints per-file detail, only when verbose.
+func Verbosef(format string, args ...interface{}) {
+	printf(LevelVerbose, &stdout, "", format, args...)
+}
+
+// Warnf prints a "warning: " line to stderr at every level.
+func Warnf(format string, args ...interface{}) {
+	logf("warning", "", format, args...)
+}
+
+// Errorf prints an "error: " line to stderr at every level.
+func Errorf(format string, args ...interface{}) {
+	logf("error", "", format, args...)
+}
+
+// Alertf prints an "ALERT: " line to stderr at every level, for problems that
+// need attention right away (e.g. a disk that looks offline).
+func Alertf(format string, args ...interface{}) {
+	logf("alert", "", format, args...)
+}
+
+// PathWarnf is Warnf about one file: "warning: <path>: <msg>", or a JSON line
+// with a separate "path" field.
+func PathWarnf(path, format string, args ...interface{}) {
+	logf("warning", path, format, args...)
+}
+
+// PathErrorf is Errorf about one file.
+func PathErrorf(path, format string, args ...interface{}) {
+	logf("error", path, format, args...)
+}
+
+// Event records a milestone such as a finished scan. Text output already has
+// its own summaries, so events are only written in JSON format (unless quiet).
+func Event(msg string, fields Fields) {
+	mu.Lock()
+	defer mu.Unlock()
+	if logFormat != FormatJSON || level < LevelNormal {
+		return
+	}
+	writeJSON("info", "", msg, fields)
+}
+
+func logf(lvl, path, f string, args ...interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	msg := fmt.Sprintf(f, args...)
+	if logFormat == FormatJSON {
+		writeJSON(lvl, path, strings.TrimRight(msg, "\n"), nil)
+		return
+	}
+	if path != "" {
+		msg = path + ": " + msg
+	}
+	prefix := lvl + ": "
+	if lvl == "alert" {
+		prefix = "ALERT: "
+	}
+	fmt.Fprint(stderr, prefix+msg)
+}
+
+// writeJSON writes one log line; mu must be held.
+func writeJSON(lvl, path, msg string, fields Fields) {
+	line := make(map[string]interface{}, len(fields)+4)
+	for k, v := range fields {
+		line[k] = v
+	}
+	line["level"] = lvl
+	line["time"] = now().UTC().Format(time.RFC3339)
+	line["msg"] = msg
+	if path != "" {
+		line["path"] = path
+	}
+	b, err := json.Marshal(line)
+	if err != nil {
+		b, _ = json.Marshal(map[string]string{"level": lvl, "msg": msg})
+	}
+	fmt.Fprintf(stderr, "%s\n", b)
+}
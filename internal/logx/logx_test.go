@@ -0,0 +1,68 @@
+package logx
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestLevels(t *testing.T) {
+	var out, errOut bytes.Buffer
+	SetOutput(&out, &errOut)
+	defer SetLevel(LevelNormal)
+
+	tests := []struct {
+		level   Level
+		wantOut string
+	}{
+		{LevelQuiet, ""},
+		{LevelNormal, "summary\n"},
+		{LevelVerbose, "summary\nfile a\n"},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		errOut.Reset()
+		SetLevel(tt.level)
+		Infof("summary\n")
+		Verbosef("file %s\n", "a")
+		Warnf("disk %d slow\n", 3)
+		Errorf("boom\n")
+		if out.String() != tt.wantOut {
+			t.Errorf("level %d: stdout = %q, want %q", tt.level, out.String(), tt.wantOut)
+		}
+		if want := "warning: disk 3 slow\nerror: boom\n"; errOut.String() != want {
+			t.Errorf("level %d: stderr = %q, want %q", tt.level, errOut.String(), want)
+		}
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	var out, errOut bytes.Buffer
+	SetOutput(&out, &errOut)
+	SetFormat(FormatJSON)
+	defer SetFormat(FormatText)
+	now = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+
+	PathWarnf("/mnt/disk1/a", "stat: %s\n", "permission denied")
+	Errorf("boom\n")
+	Event("scan complete", Fields{"files": 3})
+
+	want := `{"level":"warning","msg":"stat: permission denied","path":"/mnt/disk1/a","time":"2024-05-01T12:00:00Z"}
+{"level":"error","msg":"boom","time":"2024-05-01T12:00:00Z"}
+{"files":3,"level":"info","msg":"scan complete","time":"2024-05-01T12:00:00Z"}
+`
+	if errOut.String() != want {
+		t.Errorf("stderr =\n%s\nwant\n%s", errOut.String(), want)
+	}
+	if out.Len() != 0 {
+		t.Errorf("stdout = %q, want nothing", out.String())
+	}
+
+	SetFormat(FormatText)
+	errOut.Reset()
+	PathWarnf("/mnt/disk1/a", "stat: %s\n", "permission denied")
+	if want := "warning: /mnt/disk1/a: stat: permission denied\n"; errOut.String() != want {
+		t.Errorf("text stderr = %q, want %q", errOut.String(), want)
+	}
+}
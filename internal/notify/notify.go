@@ -0,0 +1,141 @@
+// Package notify sends filehasher's summaries by email.
+package notify
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPort is the SMTP submission port, which upgrades to TLS with
+// STARTTLS.
+const DefaultPort = 587
+
+// implicitTLSPort is SMTPS, where the connection starts out encrypted.
+const implicitTLSPort = 465
+
+// dialTimeout bounds connecting to the mail server.
+const dialTimeout = 30 * time.Second
+
+// SMTP is an outgoing mail server and the account to send from.
+type SMTP struct {
+	Host string
+	Port int // DefaultPort when 0; 465 connects with TLS from the start
+	// Username and Password log in with PLAIN auth, only over TLS or to
+	// localhost. Leave Username empty for a relay that needs no login.
+	Username string
+	Password string
+	From     string
+}
+
+// SendHTML mails an HTML message to every address in to.
+func (s SMTP) SendHTML(to []string, subject, html string) error {
+	if s.Host == "" {
+		return fmt.Errorf("no SMTP server configured")
+	}
+	if s.From == "" {
+		return fmt.Errorf("no sender address configured")
+	}
+	if len(to) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+	msg, err := buildMessage(s.From, to, subject, html, time.Now())
+	if err != nil {
+		return err
+	}
+	return s.send(to, msg)
+}
+
+func (s SMTP) send(to []string, msg []byte) error {
+	port := s.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: s.Host}
+
+	var conn net.Conn
+	var err error
+	if port == implicitTLSPort {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: dialTimeout}, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, dialTimeout)
+	}
+	if err != nil {
+		return fmt.Errorf("connect to %s: %w", addr, err)
+	}
+	c, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("connect to %s: %w", addr, err)
+	}
+	defer c.Close()
+
+	if port != implicitTLSPort {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("starttls: %w", err)
+			}
+		}
+	}
+	if s.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			return fmt.Errorf("login as %s: %w", s.Username, err)
+		}
+	}
+	if err := c.Mail(s.From); err != nil {
+		return fmt.Errorf("sender %s: %w", s.From, err)
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("recipient %s: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// buildMessage formats a single-part HTML message. The body is
+// quoted-printable so long table rows stay within SMTP's line limit.
+func buildMessage(from string, to []string, subject, html string, date time.Time) ([]byte, error) {
+	for _, addr := range append([]string{from}, to...) {
+		if strings.ContainsAny(addr, "\r\n") {
+			return nil, fmt.Errorf("invalid address %q", addr)
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/html; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	buf.WriteString("\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write([]byte(html)); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"bufio"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildMessage(t *testing.T) {
+	html := "<p>" + strings.Repeat("long row ", 200) + "</p>"
+	msg, err := buildMessage("tower@example.com", []string{"a@example.com", "b@example.com"},
+		"filehasher: 3 corrupted – disk2", html, time.Date(2026, 10, 17, 6, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("buildMessage: %v", err)
+	}
+
+	head, body, ok := strings.Cut(string(msg), "\r\n\r\n")
+	if !ok {
+		t.Fatalf("no header/body separator in %q", msg)
+	}
+	for _, want := range []string{
+		"From: tower@example.com",
+		"To: a@example.com, b@example.com",
+		"Subject: =?utf-8?q?",
+		"Date: Sat, 17 Oct 2026 06:00:00 +0000",
+		"Content-Type: text/html; charset=utf-8",
+		"Content-Transfer-Encoding: quoted-printable",
+	} {
+		if !strings.Contains(head, want) {
+			t.Errorf("header lacks %q:\n%s", want, head)
+		}
+	}
+	for _, line := range strings.Split(body, "\r\n") {
+		if len(line) > 76 {
+			t.Fatalf("body line of %d characters, want at most 76", len(line))
+		}
+	}
+	decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+	if err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if string(decoded) != html {
+		t.Errorf("decoded body differs from the HTML sent")
+	}
+
+	if _, err := buildMessage("x@example.com\r\nBcc: y@example.com", []string{"a@example.com"}, "s", "", time.Now()); err == nil {
+		t.Error("buildMessage accepted an address with a line break")
+	}
+}
+
+// TestSendHTML talks to a minimal SMTP server without TLS or login.
+func TestSendHTML(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	got := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		var cmds []string
+		reply("220 test ESMTP")
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				got <- cmds
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			if inData {
+				if line == "." {
+					inData = false
+					reply("250 queued")
+				}
+				continue
+			}
+			cmds = append(cmds, line)
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				reply("250 test")
+			case line == "DATA":
+				inData = true
+				reply("354 go ahead")
+			case line == "QUIT":
+				reply("221 bye")
+				got <- cmds
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	s := SMTP{Host: "127.0.0.1", Port: ln.Addr().(*net.TCPAddr).Port, From: "tower@example.com"}
+	if err := s.SendHTML([]string{"me@example.com"}, "all clear", "<p>ok</p>"); err != nil {
+		t.Fatalf("SendHTML: %v", err)
+	}
+	cmds := strings.Join(<-got, "\n")
+	for _, want := range []string{"MAIL FROM:<tower@example.com>", "RCPT TO:<me@example.com>", "DATA", "QUIT"} {
+		if !strings.Contains(cmds, want) {
+			t.Errorf("server never saw %q:\n%s", want, cmds)
+		}
+	}
+
+	if err := (SMTP{From: "tower@example.com"}).SendHTML([]string{"me@example.com"}, "s", ""); err == nil {
+		t.Error("SendHTML without a server succeeded")
+	}
+}
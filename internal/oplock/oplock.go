@@ -0,0 +1,141 @@
+// Package oplock keeps scans and verifies of one catalog from running at the
+// same time, whether they come from cron, the CLI or the dashboard. The lock
+// is a file next to the catalog, held with an OS file lock (flock on Unix)
+// for as long as the operation runs and naming the process that holds it.
+// The OS drops the lock when its holder exits, so one left behind by a
+// process that died is simply taken over.
+package oplock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Info describes the holder of a lock.
+type Info struct {
+	PID       int       `json:"pid"`
+	Operation string    `json:"operation"` // e.g. "scan" or "verify"
+	Started   time.Time `json:"started"`
+}
+
+// BusyError is returned by Acquire while another live process holds the lock.
+type BusyError struct {
+	Path   string
+	Holder Info
+}
+
+func (e *BusyError) Error() string {
+	return fmt.Sprintf("another operation is running (%s, pid %d, started %s; lock %s)",
+		e.Holder.Operation, e.Holder.PID, e.Holder.Started.Local().Format("2006-01-02 15:04:05"), e.Path)
+}
+
+// Lock is a held lock.
+type Lock struct {
+	path string
+	info Info
+	f    *os.File
+}
+
+// Path returns the lock file for the catalog at dbPath.
+func Path(dbPath string) string {
+	return dbPath + ".lock"
+}
+
+// errLocked is returned by lockFile when another open file holds the lock.
+var errLocked = errors.New("locked")
+
+// Acquire takes the lock at path for op. It fails with a *BusyError while a
+// running process holds it, and takes over a lock whose holder has exited.
+func Acquire(path, op string) (*Lock, error) {
+	info := Info{PID: os.Getpid(), Operation: op, Started: time.Now()}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("create lock: %w", err)
+		}
+		if err := lockFile(f); err != nil {
+			f.Close()
+			if !errors.Is(err, errLocked) {
+				return nil, fmt.Errorf("lock %s: %w", path, err)
+			}
+			holder, _ := read(path)
+			if holder == nil {
+				holder = &Info{}
+			}
+			return nil, &BusyError{Path: path, Holder: *holder}
+		}
+		// A holder releasing meanwhile removes the file it locked; ours is
+		// only the lock if it is still the file at path.
+		if !stillAt(f, path) {
+			f.Close()
+			continue
+		}
+		if err := f.Truncate(0); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("write lock: %w", err)
+		}
+		if _, err := f.WriteAt(data, 0); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("write lock: %w", err)
+		}
+		return &Lock{path: path, info: info, f: f}, nil
+	}
+	return nil, fmt.Errorf("lock %s keeps changing; is another operation starting?", path)
+}
+
+// stillAt reports whether f is the file currently at path.
+func stillAt(f *os.File, path string) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	cur, err := os.Stat(path)
+	return err == nil && os.SameFile(fi, cur)
+}
+
+// Release gives up the lock. The file is removed while still locked, so
+// nobody can take it over in between.
+func (l *Lock) Release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	err := os.Remove(l.path)
+	cerr := l.f.Close()
+	l.f = nil
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		// Windows can't remove a file that is still open. Try again now it
+		// is closed; if someone has opened it since, it is theirs to remove.
+		os.Remove(l.path)
+	}
+	return cerr
+}
+
+// Holder returns who holds the lock at path, or nil when nobody does (or
+// only an exited process left it behind).
+func Holder(path string) *Info {
+	info, err := read(path)
+	if err != nil || !alive(info.PID) {
+		return nil
+	}
+	return info
+}
+
+func read(path string) (*Info, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("parse lock %s: %w", path, err)
+	}
+	return &info, nil
+}
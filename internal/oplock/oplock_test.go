@@ -0,0 +1,143 @@
+package oplock
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAcquireBusyRelease(t *testing.T) {
+	path := Path(filepath.Join(t.TempDir(), "catalog.db"))
+
+	l, err := Acquire(path, "scan")
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	h := Holder(path)
+	if h == nil || h.PID != os.Getpid() || h.Operation != "scan" {
+		t.Fatalf("Holder = %+v, want this process running scan", h)
+	}
+
+	_, err = Acquire(path, "verify")
+	var busy *BusyError
+	if !errors.As(err, &busy) || busy.Holder.Operation != "scan" {
+		t.Fatalf("second Acquire = %v, want a BusyError naming the scan", err)
+	}
+
+	if err := l.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if Holder(path) != nil {
+		t.Error("Holder should be nil after Release")
+	}
+	l2, err := Acquire(path, "verify")
+	if err != nil {
+		t.Fatalf("Acquire after Release: %v", err)
+	}
+	l2.Release()
+
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 0 {
+		t.Errorf("left %d files behind, want none", len(entries))
+	}
+}
+
+func TestAcquireTakesOverStaleLock(t *testing.T) {
+	path := Path(filepath.Join(t.TempDir(), "catalog.db"))
+
+	// A pid that has certainly exited
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("cannot run true: %v", err)
+	}
+	stale, _ := json.Marshal(Info{PID: cmd.Process.Pid, Operation: "verify", Started: time.Now().Add(-time.Hour)})
+	if err := os.WriteFile(path, stale, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if Holder(path) != nil {
+		t.Error("Holder should ignore a lock whose process exited")
+	}
+
+	l, err := Acquire(path, "scan")
+	if err != nil {
+		t.Fatalf("Acquire over stale lock: %v", err)
+	}
+	defer l.Release()
+	if h := Holder(path); h == nil || h.PID != os.Getpid() {
+		t.Errorf("Holder = %+v, want this process", h)
+	}
+}
+
+func TestAcquireTakesOverGarbage(t *testing.T) {
+	path := Path(filepath.Join(t.TempDir(), "catalog.db"))
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := Acquire(path, "scan")
+	if err != nil {
+		t.Fatalf("Acquire over unreadable lock: %v", err)
+	}
+	l.Release()
+}
+
+func TestAcquireExclusive(t *testing.T) {
+	path := Path(filepath.Join(t.TempDir(), "catalog.db"))
+
+	// Many contenders taking and releasing the lock, some over a stale
+	// file: at no point may two of them hold it.
+	var holders atomic.Int32
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				l, err := Acquire(path, "scan")
+				if err != nil {
+					var busy *BusyError
+					if !errors.As(err, &busy) {
+						t.Errorf("Acquire: %v", err)
+						return
+					}
+					continue
+				}
+				if n := holders.Add(1); n > 1 {
+					t.Errorf("%d contenders hold the lock at once", n)
+				}
+				time.Sleep(50 * time.Microsecond)
+				holders.Add(-1)
+				if err := l.Release(); err != nil {
+					t.Errorf("Release: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestAcquireAfterHolderExits(t *testing.T) {
+	path := Path(filepath.Join(t.TempDir(), "catalog.db"))
+	l, err := Acquire(path, "scan")
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	// Closing the file is what the OS does when a holder dies: the lock is
+	// gone even though the file with its record is left behind.
+	l.f.Close()
+	l.f = nil
+
+	l2, err := Acquire(path, "verify")
+	if err != nil {
+		t.Fatalf("Acquire after holder exited: %v", err)
+	}
+	defer l2.Release()
+	if info, _ := read(path); info == nil || info.Operation != "verify" {
+		t.Errorf("lock record = %+v, want the verify", info)
+	}
+}
@@ -0,0 +1,92 @@
+// Package progress counts the work done by a scan or verify so every view of
+// it (terminal summary, dashboard, logs) reports the same totals, rate and
+// ETA.
+package progress
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Tracker is a set of counters for one run. All methods are safe for
+// concurrent use; walkers add to the totals while hashers add to the done
+// counts.
+type Tracker struct {
+	start time.Time
+
+	processed  atomic.Int64 // files finished, including errors
+	errors     atomic.Int64
+	skipped    atomic.Int64 // files passed over as unchanged
+	bytesDone  atomic.Int64
+	filesTotal atomic.Int64 // files found that need work
+	bytesTotal atomic.Int64
+}
+
+// Snapshot is a consistent-enough copy of a Tracker's counters at one moment,
+// with the derived rate and estimate.
+type Snapshot struct {
+	Processed  int64         `json:"processed"`
+	Errors     int64         `json:"errors"`
+	Skipped    int64         `json:"skipped"`
+	BytesDone  int64         `json:"bytesDone"`
+	FilesTotal int64         `json:"filesTotal"`
+	BytesTotal int64         `json:"bytesTotal"`
+	Elapsed    time.Duration `json:"-"`
+	// Rate is the average throughput since the start, in bytes per second.
+	Rate float64 `json:"rate"`
+	// ETA is the time left for BytesTotal at Rate; 0 when unknown or done.
+	// Totals still grow while a walk runs, so it is only an estimate until
+	// the walk finishes.
+	ETA time.Duration `json:"-"`
+}
+
+// New returns a Tracker whose clock starts now.
+func New() *Tracker {
+	return &Tracker{start: time.Now()}
+}
+
+// AddProcessed counts n more finished files.
+func (t *Tracker) AddProcessed(n int64) { t.processed.Add(n) }
+
+// AddErrors counts n more files that failed.
+func (t *Tracker) AddErrors(n int64) { t.errors.Add(n) }
+
+// AddSkipped counts n more files that needed no work.
+func (t *Tracker) AddSkipped(n int64) { t.skipped.Add(n) }
+
+// AddBytes counts n more bytes read.
+func (t *Tracker) AddBytes(n int64) { t.bytesDone.Add(n) }
+
+// AddTotal grows the known amount of work by files and bytes, and returns the
+// new byte total.
+func (t *Tracker) AddTotal(files, bytes int64) int64 {
+	t.filesTotal.Add(files)
+	return t.bytesTotal.Add(bytes)
+}
+
+// Processed returns the number of finished files.
+func (t *Tracker) Processed() int64 { return t.processed.Load() }
+
+// Snapshot reads the counters and derives the rate and ETA.
+func (t *Tracker) Snapshot() Snapshot {
+	return t.snapshot(time.Now())
+}
+
+func (t *Tracker) snapshot(now time.Time) Snapshot {
+	s := Snapshot{
+		Processed:  t.processed.Load(),
+		Errors:     t.errors.Load(),
+		Skipped:    t.skipped.Load(),
+		BytesDone:  t.bytesDone.Load(),
+		FilesTotal: t.filesTotal.Load(),
+		BytesTotal: t.bytesTotal.Load(),
+		Elapsed:    now.Sub(t.start),
+	}
+	if secs := s.Elapsed.Seconds(); secs > 0 {
+		s.Rate = float64(s.BytesDone) / secs
+	}
+	if s.Rate > 0 && s.BytesTotal > s.BytesDone {
+		s.ETA = time.Duration(float64(s.BytesTotal-s.BytesDone) / s.Rate * float64(time.Second))
+	}
+	return s
+}
@@ -0,0 +1,61 @@
+package progress
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTrackerConcurrent(t *testing.T) {
+	tr := New()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				tr.AddTotal(1, 10)
+				tr.AddProcessed(1)
+				tr.AddBytes(10)
+			}
+			tr.AddErrors(1)
+			tr.AddSkipped(2)
+		}()
+	}
+	wg.Wait()
+
+	s := tr.Snapshot()
+	if s.Processed != 8000 || s.BytesDone != 80000 || s.FilesTotal != 8000 || s.BytesTotal != 80000 {
+		t.Errorf("counts = %+v", s)
+	}
+	if s.Errors != 8 || s.Skipped != 16 {
+		t.Errorf("errors/skipped = %d/%d, want 8/16", s.Errors, s.Skipped)
+	}
+	if tr.Processed() != 8000 {
+		t.Errorf("Processed() = %d, want 8000", tr.Processed())
+	}
+	if s.ETA != 0 {
+		t.Errorf("ETA = %v with all work done, want 0", s.ETA)
+	}
+}
+
+func TestTrackerRateAndETA(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tr := &Tracker{start: start}
+	tr.AddTotal(4, 4000)
+	tr.AddBytes(1000)
+
+	s := tr.snapshot(start.Add(10 * time.Second))
+	if s.Rate != 100 {
+		t.Errorf("Rate = %v, want 100 B/s", s.Rate)
+	}
+	if s.ETA != 30*time.Second {
+		t.Errorf("ETA = %v, want 30s", s.ETA)
+	}
+
+	// Nothing read yet: no rate, no estimate
+	s = (&Tracker{start: start}).snapshot(start.Add(time.Second))
+	if s.Rate != 0 || s.ETA != 0 {
+		t.Errorf("idle tracker: rate %v, ETA %v; want 0, 0", s.Rate, s.ETA)
+	}
+}
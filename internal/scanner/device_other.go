@@ -0,0 +1,11 @@
+//go:build !unix
+
+package scanner
+
+import "os"
+
+// deviceOf reports false: this platform has no device IDs, so walks don't
+// stop at filesystem boundaries.
+func deviceOf(info os.FileInfo) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,193 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DisksIniPath is where Unraid's emhttp keeps the state of every configured
+// array disk and pool: slot name, block device, drive id, spin type and
+// whether the file system is mounted. It only exists on a running Unraid
+// server; elsewhere detection falls back to the directories under /mnt.
+const DisksIniPath = "/var/local/emhttp/disks.ini"
+
+// disksIni and mntRoot are variables so tests can point them elsewhere.
+var (
+	disksIni = DisksIniPath
+	mntRoot  = "/mnt"
+)
+
+// UnmountedDisk is a disk Unraid has configured that can't be scanned now.
+type UnmountedDisk struct {
+	DiskInfo
+	Reason string // Unraid's fsStatus, e.g. "Unmountable: No file system"
+}
+
+// Detection is what DetectDisks found.
+type Detection struct {
+	Disks     []DiskInfo      // mounted and ready to scan, sorted by name
+	Unmounted []UnmountedDisk // configured in disks.ini but not mounted
+	Source    string          // DisksIniPath, or "/mnt" for the directory heuristic
+}
+
+// iniDisk is one section of disks.ini.
+type iniDisk struct {
+	Name       string
+	Device     string
+	ID         string
+	Type       string // "Parity", "Data", "Cache" or "Flash"
+	Status     string // e.g. "DISK_OK", "DISK_NP" for an empty slot
+	FsStatus   string // "Mounted", "Unmounted", "Unmountable: ..."
+	Rotational string // "1" or "0"
+}
+
+// parseDisksIni reads disks.ini: sections like ["disk1"] followed by
+// key="value" lines. Sections are returned in file order.
+func parseDisksIni(r io.Reader) ([]iniDisk, error) {
+	var disks []iniDisk
+	var cur *iniDisk
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			disks = append(disks, iniDisk{Name: unquoteIni(line[1 : len(line)-1])})
+			cur = &disks[len(disks)-1]
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !ok || cur == nil {
+			continue
+		}
+		val = unquoteIni(val)
+		switch strings.TrimSpace(key) {
+		case "name":
+			cur.Name = val
+		case "device":
+			cur.Device = val
+		case "id":
+			cur.ID = val
+		case "type":
+			cur.Type = val
+		case "status":
+			cur.Status = val
+		case "fsStatus":
+			cur.FsStatus = val
+		case "rotational":
+			cur.Rotational = val
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return disks, nil
+}
+
+func unquoteIni(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	return s
+}
+
+// poolMemberPattern splits a pool slot like "cache2" into pool and index.
+var poolMemberPattern = regexp.MustCompile(`^(.+?)(\d+)$`)
+
+// disksFromIni turns disks.ini sections into scannable disks and the
+// configured ones that aren't mounted. Parity and flash are never scanned,
+// empty slots are skipped, and the second and later devices of a
+// multi-device pool ("cache2" next to "cache") are part of that pool's
+// mount rather than disks of their own.
+func disksFromIni(entries []iniDisk, mnt string) *Detection {
+	pools := map[string]bool{}
+	for _, e := range entries {
+		if strings.EqualFold(e.Type, "Cache") {
+			pools[e.Name] = true
+		}
+	}
+
+	det := &Detection{Source: disksIni}
+	for _, e := range entries {
+		var isData bool
+		switch {
+		case strings.EqualFold(e.Type, "Data"):
+			isData = true
+		case strings.EqualFold(e.Type, "Cache"):
+		default:
+			continue
+		}
+		if e.Name == "" || strings.ContainsRune(e.Name, '/') || (e.Device == "" && strings.HasPrefix(e.Status, "DISK_NP")) {
+			continue
+		}
+		path := filepath.Join(mnt, e.Name)
+		mounted := strings.EqualFold(e.FsStatus, "Mounted")
+		if !isData && !mounted {
+			if m := poolMemberPattern.FindStringSubmatch(e.Name); m != nil && pools[m[1]] {
+				continue
+			}
+		}
+
+		d := DiskInfo{Name: e.Name, Path: path, Device: e.Device, ID: e.ID}
+		switch e.Rotational {
+		case "1":
+			d.Type = DiskTypeHDD
+		case "0":
+			d.Type = DiskTypeSSD
+		}
+		if mounted {
+			if entries, err := os.ReadDir(path); err != nil || len(entries) == 0 {
+				mounted = false
+			}
+		}
+		if !mounted {
+			reason := e.FsStatus
+			if reason == "" {
+				reason = "not mounted"
+			}
+			det.Unmounted = append(det.Unmounted, UnmountedDisk{DiskInfo: d, Reason: reason})
+			continue
+		}
+		if d.Type == DiskTypeUnknown {
+			d.Type = detectDiskType(path)
+		}
+		det.Disks = append(det.Disks, d)
+	}
+
+	sort.Slice(det.Disks, func(i, j int) bool { return det.Disks[i].Name < det.Disks[j].Name })
+	sort.Slice(det.Unmounted, func(i, j int) bool { return det.Unmounted[i].Name < det.Unmounted[j].Name })
+	return det
+}
+
+// DetectDisks finds the disks to scan. On Unraid it reads disks.ini, which
+// knows every configured disk and pool, including the ones that aren't
+// mounted, and ignores other mounts under /mnt. Without disks.ini it falls
+// back to the array disk, cache and named pool directories under /mnt.
+func DetectDisks() (*Detection, error) {
+	f, err := os.Open(disksIni)
+	if err == nil {
+		defer f.Close()
+		entries, err := parseDisksIni(f)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", disksIni, err)
+		}
+		return disksFromIni(entries, mntRoot), nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read %s: %w", disksIni, err)
+	}
+
+	disks, err := detectMntDisks(mntRoot)
+	if err != nil {
+		return nil, err
+	}
+	return &Detection{Disks: disks, Source: mntRoot}, nil
+}
@@ -0,0 +1,139 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// pseudoFSTypes are kernel filesystems that never hold user data. A walk
+// always skips mounts of these types, even with CrossFilesystems.
+var pseudoFSTypes = map[string]bool{
+	"proc": true, "sysfs": true, "devtmpfs": true, "devpts": true,
+	"cgroup": true, "cgroup2": true, "debugfs": true, "tracefs": true,
+	"securityfs": true, "bpf": true, "pstore": true, "configfs": true,
+	"fusectl": true, "mqueue": true, "hugetlbfs": true, "autofs": true,
+}
+
+// mount is one line of /proc/mounts.
+type mount struct {
+	source string
+	target string
+	fstype string
+}
+
+// readMounts parses /proc/mounts into a map keyed by mount point. It returns
+// nil if the file can't be read (e.g. outside Linux).
+func readMounts(procMounts string) map[string]mount {
+	f, err := os.Open(procMounts)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	mounts := make(map[string]mount)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		m := mount{source: unescapeMount(fields[0]), target: unescapeMount(fields[1]), fstype: fields[2]}
+		mounts[m.target] = m // later (stacked) mounts hide earlier ones
+	}
+	return mounts
+}
+
+// unescapeMount decodes the octal escapes (\040 for space, ...) the kernel
+// uses for whitespace and backslashes in /proc/mounts.
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// mountOf returns the mount holding path: the one with the longest mount
+// point that is path or one of its parents.
+func mountOf(mounts map[string]mount, path string) (mount, bool) {
+	for p := filepath.Clean(path); ; p = filepath.Dir(p) {
+		if m, ok := mounts[p]; ok {
+			return m, true
+		}
+		if p == filepath.Dir(p) {
+			return mount{}, false
+		}
+	}
+}
+
+// sameZFSPool reports whether m is a dataset of the ZFS pool root lives on.
+// Each dataset is its own filesystem, but on an Unraid ZFS pool they are
+// part of the same disk and must not be skipped.
+func sameZFSPool(root, m mount) bool {
+	if root.fstype != "zfs" || m.fstype != "zfs" {
+		return false
+	}
+	pool, _, _ := strings.Cut(root.source, "/")
+	return m.source == pool || strings.HasPrefix(m.source, pool+"/")
+}
+
+// crossing decides whether the walk from a root on rootDev/root may enter
+// directory path. It returns why not, or "" to enter it. Only mount points
+// listed in mounts count as another filesystem, since btrfs subvolumes have
+// their own device ID too; without mounts, or with OneFilesystem, any device
+// change does.
+func (s *Scanner) crossing(path string, d fs.DirEntry, rootDev uint64, root mount, mounts map[string]mount) string {
+	info, err := d.Info()
+	if err != nil {
+		return ""
+	}
+	dev, ok := deviceOf(info)
+	if !ok || dev == rootDev {
+		return ""
+	}
+	m, isMount := mounts[path]
+	if s.OneFilesystem {
+		return "skipping directory on another device (--one-filesystem)"
+	}
+	if mounts != nil && !isMount {
+		return ""
+	}
+	if isMount && s.excludesFSType(m.fstype) {
+		return fmt.Sprintf("skipping %s mount", m.fstype)
+	}
+	if s.CrossFilesystems || sameZFSPool(root, m) {
+		return ""
+	}
+	if isMount {
+		return fmt.Sprintf("skipping %s mount on another filesystem (use --cross-filesystems to include it)", m.fstype)
+	}
+	return "skipping directory on another filesystem (use --cross-filesystems to include it)"
+}
+
+// excludesFSType reports whether mounts of fstype are never walked.
+func (s *Scanner) excludesFSType(fstype string) bool {
+	if pseudoFSTypes[fstype] {
+		return true
+	}
+	for _, t := range s.ExcludeFSTypes {
+		if t == fstype {
+			return true
+		}
+	}
+	return false
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"os/exec"
@@ -11,8 +12,11 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/maisi/unraid-filehasher/internal/hasher"
+	"github.com/maisi/unraid-filehasher/internal/logx"
 )
 
 // DiskType represents the storage type of a disk.
@@ -35,16 +39,66 @@ func (dt DiskType) String() string {
 	}
 }
 
-// DefaultWorkers returns the recommended worker count for this disk type.
-func (dt DiskType) DefaultWorkers() int {
+// ParseDiskType is the inverse of DiskType.String; anything but "HDD" or
+// "SSD" is DiskTypeUnknown.
+func ParseDiskType(s string) DiskType {
+	switch s {
+	case "HDD":
+		return DiskTypeHDD
+	case "SSD":
+		return DiskTypeSSD
+	default:
+		return DiskTypeUnknown
+	}
+}
+
+// WorkerDefaults are the hash workers per disk used when none is given,
+// chosen by disk type.
+type WorkerDefaults struct {
+	HDD     int
+	SSD     int
+	Unknown int
+}
+
+// BuiltinWorkers is the default heuristic: one worker per HDD, where parallel
+// reads only add seeking, four per SSD, and two when the type is unknown.
+var BuiltinWorkers = WorkerDefaults{HDD: 1, SSD: 4, Unknown: 2}
+
+// For returns the worker count for a disk of type dt.
+func (w WorkerDefaults) For(dt DiskType) int {
 	switch dt {
 	case DiskTypeHDD:
-		return 1
+		return w.HDD
 	case DiskTypeSSD:
-		return 4
+		return w.SSD
 	default:
-		return 2
+		return w.Unknown
+	}
+}
+
+// ByDisk maps each disk's name to the worker count for its type, for
+// hashing pipelines that are set up per disk name (e.g. verify).
+func (w WorkerDefaults) ByDisk(disks []DiskInfo) map[string]int {
+	m := make(map[string]int, len(disks))
+	for _, d := range disks {
+		m[d.Name] = w.For(d.Type)
 	}
+	return m
+}
+
+// ByType is ByDisk for disk types as recorded in the catalog (see
+// ParseDiskType), keyed by disk name.
+func (w WorkerDefaults) ByType(types map[string]string) map[string]int {
+	m := make(map[string]int, len(types))
+	for name, t := range types {
+		m[name] = w.For(ParseDiskType(t))
+	}
+	return m
+}
+
+// DefaultWorkers returns the built-in worker count for this disk type.
+func (dt DiskType) DefaultWorkers() int {
+	return BuiltinWorkers.For(dt)
 }
 
 // Package-level compiled regex patterns for Unraid disk names.
@@ -53,18 +107,166 @@ var (
 	cachePattern = regexp.MustCompile(`^cache\d*$`)
 )
 
+// PoolConfigDir is where Unraid keeps one <name>.cfg per named pool.
+const PoolConfigDir = "/boot/config/pools"
+
+// reservedMountNames are directories under /mnt that are never a single
+// disk or pool: user shares, unassigned devices and remote mounts.
+var reservedMountNames = map[string]bool{
+	"user": true, "user0": true, "disks": true, "remotes": true,
+	"addons": true, "rootshare": true,
+}
+
+// poolNames holds named pools (e.g. "nvme", "ssdpool") that are treated like
+// cache pools, on top of those matching cachePattern. See SetPoolNames.
+var (
+	poolMu    sync.RWMutex
+	poolNames = map[string]bool{}
+)
+
+// SetPoolNames sets the named pools recognized by DetectUnraidDisks,
+// ResolveDisk and ResolveShareFile, replacing any set before. Names reserved
+// for shares and unassigned devices (user, user0, disks, remotes, ...) are
+// rejected.
+func SetPoolNames(names []string) error {
+	m := make(map[string]bool, len(names))
+	for _, n := range names {
+		n = strings.TrimSpace(n)
+		if n == "" {
+			continue
+		}
+		if reservedMountNames[n] || strings.ContainsRune(n, '/') {
+			return fmt.Errorf("invalid pool name %q", n)
+		}
+		m[n] = true
+	}
+	poolMu.Lock()
+	poolNames = m
+	poolMu.Unlock()
+	return nil
+}
+
+// LoadPoolNames lists the pools configured on this server, one per <name>.cfg
+// in dir (normally PoolConfigDir). A missing dir gives no names and no error.
+func LoadPoolNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", dir, err)
+	}
+	var names []string
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), ".cfg"); ok && !e.IsDir() && name != "" && !reservedMountNames[name] {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// isPool reports whether name under /mnt is a cache or named pool.
+func isPool(name string) bool {
+	if cachePattern.MatchString(name) {
+		return true
+	}
+	poolMu.RLock()
+	defer poolMu.RUnlock()
+	return poolNames[name]
+}
+
+// isUnraidDisk reports whether name under /mnt is an array disk or a pool.
+func isUnraidDisk(name string) bool {
+	return diskPattern.MatchString(name) || isPool(name)
+}
+
 // DiskInfo represents a detected Unraid disk.
 type DiskInfo struct {
 	Name string   // e.g., "disk1", "disk2", "cache"
 	Path string   // e.g., "/mnt/disk1"
 	Type DiskType // HDD, SSD, or unknown
+
+	// Device and ID come from Unraid's disks.ini (e.g. "sdc" and the drive
+	// model and serial); they are empty when the disk was found under /mnt.
+	Device string
+	ID     string
 }
 
 // Scanner walks filesystem paths and feeds files to the hasher.
 type Scanner struct {
 	excludePatterns []*regexp.Regexp
+
+	// MaxDepth limits how many directory levels below each walk root are
+	// descended into: 0 picks up only the files directly in the root, 1 also
+	// those in its subdirectories, and so on. Deeper directories are not
+	// entered. NoMaxDepth, which New sets, means no limit.
+	MaxDepth int
+
+	// CrossFilesystems lets a walk descend into other filesystems mounted
+	// below its root. By default they are skipped with a warning, except
+	// datasets of the same ZFS pool.
+	CrossFilesystems bool
+
+	// OneFilesystem is the strict opposite, like find -xdev: any directory
+	// whose device differs from the root's is skipped, including datasets
+	// of the same ZFS pool and btrfs subvolumes.
+	OneFilesystem bool
+
+	// ExcludeFSTypes lists filesystem types (as in /proc/mounts, e.g. "nfs",
+	// "fuse.sshfs") that are never walked, even with CrossFilesystems.
+	// Kernel pseudo filesystems such as proc and sysfs are always skipped.
+	ExcludeFSTypes []string
+
+	// NohashMarker names a file that opts its directory out of scanning: a
+	// directory containing it is skipped with everything below it, like
+	// CACHEDIR.TAG for backup tools. Empty disables the check.
+	NohashMarker string
+
+	// CacheDirTag skips directories tagged as caches by a CACHEDIR.TAG file
+	// (https://bford.info/cachedir/), e.g. browser caches and build output.
+	CacheDirTag bool
+
+	// OnError, if set, is called (after the warning is logged) for every path
+	// a walk or path list couldn't read, e.g. a directory without permission.
+	OnError func(path string, err error)
+
+	// MinAge skips files modified less than this long ago, such as downloads
+	// still being written; a later scan picks them up. 0 disables the check.
+	MinAge time.Duration
+
+	// TrackPerms fills in FileInfo.Perms with each file's owner and mode.
+	TrackPerms bool
+
+	// recent holds the paths MinAge skipped, so a pre-walk of the same disk
+	// doesn't count them twice.
+	recentMu sync.Mutex
+	recent   map[string]struct{}
+}
+
+// DefaultNohashMarker is the marker file name scans look for by default.
+const DefaultNohashMarker = ".nohash"
+
+// cacheDirSignature is how a valid CACHEDIR.TAG file must begin.
+const cacheDirSignature = "Signature: 8a477f597d28d172789f06886806bc55"
+
+// isCacheDir reports whether dir holds a CACHEDIR.TAG with the standard
+// signature. A file of that name with other contents doesn't count.
+func isCacheDir(dir string) bool {
+	f, err := os.Open(filepath.Join(dir, "CACHEDIR.TAG"))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	buf := make([]byte, len(cacheDirSignature))
+	if _, err := io.ReadFull(f, buf); err != nil {
+		return false
+	}
+	return string(buf) == cacheDirSignature
 }
 
+// procMounts is read at the start of each walk to find mount points.
+var procMounts = "/proc/mounts"
+
 // New creates a new Scanner with optional exclude patterns.
 func New(excludePatterns []string) (*Scanner, error) {
 	var compiled []*regexp.Regexp
@@ -75,16 +277,61 @@ func New(excludePatterns []string) (*Scanner, error) {
 		}
 		compiled = append(compiled, re)
 	}
-	return &Scanner{excludePatterns: compiled}, nil
+	return &Scanner{excludePatterns: compiled, MaxDepth: NoMaxDepth}, nil
+}
+
+// NoMaxDepth is the Scanner.MaxDepth that walks trees to any depth.
+const NoMaxDepth = -1
+
+// MatchExclude returns the first exclude pattern that matches path, or ""
+// if none does. Walk applies it to every file and directory it visits.
+func (s *Scanner) MatchExclude(path string) string {
+	for _, re := range s.excludePatterns {
+		if re.MatchString(path) {
+			return re.String()
+		}
+	}
+	return ""
+}
+
+// ExcludedBy explains why a walk would skip path: it returns the matching
+// pattern and what it matched, either path itself or an ancestor directory
+// the walk would not enter. Like the walk, it goes top-down, so the
+// outermost excluded directory wins. Both are empty if nothing in the path
+// is excluded. Ancestors above a scan root are checked too, though a walk
+// only tests the root and what lies below it.
+func (s *Scanner) ExcludedBy(path string) (pattern, matched string) {
+	path = filepath.Clean(path)
+	chain := []string{path}
+	for dir := filepath.Dir(path); dir != chain[len(chain)-1]; dir = filepath.Dir(dir) {
+		chain = append(chain, dir)
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		if p := s.MatchExclude(chain[i]); p != "" {
+			return p, chain[i]
+		}
+	}
+	return "", ""
 }
 
-// DetectUnraidDisks auto-detects mounted Unraid array disks and cache pools.
+// DetectUnraidDisks auto-detects mounted Unraid array disks and pools, from
+// disks.ini when it exists (see DetectDisks).
 func DetectUnraidDisks() ([]DiskInfo, error) {
+	det, err := DetectDisks()
+	if err != nil {
+		return nil, err
+	}
+	return det.Disks, nil
+}
+
+// detectMntDisks finds mounted array disks, cache pools and the named pools
+// set with SetPoolNames by their directory names under mnt.
+func detectMntDisks(mnt string) ([]DiskInfo, error) {
 	var disks []DiskInfo
 
-	entries, err := os.ReadDir("/mnt")
+	entries, err := os.ReadDir(mnt)
 	if err != nil {
-		return nil, fmt.Errorf("read /mnt: %w", err)
+		return nil, fmt.Errorf("read %s: %w", mnt, err)
 	}
 
 	for _, e := range entries {
@@ -92,9 +339,9 @@ func DetectUnraidDisks() ([]DiskInfo, error) {
 			continue
 		}
 		name := e.Name()
-		path := filepath.Join("/mnt", name)
+		path := filepath.Join(mnt, name)
 
-		if diskPattern.MatchString(name) || cachePattern.MatchString(name) {
+		if isUnraidDisk(name) {
 			// Verify it's actually mounted (has files)
 			subEntries, err := os.ReadDir(path)
 			if err != nil {
@@ -425,7 +672,8 @@ func readRotationalSysfs(dev string) (string, bool) {
 
 // ResolveDisk determines which Unraid disk a path belongs to.
 // For paths like /mnt/disk1/..., it returns "disk1".
-// For paths like /mnt/cache/..., it returns "cache".
+// For paths like /mnt/cache/... or a named pool /mnt/nvme/..., it returns
+// the pool name.
 // For paths like /mnt/user/..., it resolves the symlink to find the actual disk.
 // For other paths, it returns the base of the given root.
 func ResolveDisk(filePath, scanRoot string) string {
@@ -433,7 +681,7 @@ func ResolveDisk(filePath, scanRoot string) string {
 		parts := strings.SplitN(strings.TrimPrefix(filePath, "/mnt/"), "/", 2)
 		if len(parts) >= 1 {
 			name := parts[0]
-			if diskPattern.MatchString(name) || cachePattern.MatchString(name) {
+			if isUnraidDisk(name) {
 				return name
 			}
 		}
@@ -451,6 +699,83 @@ func ResolveDisk(filePath, scanRoot string) string {
 	return filepath.Base(scanRoot)
 }
 
+// ResolveShareFile maps a user share path (/mnt/user/... or /mnt/user0/...)
+// to the file on the array disk or pool that holds it. Symlinks are followed
+// first; otherwise each of disks is probed for the same share-relative path
+// (/mnt/user0 excludes pools, so cache disks are skipped for it). It returns
+// false if path is not a share path or no disk has the file.
+func ResolveShareFile(path string, disks []DiskInfo) (string, bool) {
+	var rel string
+	userOnly := false
+	switch {
+	case strings.HasPrefix(path, "/mnt/user/"):
+		rel = strings.TrimPrefix(path, "/mnt/user/")
+	case strings.HasPrefix(path, "/mnt/user0/"):
+		rel = strings.TrimPrefix(path, "/mnt/user0/")
+		userOnly = true
+	default:
+		return "", false
+	}
+
+	if resolved, err := filepath.EvalSymlinks(path); err == nil && resolved != path {
+		name := ResolveDisk(resolved, resolved)
+		if isUnraidDisk(name) {
+			return resolved, true
+		}
+	}
+
+	for _, d := range disks {
+		if userOnly && isPool(d.Name) {
+			continue
+		}
+		candidate := filepath.Join(d.Path, rel)
+		if fi, err := os.Stat(candidate); err == nil && fi.Mode().IsRegular() {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// DedupeRoots drops scan roots that lie inside (or repeat) another root, so
+// no file is walked and hashed twice in one run. The remaining roots keep
+// their order; dropped maps each removed root's path to the root covering it.
+func DedupeRoots(disks []DiskInfo) (kept []DiskInfo, dropped map[string]string) {
+	dropped = make(map[string]string)
+	for i, d := range disks {
+		covered := ""
+		for j, o := range disks {
+			if i == j {
+				continue
+			}
+			rel, err := filepath.Rel(o.Path, d.Path)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				continue
+			}
+			// Of two identical roots, keep the first
+			if rel != "." || j < i {
+				covered = o.Path
+				break
+			}
+		}
+		if covered != "" {
+			dropped[d.Path] = covered
+			continue
+		}
+		kept = append(kept, d)
+	}
+	return kept, dropped
+}
+
+// depthBelow returns how many path components path is below root
+// (a direct child of root is 1).
+func depthBelow(root, path string) int {
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." {
+		return 0
+	}
+	return strings.Count(rel, string(filepath.Separator)) + 1
+}
+
 // Walk walks a directory tree and sends discovered files to the channel.
 // It skips files matching the exclude patterns.
 // Each file includes its stat info (size, mtime) so callers don't need to re-stat.
@@ -459,8 +784,22 @@ func (s *Scanner) Walk(root string, disk string, files chan<- hasher.FileInfo) e
 }
 
 // WalkContext walks a directory tree with cancellation support.
-// It stops walking and returns when the context is cancelled.
+// It stops walking and returns when the context is cancelled. Other
+// filesystems mounted below root are skipped unless CrossFilesystems is set,
+// and a root on an excluded filesystem type is not walked at all.
 func (s *Scanner) WalkContext(ctx context.Context, root string, disk string, files chan<- hasher.FileInfo) error {
+	mounts := readMounts(procMounts)
+	rootMount, _ := mountOf(mounts, root)
+	if s.excludesFSType(rootMount.fstype) {
+		logx.PathWarnf(root, "skipping %s filesystem\n", rootMount.fstype)
+		return nil
+	}
+	var rootDev uint64
+	haveDev := false
+	if info, err := os.Stat(root); err == nil {
+		rootDev, haveDev = deviceOf(info)
+	}
+
 	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 		// Check for cancellation
 		select {
@@ -471,17 +810,35 @@ func (s *Scanner) WalkContext(ctx context.Context, root string, disk string, fil
 
 		if err != nil {
 			// Log but continue on permission errors, etc.
-			fmt.Fprintf(os.Stderr, "warning: %s: %v\n", path, err)
+			logx.PathWarnf(path, "%v\n", err)
+			s.failed(path, err)
 			return nil
 		}
 
 		// Skip directories (we only hash files)
 		if d.IsDir() {
-			for _, re := range s.excludePatterns {
-				if re.MatchString(path) {
+			if s.MatchExclude(path) != "" {
+				return filepath.SkipDir
+			}
+			if s.MaxDepth >= 0 && path != root && depthBelow(root, path) > s.MaxDepth {
+				return filepath.SkipDir
+			}
+			if haveDev && path != root {
+				if reason := s.crossing(path, d, rootDev, rootMount, mounts); reason != "" {
+					logx.PathWarnf(path, "%s\n", reason)
 					return filepath.SkipDir
 				}
 			}
+			if s.NohashMarker != "" {
+				if _, err := os.Lstat(filepath.Join(path, s.NohashMarker)); err == nil {
+					logx.Verbosef("  skipping %s (has %s)\n", path, s.NohashMarker)
+					return filepath.SkipDir
+				}
+			}
+			if s.CacheDirTag && isCacheDir(path) {
+				logx.Verbosef("  skipping %s (has CACHEDIR.TAG)\n", path)
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
@@ -491,34 +848,32 @@ func (s *Scanner) WalkContext(ctx context.Context, root string, disk string, fil
 		}
 
 		// Check exclude patterns
-		for _, re := range s.excludePatterns {
-			if re.MatchString(path) {
-				return nil
-			}
+		if s.MatchExclude(path) != "" {
+			return nil
 		}
 
 		// Get file info for size and mtime
 		info, err := d.Info()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "warning: stat %s: %v\n", path, err)
+			logx.PathWarnf(path, "stat: %v\n", err)
+			s.failed(path, fmt.Errorf("stat: %w", err))
 			return nil
 		}
 
 		// Skip zero-byte files — there's nothing to hash.
-		// NOTE: If corruption truncates a file to 0 bytes, it will not appear
-		// in the database and won't be flagged as corrupted. This is an
+		// NOTE: Empty files are never added to the database. This is an
 		// intentional trade-off: tracking millions of legitimately empty files
-		// (lock files, markers, etc.) would add noise for little benefit.
+		// (lock files, markers, etc.) would add noise for little benefit. A
+		// tracked file that gets truncated to 0 bytes is still caught: verify
+		// reports it as corrupted.
 		if info.Size() == 0 {
 			return nil
 		}
-
-		files <- hasher.FileInfo{
-			Path:  path,
-			Disk:  disk,
-			Size:  info.Size(),
-			Mtime: info.ModTime().Unix(),
+		if s.tooRecent(path, info) {
+			return nil
 		}
+
+		files <- s.fileInfo(path, disk, info)
 		return nil
 	})
 
@@ -529,3 +884,111 @@ func (s *Scanner) WalkContext(ctx context.Context, root string, disk string, fil
 
 	return err
 }
+
+// WalkList reads newline-separated file paths from r and sends those files
+// to the channel instead of walking a tree. The same filters as Walk apply:
+// excluded, non-regular and zero-byte files are skipped. Paths that can't be
+// stat'ed are reported on stderr and skipped, and a path listed more than
+// once is sent only the first time. Each file's disk comes from ResolveDisk,
+// falling back to the name of its parent directory.
+func (s *Scanner) WalkList(r io.Reader, files chan<- hasher.FileInfo) error {
+	br := bufio.NewReader(r)
+	seen := make(map[string]struct{})
+	for {
+		line, readErr := br.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return fmt.Errorf("read path list: %w", readErr)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line != "" {
+			s.sendListed(line, seen, files)
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
+
+// tooRecent reports whether MinAge skips the file at path, and notes it.
+func (s *Scanner) tooRecent(path string, info os.FileInfo) bool {
+	if s.MinAge <= 0 {
+		return false
+	}
+	age := time.Since(info.ModTime())
+	if age >= s.MinAge {
+		return false
+	}
+	s.recentMu.Lock()
+	if s.recent == nil {
+		s.recent = map[string]struct{}{}
+	}
+	_, seen := s.recent[path]
+	s.recent[path] = struct{}{}
+	s.recentMu.Unlock()
+	if !seen {
+		logx.Verbosef("  skipping %s (modified %s ago)\n", path, age.Round(time.Second))
+	}
+	return true
+}
+
+// SkippedRecent returns how many files MinAge has skipped so far.
+func (s *Scanner) SkippedRecent() int {
+	s.recentMu.Lock()
+	defer s.recentMu.Unlock()
+	return len(s.recent)
+}
+
+// failed reports an unreadable path to OnError.
+func (s *Scanner) failed(path string, err error) {
+	if s.OnError != nil {
+		s.OnError(path, err)
+	}
+}
+
+func (s *Scanner) sendListed(line string, seen map[string]struct{}, files chan<- hasher.FileInfo) {
+	path, err := filepath.Abs(line)
+	if err != nil {
+		logx.PathWarnf(line, "%v\n", err)
+		s.failed(line, err)
+		return
+	}
+	if _, ok := seen[path]; ok {
+		return
+	}
+	seen[path] = struct{}{}
+	info, err := os.Lstat(path)
+	if err != nil {
+		logx.Warnf("%v\n", err)
+		s.failed(path, err)
+		return
+	}
+	if !info.Mode().IsRegular() {
+		logx.PathWarnf(path, "not a regular file, skipping\n")
+		return
+	}
+	if s.MatchExclude(path) != "" {
+		return
+	}
+	if info.Size() == 0 {
+		return
+	}
+	if s.tooRecent(path, info) {
+		return
+	}
+	files <- s.fileInfo(path, ResolveDisk(path, filepath.Dir(path)), info)
+}
+
+// fileInfo describes the file at path for the hasher.
+func (s *Scanner) fileInfo(path, disk string, info os.FileInfo) hasher.FileInfo {
+	fi := hasher.FileInfo{
+		Path:      path,
+		Disk:      disk,
+		Size:      info.Size(),
+		Mtime:     info.ModTime().Unix(),
+		MtimeNsec: int64(info.ModTime().Nanosecond()),
+	}
+	if s.TrackPerms {
+		fi.Perms = hasher.PermsOf(info)
+	}
+	return fi
+}
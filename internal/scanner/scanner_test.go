@@ -3,7 +3,9 @@ package scanner
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/maisi/unraid-filehasher/internal/hasher"
 )
@@ -67,6 +69,36 @@ func TestDiskTypeDefaultWorkers(t *testing.T) {
 	}
 }
 
+func TestWorkersByDisk(t *testing.T) {
+	got := BuiltinWorkers.ByDisk([]DiskInfo{
+		{Name: "disk1", Type: DiskTypeHDD},
+		{Name: "cache", Type: DiskTypeSSD},
+	})
+	if len(got) != 2 || got["disk1"] != 1 || got["cache"] != 4 {
+		t.Errorf("ByDisk = %v, want disk1:1 cache:4", got)
+	}
+}
+
+func TestWorkerDefaults(t *testing.T) {
+	w := WorkerDefaults{HDD: 2, SSD: 8, Unknown: 3}
+	if w.For(DiskTypeHDD) != 2 || w.For(DiskTypeSSD) != 8 || w.For(DiskTypeUnknown) != 3 {
+		t.Errorf("For = %d/%d/%d, want 2/8/3", w.For(DiskTypeHDD), w.For(DiskTypeSSD), w.For(DiskTypeUnknown))
+	}
+	byDisk := w.ByDisk([]DiskInfo{{Name: "disk1", Type: DiskTypeHDD}, {Name: "disk2"}})
+	if byDisk["disk1"] != 2 || byDisk["disk2"] != 3 {
+		t.Errorf("ByDisk = %v, want disk1:2 disk2:3", byDisk)
+	}
+	byType := w.ByType(map[string]string{"disk1": "HDD", "cache": "SSD", "disk9": "unknown"})
+	if byType["disk1"] != 2 || byType["cache"] != 8 || byType["disk9"] != 3 {
+		t.Errorf("ByType = %v, want disk1:2 cache:8 disk9:3", byType)
+	}
+	for _, dt := range []DiskType{DiskTypeHDD, DiskTypeSSD, DiskTypeUnknown} {
+		if got := ParseDiskType(dt.String()); got != dt {
+			t.Errorf("ParseDiskType(%q) = %v, want %v", dt.String(), got, dt)
+		}
+	}
+}
+
 func TestNewScanner(t *testing.T) {
 	// Valid patterns
 	sc, err := New([]string{`\.tmp$`, `^/mnt/disk1/Trash`})
@@ -240,3 +272,594 @@ func TestWalkExcludeDirectory(t *testing.T) {
 		t.Errorf("got %d files, want 1", len(results))
 	}
 }
+
+func TestWalkNohashMarker(t *testing.T) {
+	dir := t.TempDir()
+	for path, data := range map[string]string{
+		"root.txt":               "root",
+		"keep/a.txt":             "a",
+		"cache/.nohash":          "",
+		"cache/b.txt":            "b",
+		"cache/deep/c.txt":       "c",
+		"keep/sub/.nohash":       "",
+		"keep/sub/d.txt":         "d",
+		"keep/other/.nohash.bak": "x",
+	} {
+		p := filepath.Join(dir, path)
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	walk := func(marker string) []string {
+		sc, _ := New(nil)
+		sc.NohashMarker = marker
+		ch := make(chan hasher.FileInfo, 20)
+		if err := sc.Walk(dir, "disk1", ch); err != nil {
+			t.Fatalf("Walk: %v", err)
+		}
+		close(ch)
+		var got []string
+		for fi := range ch {
+			rel, _ := filepath.Rel(dir, fi.Path)
+			got = append(got, rel)
+		}
+		return got
+	}
+
+	if got := strings.Join(walk(DefaultNohashMarker), ","); got != "keep/a.txt,keep/other/.nohash.bak,root.txt" {
+		t.Errorf("with marker: %s", got)
+	}
+	if got := walk(""); len(got) != 6 {
+		t.Errorf("without marker: got %d files %v, want 6", len(got), got)
+	}
+}
+
+func TestWalkCacheDirTag(t *testing.T) {
+	dir := t.TempDir()
+	for path, data := range map[string]string{
+		"keep.txt":               "k",
+		"cache/CACHEDIR.TAG":     cacheDirSignature + "\n# This file is a cache directory tag.\n",
+		"cache/blob":             "b",
+		"fake/CACHEDIR.TAG":      "not a real tag",
+		"fake/data":              "d",
+		"exact/CACHEDIR.TAG":     cacheDirSignature,
+		"exact/sub/deeper/blob2": "b",
+	} {
+		p := filepath.Join(dir, path)
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	walk := func(tag bool) []string {
+		sc, _ := New(nil)
+		sc.CacheDirTag = tag
+		ch := make(chan hasher.FileInfo, 20)
+		if err := sc.Walk(dir, "disk1", ch); err != nil {
+			t.Fatalf("Walk: %v", err)
+		}
+		close(ch)
+		var got []string
+		for fi := range ch {
+			rel, _ := filepath.Rel(dir, fi.Path)
+			got = append(got, rel)
+		}
+		return got
+	}
+
+	if got := strings.Join(walk(true), ","); got != "fake/CACHEDIR.TAG,fake/data,keep.txt" {
+		t.Errorf("with CacheDirTag: %s", got)
+	}
+	if got := walk(false); len(got) != 7 {
+		t.Errorf("without CacheDirTag: got %d files %v, want 7", len(got), got)
+	}
+}
+
+func TestExcludedBy(t *testing.T) {
+	sc, err := New([]string{`\.tmp$`, `(^|/)(appdata)(/|$)`})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	tests := []struct {
+		path, pattern, matched string
+	}{
+		{"/mnt/disk1/foo.tmp", `\.tmp$`, "/mnt/disk1/foo.tmp"},
+		{"/mnt/cache/appdata/plex/db.sqlite", `(^|/)(appdata)(/|$)`, "/mnt/cache/appdata"},
+		{"/mnt/cache/appdata/", `(^|/)(appdata)(/|$)`, "/mnt/cache/appdata"},
+		{"/mnt/disk1/Movies/a.mkv", "", ""},
+	}
+	for _, tt := range tests {
+		pattern, matched := sc.ExcludedBy(tt.path)
+		if pattern != tt.pattern || matched != tt.matched {
+			t.Errorf("ExcludedBy(%q) = %q, %q; want %q, %q", tt.path, pattern, matched, tt.pattern, tt.matched)
+		}
+	}
+}
+
+func TestWalkMaxDepth(t *testing.T) {
+	dir := t.TempDir()
+	deep := filepath.Join(dir, "a", "b")
+	if err := os.MkdirAll(deep, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, p := range []string{
+		filepath.Join(dir, "top.txt"),
+		filepath.Join(dir, "a", "mid.txt"),
+		filepath.Join(deep, "deep.txt"),
+	} {
+		if err := os.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatalf("write %s: %v", p, err)
+		}
+	}
+
+	for _, tt := range []struct {
+		maxDepth int
+		want     int
+	}{
+		{NoMaxDepth, 3},
+		{0, 1},
+		{1, 2},
+		{2, 3},
+	} {
+		sc, _ := New(nil)
+		sc.MaxDepth = tt.maxDepth
+
+		ch := make(chan hasher.FileInfo, 10)
+		go func() {
+			defer close(ch)
+			if err := sc.Walk(dir, "testdisk", ch); err != nil {
+				t.Errorf("Walk: %v", err)
+			}
+		}()
+		var got int
+		for range ch {
+			got++
+		}
+		if got != tt.want {
+			t.Errorf("MaxDepth=%d: got %d files, want %d", tt.maxDepth, got, tt.want)
+		}
+	}
+}
+
+func TestWalkMinAge(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.mkv")
+	fresh := filepath.Join(dir, "fresh.mkv.part")
+	for _, p := range []string{old, fresh} {
+		if err := os.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatalf("write %s: %v", p, err)
+		}
+	}
+	hourAgo := time.Now().Add(-time.Hour)
+	os.Chtimes(old, hourAgo, hourAgo)
+
+	sc, _ := New(nil)
+	sc.MinAge = time.Minute
+	walk := func() []string {
+		ch := make(chan hasher.FileInfo, 10)
+		go func() {
+			defer close(ch)
+			if err := sc.Walk(dir, "testdisk", ch); err != nil {
+				t.Errorf("Walk: %v", err)
+			}
+		}()
+		var got []string
+		for fi := range ch {
+			got = append(got, filepath.Base(fi.Path))
+		}
+		return got
+	}
+
+	if got := walk(); len(got) != 1 || got[0] != "old.mkv" {
+		t.Errorf("Walk with MinAge = %v, want only old.mkv", got)
+	}
+	// A second walk of the same tree (as a pre-walk does) counts it once
+	walk()
+	if n := sc.SkippedRecent(); n != 1 {
+		t.Errorf("SkippedRecent = %d, want 1", n)
+	}
+
+	sc.MinAge = 0
+	if got := walk(); len(got) != 2 {
+		t.Errorf("Walk without MinAge = %v, want both files", got)
+	}
+}
+
+func TestWalkTrackPerms(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("x"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	os.Chmod(path, 0640) // regardless of umask
+
+	sc, _ := New(nil)
+	walk := func() hasher.FileInfo {
+		ch := make(chan hasher.FileInfo, 10)
+		go func() {
+			defer close(ch)
+			sc.Walk(dir, "testdisk", ch)
+		}()
+		var fi hasher.FileInfo
+		for fi = range ch {
+		}
+		return fi
+	}
+
+	if fi := walk(); fi.Perms != nil {
+		t.Errorf("Perms without TrackPerms = %+v", fi.Perms)
+	}
+	sc.TrackPerms = true
+	fi := walk()
+	want := hasher.Perms{UID: uint32(os.Getuid()), GID: uint32(os.Getgid()), Mode: 0640}
+	if fi.Perms == nil || *fi.Perms != want {
+		t.Errorf("Perms = %+v, want %+v", fi.Perms, want)
+	}
+}
+
+func TestWalkList(t *testing.T) {
+	dir := t.TempDir()
+	spaced := filepath.Join(dir, "with space.txt")
+	plain := filepath.Join(dir, "plain.txt")
+	excluded := filepath.Join(dir, "skip.tmp")
+	empty := filepath.Join(dir, "empty.txt")
+	for path, content := range map[string]string{spaced: "a", plain: "bb", excluded: "c", empty: ""} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+	link := filepath.Join(dir, "link.txt")
+	if err := os.Symlink(plain, link); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	sc, err := New([]string{`\.tmp$`})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	list := strings.Join([]string{
+		spaced,
+		"",
+		plain + "\r", // CRLF input
+		excluded,
+		empty,
+		link,
+		dir,
+		filepath.Join(dir, "does-not-exist"),
+		filepath.Join(dir, ".", "plain.txt"), // duplicate, spelled differently
+		plain,                                // duplicate, no trailing newline
+	}, "\n")
+
+	ch := make(chan hasher.FileInfo, 20)
+	if err := sc.WalkList(strings.NewReader(list), ch); err != nil {
+		t.Fatalf("WalkList: %v", err)
+	}
+	close(ch)
+
+	var got []string
+	for fi := range ch {
+		got = append(got, fi.Path)
+		if fi.Disk != filepath.Base(dir) {
+			t.Errorf("Disk = %q for %s, want %q", fi.Disk, fi.Path, filepath.Base(dir))
+		}
+		if fi.Size <= 0 || fi.Mtime <= 0 {
+			t.Errorf("%s: size %d, mtime %d; want both set", fi.Path, fi.Size, fi.Mtime)
+		}
+	}
+	want := []string{spaced, plain}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("paths = %q, want %q", got, want)
+	}
+}
+
+func TestResolveShareFile(t *testing.T) {
+	disk1 := t.TempDir()
+	disk2 := t.TempDir()
+	cache := t.TempDir()
+	for _, p := range []string{
+		filepath.Join(disk2, "Movies", "a.mkv"),
+		filepath.Join(cache, "Downloads", "b.iso"),
+	} {
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := os.WriteFile(p, []byte("x"), 0644); err != nil {
+			t.Fatalf("write %s: %v", p, err)
+		}
+	}
+	disks := []DiskInfo{
+		{Name: "cache", Path: cache},
+		{Name: "disk1", Path: disk1},
+		{Name: "disk2", Path: disk2},
+	}
+
+	tests := []struct {
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{"/mnt/user/Movies/a.mkv", filepath.Join(disk2, "Movies", "a.mkv"), true},
+		{"/mnt/user/Downloads/b.iso", filepath.Join(cache, "Downloads", "b.iso"), true},
+		{"/mnt/user0/Downloads/b.iso", "", false}, // user0 excludes pools
+		{"/mnt/user/Movies/nope.mkv", "", false},
+		{"/mnt/disk1/Movies/a.mkv", "", false}, // not a share path
+	}
+	for _, tt := range tests {
+		got, ok := ResolveShareFile(tt.path, disks)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("ResolveShareFile(%q) = %q, %v; want %q, %v", tt.path, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestNamedPools(t *testing.T) {
+	t.Cleanup(func() { SetPoolNames(nil) })
+
+	// Without the pool configured, a file on it is attributed to the root
+	if got := ResolveDisk("/mnt/nvme/appdata/x", "/mnt/nvme/appdata"); got != "appdata" {
+		t.Errorf("unconfigured pool: ResolveDisk = %q, want appdata", got)
+	}
+
+	if err := SetPoolNames([]string{"nvme", " ssdpool "}); err != nil {
+		t.Fatalf("SetPoolNames: %v", err)
+	}
+	tests := []struct {
+		filePath string
+		scanRoot string
+		expected string
+	}{
+		{"/mnt/nvme/appdata/x", "/mnt/nvme/appdata", "nvme"},
+		{"/mnt/nvme/appdata/x", "/mnt/nvme", "nvme"},
+		{"/mnt/ssdpool/vm/disk.img", "/mnt/ssdpool/vm", "ssdpool"},
+		{"/mnt/cache/appdata/x", "/mnt/cache/appdata", "cache"},
+		{"/mnt/other/appdata/x", "/mnt/other/appdata", "appdata"},
+	}
+	for _, tt := range tests {
+		if got := ResolveDisk(tt.filePath, tt.scanRoot); got != tt.expected {
+			t.Errorf("ResolveDisk(%q, %q) = %q, want %q", tt.filePath, tt.scanRoot, got, tt.expected)
+		}
+	}
+
+	// user0 leaves named pools out just like cache
+	nvme := t.TempDir()
+	p := filepath.Join(nvme, "Downloads", "b.iso")
+	os.MkdirAll(filepath.Dir(p), 0755)
+	os.WriteFile(p, []byte("x"), 0644)
+	disks := []DiskInfo{{Name: "nvme", Path: nvme}}
+	if got, ok := ResolveShareFile("/mnt/user/Downloads/b.iso", disks); !ok || got != p {
+		t.Errorf("ResolveShareFile(user) = %q, %v; want %q", got, ok, p)
+	}
+	if got, ok := ResolveShareFile("/mnt/user0/Downloads/b.iso", disks); ok {
+		t.Errorf("ResolveShareFile(user0) = %q, want no match", got)
+	}
+
+	for _, bad := range []string{"user", "user0", "disks", "a/b"} {
+		if err := SetPoolNames([]string{bad}); err == nil {
+			t.Errorf("SetPoolNames(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestLoadPoolNames(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"nvme.cfg", "ssdpool.cfg", "user0.cfg", "notes.txt"} {
+		os.WriteFile(filepath.Join(dir, name), []byte("diskFsType=\"btrfs\"\n"), 0644)
+	}
+	names, err := LoadPoolNames(dir)
+	if err != nil {
+		t.Fatalf("LoadPoolNames: %v", err)
+	}
+	if strings.Join(names, ",") != "nvme,ssdpool" {
+		t.Errorf("LoadPoolNames = %v, want [nvme ssdpool]", names)
+	}
+
+	names, err = LoadPoolNames(filepath.Join(dir, "missing"))
+	if err != nil || len(names) != 0 {
+		t.Errorf("LoadPoolNames(missing) = %v, %v; want none, nil", names, err)
+	}
+}
+
+func TestReadMounts(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "mounts")
+	os.WriteFile(f, []byte(`/dev/md1p1 /mnt/disk1 xfs rw,noatime 0 0
+cache /mnt/cache zfs rw 0 0
+cache/appdata /mnt/cache/appdata zfs rw 0 0
+//nas/share /mnt/remotes/My\040Share cifs rw 0 0
+proc /proc proc rw 0 0
+`), 0644)
+	mounts := readMounts(f)
+
+	if m := mounts["/mnt/remotes/My Share"]; m.fstype != "cifs" {
+		t.Errorf("escaped mount point not decoded: %v", mounts)
+	}
+	if m, ok := mountOf(mounts, "/mnt/cache/appdata/plex/db"); !ok || m.source != "cache/appdata" {
+		t.Errorf("mountOf(appdata file) = %+v, %v", m, ok)
+	}
+	if m, ok := mountOf(mounts, "/mnt/disk1/Movies"); !ok || m.fstype != "xfs" {
+		t.Errorf("mountOf(disk1 dir) = %+v, %v", m, ok)
+	}
+	if _, ok := mountOf(mounts, "/home/x"); ok {
+		t.Error("mountOf(/home/x) found a mount, want none")
+	}
+
+	if !sameZFSPool(mounts["/mnt/cache"], mounts["/mnt/cache/appdata"]) {
+		t.Error("dataset of the root's pool not recognized")
+	}
+	if sameZFSPool(mounts["/mnt/disk1"], mounts["/mnt/cache/appdata"]) {
+		t.Error("xfs root treated as the same ZFS pool")
+	}
+
+	s := &Scanner{ExcludeFSTypes: []string{"cifs"}}
+	for fstype, want := range map[string]bool{"proc": true, "cifs": true, "xfs": false, "zfs": false} {
+		if got := s.excludesFSType(fstype); got != want {
+			t.Errorf("excludesFSType(%q) = %v, want %v", fstype, got, want)
+		}
+	}
+}
+
+func TestWalkExcludedFSType(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "a.txt"), []byte("x"), 0644)
+
+	f := filepath.Join(t.TempDir(), "mounts")
+	os.WriteFile(f, []byte("nas:/export "+root+" nfs4 rw 0 0\n"), 0644)
+	old := procMounts
+	procMounts = f
+	t.Cleanup(func() { procMounts = old })
+
+	walk := func(s *Scanner) int {
+		files := make(chan hasher.FileInfo, 10)
+		if err := s.Walk(root, "nfs", files); err != nil {
+			t.Fatalf("Walk: %v", err)
+		}
+		close(files)
+		return len(files)
+	}
+	if n := walk(&Scanner{}); n != 1 {
+		t.Errorf("walk of nfs root found %d files, want 1", n)
+	}
+	if n := walk(&Scanner{OneFilesystem: true}); n != 1 {
+		t.Errorf("walk with OneFilesystem found %d files, want 1", n)
+	}
+	if n := walk(&Scanner{ExcludeFSTypes: []string{"nfs4"}}); n != 0 {
+		t.Errorf("walk with nfs4 excluded found %d files, want 0", n)
+	}
+}
+
+func TestDedupeRoots(t *testing.T) {
+	kept, dropped := DedupeRoots([]DiskInfo{
+		{Name: "movies", Path: "/mnt/disk1/movies"},
+		{Name: "disk1", Path: "/mnt/disk1"},
+		{Name: "disk10", Path: "/mnt/disk10"},
+		{Name: "disk2", Path: "/mnt/disk2"},
+		{Name: "disk2", Path: "/mnt/disk2/"},
+	})
+	var names []string
+	for _, d := range kept {
+		names = append(names, d.Path)
+	}
+	if got := strings.Join(names, ","); got != "/mnt/disk1,/mnt/disk10,/mnt/disk2" {
+		t.Errorf("kept = %s, want /mnt/disk1,/mnt/disk10,/mnt/disk2", got)
+	}
+	if len(dropped) != 2 || dropped["/mnt/disk1/movies"] != "/mnt/disk1" || dropped["/mnt/disk2/"] != "/mnt/disk2" {
+		t.Errorf("dropped = %v", dropped)
+	}
+}
+
+func TestDetectDisksFromIni(t *testing.T) {
+	dir := t.TempDir()
+	mnt := filepath.Join(dir, "mnt")
+	for _, d := range []string{"disk1", "disk2", "cache", "disk3", "user", "disks/UD"} {
+		os.MkdirAll(filepath.Join(mnt, d), 0755)
+	}
+	// disk3's directory exists but is empty: the mount point of a stopped disk
+	for _, d := range []string{"disk1", "disk2", "cache", "user", "disks/UD"} {
+		os.WriteFile(filepath.Join(mnt, d, "f"), []byte("x"), 0644)
+	}
+	ini := filepath.Join(dir, "disks.ini")
+	os.WriteFile(ini, []byte(`["parity"]
+idx="0"
+name="parity"
+device="sdb"
+type="Parity"
+status="DISK_OK"
+rotational="1"
+fsStatus="-"
+["disk1"]
+idx="1"
+name="disk1"
+device="sdc"
+id="WDC_WD80EFZZ_ABC123"
+type="Data"
+status="DISK_OK"
+rotational="1"
+fsStatus="Mounted"
+["disk2"]
+idx="2"
+name="disk2"
+device="sdd"
+type="Data"
+status="DISK_OK"
+rotational="0"
+fsStatus="Mounted"
+["disk3"]
+idx="3"
+name="disk3"
+device="sde"
+type="Data"
+status="DISK_OK"
+rotational="1"
+fsStatus="Unmountable: No file system"
+["disk4"]
+idx="4"
+name="disk4"
+device=""
+type="Data"
+status="DISK_NP"
+["cache"]
+idx="30"
+name="cache"
+device="nvme0n1"
+type="Cache"
+status="DISK_OK"
+rotational="0"
+fsStatus="Mounted"
+["cache2"]
+idx="31"
+name="cache2"
+device="nvme1n1"
+type="Cache"
+status="DISK_OK"
+rotational="0"
+fsStatus="-"
+["flash"]
+name="flash"
+device="sda"
+type="Flash"
+fsStatus="Mounted"
+`), 0644)
+
+	oldIni, oldMnt := disksIni, mntRoot
+	disksIni, mntRoot = ini, mnt
+	t.Cleanup(func() { disksIni, mntRoot = oldIni, oldMnt })
+
+	det, err := DetectDisks()
+	if err != nil {
+		t.Fatalf("DetectDisks: %v", err)
+	}
+	if det.Source != ini {
+		t.Errorf("Source = %q, want %q", det.Source, ini)
+	}
+	var names []string
+	for _, d := range det.Disks {
+		names = append(names, d.Name)
+	}
+	if strings.Join(names, ",") != "cache,disk1,disk2" {
+		t.Fatalf("Disks = %v, want cache, disk1 and disk2 only", names)
+	}
+	if d := det.Disks[1]; d.Path != filepath.Join(mnt, "disk1") || d.Device != "sdc" || d.ID != "WDC_WD80EFZZ_ABC123" || d.Type != DiskTypeHDD {
+		t.Errorf("disk1 = %+v", d)
+	}
+	if det.Disks[2].Type != DiskTypeSSD || det.Disks[0].Type != DiskTypeSSD {
+		t.Errorf("rotational=0 not SSD: %+v", det.Disks)
+	}
+	if len(det.Unmounted) != 1 || det.Unmounted[0].Name != "disk3" || !strings.HasPrefix(det.Unmounted[0].Reason, "Unmountable") {
+		t.Errorf("Unmounted = %+v, want disk3 only", det.Unmounted)
+	}
+
+	// Without disks.ini the /mnt directories are used
+	disksIni = filepath.Join(dir, "missing.ini")
+	det, err = DetectDisks()
+	if err != nil {
+		t.Fatalf("DetectDisks without ini: %v", err)
+	}
+	names = nil
+	for _, d := range det.Disks {
+		names = append(names, d.Name)
+	}
+	if det.Source != mnt || strings.Join(names, ",") != "cache,disk1,disk2" {
+		t.Errorf("fallback = %v from %q, want cache, disk1 and disk2 from %q", names, det.Source, mnt)
+	}
+}
@@ -2,23 +2,48 @@ package verifier
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/maisi/unraid-filehasher/internal/db"
 	"github.com/maisi/unraid-filehasher/internal/hasher"
+	"github.com/maisi/unraid-filehasher/internal/logx"
+	"github.com/maisi/unraid-filehasher/internal/xattr"
 )
 
 // VerifyResult represents the outcome of verifying a single file.
 type VerifyResult struct {
 	Path    string
-	Status  string // ok, corrupted, missing
+	Disk    string // the disk the file is tracked on
+	Status  string // ok, corrupted, changed, error, acknowledged, missing
 	OldHash string
 	NewHash string
+	Size    int64 // bytes hashed for this file; 0 if it wasn't read
 	Err     error
+
+	// Truncated is set when a file tracked with a non-zero size is now empty;
+	// SizeChanged when FastSizeCheck caught any other size difference. Both
+	// are reported without being hashed: corrupted, or changed when the
+	// resized file's mtime moved past the recorded one. OldSize is the size
+	// on record and NewSize the size found on disk.
+	Truncated   bool
+	SizeChanged bool
+	OldSize     int64
+	NewSize     int64
+
+	// XattrHash is the checksum found in the Verifier.Xattr attribute of a
+	// hashed file, empty if it has none. XattrMismatch is set when it
+	// differs from the hash just computed. With Verifier.Shatag, XattrStale
+	// is set instead when the checksum's timestamp isn't the file's mtime.
+	XattrHash     string
+	XattrMismatch bool
+	XattrStale    bool
 }
 
 // Summary holds aggregated verification results.
@@ -26,12 +51,130 @@ type Summary struct {
 	TotalChecked int
 	OK           int
 	Corrupted    int
+	Changed      int // mismatches on files modified since they were hashed
+	Unreadable   int // files that could not be read (permissions, I/O errors); status 'error'
 	Missing      int
 	Skipped      int
 	Errors       int
+	BytesHashed  int64 // bytes read and hashed successfully, for throughput
 	Duration     time.Duration
+	SampledFrom  int      // total tracked files the sample was drawn from (0 when not sampling)
+	AbortedDisks []string // disks skipped by safe mode because nearly every file read as corrupted
+	// DisksLikelyOffline are disks where nearly every checked file was
+	// missing, as when a disk drops out of the array; their files' statuses
+	// are left unchanged and not reported one by one
+	DisksLikelyOffline []string
+	TimeBounded        bool // true if MaxDuration elapsed before every file was checked
+	Remaining          int  // files not reached before the time budget ran out
+	// PermsChanged lists files whose owner or mode differs from the one
+	// scan --track-perms recorded (CheckPerms only), sorted by path
+	PermsChanged []PermsChange
+	// XattrMismatches counts hashed files whose Verifier.Xattr checksum
+	// differs from their contents
+	XattrMismatches int
+	XattrStale      int // Shatag only: checksums older than the file's mtime
+	// StatusUpdates counts files whose catalog status changed; the rest
+	// only had last_verified refreshed, unless Verifier.TouchVerified is off
+	StatusUpdates int
+}
+
+// PermsChange is a file whose owner or mode changed since it was recorded.
+type PermsChange struct {
+	Path string
+	Old  db.Perms
+	New  db.Perms
+}
+
+// Safe-mode defaults: a disk where more than 90% of at least 50 checked files
+// fail verification is treated as offline/unreadable rather than corrupted.
+const (
+	DefaultSafeModeThreshold = 0.9
+	DefaultSafeModeMinSample = 50
+)
+
+// DefaultOfflineThreshold is the share of a disk's checked files (at least
+// SafeModeMinSample of them) that must be missing for the disk to be
+// reported as likely offline instead of file by file.
+const DefaultOfflineThreshold = 0.9
+
+// DefaultBatchSize is how many catalog updates are committed together, so a
+// crash late in a long verify keeps what was recorded before it.
+const DefaultBatchSize = 1000
+
+// flushEvery commits a batch that's been open this long, however small.
+const flushEvery = 2 * time.Second
+
+// diskHealth tracks per-disk verification outcomes for safe mode.
+// Corrupted results are held back until the run finishes so that a disk
+// which turns out to be unreadable never has its catalog entries rewritten.
+type diskHealth struct {
+	checked   int
+	corrupted int
+	missing   int
+	pending   []held
+	aborted   atomic.Bool
+}
+
+// held is a result applied only once the run is over, with the record it
+// belongs to. Only files with problems are held, so the records of a healthy
+// catalog are never all in memory at once.
+type held struct {
+	vr     VerifyResult
+	stored *db.FileRecord
+}
+
+// fileSource hands verifyFiles the records to check, one disk at a time.
+type fileSource struct {
+	total int
+	disks []string
+	each  func(disk string, fn func(*db.FileRecord) error) error
+}
+
+// sliceSource serves records already loaded, in their given order.
+func sliceSource(files []*db.FileRecord) fileSource {
+	byDisk := make(map[string][]*db.FileRecord)
+	var disks []string
+	for _, f := range files {
+		if _, ok := byDisk[f.Disk]; !ok {
+			disks = append(disks, f.Disk)
+		}
+		byDisk[f.Disk] = append(byDisk[f.Disk], f)
+	}
+	return fileSource{
+		total: len(files),
+		disks: disks,
+		each: func(disk string, fn func(*db.FileRecord) error) error {
+			for _, f := range byDisk[disk] {
+				if err := fn(f); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// catalogSource streams the records of disk (every disk when empty) from
+// the catalog as they are verified, so memory use doesn't grow with it.
+func (v *Verifier) catalogSource(disk string) (fileSource, error) {
+	total, err := v.db.CountFiles(disk)
+	if err != nil {
+		return fileSource{}, fmt.Errorf("count files: %w", err)
+	}
+	disks := []string{disk}
+	if disk == "" {
+		if disks, err = v.db.GetTrackedDisks(); err != nil {
+			return fileSource{}, fmt.Errorf("get disks: %w", err)
+		}
+	} else if total == 0 {
+		disks = nil
+	}
+	return fileSource{total: int(total), disks: disks, each: v.db.IterateFiles}, nil
 }
 
+// errStopFeed ends a feeder's iteration early.
+var errStopFeed = errors.New("stop feeding")
+
 // Verifier checks files against their stored hashes.
 type Verifier struct {
 	db               *db.DB
@@ -39,6 +182,66 @@ type Verifier struct {
 	quick            bool                                // only check files with changed mtime/size
 	PauseFunc        func(context.Context) error         // optional: called before feeding each file (e.g. DnD pause)
 	ThermalPauseFunc func(context.Context, string) error // optional: per-disk thermal pause; receives (ctx, diskName)
+
+	// Safe mode: if more than SafeModeThreshold of a disk's files (after at
+	// least SafeModeMinSample checks) come back corrupted, stop verifying that
+	// disk and leave its statuses untouched. A threshold of 0 disables it.
+	SafeModeThreshold float64
+	SafeModeMinSample int
+
+	// OfflineThreshold: if at least this share of a disk's checked files
+	// (and at least SafeModeMinSample files) are missing, the disk is listed
+	// in Summary.DisksLikelyOffline instead of having every file marked
+	// missing. 0 disables it.
+	OfflineThreshold float64
+
+	// MaxDuration, if set, bounds how long files are fed to the hasher. Files
+	// are then loaded least-recently-verified first; once the budget is spent
+	// in-flight hashes finish and their results are committed.
+	MaxDuration time.Duration
+
+	// PathBase resolves relative catalog paths (--path-mode relative) to
+	// files on disk. Absolute catalog paths ignore it.
+	PathBase string
+
+	// FastSizeCheck reports a file whose size differs from the stored size
+	// straight away instead of hashing it to find out: changed if its mtime
+	// moved since it was hashed, corrupted if not.
+	FastSizeCheck bool
+
+	// ReadRetries is passed to the hasher: how often a transient read
+	// error is retried before the file is reported as unreadable.
+	ReadRetries int
+
+	// DiskWorkers sets the hash workers of individual disks' pipelines (see
+	// scanner.WorkerDefaults.ByDisk); disks not listed get the workers passed
+	// to New.
+	DiskWorkers map[string]int
+
+	// CheckPerms compares the owner and mode of files with a recorded one
+	// (scan --track-perms) and lists differences in Summary.PermsChanged.
+	// Statuses aren't affected.
+	CheckPerms bool
+
+	// Xattr names an extended attribute holding a checksum (scan --xattr).
+	// It is read for every file hashed whole and compared with the computed
+	// hash, as a second opinion next to the catalog's; statuses still follow
+	// the catalog.
+	Xattr string
+
+	// Shatag reads the checksum from cshatag's attributes instead of Xattr,
+	// and like cshatag only counts a checksum stamped with the file's
+	// current mtime as a mismatch; an older one is merely stale, left behind
+	// by a modification.
+	Shatag bool
+
+	// BatchSize is how many status updates are committed per transaction.
+	BatchSize int
+
+	// TouchVerified refreshes last_verified for files whose status doesn't
+	// change. Turned off, a verify that finds everything as it was writes
+	// nothing at all, but last_verified then only moves on a change.
+	TouchVerified bool
 }
 
 // New creates a new Verifier.
@@ -47,10 +250,34 @@ func New(database *db.DB, workers int, quick bool) *Verifier {
 		workers = 4
 	}
 	return &Verifier{
-		db:      database,
-		workers: workers,
-		quick:   quick,
+		db:                database,
+		workers:           workers,
+		quick:             quick,
+		SafeModeThreshold: DefaultSafeModeThreshold,
+		SafeModeMinSample: DefaultSafeModeMinSample,
+		OfflineThreshold:  DefaultOfflineThreshold,
+		PathBase:          db.DefaultPathBase,
+		ReadRetries:       hasher.DefaultReadRetries,
+		BatchSize:         DefaultBatchSize,
+		TouchVerified:     true,
+	}
+}
+
+// workersFor returns the worker count of disk's hashing pipeline.
+func (v *Verifier) workersFor(disk string) int {
+	if n := v.DiskWorkers[disk]; n > 0 {
+		return n
+	}
+	return v.workers
+}
+
+// tripped reports whether the disk's corruption rate is high enough that it
+// should be treated as offline/unreadable.
+func (v *Verifier) tripped(dh *diskHealth) bool {
+	if v.SafeModeThreshold <= 0 || dh.checked == 0 || dh.checked < v.SafeModeMinSample {
+		return false
 	}
+	return float64(dh.corrupted)/float64(dh.checked) > v.SafeModeThreshold
 }
 
 // VerifyAll verifies all tracked files and returns a summary.
@@ -60,24 +287,77 @@ func (v *Verifier) VerifyAll(resultCb func(VerifyResult), progressCb func(done,
 
 // VerifyAllContext verifies all tracked files with cancellation support.
 func (v *Verifier) VerifyAllContext(ctx context.Context, resultCb func(VerifyResult), progressCb func(done, total int)) (*Summary, error) {
-	files, err := v.db.GetAllFiles()
+	if v.MaxDuration > 0 {
+		files, err := v.db.GetFilesByLastVerified("")
+		if err != nil {
+			return nil, fmt.Errorf("get files: %w", err)
+		}
+		return v.verifyFiles(ctx, sliceSource(files), resultCb, progressCb)
+	}
+	src, err := v.catalogSource("")
 	if err != nil {
-		return nil, fmt.Errorf("get files: %w", err)
+		return nil, err
 	}
-	return v.verifyFiles(ctx, files, resultCb, progressCb)
+	return v.verifyFiles(ctx, src, resultCb, progressCb)
 }
 
 // VerifyDisk verifies all tracked files on a specific disk.
 func (v *Verifier) VerifyDisk(disk string, resultCb func(VerifyResult), progressCb func(done, total int)) (*Summary, error) {
-	files, err := v.db.GetFilesByDisk(disk)
+	return v.VerifyDiskContext(context.Background(), disk, resultCb, progressCb)
+}
+
+// VerifyDiskContext verifies all tracked files on a disk with cancellation support.
+func (v *Verifier) VerifyDiskContext(ctx context.Context, disk string, resultCb func(VerifyResult), progressCb func(done, total int)) (*Summary, error) {
+	if v.MaxDuration > 0 {
+		files, err := v.db.GetFilesByLastVerified(disk)
+		if err != nil {
+			return nil, fmt.Errorf("get files for disk %s: %w", disk, err)
+		}
+		return v.verifyFiles(ctx, sliceSource(files), resultCb, progressCb)
+	}
+	src, err := v.catalogSource(disk)
 	if err != nil {
 		return nil, fmt.Errorf("get files for disk %s: %w", disk, err)
 	}
-	return v.verifyFiles(context.Background(), files, resultCb, progressCb)
+	return v.verifyFiles(ctx, src, resultCb, progressCb)
+}
+
+// VerifyFiles verifies the given records, e.g. a handful of files picked out
+// for a spot check.
+func (v *Verifier) VerifyFiles(files []*db.FileRecord, resultCb func(VerifyResult), progressCb func(done, total int)) (*Summary, error) {
+	return v.verifyFiles(context.Background(), sliceSource(files), resultCb, progressCb)
 }
 
-func (v *Verifier) verifyFiles(ctx context.Context, files []*db.FileRecord, resultCb func(VerifyResult), progressCb func(done, total int)) (*Summary, error) {
-	total := len(files)
+// VerifySample verifies roughly percent% of tracked files (optionally on a
+// single disk), preferring those that were verified least recently.
+func (v *Verifier) VerifySample(disk string, percent float64, resultCb func(VerifyResult), progressCb func(done, total int)) (*Summary, error) {
+	total, err := v.db.CountFiles(disk)
+	if err != nil {
+		return nil, fmt.Errorf("count files: %w", err)
+	}
+	files, err := v.db.GetFilesForSampledVerify(disk, percent)
+	if err != nil {
+		return nil, fmt.Errorf("get sample: %w", err)
+	}
+	summary, err := v.verifyFiles(context.Background(), sliceSource(files), resultCb, progressCb)
+	if summary != nil {
+		summary.SampledFrom = int(total)
+	}
+	return summary, err
+}
+
+// offline reports whether so many of the disk's checked files are missing
+// that the disk itself is most likely gone.
+func (v *Verifier) offline(dh *diskHealth) bool {
+	total := dh.checked + dh.missing
+	if v.OfflineThreshold <= 0 || total == 0 || total < v.SafeModeMinSample {
+		return false
+	}
+	return float64(dh.missing)/float64(total) >= v.OfflineThreshold
+}
+
+func (v *Verifier) verifyFiles(ctx context.Context, src fileSource, resultCb func(VerifyResult), progressCb func(done, total int)) (*Summary, error) {
+	total := src.total
 	var done atomic.Int64
 	updateProgress := func(delta int64) {
 		if progressCb == nil {
@@ -90,89 +370,207 @@ func (v *Verifier) verifyFiles(ctx context.Context, files []*db.FileRecord, resu
 	start := time.Now()
 	summary := &Summary{}
 
-	// Set up the parallel hasher
-	input := make(chan hasher.FileInfo, v.workers*2)
-	output := make(chan hasher.Result, v.workers*2)
-
-	h := hasher.New(v.workers)
-
-	// Build a lookup map from path to stored record
-	storedMap := make(map[string]*db.FileRecord, len(files))
-	for _, f := range files {
-		storedMap[f.Path] = f
+	// Per-disk safe-mode state
+	health := make(map[string]*diskHealth, len(src.disks))
+	for _, disk := range src.disks {
+		health[disk] = &diskHealth{}
 	}
 
-	// Start the hasher in a goroutine
-	go h.HashFilesContext(ctx, input, output)
+	// Records of the files handed to the hashers, by on-disk path, until
+	// their result comes back; catalog updates go through stored.Path
+	var inflight sync.Map
+
+	// The feeder runs under its own context so a time budget only stops new
+	// files from being queued; files already handed to the hasher still finish
+	// and get committed.
+	feedCtx := ctx
+	if v.MaxDuration > 0 {
+		var cancelFeed context.CancelFunc
+		feedCtx, cancelFeed = context.WithTimeout(ctx, v.MaxDuration)
+		defer cancelFeed()
+	}
+	var fed atomic.Int64 // files the feeder got through (checked, skipped or queued)
 
 	// Track files the feeder determined are missing (avoids double stat later)
-	var missingPaths []string
+	var missingFiles []*db.FileRecord
+	var unhashed []held // size differs from the catalog, or can't be stat'ed; not hashed
+	var permsChanged []PermsChange
+	var feedErr error // reading the catalog failed
 	var missingMu sync.Mutex
 	var skippedCount atomic.Int64
+	var unsupportedCount atomic.Int64
 
-	// Feed files to the hasher
-	go func() {
+	// feed queues one disk's files for its hasher
+	feed := func(disk string, input chan<- hasher.FileInfo) {
 		defer close(input)
-		for _, f := range files {
-			// Check for cancellation
+		err := src.each(disk, func(f *db.FileRecord) error {
+			// Check for cancellation or an exhausted time budget
 			select {
-			case <-ctx.Done():
-				return
+			case <-feedCtx.Done():
+				return errStopFeed
 			default:
 			}
+			fed.Add(1)
+			// Safe mode: stop feeding a disk that already looks unreadable
+			if health[f.Disk].aborted.Load() {
+				updateProgress(1)
+				return nil
+			}
+			// Records hashed with an algorithm this build lacks can't be checked;
+			// leave their status alone rather than reporting them corrupted.
+			if !hasher.Supported(f.Algo) || !hasher.SupportedSecondary(f.SecondaryAlgo) {
+				logx.PathErrorf(f.Path, "unsupported hash algorithm %q/%q, skipping\n", f.Algo, f.SecondaryAlgo)
+				unsupportedCount.Add(1)
+				updateProgress(1)
+				return nil
+			}
+			path := db.AbsolutePath(f.Path, v.PathBase)
 			// Check if file still exists
-			stat, err := os.Stat(f.Path)
+			stat, err := os.Stat(path)
 			if err != nil {
 				if os.IsNotExist(err) {
 					// Track missing files for post-pipeline processing
 					missingMu.Lock()
-					missingPaths = append(missingPaths, f.Path)
+					missingFiles = append(missingFiles, f)
 					missingMu.Unlock()
 					updateProgress(1)
-					continue
+					return nil
 				}
+				// Exists but can't be stat'ed (e.g. permissions): unreadable
+				missingMu.Lock()
+				unhashed = append(unhashed, held{VerifyResult{Path: path, Disk: f.Disk, Status: "error", OldHash: f.SHA256, Err: err}, f})
+				missingMu.Unlock()
 				updateProgress(1)
-				continue
+				return nil
+			}
+
+			if v.CheckPerms && f.Perms != nil {
+				if now := hasher.PermsOf(stat); now != nil && db.Perms(*now) != *f.Perms {
+					missingMu.Lock()
+					permsChanged = append(permsChanged, PermsChange{Path: path, Old: *f.Perms, New: db.Perms(*now)})
+					missingMu.Unlock()
+				}
+			}
+
+			// The scanner ignores empty files, so a tracked file truncated to
+			// 0 bytes would otherwise never be flagged. No need to hash it.
+			truncated := f.Size > 0 && stat.Size() == 0
+			if truncated || (v.FastSizeCheck && stat.Size() != f.Size) {
+				status := "corrupted"
+				if !truncated && f.Status != "acknowledged" &&
+					db.MtimeAfter(f.Mtime, f.MtimeNsec, stat.ModTime().Unix(), int64(stat.ModTime().Nanosecond())) {
+					status = "changed"
+				}
+				missingMu.Lock()
+				unhashed = append(unhashed, held{VerifyResult{
+					Path:        path,
+					Disk:        f.Disk,
+					Status:      status,
+					OldHash:     f.SHA256,
+					Truncated:   truncated,
+					SizeChanged: !truncated,
+					OldSize:     f.Size,
+					NewSize:     stat.Size(),
+				}, f})
+				missingMu.Unlock()
+				updateProgress(1)
+				return nil
 			}
 
 			// In quick mode, skip files whose mtime and size haven't changed
-			if v.quick && stat.ModTime().Unix() == f.Mtime && stat.Size() == f.Size {
+			if v.quick && stat.Size() == f.Size &&
+				db.SameMtime(f.Mtime, f.MtimeNsec, stat.ModTime().Unix(), int64(stat.ModTime().Nanosecond())) {
 				skippedCount.Add(1)
 				updateProgress(1)
-				continue
+				return nil
 			}
 
 			// Pause hook (e.g. DnD window)
 			if v.PauseFunc != nil {
-				if err := v.PauseFunc(ctx); err != nil {
-					return
+				if err := v.PauseFunc(feedCtx); err != nil {
+					fed.Add(-1)
+					return errStopFeed
 				}
 			}
 
 			// Per-disk thermal pause hook
 			if v.ThermalPauseFunc != nil {
-				if err := v.ThermalPauseFunc(ctx, f.Disk); err != nil {
-					return
+				if err := v.ThermalPauseFunc(feedCtx, f.Disk); err != nil {
+					fed.Add(-1)
+					return errStopFeed
 				}
 			}
 
-			input <- hasher.FileInfo{Path: f.Path, Disk: f.Disk}
+			inflight.Store(path, f)
+			input <- hasher.FileInfo{Path: path, Disk: f.Disk, Algo: f.Algo, Secondary: f.SecondaryAlgo, ChunkSize: f.ChunkSize}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errStopFeed) {
+			missingMu.Lock()
+			if feedErr == nil {
+				feedErr = fmt.Errorf("read files of %s: %w", disk, err)
+			}
+			missingMu.Unlock()
 		}
+	}
+
+	// One pipeline per disk, like scan, so a slow disk doesn't hold up the
+	// others' workers. Results from all disks are merged into output and
+	// collected below by this goroutine alone.
+	output := make(chan hasher.Result, v.workers*2)
+	var pipelines sync.WaitGroup
+	for _, disk := range src.disks {
+		workers := v.workersFor(disk)
+		input := make(chan hasher.FileInfo, workers*2)
+		diskOutput := make(chan hasher.Result, workers*2)
+
+		h := hasher.New(workers)
+		h.ReadRetries = v.ReadRetries
+		go h.HashFilesContext(ctx, input, diskOutput)
+		go feed(disk, input)
+
+		pipelines.Add(1)
+		go func() {
+			defer pipelines.Done()
+			for r := range diskOutput {
+				select {
+				case output <- r:
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+	go func() {
+		pipelines.Wait()
+		close(output)
 	}()
 
-	// Begin a transaction for batch updates
-	tx, err := v.db.BeginBatch()
-	if err != nil {
-		return nil, fmt.Errorf("begin transaction: %w", err)
+	// Status updates go through a db.Writer, committed in batches like
+	// scan's. A file whose status stays the same at most has last_verified
+	// refreshed.
+	var writeErrors atomic.Int64
+	w := v.db.NewWriter(v.BatchSize, flushEvery, func(err error) {
+		logx.Warnf("%v\n", err)
+		writeErrors.Add(1)
+	})
+	defer w.Close()
+	setStatus := func(stored *db.FileRecord, status string) {
+		if stored.Status == status {
+			if v.TouchVerified {
+				w.TouchVerified(stored.Path)
+			}
+			return
+		}
+		summary.StatusUpdates++
+		w.SetStatus(stored.Path, status)
 	}
-	defer tx.Rollback() // no-op after commit, prevents resource leak
 
 	// Collect results
 	for result := range output {
-		// Check for cancellation
+		// Check for cancellation; batches already queued are still committed
 		select {
 		case <-ctx.Done():
-			tx.Rollback()
+			w.Close()
 			summary.Duration = time.Since(start)
 			return summary, ctx.Err()
 		default:
@@ -181,80 +579,337 @@ func (v *Verifier) verifyFiles(ctx context.Context, files []*db.FileRecord, resu
 		summary.TotalChecked++
 		updateProgress(1)
 
-		stored := storedMap[result.Path]
-		if stored == nil {
+		r, ok := inflight.LoadAndDelete(result.Path)
+		if !ok {
 			continue
 		}
+		stored := r.(*db.FileRecord)
 
 		var vr VerifyResult
 		vr.Path = result.Path
+		vr.Disk = stored.Disk
 		vr.OldHash = stored.SHA256
+		if result.Err == nil {
+			vr.Size = result.Size
+			summary.BytesHashed += result.Size
+			if (v.Xattr != "" || v.Shatag) && stored.ChunkSize == 0 {
+				v.checkXattr(&vr, result)
+				if vr.XattrMismatch {
+					summary.XattrMismatches++
+				}
+				if vr.XattrStale {
+					summary.XattrStale++
+				}
+			}
+		}
+
+		dh := health[stored.Disk]
+		dh.checked++
 
-		if result.Err != nil {
+		// A stored secondary checksum must match too
+		mismatch := result.SHA256 != stored.SHA256 ||
+			(stored.SecondaryHash != "" && result.Secondary != stored.SecondaryHash)
+
+		// Content differs but the file was modified since it was hashed, or
+		// is being written to right now: most likely an intentional edit
+		// rather than bit rot.
+		if result.Err == nil && mismatch && stored.Status != "acknowledged" &&
+			(result.InFlight || db.MtimeAfter(stored.Mtime, stored.MtimeNsec, result.Mtime, result.MtimeNsec)) {
+			vr.NewHash = result.SHA256
+			vr.Status = "changed"
+			summary.Changed++
+			setStatus(stored, "changed")
+			if resultCb != nil {
+				resultCb(vr)
+			}
+			continue
+		}
+
+		if result.Err != nil || mismatch {
+			// Corrupted or unreadable: defer until we know the disk is
+			// healthy. Both count toward safe mode, since an offline disk
+			// shows up as either.
 			vr.Status = "corrupted"
-			vr.Err = result.Err
-			summary.Errors++
-			summary.Corrupted++
-			if err := v.db.UpdateStatusTx(tx, result.Path, "corrupted"); err != nil {
-				fmt.Fprintf(os.Stderr, "warning: update status for %s: %v\n", result.Path, err)
-				summary.Errors++
+			if result.Err != nil {
+				vr.Status = "error"
 			}
-		} else {
+			vr.Err = result.Err
 			vr.NewHash = result.SHA256
-			if result.SHA256 == stored.SHA256 {
-				vr.Status = "ok"
-				summary.OK++
-				if err := v.db.UpdateStatusTx(tx, result.Path, "ok"); err != nil {
-					fmt.Fprintf(os.Stderr, "warning: update status for %s: %v\n", result.Path, err)
-					summary.Errors++
-				}
-			} else {
-				vr.Status = "corrupted"
-				summary.Corrupted++
-				if err := v.db.UpdateStatusTx(tx, result.Path, "corrupted"); err != nil {
-					fmt.Fprintf(os.Stderr, "warning: update status for %s: %v\n", result.Path, err)
-					summary.Errors++
-				}
+			dh.corrupted++
+			dh.pending = append(dh.pending, held{vr, stored})
+			if v.tripped(dh) {
+				dh.aborted.Store(true)
 			}
+			continue
 		}
 
+		vr.NewHash = result.SHA256
+		vr.Status = "ok"
+		summary.OK++
+		setStatus(stored, "ok")
+
 		if resultCb != nil {
 			resultCb(vr)
 		}
 	}
 
-	// Process missing files identified by the feeder goroutine (no re-stat needed)
+	// Resized and unstat-able files count toward their disk's safe-mode
+	// tally like any other failure (feeder is done, so no lock contention).
+	// Resized files that were edited are reported changed right away.
 	missingMu.Lock()
-	for _, path := range missingPaths {
+	for _, h := range unhashed {
+		summary.TotalChecked++
+		if h.vr.Status == "changed" {
+			summary.Changed++
+			setStatus(h.stored, "changed")
+			if resultCb != nil {
+				resultCb(h.vr)
+			}
+			continue
+		}
+		dh := health[h.stored.Disk]
+		dh.checked++
+		dh.corrupted++
+		dh.pending = append(dh.pending, h)
+	}
+	missingMu.Unlock()
+
+	// Apply deferred corruption results, except on disks safe mode rejected
+	for disk, dh := range health {
+		if dh.aborted.Load() || v.tripped(dh) {
+			summary.AbortedDisks = append(summary.AbortedDisks, disk)
+			continue
+		}
+		for _, h := range dh.pending {
+			vr, stored := h.vr, h.stored
+			// Already reviewed by the user: keep it off the alert list
+			if stored.Status == "acknowledged" {
+				vr.Status = "acknowledged"
+				if resultCb != nil {
+					resultCb(vr)
+				}
+				continue
+			}
+			if vr.Status == "error" {
+				summary.Unreadable++
+			} else {
+				summary.Corrupted++
+			}
+			setStatus(stored, vr.Status)
+			if resultCb != nil {
+				resultCb(vr)
+			}
+		}
+	}
+	sort.Strings(summary.AbortedDisks)
+
+	// Process missing files identified by the feeder goroutine (no re-stat
+	// needed). A disk whose files are nearly all gone is reported once.
+	missingMu.Lock()
+	for _, stored := range missingFiles {
+		health[stored.Disk].missing++
+	}
+	for _, disk := range src.disks {
+		if v.offline(health[disk]) {
+			summary.DisksLikelyOffline = append(summary.DisksLikelyOffline, disk)
+		}
+	}
+	sort.Strings(summary.DisksLikelyOffline)
+	for _, stored := range missingFiles {
 		summary.TotalChecked++
-		summary.Missing++
 		// already counted as done in feeder
-		if err := v.db.UpdateStatusTx(tx, path, "missing"); err != nil {
-			fmt.Fprintf(os.Stderr, "warning: update status for %s: %v\n", path, err)
-			summary.Errors++
+		path := db.AbsolutePath(stored.Path, v.PathBase)
+		if v.offline(health[stored.Disk]) {
+			continue
 		}
+		summary.Missing++
+		setStatus(stored, "missing")
 
 		if resultCb != nil {
-			stored := storedMap[path]
-			oldHash := ""
-			if stored != nil {
-				oldHash = stored.SHA256
-			}
 			resultCb(VerifyResult{
 				Path:    path,
+				Disk:    stored.Disk,
 				Status:  "missing",
-				OldHash: oldHash,
+				OldHash: stored.SHA256,
 			})
 		}
 	}
 	missingMu.Unlock()
 
+	sort.Slice(permsChanged, func(i, j int) bool { return permsChanged[i].Path < permsChanged[j].Path })
+	summary.PermsChanged = permsChanged
+
 	// Assign atomic skipped count to summary (safe: feeder goroutine has finished by now)
 	summary.Skipped = int(skippedCount.Load())
+	summary.Errors += int(unsupportedCount.Load())
 
-	if err := tx.Commit(); err != nil {
+	if v.MaxDuration > 0 && ctx.Err() == nil && feedCtx.Err() != nil {
+		summary.Remaining = total - int(fed.Load())
+		summary.TimeBounded = summary.Remaining > 0
+	}
+
+	if err := w.Close(); err != nil {
 		return nil, fmt.Errorf("commit: %w", err)
 	}
+	summary.Errors += int(writeErrors.Load())
+	if feedErr != nil {
+		summary.Duration = time.Since(start)
+		return summary, feedErr
+	}
+
+	summary.Duration = time.Since(start)
+	return summary, nil
+}
+
+// checkXattr compares the checksum kept in the hashed file's attributes
+// with the hash just computed. Attributes that are missing or can't be read
+// leave vr alone.
+func (v *Verifier) checkXattr(vr *VerifyResult, result hasher.Result) {
+	if v.Shatag {
+		t, err := xattr.GetShatag(result.Path)
+		if err != nil {
+			if !errors.Is(err, xattr.ErrNotSet) {
+				logx.Verbosef("  %v\n", err)
+			}
+			return
+		}
+		vr.XattrHash = t.SHA256
+		vr.XattrStale = !t.Current(result.Mtime, result.MtimeNsec)
+		vr.XattrMismatch = !vr.XattrStale && !strings.EqualFold(t.SHA256, result.SHA256)
+		return
+	}
+	value, err := xattr.Get(result.Path, v.Xattr)
+	if err != nil {
+		if !errors.Is(err, xattr.ErrNotSet) {
+			logx.Verbosef("  %v\n", err)
+		}
+		return
+	}
+	vr.XattrHash = strings.TrimSpace(value)
+	vr.XattrMismatch = vr.XattrHash != "" && !strings.EqualFold(vr.XattrHash, result.SHA256)
+}
+
+// ShareResult is the outcome of checking one user share record against the
+// disk file that backs it.
+type ShareResult struct {
+	SharePath string
+	DiskPath  string // empty if the share path couldn't be resolved
+	Status    string // match, mismatch, unresolved, error
+	ShareHash string
+	DiskHash  string
+	Hashed    bool // DiskHash came from re-hashing because the disk file isn't tracked
+	Err       error
+}
+
+// ShareSummary aggregates a VerifyShares run.
+type ShareSummary struct {
+	Checked    int
+	Matched    int
+	Mismatched int
+	Unresolved int
+	Hashed     int
+	Errors     int
+	Duration   time.Duration
+}
+
+// VerifyShares checks that every tracked /mnt/user and /mnt/user0 record
+// agrees with the disk file behind it. resolve maps a share path to the
+// backing disk path (see scanner.ResolveShareFile). When that disk path is
+// tracked too the two stored hashes are compared directly; only untracked
+// disk files are re-hashed. Catalog statuses are not changed.
+func (v *Verifier) VerifyShares(resolve func(string) (string, bool), resultCb func(ShareResult)) (*ShareSummary, error) {
+	start := time.Now()
+	summary := &ShareSummary{}
+
+	records, err := v.db.GetFilesMatching(nil, []string{"/mnt/user/*", "/mnt/user0/*"}, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("load share records: %w", err)
+	}
+
+	report := func(r ShareResult) {
+		summary.Checked++
+		switch r.Status {
+		case "match":
+			summary.Matched++
+		case "mismatch":
+			summary.Mismatched++
+		case "unresolved":
+			summary.Unresolved++
+		case "error":
+			summary.Errors++
+		}
+		if r.Hashed {
+			summary.Hashed++
+		}
+		if resultCb != nil {
+			resultCb(r)
+		}
+	}
+
+	// DB-to-DB comparisons first; collect disk files that need hashing. A
+	// disk file can back several share records (/mnt/user and /mnt/user0);
+	// it is hashed once and compared with each of them.
+	shareByDisk := make(map[string][]*db.FileRecord)
+	var toHash []hasher.FileInfo
+	for _, rec := range records {
+		diskPath, ok := resolve(rec.Path)
+		if !ok {
+			report(ShareResult{SharePath: rec.Path, Status: "unresolved", ShareHash: rec.SHA256})
+			continue
+		}
+		diskRec, err := v.db.GetFileByPath(diskPath)
+		if err != nil {
+			report(ShareResult{SharePath: rec.Path, DiskPath: diskPath, Status: "error", ShareHash: rec.SHA256, Err: err})
+			continue
+		}
+		if diskRec != nil && diskRec.Algo == rec.Algo {
+			r := ShareResult{SharePath: rec.Path, DiskPath: diskPath, Status: "match", ShareHash: rec.SHA256, DiskHash: diskRec.SHA256}
+			if diskRec.SHA256 != rec.SHA256 {
+				r.Status = "mismatch"
+			}
+			report(r)
+			continue
+		}
+		if _, queued := shareByDisk[diskPath]; !queued {
+			toHash = append(toHash, hasher.FileInfo{Path: diskPath, Disk: rec.Disk, Algo: rec.Algo, ChunkSize: rec.ChunkSize})
+		}
+		shareByDisk[diskPath] = append(shareByDisk[diskPath], rec)
+	}
+
+	if len(toHash) > 0 {
+		input := make(chan hasher.FileInfo, v.workers*4)
+		output := make(chan hasher.Result, v.workers*4)
+		go func() {
+			defer close(input)
+			for _, fi := range toHash {
+				input <- fi
+			}
+		}()
+		h := hasher.New(v.workers)
+		h.ReadRetries = v.ReadRetries
+		go h.HashFiles(input, output)
+
+		for result := range output {
+			recs := shareByDisk[result.Path]
+			for _, rec := range recs {
+				r := ShareResult{SharePath: rec.Path, DiskPath: result.Path, ShareHash: rec.SHA256, DiskHash: result.SHA256, Hashed: true}
+				switch {
+				case result.Err != nil:
+					r.Status = "error"
+					r.Err = result.Err
+				case rec.Algo != recs[0].Algo:
+					// Hashed with the first record's algorithm
+					r.Status = "error"
+					r.Err = fmt.Errorf("hashed with %s like %s, but tracked with %s", recs[0].Algo, recs[0].Path, rec.Algo)
+				case result.SHA256 == rec.SHA256:
+					r.Status = "match"
+				default:
+					r.Status = "mismatch"
+				}
+				report(r)
+			}
+		}
+	}
 
 	summary.Duration = time.Since(start)
 	return summary, nil
@@ -3,20 +3,25 @@ package verifier
 import (
 	"context"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/maisi/unraid-filehasher/internal/db"
+	"github.com/maisi/unraid-filehasher/internal/xattr"
 )
 
 func setupTestDB(t *testing.T) *db.DB {
 	t.Helper()
 	dir := t.TempDir()
-	database, err := db.Open(filepath.Join(dir, "test.db"))
+	database, err := db.Open(filepath.Join(dir, "test.db"), db.Options{})
 	if err != nil {
 		t.Fatalf("Open: %v", err)
 	}
@@ -33,6 +38,23 @@ func writeTestFile(t *testing.T, path string, content []byte) string {
 	return hex.EncodeToString(h[:])
 }
 
+// seedFiles stores records in the catalog in one transaction.
+func seedFiles(t *testing.T, database *db.DB, records ...*db.FileRecord) {
+	t.Helper()
+	tx, err := database.BeginBatch()
+	if err != nil {
+		t.Fatalf("BeginBatch: %v", err)
+	}
+	for _, r := range records {
+		if err := database.UpsertFileTx(tx, r); err != nil {
+			t.Fatalf("UpsertFileTx %s: %v", r.Path, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+}
+
 func TestVerifyAllOK(t *testing.T) {
 	database := setupTestDB(t)
 	dir := t.TempDir()
@@ -86,6 +108,42 @@ func TestVerifyAllOK(t *testing.T) {
 	}
 }
 
+func TestVerifyBytesHashed(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "test.txt")
+	content := []byte("some bytes to hash\n")
+	hash := writeTestFile(t, path, content)
+	stat, _ := os.Stat(path)
+	now := time.Now()
+
+	seedFiles(t, database, &db.FileRecord{
+		Path:         path,
+		Disk:         "disk1",
+		Size:         stat.Size(),
+		Mtime:        stat.ModTime().Unix(),
+		SHA256:       hash,
+		FirstSeen:    now,
+		LastVerified: now,
+		Status:       "ok",
+	})
+
+	var results []VerifyResult
+	summary, err := New(database, 1, false).VerifyAll(func(r VerifyResult) {
+		results = append(results, r)
+	}, nil)
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	if summary.BytesHashed != int64(len(content)) {
+		t.Errorf("BytesHashed = %d, want %d", summary.BytesHashed, len(content))
+	}
+	if len(results) != 1 || results[0].Size != int64(len(content)) {
+		t.Errorf("results = %+v, want one of size %d", results, len(content))
+	}
+}
+
 func TestVerifyCorrupted(t *testing.T) {
 	database := setupTestDB(t)
 	dir := t.TempDir()
@@ -129,6 +187,252 @@ func TestVerifyCorrupted(t *testing.T) {
 	}
 }
 
+func TestVerifyUnreadable(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+
+	// A directory where a file used to be: it exists but can't be read,
+	// which must not be mistaken for corruption
+	path := filepath.Join(dir, "unreadable")
+	if err := os.Mkdir(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	seedFiles(t, database, &db.FileRecord{
+		Path: path, Disk: "disk1", Size: 100, Mtime: now.Unix(),
+		SHA256: "abc", FirstSeen: now, LastVerified: now, Status: "ok",
+	})
+
+	v := New(database, 1, false)
+	var results []VerifyResult
+	summary, err := v.VerifyAll(func(r VerifyResult) {
+		results = append(results, r)
+	}, nil)
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	if summary.Unreadable != 1 || summary.Corrupted != 0 {
+		t.Errorf("Unreadable = %d, Corrupted = %d, want 1, 0", summary.Unreadable, summary.Corrupted)
+	}
+	if len(results) != 1 || results[0].Status != "error" || results[0].Err == nil {
+		t.Errorf("expected 1 error result with its read error, got %+v", results)
+	}
+	rec, _ := database.GetFileByPath(path)
+	if rec.Status != "error" {
+		t.Errorf("status = %q, want error", rec.Status)
+	}
+}
+
+func TestVerifyTruncatedToZero(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "test.txt")
+	content := []byte("original content\n")
+	hash := writeTestFile(t, path, content)
+
+	stat, _ := os.Stat(path)
+	now := time.Now()
+
+	seedFiles(t, database, &db.FileRecord{
+		Path:         path,
+		Disk:         "disk1",
+		Size:         stat.Size(),
+		Mtime:        stat.ModTime().Unix(),
+		SHA256:       hash,
+		FirstSeen:    now,
+		LastVerified: now,
+		Status:       "ok",
+	})
+
+	// Simulate truncation
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+
+	// Quick mode must not skip it either: the size changed
+	for _, quick := range []bool{false, true} {
+		v := New(database, 1, quick)
+		var results []VerifyResult
+		summary, err := v.VerifyAll(func(r VerifyResult) {
+			results = append(results, r)
+		}, nil)
+		if err != nil {
+			t.Fatalf("VerifyAll(quick=%v): %v", quick, err)
+		}
+		if summary.Corrupted != 1 || summary.OK != 0 || summary.Skipped != 0 {
+			t.Errorf("quick=%v: summary = %+v, want 1 corrupted", quick, summary)
+		}
+		if summary.BytesHashed != 0 {
+			t.Errorf("quick=%v: BytesHashed = %d, want 0 (not hashed)", quick, summary.BytesHashed)
+		}
+		if len(results) != 1 || !results[0].Truncated || results[0].OldSize != int64(len(content)) {
+			t.Errorf("quick=%v: results = %+v, want one truncated result", quick, results)
+		}
+	}
+
+	rec, _ := database.GetFileByPath(path)
+	if rec.Status != "corrupted" {
+		t.Errorf("status = %q, want corrupted", rec.Status)
+	}
+}
+
+func TestVerifyFastSizeCheck(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "test.txt")
+	hash := writeTestFile(t, path, []byte("original content\n"))
+	stat, _ := os.Stat(path)
+	now := time.Now()
+
+	seedFiles(t, database, &db.FileRecord{
+		Path:         path,
+		Disk:         "disk1",
+		Size:         stat.Size(),
+		Mtime:        stat.ModTime().Unix(),
+		SHA256:       hash,
+		FirstSeen:    now,
+		LastVerified: now,
+		Status:       "ok",
+	})
+
+	writeTestFile(t, path, []byte("appended: original content\n"))
+	// Keep the mtime so the mismatch reads as corruption, not an edit
+	if err := os.Chtimes(path, stat.ModTime(), stat.ModTime()); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	// Default: hashed, so the mismatch is found by comparing hashes
+	summary, err := New(database, 1, false).VerifyAll(nil, nil)
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	if summary.Corrupted != 1 || summary.BytesHashed == 0 {
+		t.Errorf("default: summary = %+v, want 1 corrupted after hashing", summary)
+	}
+
+	v := New(database, 1, false)
+	v.FastSizeCheck = true
+	var results []VerifyResult
+	summary, err = v.VerifyAll(func(r VerifyResult) {
+		results = append(results, r)
+	}, nil)
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	if summary.Corrupted != 1 || summary.BytesHashed != 0 {
+		t.Errorf("fast: summary = %+v, want 1 corrupted without hashing", summary)
+	}
+	if len(results) != 1 || !results[0].SizeChanged || results[0].Truncated ||
+		results[0].OldSize != stat.Size() || results[0].NewSize != stat.Size()+10 {
+		t.Errorf("fast: results = %+v, want one size-changed result", results)
+	}
+
+	// A resize that moved the mtime is an edit, not corruption
+	later := stat.ModTime().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	results = nil
+	summary, err = v.VerifyAll(func(r VerifyResult) {
+		results = append(results, r)
+	}, nil)
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	if summary.Changed != 1 || summary.Corrupted != 0 || summary.BytesHashed != 0 {
+		t.Errorf("fast, edited: summary = %+v, want 1 changed without hashing", summary)
+	}
+	if len(results) != 1 || results[0].Status != "changed" || !results[0].SizeChanged {
+		t.Errorf("fast, edited: results = %+v, want one changed, size-changed result", results)
+	}
+	if rec, _ := database.GetFileByPath(path); rec == nil || rec.Status != "changed" {
+		t.Errorf("fast, edited: stored record = %+v, want status changed", rec)
+	}
+}
+
+func TestVerifyChangedVsCorrupted(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+	now := time.Now()
+	old := time.Unix(1700000000, 0)
+
+	edited := filepath.Join(dir, "edited.txt")
+	rotted := filepath.Join(dir, "rotted.txt")
+	var records []*db.FileRecord
+	for _, path := range []string{edited, rotted} {
+		hash := writeTestFile(t, path, []byte("original\n"))
+		records = append(records, &db.FileRecord{
+			Path: path, Disk: "disk1", Size: 9, Mtime: old.Unix(),
+			SHA256: hash, FirstSeen: now, LastVerified: now, Status: "ok",
+		})
+		writeTestFile(t, path, []byte("modified\n"))
+	}
+	seedFiles(t, database, records...)
+
+	// The edit bumped the mtime; the rot left it as recorded
+	if err := os.Chtimes(edited, now, now); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if err := os.Chtimes(rotted, old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	statuses := make(map[string]string)
+	summary, err := New(database, 1, false).VerifyAll(func(r VerifyResult) {
+		statuses[r.Path] = r.Status
+	}, nil)
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	if summary.Changed != 1 || summary.Corrupted != 1 {
+		t.Errorf("Changed = %d, Corrupted = %d; want 1, 1", summary.Changed, summary.Corrupted)
+	}
+	if statuses[edited] != "changed" || statuses[rotted] != "corrupted" {
+		t.Errorf("statuses = %v", statuses)
+	}
+
+	stats, _ := database.GetStats(db.StatsOptions{})
+	if stats.ChangedFiles != 1 || stats.CorruptedFiles != 1 {
+		t.Errorf("stats changed = %d, corrupted = %d; want 1, 1", stats.ChangedFiles, stats.CorruptedFiles)
+	}
+}
+
+func TestVerifySecondaryChecksum(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+	now := time.Now()
+
+	good := filepath.Join(dir, "good.txt")
+	bad := filepath.Join(dir, "bad.txt")
+	var records []*db.FileRecord
+	for path, crc := range map[string]string{good: "f0ff7292", bad: "00000000"} {
+		hash := writeTestFile(t, path, []byte("hello world\n"))
+		stat, _ := os.Stat(path)
+		records = append(records, &db.FileRecord{
+			Path: path, Disk: "disk1", Size: stat.Size(), Mtime: stat.ModTime().Unix(),
+			SHA256: hash, SecondaryAlgo: "crc32c", SecondaryHash: crc,
+			FirstSeen: now, LastVerified: now, Status: "ok",
+		})
+	}
+	seedFiles(t, database, records...)
+
+	statuses := make(map[string]string)
+	summary, err := New(database, 1, false).VerifyAll(func(r VerifyResult) {
+		statuses[r.Path] = r.Status
+	}, nil)
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	if summary.OK != 1 || summary.Corrupted != 1 {
+		t.Errorf("OK = %d, Corrupted = %d; want 1, 1", summary.OK, summary.Corrupted)
+	}
+	if statuses[good] != "ok" || statuses[bad] != "corrupted" {
+		t.Errorf("statuses = %v; a secondary mismatch alone must flag corruption", statuses)
+	}
+}
+
 func TestVerifyMissing(t *testing.T) {
 	database := setupTestDB(t)
 
@@ -247,6 +551,108 @@ func TestVerifyDisk(t *testing.T) {
 	}
 }
 
+func TestVerifyFiles(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+
+	path1 := filepath.Join(dir, "suspect.txt")
+	writeTestFile(t, path1, []byte("suspect content\n"))
+	stat1, _ := os.Stat(path1)
+	path2 := filepath.Join(dir, "other.txt")
+	writeTestFile(t, path2, []byte("other content\n"))
+	stat2, _ := os.Stat(path2)
+
+	now := time.Now()
+	seedFiles(t, database,
+		&db.FileRecord{
+			Path: path1, Disk: "disk1", Size: stat1.Size(), Mtime: stat1.ModTime().Unix(),
+			SHA256: "wrong_hash", FirstSeen: now, LastVerified: now, Status: "ok",
+		},
+		&db.FileRecord{
+			Path: path2, Disk: "disk1", Size: stat2.Size(), Mtime: stat2.ModTime().Unix(),
+			SHA256: "also_wrong", FirstSeen: now, LastVerified: now, Status: "ok",
+		},
+	)
+
+	// Only the picked file is checked and gets its status updated
+	rec, _ := database.GetFileByPath(path1)
+	v := New(database, 1, false)
+	summary, err := v.VerifyFiles([]*db.FileRecord{rec}, func(r VerifyResult) {}, nil)
+	if err != nil {
+		t.Fatalf("VerifyFiles: %v", err)
+	}
+	if summary.TotalChecked != 1 || summary.Corrupted != 1 {
+		t.Errorf("TotalChecked = %d, Corrupted = %d, want 1, 1", summary.TotalChecked, summary.Corrupted)
+	}
+	if rec, _ := database.GetFileByPath(path1); rec.Status != "corrupted" {
+		t.Errorf("picked file status = %q, want corrupted", rec.Status)
+	}
+	if rec, _ := database.GetFileByPath(path2); rec.Status != "ok" {
+		t.Errorf("other file status = %q, want ok (not verified)", rec.Status)
+	}
+}
+
+func TestVerifyPerDiskPipelines(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+
+	// Several files on each of three disks, one disk with a corrupted file
+	now := time.Now()
+	var records []*db.FileRecord
+	for _, disk := range []string{"disk1", "disk2", "cache"} {
+		for i := 0; i < 5; i++ {
+			path := filepath.Join(dir, fmt.Sprintf("%s-%d", disk, i))
+			hash := writeTestFile(t, path, []byte(path))
+			if disk == "disk2" && i == 3 {
+				hash = "wrong_hash"
+			}
+			st, _ := os.Stat(path)
+			records = append(records, &db.FileRecord{
+				Path: path, Disk: disk, Size: st.Size(), Mtime: st.ModTime().Unix(),
+				SHA256: hash, FirstSeen: now, LastVerified: now, Status: "ok",
+			})
+		}
+	}
+	seedFiles(t, database, records...)
+
+	v := New(database, 2, false)
+	v.DiskWorkers = map[string]int{"disk1": 1, "cache": 4} // disk2 uses the default 2
+	if got := v.workersFor("disk2"); got != 2 {
+		t.Errorf("workersFor(disk2) = %d, want 2", got)
+	}
+	// Progress comes from several feeders at once; it must still reach 15/15
+	var mu sync.Mutex
+	var maxDone, lastTotal int
+	perDisk := map[string]int{}
+	summary, err := v.VerifyAll(func(r VerifyResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		perDisk[r.Disk]++
+	}, func(done, total int) {
+		mu.Lock()
+		defer mu.Unlock()
+		maxDone = max(maxDone, done)
+		lastTotal = total
+	})
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	if summary.TotalChecked != 15 || summary.OK != 14 || summary.Corrupted != 1 {
+		t.Errorf("TotalChecked/OK/Corrupted = %d/%d/%d, want 15/14/1",
+			summary.TotalChecked, summary.OK, summary.Corrupted)
+	}
+	if maxDone != 15 || lastTotal != 15 {
+		t.Errorf("final progress = %d/%d, want 15/15", maxDone, lastTotal)
+	}
+	// The dashboard's per-disk progress counts results by their Disk
+	if want := map[string]int{"disk1": 5, "disk2": 5, "cache": 5}; fmt.Sprint(perDisk) != fmt.Sprint(want) {
+		t.Errorf("results per disk = %v, want %v", perDisk, want)
+	}
+	if rec, _ := database.GetFileByPath(filepath.Join(dir, "disk2-3")); rec.Status != "corrupted" {
+		t.Errorf("disk2-3 status = %q, want corrupted", rec.Status)
+	}
+}
+
 func TestNewVerifierDefaultWorkers(t *testing.T) {
 	v := New(nil, 0, false)
 	if v.workers != 4 {
@@ -397,3 +803,640 @@ func TestVerifyThermalPauseFuncCancellation(t *testing.T) {
 		t.Errorf("TotalChecked = %d, want 0 (feeder was cancelled)", summary.TotalChecked)
 	}
 }
+
+func TestVerifySample(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+
+	now := time.Now()
+	var records []*db.FileRecord
+	for i := 0; i < 4; i++ {
+		path := filepath.Join(dir, "file"+string(rune('0'+i))+".txt")
+		hash := writeTestFile(t, path, []byte("sample "+string(rune('0'+i))))
+		stat, _ := os.Stat(path)
+		records = append(records, &db.FileRecord{
+			Path: path, Disk: "disk1", Size: stat.Size(), Mtime: stat.ModTime().Unix(),
+			SHA256: hash, FirstSeen: now, LastVerified: now, Status: "ok",
+		})
+	}
+	seedFiles(t, database, records...)
+
+	v := New(database, 1, false)
+	summary, err := v.VerifySample("", 50, func(VerifyResult) {}, nil)
+	if err != nil {
+		t.Fatalf("VerifySample: %v", err)
+	}
+	if summary.TotalChecked != 2 {
+		t.Errorf("TotalChecked = %d, want 2", summary.TotalChecked)
+	}
+	if summary.SampledFrom != 4 {
+		t.Errorf("SampledFrom = %d, want 4", summary.SampledFrom)
+	}
+}
+
+func TestVerifySafeModeAbortsUnreadableDisk(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+
+	now := time.Now()
+	var records []*db.FileRecord
+	// disk1: every stored hash is wrong, as if the disk returned garbage
+	for i := 0; i < 5; i++ {
+		path := filepath.Join(dir, "bad"+string(rune('0'+i))+".txt")
+		writeTestFile(t, path, []byte("bad "+string(rune('0'+i))))
+		stat, _ := os.Stat(path)
+		records = append(records, &db.FileRecord{
+			Path: path, Disk: "disk1", Size: stat.Size(), Mtime: stat.ModTime().Unix(),
+			SHA256: "wrong", FirstSeen: now, LastVerified: now, Status: "ok",
+		})
+	}
+	// disk2: a single genuinely corrupted file
+	path := filepath.Join(dir, "good.txt")
+	writeTestFile(t, path, []byte("good"))
+	stat, _ := os.Stat(path)
+	records = append(records, &db.FileRecord{
+		Path: path, Disk: "disk2", Size: stat.Size(), Mtime: stat.ModTime().Unix(),
+		SHA256: "wrong", FirstSeen: now, LastVerified: now, Status: "ok",
+	})
+	seedFiles(t, database, records...)
+
+	v := New(database, 1, false)
+	v.SafeModeMinSample = 3
+
+	summary, err := v.VerifyAll(func(VerifyResult) {}, nil)
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	if len(summary.AbortedDisks) != 1 || summary.AbortedDisks[0] != "disk1" {
+		t.Errorf("AbortedDisks = %v, want [disk1]", summary.AbortedDisks)
+	}
+	if summary.Corrupted != 1 {
+		t.Errorf("Corrupted = %d, want 1 (only disk2)", summary.Corrupted)
+	}
+
+	corrupted, _ := database.GetFilesByStatus("corrupted")
+	if len(corrupted) != 1 || corrupted[0].Disk != "disk2" {
+		t.Errorf("expected only the disk2 file to be marked corrupted, got %v", corrupted)
+	}
+}
+
+func TestVerifyDiskLikelyOffline(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+	now := time.Now()
+
+	var records []*db.FileRecord
+	// disk1: every file gone, as if the disk dropped out
+	for i := 0; i < 4; i++ {
+		records = append(records, &db.FileRecord{
+			Path: filepath.Join(dir, "gone", fmt.Sprintf("f%d", i)), Disk: "disk1", Size: 1,
+			Mtime: now.Unix(), SHA256: "x", FirstSeen: now, LastVerified: now, Status: "ok",
+		})
+	}
+	// disk2: one file deleted among present ones
+	for i := 0; i < 3; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("ok%d", i))
+		hash := writeTestFile(t, path, []byte("ok"))
+		stat, _ := os.Stat(path)
+		records = append(records, &db.FileRecord{
+			Path: path, Disk: "disk2", Size: stat.Size(), Mtime: stat.ModTime().Unix(),
+			SHA256: hash, FirstSeen: now, LastVerified: now, Status: "ok",
+		})
+	}
+	records = append(records, &db.FileRecord{
+		Path: filepath.Join(dir, "deleted"), Disk: "disk2", Size: 1,
+		Mtime: now.Unix(), SHA256: "x", FirstSeen: now, LastVerified: now, Status: "ok",
+	})
+	seedFiles(t, database, records...)
+
+	v := New(database, 1, false)
+	v.SafeModeMinSample = 3
+
+	var missing []string
+	summary, err := v.VerifyAll(func(r VerifyResult) {
+		if r.Status == "missing" {
+			missing = append(missing, r.Path)
+		}
+	}, nil)
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	if fmt.Sprint(summary.DisksLikelyOffline) != "[disk1]" {
+		t.Errorf("DisksLikelyOffline = %v, want [disk1]", summary.DisksLikelyOffline)
+	}
+	if summary.Missing != 1 || len(missing) != 1 {
+		t.Errorf("Missing = %d, reported %v; want only the disk2 file", summary.Missing, missing)
+	}
+	if summary.OK != 3 {
+		t.Errorf("OK = %d, want 3", summary.OK)
+	}
+
+	// The offline disk's files keep their status
+	stored, _ := database.GetFilesByStatus("missing")
+	if len(stored) != 1 || stored[0].Disk != "disk2" {
+		t.Errorf("expected only the disk2 file to be marked missing, got %v", stored)
+	}
+}
+
+func TestVerifyMaxDuration(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+
+	now := time.Now()
+	var records []*db.FileRecord
+	for i := 0; i < 3; i++ {
+		path := filepath.Join(dir, "file"+string(rune('0'+i))+".txt")
+		hash := writeTestFile(t, path, []byte("budget "+string(rune('0'+i))))
+		stat, _ := os.Stat(path)
+		records = append(records, &db.FileRecord{
+			Path: path, Disk: "disk1", Size: stat.Size(), Mtime: stat.ModTime().Unix(),
+			SHA256: hash, FirstSeen: now, LastVerified: now, Status: "ok",
+		})
+	}
+	seedFiles(t, database, records...)
+
+	// The pause hook stalls the feeder past the budget after the first file.
+	v := New(database, 1, false)
+	v.MaxDuration = 50 * time.Millisecond
+	calls := 0
+	v.PauseFunc = func(ctx context.Context) error {
+		calls++
+		if calls == 1 {
+			return nil
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	summary, err := v.VerifyAll(func(VerifyResult) {}, nil)
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	if !summary.TimeBounded {
+		t.Error("TimeBounded = false, want true")
+	}
+	if summary.OK != 1 {
+		t.Errorf("OK = %d, want 1", summary.OK)
+	}
+	if summary.Remaining != 2 {
+		t.Errorf("Remaining = %d, want 2", summary.Remaining)
+	}
+}
+
+func TestVerifyPerFileAlgorithm(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+	now := time.Now()
+
+	content := []byte("hashed with sha512\n")
+	p512 := filepath.Join(dir, "a.txt")
+	writeTestFile(t, p512, content)
+	sum := sha512.Sum512(content)
+
+	pUnknown := filepath.Join(dir, "b.txt")
+	writeTestFile(t, pUnknown, []byte("legacy\n"))
+
+	seedFiles(t, database,
+		&db.FileRecord{
+			Path: p512, Disk: "disk1", SHA256: hex.EncodeToString(sum[:]), Algo: "sha512",
+			FirstSeen: now, LastVerified: now, Status: "ok",
+		},
+		&db.FileRecord{
+			Path: pUnknown, Disk: "disk1", SHA256: "deadbeef", Algo: "md5",
+			FirstSeen: now, LastVerified: now, Status: "ok",
+		},
+	)
+
+	v := New(database, 1, false)
+	summary, err := v.VerifyAll(nil, nil)
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	if summary.OK != 1 {
+		t.Errorf("OK = %d, want 1 (sha512 record verified with sha512)", summary.OK)
+	}
+	if summary.Corrupted != 0 {
+		t.Errorf("Corrupted = %d, want 0", summary.Corrupted)
+	}
+	if summary.Errors != 1 {
+		t.Errorf("Errors = %d, want 1 (unsupported algorithm)", summary.Errors)
+	}
+
+	// The unsupported record keeps its previous status
+	corrupted, _ := database.GetFilesByStatus("corrupted")
+	if len(corrupted) != 0 {
+		t.Errorf("got %d corrupted files, want 0", len(corrupted))
+	}
+}
+
+func TestVerifyAcknowledged(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+	now := time.Now()
+
+	restored := filepath.Join(dir, "restored.txt")
+	hash := writeTestFile(t, restored, []byte("restored from backup\n"))
+	stillBad := filepath.Join(dir, "still-bad.txt")
+	writeTestFile(t, stillBad, []byte("changed\n"))
+
+	seedFiles(t, database,
+		&db.FileRecord{Path: restored, Disk: "disk1", SHA256: hash, FirstSeen: now, LastVerified: now, Status: "acknowledged"},
+		&db.FileRecord{Path: stillBad, Disk: "disk1", SHA256: "0000", FirstSeen: now, LastVerified: now, Status: "acknowledged"},
+	)
+
+	v := New(database, 1, false)
+	summary, err := v.VerifyAll(nil, nil)
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	if summary.OK != 1 {
+		t.Errorf("OK = %d, want 1", summary.OK)
+	}
+	if summary.Corrupted != 0 {
+		t.Errorf("Corrupted = %d, want 0 (acknowledged mismatch is not re-alerted)", summary.Corrupted)
+	}
+
+	ok, _ := database.GetFilesByStatus("ok")
+	if len(ok) != 1 || ok[0].Path != restored {
+		t.Errorf("ok files = %v, want only %s", ok, restored)
+	}
+	acked, _ := database.GetFilesByStatus("acknowledged")
+	if len(acked) != 1 || acked[0].Path != stillBad {
+		t.Errorf("acknowledged files = %v, want only %s", acked, stillBad)
+	}
+}
+
+func TestVerifyShares(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+	now := time.Now()
+
+	untracked := filepath.Join(dir, "untracked.txt")
+	untrackedHash := writeTestFile(t, untracked, []byte("only on the disk\n"))
+
+	records := []*db.FileRecord{
+		{Path: "/mnt/user/share/same", Disk: "user", SHA256: "aaa"},
+		{Path: "/mnt/disk1/share/same", Disk: "disk1", SHA256: "aaa"},
+		{Path: "/mnt/user/share/differs", Disk: "user", SHA256: "bbb"},
+		{Path: "/mnt/disk1/share/differs", Disk: "disk1", SHA256: "ccc"},
+		{Path: "/mnt/user/share/untracked", Disk: "user", SHA256: untrackedHash},
+		{Path: "/mnt/user0/share/untracked", Disk: "user0", SHA256: untrackedHash},
+		{Path: "/mnt/user0/share/stale", Disk: "user0", SHA256: "eee"},
+		{Path: "/mnt/user/share/gone", Disk: "user", SHA256: "ddd"},
+	}
+	for _, f := range records {
+		f.FirstSeen, f.LastVerified, f.Status = now, now, "ok"
+	}
+	seedFiles(t, database, records...)
+
+	resolve := func(p string) (string, bool) {
+		switch p {
+		case "/mnt/user/share/same", "/mnt/user/share/differs":
+			return "/mnt/disk1/share/" + filepath.Base(p), true
+		case "/mnt/user/share/untracked", "/mnt/user0/share/untracked", "/mnt/user0/share/stale":
+			return untracked, true
+		}
+		return "", false
+	}
+
+	v := New(database, 1, false)
+	results := map[string]string{}
+	summary, err := v.VerifyShares(resolve, func(r ShareResult) {
+		results[r.SharePath] = r.Status
+	})
+	if err != nil {
+		t.Fatalf("VerifyShares: %v", err)
+	}
+
+	want := map[string]string{
+		"/mnt/user/share/same":       "match",
+		"/mnt/user/share/differs":    "mismatch",
+		"/mnt/user/share/untracked":  "match",
+		"/mnt/user0/share/untracked": "match",
+		"/mnt/user0/share/stale":     "mismatch",
+		"/mnt/user/share/gone":       "unresolved",
+	}
+	for p, status := range want {
+		if results[p] != status {
+			t.Errorf("%s: status = %q, want %q", p, results[p], status)
+		}
+	}
+	if summary.Checked != 6 || summary.Matched != 3 || summary.Mismatched != 2 || summary.Unresolved != 1 {
+		t.Errorf("summary = %+v", summary)
+	}
+	// The untracked disk file backs three share records, each compared
+	// with its hash
+	if summary.Hashed != 3 {
+		t.Errorf("Hashed = %d, want 3 (the records of the untracked disk file)", summary.Hashed)
+	}
+}
+
+func TestVerifyRelativePaths(t *testing.T) {
+	database := setupTestDB(t)
+	base := t.TempDir()
+	now := time.Now()
+
+	os.MkdirAll(filepath.Join(base, "disk1", "Movies"), 0755)
+	hash := writeTestFile(t, filepath.Join(base, "disk1", "Movies", "a.mkv"), []byte("movie\n"))
+
+	seedFiles(t, database,
+		&db.FileRecord{Path: "disk1/Movies/a.mkv", Disk: "disk1", SHA256: hash, FirstSeen: now, LastVerified: now, Status: "ok"},
+		&db.FileRecord{Path: "disk1/Movies/gone.mkv", Disk: "disk1", SHA256: "x", FirstSeen: now, LastVerified: now, Status: "ok"},
+	)
+
+	v := New(database, 1, false)
+	v.PathBase = base
+	summary, err := v.VerifyAll(nil, nil)
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	if summary.OK != 1 || summary.Missing != 1 {
+		t.Errorf("OK = %d, Missing = %d; want 1, 1", summary.OK, summary.Missing)
+	}
+
+	missing, _ := database.GetFilesByStatus("missing")
+	if len(missing) != 1 || missing[0].Path != "disk1/Movies/gone.mkv" {
+		t.Errorf("missing = %v, want the relative record updated in place", missing)
+	}
+}
+
+func TestVerifyQuickModeSubSecondChange(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "test.txt")
+	hash := writeTestFile(t, path, []byte("test content\n"))
+	mtime := time.Unix(1700000000, 900_000_000)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	stat, _ := os.Stat(path)
+	if stat.ModTime().Nanosecond() == 0 {
+		t.Skip("filesystem does not store sub-second mtimes")
+	}
+	now := time.Now()
+
+	// Same second and size, but recorded at an earlier sub-second mtime
+	seedFiles(t, database, &db.FileRecord{
+		Path:         path,
+		Disk:         "disk1",
+		Size:         stat.Size(),
+		Mtime:        mtime.Unix(),
+		MtimeNsec:    100_000_000,
+		SHA256:       hash,
+		FirstSeen:    now,
+		LastVerified: now,
+		Status:       "ok",
+	})
+
+	v := New(database, 1, true)
+	summary, err := v.VerifyAll(nil, nil)
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	if summary.Skipped != 0 || summary.TotalChecked != 1 {
+		t.Errorf("Skipped = %d, TotalChecked = %d; want 0, 1 (sub-second change must be re-hashed)",
+			summary.Skipped, summary.TotalChecked)
+	}
+}
+
+func TestVerifyCheckPerms(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "test.txt")
+	content := []byte("test content\n")
+	hash := writeTestFile(t, path, content)
+	if err := os.Chmod(path, 0600); err != nil {
+		t.Fatal(err)
+	}
+	stat, _ := os.Stat(path)
+	uid, gid := uint32(os.Getuid()), uint32(os.Getgid())
+	now := time.Now()
+
+	// Recorded as 0644 by an earlier scan; chmod leaves the mtime alone
+	seedFiles(t, database, &db.FileRecord{
+		Path: path, Disk: "disk1", Size: stat.Size(), Mtime: stat.ModTime().Unix(),
+		MtimeNsec: int64(stat.ModTime().Nanosecond()), SHA256: hash,
+		FirstSeen: now, LastVerified: now, Status: "ok",
+		Perms: &db.Perms{UID: uid, GID: gid, Mode: 0644},
+	})
+
+	v := New(database, 1, true)
+	summary, err := v.VerifyAll(nil, nil)
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	if len(summary.PermsChanged) != 0 {
+		t.Errorf("PermsChanged without CheckPerms = %v", summary.PermsChanged)
+	}
+
+	// Even quick mode, which skips the unchanged contents, notices the chmod
+	v.CheckPerms = true
+	summary, err = v.VerifyAll(nil, nil)
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	want := PermsChange{Path: path, Old: db.Perms{UID: uid, GID: gid, Mode: 0644}, New: db.Perms{UID: uid, GID: gid, Mode: 0600}}
+	if len(summary.PermsChanged) != 1 || summary.PermsChanged[0] != want {
+		t.Errorf("PermsChanged = %+v, want [%+v]", summary.PermsChanged, want)
+	}
+	if summary.Skipped != 1 {
+		t.Errorf("Skipped = %d, want 1", summary.Skipped)
+	}
+}
+
+func TestVerifyXattr(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+
+	good := filepath.Join(dir, "good.txt")
+	stale := filepath.Join(dir, "stale.txt")
+	goodHash := writeTestFile(t, good, []byte("good\n"))
+	staleHash := writeTestFile(t, stale, []byte("stale\n"))
+	if err := xattr.Set(good, "user.sha256", goodHash+"\n"); errors.Is(err, xattr.ErrUnsupported) {
+		t.Skip("no user extended attributes here")
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	if err := xattr.Set(stale, "user.sha256", strings.Repeat("0", 64)); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	var records []*db.FileRecord
+	for path, hash := range map[string]string{good: goodHash, stale: staleHash} {
+		records = append(records, &db.FileRecord{
+			Path: path, Disk: "disk1", Size: 5, SHA256: hash, FirstSeen: now, LastVerified: now, Status: "ok",
+		})
+	}
+	seedFiles(t, database, records...)
+
+	v := New(database, 1, false)
+	v.Xattr = "user.sha256"
+	results := map[string]VerifyResult{}
+	summary, err := v.VerifyAll(func(r VerifyResult) { results[r.Path] = r }, nil)
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	if summary.OK != 2 || summary.XattrMismatches != 1 {
+		t.Errorf("OK = %d, XattrMismatches = %d; want 2 and 1", summary.OK, summary.XattrMismatches)
+	}
+	if r := results[good]; r.XattrHash != goodHash || r.XattrMismatch {
+		t.Errorf("good: XattrHash = %q, XattrMismatch = %v", r.XattrHash, r.XattrMismatch)
+	}
+	if r := results[stale]; !r.XattrMismatch {
+		t.Errorf("stale: XattrMismatch = false, want true")
+	}
+}
+
+func TestVerifyShatag(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+
+	rotten := filepath.Join(dir, "rotten.txt")
+	edited := filepath.Join(dir, "edited.txt")
+	now := time.Now()
+	var records []*db.FileRecord
+	for _, path := range []string{rotten, edited} {
+		hash := writeTestFile(t, path, []byte(filepath.Base(path)))
+		records = append(records, &db.FileRecord{
+			Path: path, Disk: "disk1", Size: 5, SHA256: hash, FirstSeen: now, LastVerified: now, Status: "ok",
+		})
+	}
+	seedFiles(t, database, records...)
+
+	// Both tags hold a wrong hash, but only rotten's is stamped with the
+	// file's current mtime; edited's predates its last modification
+	stat, _ := os.Stat(rotten)
+	tag := xattr.Shatag{SHA256: strings.Repeat("0", 64), Mtime: stat.ModTime().Unix(), MtimeNsec: int64(stat.ModTime().Nanosecond())}
+	if err := xattr.SetShatag(rotten, tag); errors.Is(err, xattr.ErrUnsupported) {
+		t.Skip("no user extended attributes here")
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	tag.Mtime--
+	if err := xattr.SetShatag(edited, tag); err != nil {
+		t.Fatal(err)
+	}
+
+	v := New(database, 1, false)
+	v.Shatag = true
+	results := map[string]VerifyResult{}
+	summary, err := v.VerifyAll(func(r VerifyResult) { results[r.Path] = r }, nil)
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	if summary.XattrMismatches != 1 || summary.XattrStale != 1 {
+		t.Errorf("XattrMismatches = %d, XattrStale = %d; want 1 and 1", summary.XattrMismatches, summary.XattrStale)
+	}
+	if r := results[rotten]; !r.XattrMismatch || r.XattrStale {
+		t.Errorf("rotten: XattrMismatch = %v, XattrStale = %v", r.XattrMismatch, r.XattrStale)
+	}
+	if r := results[edited]; r.XattrMismatch || !r.XattrStale {
+		t.Errorf("edited: XattrMismatch = %v, XattrStale = %v", r.XattrMismatch, r.XattrStale)
+	}
+}
+
+func TestVerifyStatusUpdates(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+	past := time.Now().Add(-48 * time.Hour)
+
+	// Five files already ok, two new, one gone missing
+	var records []*db.FileRecord
+	for i := 0; i < 8; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("f%d.txt", i))
+		hash := "abc123"
+		if i < 7 {
+			hash = writeTestFile(t, path, []byte(fmt.Sprintf("file %d\n", i)))
+		}
+		status := "ok"
+		if i == 5 || i == 6 {
+			status = "new"
+		}
+		stat, _ := os.Stat(path)
+		var size, mtime int64 = 10, past.Unix()
+		if stat != nil {
+			size, mtime = stat.Size(), stat.ModTime().Unix()
+		}
+		records = append(records, &db.FileRecord{
+			Path: path, Disk: "disk1", Size: size, Mtime: mtime, SHA256: hash,
+			FirstSeen: past, LastVerified: past, Status: status,
+		})
+	}
+	seedFiles(t, database, records...)
+
+	v := New(database, 2, false)
+	v.BatchSize = 3
+	summary, err := v.VerifyAll(nil, nil)
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	if summary.OK != 7 || summary.Missing != 1 {
+		t.Fatalf("OK = %d, Missing = %d; want 7 and 1", summary.OK, summary.Missing)
+	}
+	// The two new files became ok and the missing one missing; the rest
+	// kept their status
+	if summary.StatusUpdates != 3 {
+		t.Errorf("StatusUpdates = %d, want 3", summary.StatusUpdates)
+	}
+	if summary.Errors != 0 {
+		t.Errorf("Errors = %d, want 0", summary.Errors)
+	}
+
+	for i := 0; i < 8; i++ {
+		rec, err := database.GetFileByPath(filepath.Join(dir, fmt.Sprintf("f%d.txt", i)))
+		if err != nil || rec == nil {
+			t.Fatalf("GetFileByPath f%d: %v", i, err)
+		}
+		want := "ok"
+		if i == 7 {
+			want = "missing"
+		}
+		if rec.Status != want {
+			t.Errorf("f%d status = %q, want %q", i, rec.Status, want)
+		}
+		if !rec.LastVerified.After(past.Add(time.Hour)) {
+			t.Errorf("f%d last_verified = %v, not refreshed", i, rec.LastVerified)
+		}
+	}
+}
+
+func TestVerifyNoTouch(t *testing.T) {
+	database := setupTestDB(t)
+	dir := t.TempDir()
+	past := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+
+	var records []*db.FileRecord
+	for i, status := range []string{"ok", "new"} {
+		path := filepath.Join(dir, fmt.Sprintf("f%d.txt", i))
+		hash := writeTestFile(t, path, []byte(status))
+		stat, _ := os.Stat(path)
+		records = append(records, &db.FileRecord{
+			Path: path, Disk: "disk1", Size: stat.Size(), Mtime: stat.ModTime().Unix(), SHA256: hash,
+			FirstSeen: past, LastVerified: past, Status: status,
+		})
+	}
+	seedFiles(t, database, records...)
+
+	v := New(database, 1, false)
+	v.TouchVerified = false
+	summary, err := v.VerifyAll(nil, nil)
+	if err != nil {
+		t.Fatalf("VerifyAll: %v", err)
+	}
+	if summary.OK != 2 || summary.StatusUpdates != 1 {
+		t.Fatalf("OK = %d, StatusUpdates = %d; want 2 and 1", summary.OK, summary.StatusUpdates)
+	}
+
+	// The file that was already ok wasn't written; the new one was
+	unchanged, _ := database.GetFileByPath(filepath.Join(dir, "f0.txt"))
+	if !unchanged.LastVerified.Equal(past) {
+		t.Errorf("unchanged file's last_verified = %v, want %v", unchanged.LastVerified, past)
+	}
+	promoted, _ := database.GetFileByPath(filepath.Join(dir, "f1.txt"))
+	if promoted.Status != "ok" || !promoted.LastVerified.After(past) {
+		t.Errorf("new file: status %q, last_verified %v; want ok and refreshed", promoted.Status, promoted.LastVerified)
+	}
+}
@@ -0,0 +1,38 @@
+package web
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// requireToken wraps next so it only runs for requests carrying
+// "Authorization: Bearer <token>". An empty token leaves next open, as the
+// dashboard has always been on a trusted LAN.
+func requireToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	if token == "" {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="filehasher"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireTokenToPost is requireToken for a page that shows a form and acts
+// on its POST: only the POST needs the token, so the page itself loads.
+func requireTokenToPost(token string, next http.HandlerFunc) http.HandlerFunc {
+	guarded := requireToken(token, next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			guarded(w, r)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,66 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireToken(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{"no token configured", "", "", http.StatusNoContent},
+		{"no token configured, one sent", "", "Bearer anything", http.StatusNoContent},
+		{"missing", "s3cret", "", http.StatusUnauthorized},
+		{"wrong", "s3cret", "Bearer guess", http.StatusUnauthorized},
+		{"not bearer", "s3cret", "Basic s3cret", http.StatusUnauthorized},
+		{"prefix of token", "s3cret", "Bearer s3c", http.StatusUnauthorized},
+		{"correct", "s3cret", "Bearer s3cret", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/scan", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			requireToken(tt.token, ok)(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without a WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestRequireTokenToPost(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+	h := requireTokenToPost("s3cret", ok)
+
+	for _, tt := range []struct {
+		method, header string
+		want           int
+	}{
+		{http.MethodGet, "", http.StatusNoContent},
+		{http.MethodPost, "", http.StatusUnauthorized},
+		{http.MethodPost, "Bearer s3cret", http.StatusNoContent},
+	} {
+		req := httptest.NewRequest(tt.method, "/settings", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s with %q: status = %d, want %d", tt.method, tt.header, rec.Code, tt.want)
+		}
+	}
+}
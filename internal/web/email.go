@@ -0,0 +1,70 @@
+package web
+
+import (
+	"html/template"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/maisi/unraid-filehasher/internal/db"
+)
+
+// SummaryEmail is what the summary mail (report --email) shows.
+type SummaryEmail struct {
+	Host      string
+	Version   string
+	Generated time.Time
+	Stats     *db.Stats
+	Disks     []*db.DiskStats
+
+	// NewCorrupted lists files a verify found corrupted since Since that
+	// weren't corrupted before, and MoreCorrupted how many were left out of
+	// it.
+	Since         time.Time
+	NewCorrupted  []*db.FileRecord
+	MoreCorrupted int
+}
+
+// Problems reports whether anything needs attention: corrupted, unreadable
+// or missing files.
+func (s SummaryEmail) Problems() bool {
+	return s.Stats.CorruptedFiles+s.Stats.ErrorFiles+s.Stats.MissingFiles > 0
+}
+
+// emailStyles are the inline styles cls gives the dashboard's classes in
+// mail, which has no stylesheet to refer to.
+var emailStyles = map[string]string{
+	"stats-grid":       "margin-bottom: 16px;",
+	"stat-card":        "display: inline-block; min-width: 96px; margin: 0 8px 8px 0; padding: 8px 12px; border: 1px solid #d0d7de; border-radius: 6px; text-align: center;",
+	"value":            "font-size: 20px; font-weight: bold;",
+	"label":            "font-size: 11px; text-transform: uppercase; color: #57606a;",
+	"success":          "color: #1a7f37;",
+	"info":             "color: #0969da;",
+	"warning":          "color: #9a6700;",
+	"danger":           "color: #cf222e;",
+	"text-right":       "text-align: right;",
+	"text-muted":       "color: #57606a;",
+	"status-ok":        "color: #1a7f37;",
+	"status-missing":   "color: #9a6700;",
+	"status-corrupted": "color: #cf222e; font-weight: bold;",
+}
+
+var summaryEmailTemplate = template.Must(template.Must(template.New("email").Funcs(templateFuncMap).Funcs(template.FuncMap{
+	"cls": func(classes string) template.HTMLAttr {
+		var style string
+		for _, c := range strings.Fields(classes) {
+			style += emailStyles[c]
+		}
+		if style == "" {
+			return ""
+		}
+		return template.HTMLAttr(`style="` + template.HTMLEscapeString(style) + `"`)
+	},
+	// The dashboard's links would be relative to a server the mail isn't from
+	"diskURL": func(string) string { return "" },
+}).Parse(partialTemplates)).Parse(emailTemplate))
+
+// RenderSummaryEmail writes the summary mail's HTML body.
+func RenderSummaryEmail(w io.Writer, s SummaryEmail) error {
+	return summaryEmailTemplate.Execute(w, s)
+}
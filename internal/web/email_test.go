@@ -0,0 +1,69 @@
+package web
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maisi/unraid-filehasher/internal/db"
+)
+
+func TestRenderSummaryEmail(t *testing.T) {
+	now := time.Now()
+	summary := SummaryEmail{
+		Host:      "tower",
+		Generated: now,
+		Stats:     &db.Stats{TotalFiles: 3, OKFiles: 2, CorruptedFiles: 1},
+		Disks: []*db.DiskStats{
+			{Disk: "disk1", DiskType: "hdd", TotalFiles: 3, CorruptedFiles: 1, CorruptedPct: 33.3, Health: "failing", LastVerified: &now},
+		},
+		Since:        now.Add(-24 * time.Hour),
+		NewCorrupted: []*db.FileRecord{{Path: "/mnt/disk1/rotten.mkv", Disk: "disk1", Size: 1, LastVerified: now}},
+	}
+
+	var buf bytes.Buffer
+	if err := RenderSummaryEmail(&buf, summary); err != nil {
+		t.Fatalf("RenderSummaryEmail: %v", err)
+	}
+	body := buf.String()
+	for _, want := range []string{
+		"Attention needed",
+		"/mnt/disk1/rotten.mkv",
+		">disk1<",
+		`style="` + emailStyles["stat-card"] + emailStyles["danger"] + `"`,
+		`style="` + emailStyles["status-corrupted"] + `"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("summary mail lacks %q", want)
+		}
+	}
+	for _, unwanted := range []string{"class=", "href=", "ZgotmplZ"} {
+		if strings.Contains(body, unwanted) {
+			t.Errorf("summary mail contains %q; mail has no stylesheet or dashboard to refer to", unwanted)
+		}
+	}
+}
+
+func TestRenderOverviewPartials(t *testing.T) {
+	now := time.Now()
+	rec := httptest.NewRecorder()
+	renderTemplate(rec, "disks", map[string]interface{}{
+		"Page":      "disks",
+		"DiskStats": []*db.DiskStats{{Disk: "disk 1", TotalFiles: 1, MissingFiles: 1, Health: "good", LastVerified: &now}},
+	})
+	body := rec.Body.String()
+	for _, want := range []string{
+		`<a href="/disks?name=disk&#43;1" class="disk-link">disk 1</a>`,
+		`class="text-right status-missing"`,
+		`class="status-ok"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("disks page lacks %q", want)
+		}
+	}
+	if strings.Contains(body, "style=\"text-align") {
+		t.Error("disks page uses the mail's inline styles")
+	}
+}
@@ -0,0 +1,111 @@
+package web
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAcceptsGzip(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"gzip, deflate, br", true},
+		{"deflate, GZIP", true},
+		{"br;q=1.0, gzip;q=0.8", true},
+		{"gzip;q=0", false},
+		{"gzip; q=0.0", false},
+		{"identity", false},
+		{"x-gzip", false},
+	}
+	for _, tt := range tests {
+		if got := acceptsGzip(tt.header); got != tt.want {
+			t.Errorf("acceptsGzip(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestGzipResponses(t *testing.T) {
+	body := strings.Repeat("<p>all files verified</p>\n", 100)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/html", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "<!DOCTYPE html>"+body) // Content-Type sniffed
+	})
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		io.WriteString(w, `{"ok":true}`)
+	})
+	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {}\n\n")
+	})
+	mux.HandleFunc("/empty", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNoContent)
+	})
+	h := gzipResponses(mux)
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if accept != "" {
+			req.Header.Set("Accept-Encoding", accept)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	tests := []struct {
+		path, accept string
+		gzipped      bool
+	}{
+		{"/html", "gzip, deflate", true},
+		{"/json", "gzip", true},
+		{"/html", "", false},
+		{"/json", "gzip;q=0", false},
+		{"/events", "gzip", false},
+		{"/empty", "gzip", false},
+	}
+	for _, tt := range tests {
+		rec := get(tt.path, tt.accept)
+		if vary := rec.Header().Values("Vary"); len(vary) != 1 || vary[0] != "Accept-Encoding" {
+			t.Errorf("%s (%q): Vary = %q, want Accept-Encoding", tt.path, tt.accept, vary)
+		}
+		gotGzip := rec.Header().Get("Content-Encoding") == "gzip"
+		if gotGzip != tt.gzipped {
+			t.Errorf("%s (%q): gzipped = %v, want %v", tt.path, tt.accept, gotGzip, tt.gzipped)
+			continue
+		}
+		if !gotGzip {
+			continue
+		}
+		zr, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatalf("%s: gzip.NewReader: %v", tt.path, err)
+		}
+		plain, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("%s: read: %v", tt.path, err)
+		}
+		if tt.path == "/html" && string(plain) != "<!DOCTYPE html>"+body {
+			t.Errorf("/html: decompressed body differs")
+		}
+		if tt.path == "/html" && !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/html") {
+			t.Errorf("/html: Content-Type = %q, want sniffed text/html", rec.Header().Get("Content-Type"))
+		}
+		if tt.path == "/json" && string(plain) != `{"ok":true}` {
+			t.Errorf("/json: body = %q", plain)
+		}
+	}
+
+	// Streamed responses pass through uncompressed
+	if rec := get("/events", "gzip"); rec.Body.String() != "data: {}\n\n" {
+		t.Errorf("/events body = %q", rec.Body.String())
+	}
+}
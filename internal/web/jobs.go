@@ -0,0 +1,50 @@
+package web
+
+import "time"
+
+// maxJobs is how many finished jobs the runner remembers for polling.
+const maxJobs = 50
+
+// Job is one scan or verify started through the API. The runner keeps the
+// last few so a client can poll /api/jobs/{id} for the outcome after the
+// operation finished.
+type Job struct {
+	ID      int64      `json:"id"`
+	Type    string     `json:"type"`           // "scan" or "verify"
+	Disk    string     `json:"disk,omitempty"` // empty for all disks
+	State   string     `json:"state"`          // "running", "complete", "cancelled" or "error"
+	Started time.Time  `json:"started"`
+	Ended   *time.Time `json:"ended,omitempty"`
+	Done    int64      `json:"done"`
+	Total   int64      `json:"total"`
+	Errors  int64      `json:"errors"`
+	Message string     `json:"message"`
+}
+
+// jobList records jobs in start order, dropping the oldest past maxJobs.
+// It is guarded by Runner.mu.
+type jobList struct {
+	next int64
+	jobs []*Job
+}
+
+// start records a new running job and returns it.
+func (l *jobList) start(typ, disk string, now time.Time) *Job {
+	l.next++
+	j := &Job{ID: l.next, Type: typ, Disk: disk, State: "running", Started: now}
+	l.jobs = append(l.jobs, j)
+	if len(l.jobs) > maxJobs {
+		l.jobs = append(l.jobs[:0:0], l.jobs[len(l.jobs)-maxJobs:]...)
+	}
+	return j
+}
+
+// get returns the job with id, or nil if it is unknown or was dropped.
+func (l *jobList) get(id int64) *Job {
+	for _, j := range l.jobs {
+		if j.ID == id {
+			return j
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	l := newRateLimiter(1, 3)
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.allow("10.0.0.1", now); !ok {
+			t.Fatalf("request %d refused within burst", i+1)
+		}
+	}
+	ok, wait := l.allow("10.0.0.1", now)
+	if ok {
+		t.Fatal("request past burst was allowed")
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("wait = %v, want (0, 1s]", wait)
+	}
+
+	// Other clients have their own bucket.
+	if ok, _ := l.allow("10.0.0.2", now); !ok {
+		t.Error("second client refused")
+	}
+
+	// One second later one token has come back.
+	later := now.Add(time.Second)
+	if ok, _ := l.allow("10.0.0.1", later); !ok {
+		t.Error("request refused after refill")
+	}
+	if ok, _ := l.allow("10.0.0.1", later); ok {
+		t.Error("refill gave more than one token")
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	l := newRateLimiter(1, 2)
+	now := time.Now()
+	l.allow("10.0.0.1", now)
+
+	// Idle for longer than a full refill: the bucket is dropped.
+	l.allow("10.0.0.2", now.Add(5*time.Second))
+	if _, ok := l.buckets["10.0.0.1"]; ok {
+		t.Error("idle bucket was not swept")
+	}
+	if len(l.buckets) != 1 {
+		t.Errorf("buckets = %d, want 1", len(l.buckets))
+	}
+}
+
+func TestRateLimiterHandler(t *testing.T) {
+	l := newRateLimiter(1, 1)
+	h := l.limit(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	get := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/search?q=abc", nil)
+		req.RemoteAddr = "192.168.1.10:51234"
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+
+	if rec := get(); rec.Code != http.StatusOK {
+		t.Fatalf("first request: status %d, want 200", rec.Code)
+	}
+	rec := get()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: status %d, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Retry-After = %q, want 1", got)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		remote string
+		want   string
+	}{
+		{"192.168.1.10:51234", "192.168.1.10"},
+		{"[::1]:8080", "::1"},
+		{"unix-socket", "unix-socket"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tt.remote
+		if got := clientIP(req); got != tt.want {
+			t.Errorf("clientIP(%q) = %q, want %q", tt.remote, got, tt.want)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package web
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -14,6 +13,9 @@ import (
 
 	"github.com/maisi/unraid-filehasher/internal/db"
 	"github.com/maisi/unraid-filehasher/internal/hasher"
+	"github.com/maisi/unraid-filehasher/internal/logx"
+	"github.com/maisi/unraid-filehasher/internal/oplock"
+	"github.com/maisi/unraid-filehasher/internal/progress"
 	"github.com/maisi/unraid-filehasher/internal/scanner"
 	"github.com/maisi/unraid-filehasher/internal/thermal"
 	"github.com/maisi/unraid-filehasher/internal/verifier"
@@ -26,12 +28,14 @@ type ScanOptions struct {
 	FullScan       bool     `json:"fullScan"`
 	HddTwoPhase    bool     `json:"hddTwoPhase"`
 	DiskType       string   `json:"diskType"`
+	Disk           string   `json:"disk,omitempty"` // scan only this disk (e.g. "disk1")
 }
 
 // VerifyOptions holds per-operation verify parameters.
 type VerifyOptions struct {
-	Workers int  `json:"workers"`
-	Quick   bool `json:"quick"`
+	Workers int    `json:"workers"`
+	Quick   bool   `json:"quick"`
+	Disk    string `json:"disk,omitempty"` // verify only this disk's files
 }
 
 // ThermalConfig holds thermal protection parameters.
@@ -215,15 +219,28 @@ type RunnerProgress struct {
 	Message   string         `json:"message"`   // latest status message
 	Disks     []DiskProgress `json:"disks"`     // per-disk progress (nil when idle with no history)
 	DnDPaused bool           `json:"dndPaused"` // true if globally paused due to DnD schedule
+	Rate      float64        `json:"rate"`      // average bytes/s since the start (0 if unknown)
+	ETA       int64          `json:"eta"`       // estimated seconds left (0 if unknown)
+	JobID     int64          `json:"jobId"`     // job of the current or last operation (0 if none)
+}
+
+// setThroughput copies the rate and ETA of a tracker snapshot.
+func (p *RunnerProgress) setThroughput(s progress.Snapshot) {
+	p.Rate = s.Rate
+	p.ETA = int64(s.ETA.Seconds())
 }
 
 // Runner manages background scan and verify operations.
 type Runner struct {
-	db *db.DB
+	db      *db.DB
+	workers scanner.WorkerDefaults // per-disk hash workers by disk type
 
 	mu       sync.RWMutex
 	progress RunnerProgress
 	cancel   context.CancelFunc // cancel function for current operation
+	jobs     jobList
+	job      *Job         // the running job, nil when idle
+	lock     *oplock.Lock // catalog lock held by the running job
 
 	// SSE subscribers
 	subMu   sync.Mutex
@@ -231,10 +248,13 @@ type Runner struct {
 	nextSub uint64
 }
 
-// NewRunner creates a Runner for background operations.
-func NewRunner(database *db.DB) *Runner {
+// NewRunner creates a Runner for background operations. workers sizes the
+// per-disk hash pipelines of scans, and of verifies when no scan recorded the
+// disks yet.
+func NewRunner(database *db.DB, workers scanner.WorkerDefaults) *Runner {
 	return &Runner{
-		db: database,
+		db:      database,
+		workers: workers,
 		progress: RunnerProgress{
 			State: StateIdle,
 		},
@@ -310,46 +330,76 @@ func (r *Runner) Stop() error {
 	return nil
 }
 
-// StartScan begins a background scan operation. Returns an error if already busy.
-func (r *Runner) StartScan(opts ScanOptions, thermal ThermalConfig, dnd DndConfig) error {
-	r.mu.Lock()
-	if r.progress.State != StateIdle {
-		r.mu.Unlock()
-		return fmt.Errorf("already running: %s", r.progress.State)
+// Job returns a copy of the job with id, with the live counters filled in
+// while it is still running. ok is false for unknown or long-finished jobs.
+func (r *Runner) Job(id int64) (job Job, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	j := r.jobs.get(id)
+	if j == nil {
+		return Job{}, false
 	}
-	ctx, cancel := context.WithCancel(context.Background())
-	r.cancel = cancel
-	r.progress = RunnerProgress{
-		State:   StateScanning,
-		Phase:   "detecting disks",
-		Started: time.Now(),
+	job = *j
+	if j == r.job {
+		job.Done, job.Total, job.Errors = r.progress.Done, r.progress.Total, r.progress.Errors
+		job.Message = r.progress.Message
 	}
-	r.mu.Unlock()
-	r.notify(r.Progress())
+	return job, true
+}
 
+// StartScan begins a background scan operation and returns its job ID.
+// Returns an error if already busy.
+func (r *Runner) StartScan(opts ScanOptions, thermal ThermalConfig, dnd DndConfig) (int64, error) {
+	ctx, id, err := r.start("scan", opts.Disk, StateScanning, "detecting disks")
+	if err != nil {
+		return 0, err
+	}
 	go r.runScan(ctx, opts, thermal, dnd)
-	return nil
+	return id, nil
 }
 
-// StartVerify begins a background verify operation. Returns an error if already busy.
-func (r *Runner) StartVerify(opts VerifyOptions, thermal ThermalConfig, dnd DndConfig) error {
+// StartVerify begins a background verify operation and returns its job ID.
+// Returns an error if already busy.
+func (r *Runner) StartVerify(opts VerifyOptions, thermal ThermalConfig, dnd DndConfig) (int64, error) {
+	ctx, id, err := r.start("verify", opts.Disk, StateVerifying, "starting")
+	if err != nil {
+		return 0, err
+	}
+	go r.runVerify(ctx, opts, thermal, dnd)
+	return id, nil
+}
+
+// start claims the runner for a new job, so only one operation runs at a
+// time, and returns the context that cancels it.
+func (r *Runner) start(typ, disk string, state RunnerState, phase string) (context.Context, int64, error) {
 	r.mu.Lock()
 	if r.progress.State != StateIdle {
 		r.mu.Unlock()
-		return fmt.Errorf("already running: %s", r.progress.State)
+		return nil, 0, fmt.Errorf("already running: %s", r.progress.State)
+	}
+	// Also stay clear of scans and verifies run from the command line
+	if path := r.db.Path(); path != db.MemoryPath {
+		l, err := oplock.Acquire(oplock.Path(path), typ)
+		if err != nil {
+			r.mu.Unlock()
+			return nil, 0, err
+		}
+		r.lock = l
 	}
 	ctx, cancel := context.WithCancel(context.Background())
 	r.cancel = cancel
+	now := time.Now()
+	r.job = r.jobs.start(typ, disk, now)
 	r.progress = RunnerProgress{
-		State:   StateVerifying,
-		Phase:   "starting",
-		Started: time.Now(),
+		State:   state,
+		Phase:   phase,
+		Started: now,
+		JobID:   r.job.ID,
 	}
+	snap := r.progress
 	r.mu.Unlock()
-	r.notify(r.Progress())
-
-	go r.runVerify(ctx, opts, thermal, dnd)
-	return nil
+	r.notify(snap)
+	return ctx, snap.JobID, nil
 }
 
 // finishOperation sets the final progress state. Phase should be "complete", "cancelled", or "error".
@@ -357,6 +407,18 @@ func (r *Runner) StartVerify(opts VerifyOptions, thermal ThermalConfig, dnd DndC
 func (r *Runner) finishOperation(phase string, done, total, errors int64, message string, disks []DiskProgress) {
 	r.mu.Lock()
 	r.cancel = nil
+	if err := r.lock.Release(); err != nil {
+		logx.Warnf("release catalog lock: %v\n", err)
+	}
+	r.lock = nil
+	var jobID int64
+	if j := r.job; j != nil {
+		now := time.Now()
+		j.State, j.Ended = phase, &now
+		j.Done, j.Total, j.Errors, j.Message = done, total, errors, message
+		jobID = j.ID
+		r.job = nil
+	}
 	r.progress = RunnerProgress{
 		State:   StateIdle,
 		Phase:   phase,
@@ -365,6 +427,7 @@ func (r *Runner) finishOperation(phase string, done, total, errors int64, messag
 		Errors:  errors,
 		Message: message,
 		Disks:   disks,
+		JobID:   jobID,
 	}
 	snap := r.progress
 	r.mu.Unlock()
@@ -383,6 +446,20 @@ func (r *Runner) runScan(ctx context.Context, opts ScanOptions, thermalCfg Therm
 		return
 	}
 
+	if opts.Disk != "" {
+		var only []scanner.DiskInfo
+		for _, d := range disks {
+			if d.Name == opts.Disk {
+				only = append(only, d)
+			}
+		}
+		if len(only) == 0 {
+			r.finishOperation("error", 0, 0, 0, fmt.Sprintf("disk %q not found", opts.Disk), nil)
+			return
+		}
+		disks = only
+	}
+
 	// Apply disk type override
 	switch strings.ToLower(strings.TrimSpace(opts.DiskType)) {
 	case "hdd":
@@ -396,6 +473,12 @@ func (r *Runner) runScan(ctx context.Context, opts ScanOptions, thermalCfg Therm
 	default:
 		// "auto" or empty — keep detected types
 	}
+	for _, d := range disks {
+		rec := &db.Disk{Name: d.Name, Path: d.Path, Type: d.Type.String()}
+		if err := r.db.UpsertDisk(rec); err != nil {
+			logx.Warnf("record disk %s: %v\n", d.Name, err)
+		}
+	}
 
 	// Initialize per-disk progress
 	diskProgressMap := make(map[string]*DiskProgress, len(disks))
@@ -450,20 +533,42 @@ func (r *Runner) runScan(ctx context.Context, opts ScanOptions, thermalCfg Therm
 		r.finishOperation("error", 0, 0, 0, fmt.Sprintf("create scanner: %v", err), nil)
 		return
 	}
+	sc.NohashMarker = scanner.DefaultNohashMarker
+	sc.CacheDirTag = true
 
 	// Record scan history
 	var pathNames []string
 	for _, d := range disks {
 		pathNames = append(pathNames, d.Name)
 	}
-	scanID, _ := r.db.InsertScanHistory("scan", strings.Join(pathNames, ","))
+	// Hash like the catalog does (see cmd's scan), so a migrated catalog
+	// stays migrated and moves can still be matched
+	scanAlgo, err := r.db.CatalogAlgo()
+	if err != nil {
+		r.finishOperation("error", 0, 0, 0, fmt.Sprintf("get catalog algorithm: %v", err), nil)
+		return
+	}
+	scanID, _ := r.db.InsertScanHistory("scan", strings.Join(pathNames, ","), appVersion, scanAlgo)
 
 	// Aggregate result channel
 	results := make(chan hasher.Result, 256)
 
-	var skipped int64
-	var totalProcessed int64
-	var totalErrors int64
+	tracker := progress.New()
+
+	// Writes go through a single writer goroutine (see db.Writer) so
+	// dashboard requests aren't blocked behind a long batch.
+	writer := r.db.NewWriter(1000, 2*time.Second, func(error) {
+		tracker.AddErrors(1)
+	})
+	defer writer.Close()
+
+	// Keep unreadable paths with the run for the History page
+	recordError := func(path string, err error) {
+		if scanID > 0 {
+			writer.ScanError(scanID, path, err.Error())
+		}
+	}
+	sc.OnError = recordError
 
 	// Set up per-disk thermal state
 	thermalStates := make(map[string]*diskThermalState, len(disks))
@@ -493,7 +598,7 @@ func (r *Runner) runScan(ctx context.Context, opts ScanOptions, thermalCfg Therm
 	// Launch per-disk pipelines
 	var pipelineWg sync.WaitGroup
 	for _, d := range disks {
-		workers := d.Type.DefaultWorkers()
+		workers := r.workers.For(d.Type)
 		diskInput := make(chan hasher.FileInfo, workers*4)
 		output := make(chan hasher.Result, workers*4)
 
@@ -525,6 +630,9 @@ func (r *Runner) runScan(ctx context.Context, opts ScanOptions, thermalCfg Therm
 						r.updateProgress(func(p *RunnerProgress) {
 							p.Message = fmt.Sprintf("error scanning %s: %v", disk.Path, err)
 						})
+						if ctx.Err() == nil {
+							recordError(disk.Path, fmt.Errorf("walk: %w", err))
+						}
 					}
 				}()
 
@@ -538,17 +646,20 @@ func (r *Runner) runScan(ctx context.Context, opts ScanOptions, thermalCfg Therm
 					}
 
 					// Incremental check
+					fi.Algo = scanAlgo
 					if lookupMap != nil {
 						if existing, ok := lookupMap[fi.Path]; ok {
-							if existing.Size == fi.Size && existing.Mtime == fi.Mtime {
-								atomic.AddInt64(&skipped, 1)
+							if existing.Size == fi.Size && db.SameMtime(existing.Mtime, existing.MtimeNsec, fi.Mtime, fi.MtimeNsec) {
+								tracker.AddSkipped(1)
 								continue
 							}
+							fi.Algo = existing.Algo
 						}
 					}
 
 					atomic.AddInt64(&dp.FilesFound, 1)
 					atomic.AddInt64(&dp.BytesTotal, fi.Size)
+					tracker.AddTotal(1, fi.Size)
 					list = append(list, fi)
 				}
 
@@ -581,6 +692,9 @@ func (r *Runner) runScan(ctx context.Context, opts ScanOptions, thermalCfg Therm
 						r.updateProgress(func(p *RunnerProgress) {
 							p.Message = fmt.Sprintf("error scanning %s: %v", disk.Path, err)
 						})
+						if ctx.Err() == nil {
+							recordError(disk.Path, fmt.Errorf("walk: %w", err))
+						}
 					}
 				}()
 
@@ -603,18 +717,21 @@ func (r *Runner) runScan(ctx context.Context, opts ScanOptions, thermalCfg Therm
 					}
 
 					// Incremental check
+					fi.Algo = scanAlgo
 					if lookupMap != nil {
 						if existing, ok := lookupMap[fi.Path]; ok {
-							if existing.Size == fi.Size && existing.Mtime == fi.Mtime {
-								atomic.AddInt64(&skipped, 1)
+							if existing.Size == fi.Size && db.SameMtime(existing.Mtime, existing.MtimeNsec, fi.Mtime, fi.MtimeNsec) {
+								tracker.AddSkipped(1)
 								continue
 							}
+							fi.Algo = existing.Algo
 						}
 					}
 
 					// Track per-disk walk progress
 					atomic.AddInt64(&dp.FilesFound, 1)
 					atomic.AddInt64(&dp.BytesTotal, fi.Size)
+					tracker.AddTotal(1, fi.Size)
 
 					select {
 					case <-ctx.Done():
@@ -639,22 +756,6 @@ func (r *Runner) runScan(ctx context.Context, opts ScanOptions, thermalCfg Therm
 	})
 
 	// Process results
-	tx, txErr := r.db.BeginBatch()
-	if txErr != nil {
-		if thermalCancel != nil {
-			thermalCancel()
-		}
-		if dndCancel != nil {
-			dndCancel()
-		}
-		r.finishOperation("error", 0, 0, 0, fmt.Sprintf("begin transaction: %v", txErr), nil)
-		return
-	}
-	defer func() { tx.Rollback() }()
-
-	batchSize := 1000
-	batchCount := 0
-
 	cancelled := false
 	for result := range results {
 		// Check for cancellation
@@ -671,18 +772,25 @@ func (r *Runner) runScan(ctx context.Context, opts ScanOptions, thermalCfg Therm
 			break
 		}
 
-		atomic.AddInt64(&totalProcessed, 1)
+		tracker.AddProcessed(1)
 
 		if result.Err != nil {
-			atomic.AddInt64(&totalErrors, 1)
+			tracker.AddErrors(1)
+			recordError(result.Path, result.Err)
 			continue
 		}
+		tracker.AddBytes(result.BytesRead)
 
 		// Track per-disk hash progress
 		if dp, ok := diskProgressMap[result.Disk]; ok {
 			atomic.AddInt64(&dp.FilesDone, 1)
 			atomic.AddInt64(&dp.BytesDone, result.Size)
 		}
+		if result.InFlight {
+			// Still being written: leave it for the next scan
+			logx.PathWarnf(result.Path, "changed while being hashed; not stored\n")
+			continue
+		}
 
 		now := time.Now()
 		record := &db.FileRecord{
@@ -690,79 +798,62 @@ func (r *Runner) runScan(ctx context.Context, opts ScanOptions, thermalCfg Therm
 			Disk:         result.Disk,
 			Size:         result.Size,
 			Mtime:        result.Mtime,
+			MtimeNsec:    result.MtimeNsec,
 			SHA256:       result.SHA256,
+			Algo:         result.Algo,
 			FirstSeen:    now,
 			LastVerified: now,
 			Status:       "ok",
 		}
 
 		// Move detection
+		var known bool
 		if lookupMap != nil {
-			if _, ok := lookupMap[result.Path]; !ok {
-				base := filepath.Base(result.Path)
-				cands, err := r.db.FindMoveCandidates(base, result.Size, 20)
-				if err == nil {
-					for _, cand := range cands {
-						if cand.Path == result.Path {
-							continue
-						}
-						_, statErr := os.Stat(cand.Path)
-						if statErr == nil || !os.IsNotExist(statErr) {
-							continue
-						}
-						if cand.SHA256 == result.SHA256 {
-							if err := r.db.MovePathTx(tx, cand.Path, result.Path, result.Disk, result.Size, result.Mtime); err != nil {
-								atomic.AddInt64(&totalErrors, 1)
-							}
-							record = nil
-							break
-						}
-						record.Status = "corrupted"
-						break
-					}
+			_, known = lookupMap[result.Path]
+		} else {
+			existing, err := r.db.GetFileByPath(result.Path)
+			known = err != nil || existing != nil
+		}
+		if !known {
+			record.Status = "new" // until its first verify, unless it was moved
+			base := filepath.Base(result.Path)
+			cands, err := r.db.FindMoveCandidates(base, result.Size, result.Algo, 0, 20)
+			if err == nil {
+				gone := func(p string) bool {
+					_, statErr := os.Stat(p)
+					return os.IsNotExist(statErr)
+				}
+				moved, suspect := db.PickMoveSource(cands, result.Path, result.SHA256, gone)
+				switch {
+				case moved != nil:
+					writer.Move(moved.Path, record)
+					record = nil
+				case suspect != nil:
+					record.Status = "corrupted"
 				}
 			}
 		}
 
 		if record != nil {
-			if err := r.db.UpsertFileTx(tx, record); err != nil {
-				atomic.AddInt64(&totalErrors, 1)
-			}
+			writer.Upsert(record)
 		}
 
-		batchCount++
-		if batchCount >= batchSize {
-			if err := tx.Commit(); err != nil {
-				if thermalCancel != nil {
-					thermalCancel()
-				}
-				if dndCancel != nil {
-					dndCancel()
-				}
-				r.finishOperation("error", atomic.LoadInt64(&totalProcessed), 0, atomic.LoadInt64(&totalErrors),
-					fmt.Sprintf("commit batch: %v", err), cloneDiskProgress(diskProgressList))
-				return
+		if err := writer.Err(); err != nil {
+			if thermalCancel != nil {
+				thermalCancel()
 			}
-			tx, txErr = r.db.BeginBatch()
-			if txErr != nil {
-				if thermalCancel != nil {
-					thermalCancel()
-				}
-				if dndCancel != nil {
-					dndCancel()
-				}
-				r.finishOperation("error", atomic.LoadInt64(&totalProcessed), 0, atomic.LoadInt64(&totalErrors),
-					fmt.Sprintf("begin new batch: %v", txErr), cloneDiskProgress(diskProgressList))
-				return
+			if dndCancel != nil {
+				dndCancel()
 			}
-			batchCount = 0
+			snap := tracker.Snapshot()
+			r.finishOperation("error", snap.Processed, 0, snap.Errors,
+				err.Error(), cloneDiskProgress(diskProgressList))
+			return
 		}
 
 		// Update progress periodically (every 50 files to reduce lock contention)
-		processed := atomic.LoadInt64(&totalProcessed)
-		if processed%50 == 0 {
-			errors := atomic.LoadInt64(&totalErrors)
-			skip := atomic.LoadInt64(&skipped)
+		if tracker.Processed()%50 == 0 {
+			snap := tracker.Snapshot()
 
 			// Mark disks as complete if all their files are done
 			for i := range diskProgressList {
@@ -773,10 +864,11 @@ func (r *Runner) runScan(ctx context.Context, opts ScanOptions, thermalCfg Therm
 			}
 
 			r.updateProgress(func(p *RunnerProgress) {
-				p.Done = processed
-				p.Errors = errors
+				p.Done = snap.Processed
+				p.Errors = snap.Errors
+				p.setThroughput(snap)
 				p.Disks = cloneDiskProgress(diskProgressList)
-				p.Message = fmt.Sprintf("Hashed %d files, skipped %d, %d errors", processed, skip, errors)
+				p.Message = fmt.Sprintf("Hashed %d files, skipped %d, %d errors", snap.Processed, snap.Skipped, snap.Errors)
 			})
 		}
 	}
@@ -793,17 +885,15 @@ func (r *Runner) runScan(ctx context.Context, opts ScanOptions, thermalCfg Therm
 	// Handle cancellation
 	if cancelled {
 		// Commit what we have so far
-		if batchCount > 0 {
-			tx.Commit()
-		}
-		finalProcessed := atomic.LoadInt64(&totalProcessed)
-		finalErrors := atomic.LoadInt64(&totalErrors)
-		finalSkipped := atomic.LoadInt64(&skipped)
+		writer.Close()
+		final := tracker.Snapshot()
+		finalProcessed, finalErrors, finalSkipped := final.Processed, final.Errors, final.Skipped
 		elapsed := time.Since(r.Progress().Started)
 
 		if scanID > 0 {
-			r.db.CompleteScanHistory(scanID, int(finalProcessed), int(finalErrors))
+			r.db.CompleteScanHistory(scanID, int(finalProcessed), int(finalErrors), final.BytesDone, elapsed)
 		}
+		r.recordSnapshot()
 
 		for i := range diskProgressList {
 			if diskProgressList[i].Phase != "complete" {
@@ -819,28 +909,27 @@ func (r *Runner) runScan(ctx context.Context, opts ScanOptions, thermalCfg Therm
 	}
 
 	// Commit remaining
-	if batchCount > 0 {
-		if err := tx.Commit(); err != nil {
-			r.finishOperation("error", atomic.LoadInt64(&totalProcessed), 0, atomic.LoadInt64(&totalErrors),
-				fmt.Sprintf("commit final batch: %v", err), cloneDiskProgress(diskProgressList))
-			return
-		}
+	if err := writer.Close(); err != nil {
+		snap := tracker.Snapshot()
+		r.finishOperation("error", snap.Processed, 0, snap.Errors,
+			err.Error(), cloneDiskProgress(diskProgressList))
+		return
 	}
 
-	finalProcessed := atomic.LoadInt64(&totalProcessed)
-	finalErrors := atomic.LoadInt64(&totalErrors)
-	finalSkipped := atomic.LoadInt64(&skipped)
+	final := tracker.Snapshot()
+	finalProcessed, finalErrors, finalSkipped := final.Processed, final.Errors, final.Skipped
+	elapsed := time.Since(r.Progress().Started)
 
 	if scanID > 0 {
-		r.db.CompleteScanHistory(scanID, int(finalProcessed), int(finalErrors))
+		r.db.CompleteScanHistory(scanID, int(finalProcessed), int(finalErrors), final.BytesDone, elapsed)
 	}
+	r.recordSnapshot()
 
 	// Mark all disks as complete
 	for i := range diskProgressList {
 		diskProgressList[i].Phase = "complete"
 	}
 
-	elapsed := time.Since(r.Progress().Started)
 	r.finishOperation("complete", finalProcessed, finalProcessed, finalErrors,
 		fmt.Sprintf("Scan complete: %d hashed, %d skipped, %d errors in %s",
 			finalProcessed, finalSkipped, finalErrors, elapsed.Round(time.Second)),
@@ -848,8 +937,42 @@ func (r *Runner) runScan(ctx context.Context, opts ScanOptions, thermalCfg Therm
 }
 
 func (r *Runner) runVerify(ctx context.Context, opts VerifyOptions, thermalCfg ThermalConfig, dndCfg DndConfig) {
-	scanID, _ := r.db.InsertScanHistory("verify", "")
+	// Per-disk file and byte totals for progress, counted by the catalog
+	// rather than by loading every record
+	diskStats, err := r.db.GetDiskStats(db.StatsOptions{})
+	if err != nil {
+		r.finishOperation("error", 0, 0, 0, fmt.Sprintf("count files: %v", err), nil)
+		return
+	}
+	diskFileCounts := make(map[string]int64, len(diskStats))
+	diskByteCounts := make(map[string]int64, len(diskStats))
+	var total, totalBytes int64
+	for _, ds := range diskStats {
+		if opts.Disk != "" && ds.Disk != opts.Disk {
+			continue
+		}
+		diskFileCounts[ds.Disk] = ds.TotalFiles
+		diskByteCounts[ds.Disk] = ds.TotalSize
+		total += ds.TotalFiles
+		totalBytes += ds.TotalSize
+	}
+	if opts.Disk != "" && total == 0 {
+		r.finishOperation("error", 0, 0, 0, fmt.Sprintf("no tracked files on %s", opts.Disk), nil)
+		return
+	}
+
+	algos, _ := r.db.HashAlgorithms()
+	scanID, _ := r.db.InsertScanHistory("verify", opts.Disk, appVersion, strings.Join(algos, ","))
 	v := verifier.New(r.db, opts.Workers, opts.Quick)
+	if opts.Workers <= 0 {
+		// Per-disk pipelines sized by disk type, as recorded by the last
+		// scan; probe /sys only if nothing is recorded yet
+		if types, err := r.db.DiskTypes(); err == nil && len(types) > 0 {
+			v.DiskWorkers = r.workers.ByType(types)
+		} else if disks, err := scanner.DetectUnraidDisks(); err == nil {
+			v.DiskWorkers = r.workers.ByDisk(disks)
+		}
+	}
 
 	// Start DnD monitor if enabled
 	dndState := newDndPauseState()
@@ -866,21 +989,6 @@ func (r *Runner) runVerify(ctx context.Context, opts VerifyOptions, thermalCfg T
 		}
 	}()
 
-	// Load files to build per-disk progress
-	allFiles, err := r.db.GetAllFiles()
-	if err != nil {
-		r.finishOperation("error", 0, 0, 0, fmt.Sprintf("load files: %v", err), nil)
-		return
-	}
-
-	// Build per-disk counters
-	diskFileCounts := make(map[string]int64)
-	diskByteCounts := make(map[string]int64)
-	for _, f := range allFiles {
-		diskFileCounts[f.Disk]++
-		diskByteCounts[f.Disk] += f.Size
-	}
-
 	// Create disk progress list
 	diskProgressList := make([]DiskProgress, 0, len(diskFileCounts))
 	diskProgressMap := make(map[string]*DiskProgress, len(diskFileCounts))
@@ -929,8 +1037,6 @@ func (r *Runner) runVerify(ctx context.Context, opts VerifyOptions, thermalCfg T
 		}
 	}()
 
-	total := int64(len(allFiles))
-
 	r.updateProgress(func(p *RunnerProgress) {
 		p.Phase = "verifying"
 		p.Total = total
@@ -938,19 +1044,16 @@ func (r *Runner) runVerify(ctx context.Context, opts VerifyOptions, thermalCfg T
 		p.Message = fmt.Sprintf("Verifying %d files across %d disks", total, len(diskFileCounts))
 	})
 
-	// Build lookup for per-disk byte tracking from the loaded file records
-	fileRecordMap := make(map[string]*db.FileRecord, len(allFiles))
-	for _, f := range allFiles {
-		fileRecordMap[f.Path] = f
-	}
+	tracker := progress.New()
+	tracker.AddTotal(total, totalBytes)
 
 	resultCb := func(vr verifier.VerifyResult) {
+		tracker.AddProcessed(1)
+		tracker.AddBytes(vr.Size)
 		// Track per-disk progress
-		if rec, ok := fileRecordMap[vr.Path]; ok {
-			if dp, ok2 := diskProgressMap[rec.Disk]; ok2 {
-				atomic.AddInt64(&dp.FilesDone, 1)
-				atomic.AddInt64(&dp.BytesDone, rec.Size)
-			}
+		if dp, ok := diskProgressMap[vr.Disk]; ok {
+			atomic.AddInt64(&dp.FilesDone, 1)
+			atomic.AddInt64(&dp.BytesDone, vr.Size)
 		}
 	}
 
@@ -964,13 +1067,19 @@ func (r *Runner) runVerify(ctx context.Context, opts VerifyOptions, thermalCfg T
 				p.Phase = "verifying"
 				p.Done = int64(done)
 				p.Total = int64(total)
+				p.setThroughput(tracker.Snapshot())
 				p.Disks = cloneDiskProgress(diskProgressList)
 				p.Message = fmt.Sprintf("Verified %d / %d files", done, total)
 			})
 		}
 	}
 
-	summary, err := v.VerifyAllContext(ctx, resultCb, progressCb)
+	var summary *verifier.Summary
+	if opts.Disk != "" {
+		summary, err = v.VerifyDiskContext(ctx, opts.Disk, resultCb, progressCb)
+	} else {
+		summary, err = v.VerifyAllContext(ctx, resultCb, progressCb)
+	}
 
 	if err != nil {
 		// Check if it was a cancellation
@@ -985,8 +1094,9 @@ func (r *Runner) runVerify(ctx context.Context, opts VerifyOptions, thermalCfg T
 			}
 
 			if scanID > 0 && summary != nil {
-				r.db.CompleteScanHistory(scanID, summary.TotalChecked, summary.Errors)
+				r.db.CompleteScanHistory(scanID, summary.TotalChecked, summary.Errors, summary.BytesHashed, summary.Duration)
 			}
+			r.recordSnapshot()
 
 			r.finishOperation("cancelled", finalDone, total, 0,
 				fmt.Sprintf("Verify cancelled: %d / %d checked in %s",
@@ -1000,19 +1110,35 @@ func (r *Runner) runVerify(ctx context.Context, opts VerifyOptions, thermalCfg T
 	}
 
 	if scanID > 0 {
-		r.db.CompleteScanHistory(scanID, summary.TotalChecked, summary.Errors)
+		r.db.CompleteScanHistory(scanID, summary.TotalChecked, summary.Errors, summary.BytesHashed, summary.Duration)
 	}
+	r.recordSnapshot()
 
 	// Mark all disks as complete
 	for i := range diskProgressList {
 		diskProgressList[i].Phase = "complete"
 	}
 
+	msg := fmt.Sprintf("Verify complete: %d checked, %d OK, %d corrupted, %d changed, %d unreadable, %d missing in %s",
+		summary.TotalChecked, summary.OK, summary.Corrupted, summary.Changed, summary.Unreadable, summary.Missing,
+		summary.Duration.Round(time.Second))
+	if len(summary.AbortedDisks) > 0 {
+		logx.Alertf("verify: disks appear offline/unreadable, statuses left unchanged: %s\n", strings.Join(summary.AbortedDisks, ", "))
+		msg += fmt.Sprintf(" — ALERT: %s appear offline/unreadable (statuses left unchanged)", strings.Join(summary.AbortedDisks, ", "))
+	}
+	if len(summary.DisksLikelyOffline) > 0 {
+		logx.Alertf("verify: disks likely offline (nearly every file missing), statuses left unchanged: %s\n", strings.Join(summary.DisksLikelyOffline, ", "))
+		msg += fmt.Sprintf(" — ALERT: %s likely offline, nearly every file missing (statuses left unchanged)", strings.Join(summary.DisksLikelyOffline, ", "))
+	}
 	r.finishOperation("complete", int64(summary.TotalChecked), int64(summary.TotalChecked), int64(summary.Errors),
-		fmt.Sprintf("Verify complete: %d checked, %d OK, %d corrupted, %d missing in %s",
-			summary.TotalChecked, summary.OK, summary.Corrupted, summary.Missing,
-			summary.Duration.Round(time.Second)),
-		cloneDiskProgress(diskProgressList))
+		msg, cloneDiskProgress(diskProgressList))
+}
+
+// recordSnapshot stores the current catalog totals for the stats history.
+func (r *Runner) recordSnapshot() {
+	if err := r.db.InsertStatsSnapshot(); err != nil {
+		logx.Warnf("record stats snapshot: %v\n", err)
+	}
 }
 
 // cloneDiskProgress creates a snapshot of the disk progress slice for safe concurrent access.
@@ -1098,11 +1224,11 @@ func (r *Runner) thermalMonitor(
 				if !isPaused && reading.Temp >= pauseTemp {
 					ts.pause()
 					dp.Paused = true
-					log.Printf("thermal: pausing %s at %d°C (threshold %d°C)", name, reading.Temp, pauseTemp)
+					logx.Infof("thermal: pausing %s at %d°C (threshold %d°C)\n", name, reading.Temp, pauseTemp)
 				} else if isPaused && reading.Temp <= resumeTemp {
 					ts.resume()
 					dp.Paused = false
-					log.Printf("thermal: resuming %s at %d°C (threshold %d°C)", name, reading.Temp, resumeTemp)
+					logx.Infof("thermal: resuming %s at %d°C (threshold %d°C)\n", name, reading.Temp, resumeTemp)
 				}
 			}
 
@@ -1127,13 +1253,13 @@ func (r *Runner) dndMonitor(ctx context.Context, cfg DndConfig, state *dndPauseS
 
 		if inWindow && !wasPaused {
 			state.pause()
-			log.Printf("dnd: entering Do Not Disturb window (%s-%s), pausing all operations", cfg.Start, cfg.End)
+			logx.Infof("dnd: entering Do Not Disturb window (%s-%s), pausing all operations\n", cfg.Start, cfg.End)
 			r.updateProgress(func(p *RunnerProgress) {
 				p.DnDPaused = true
 			})
 		} else if !inWindow && wasPaused {
 			state.resume()
-			log.Printf("dnd: leaving Do Not Disturb window (%s-%s), resuming operations", cfg.Start, cfg.End)
+			logx.Infof("dnd: leaving Do Not Disturb window (%s-%s), resuming operations\n", cfg.Start, cfg.End)
 			r.updateProgress(func(p *RunnerProgress) {
 				p.DnDPaused = false
 			})
@@ -282,3 +282,37 @@ func TestDiskThermalState_ContextCancellation(t *testing.T) {
 		t.Errorf("waitIfPaused with cancelled context: got %v, want context.Canceled", err)
 	}
 }
+
+// --- jobList tests ---
+
+func TestJobList_StartAndGet(t *testing.T) {
+	var l jobList
+	now := time.Now()
+	a := l.start("scan", "", now)
+	b := l.start("verify", "disk1", now)
+	if a.ID != 1 || b.ID != 2 {
+		t.Fatalf("IDs = %d, %d; want 1, 2", a.ID, b.ID)
+	}
+	if got := l.get(2); got != b || got.State != "running" || got.Disk != "disk1" {
+		t.Errorf("get(2) = %+v, want the running verify of disk1", got)
+	}
+	if l.get(3) != nil {
+		t.Error("get(3) should be nil for an unknown job")
+	}
+}
+
+func TestJobList_DropsOldest(t *testing.T) {
+	var l jobList
+	for i := 0; i < maxJobs+5; i++ {
+		l.start("verify", "", time.Now())
+	}
+	if len(l.jobs) != maxJobs {
+		t.Fatalf("kept %d jobs, want %d", len(l.jobs), maxJobs)
+	}
+	if l.get(5) != nil {
+		t.Error("job 5 should have been dropped")
+	}
+	if l.get(6) == nil || l.get(maxJobs+5) == nil {
+		t.Error("the newest maxJobs jobs should be kept")
+	}
+}
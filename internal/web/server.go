@@ -3,11 +3,13 @@ package web
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
-	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"strconv"
@@ -16,15 +18,89 @@ import (
 
 	"github.com/maisi/unraid-filehasher/internal/db"
 	"github.com/maisi/unraid-filehasher/internal/format"
+	"github.com/maisi/unraid-filehasher/internal/logx"
+	"github.com/maisi/unraid-filehasher/internal/oplock"
 )
 
 // appVersion is set by Serve() and injected into every template render.
 var appVersion string
 
-// Serve starts the web dashboard on the given address.
-func Serve(database *db.DB, addr string, version string, runner *Runner) error {
+// basePath is the URL prefix the dashboard is served under ("" for the
+// root), set by Serve() and injected into every template render.
+var basePath string
+
+// lockPath is the catalog's operation lock, set by Serve(). Pages show a
+// banner while another process holds it.
+var lockPath string
+
+// ShutdownTimeout bounds how long Serve waits for open requests to finish
+// once its context is cancelled.
+const ShutdownTimeout = 10 * time.Second
+
+// Search limits. Every search is a LIKE '%q%' scan over all paths, so queries
+// that would match nearly everything are refused, and each client gets a
+// small budget of searches (searchBurst at once, searchRate per second after).
+const (
+	minSearchLen = 2
+	maxSearchLen = 256
+	searchRate   = 1.0
+	searchBurst  = 10
+)
+
+// File list API limits. /api/files and friends return at most maxAPIFiles
+// records, defaultAPIFiles when ?limit= isn't given, and share a per-client
+// budget like search does.
+const (
+	defaultAPIFiles = 1000
+	maxAPIFiles     = 10000
+)
+
+// maxScanErrors caps the errors the History drill-down lists for one run.
+const maxScanErrors = 1000
+
+// Serve runs the web dashboard on ln until ctx is cancelled, then shuts the
+// server down gracefully and returns once open connections have drained (or
+// ShutdownTimeout passed).
+//
+// With a non-empty apiToken, the endpoints that start or stop operations
+// require "Authorization: Bearer <apiToken>". With a base path (see
+// CleanBasePath), every page and endpoint is served below it, for a reverse
+// proxy that forwards e.g. /filehasher/ unchanged.
+func Serve(ctx context.Context, database *db.DB, ln net.Listener, version string, runner *Runner, apiToken, base string) error {
+	base, err := CleanBasePath(base)
+	if err != nil {
+		return err
+	}
 	appVersion = version
+	basePath = base
+	if database.Path() != db.MemoryPath {
+		lockPath = oplock.Path(database.Path())
+	}
 
+	srv := &http.Server{
+		Handler: gzipResponses(newHandler(database, runner, apiToken)),
+		// Request contexts end with ctx, so long-lived progress streams
+		// return instead of holding up Shutdown.
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.Serve(ln) }()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutdown: %w", err)
+	}
+	return nil
+}
+
+// newHandler routes every page and endpoint, below basePath when one is set.
+func newHandler(database *db.DB, runner *Runner, apiToken string) http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/", handleOverview(database))
@@ -33,27 +109,83 @@ func Serve(database *db.DB, addr string, version string, runner *Runner) error {
 	mux.HandleFunc("/missing", handleMissing(database))
 	mux.HandleFunc("/ok", handleOK(database))
 	mux.HandleFunc("/new", handleNew(database))
+	mux.HandleFunc("/changed", handleChanged(database))
+	mux.HandleFunc("/unreadable", handleUnreadable(database))
 	mux.HandleFunc("/files", handleFiles(database))
-	mux.HandleFunc("/search", handleSearch(database))
+	mux.HandleFunc("/search", newRateLimiter(searchRate, searchBurst).limit(handleSearch(database)))
 	mux.HandleFunc("/history", handleHistory(database))
-	mux.HandleFunc("/settings", handleSettings())
+	mux.HandleFunc("/duplicates", handleDuplicates(database))
+	mux.HandleFunc("/extensions", handleExtensions(database))
+	mux.HandleFunc("/largest", handleLargest(database))
+	mux.HandleFunc("/settings", requireTokenToPost(apiToken, handleSettings()))
+	mux.HandleFunc("/ack", requireToken(apiToken, handleAck(database)))
 
 	// API endpoints (JSON)
 	mux.HandleFunc("/api/stats", handleAPIStats(database))
 	mux.HandleFunc("/api/disks", handleAPIDisks(database))
+	filesLimiter := newRateLimiter(searchRate, searchBurst)
+	mux.HandleFunc("/api/corrupted", filesLimiter.limit(handleAPIFiles(database, "corrupted")))
+	mux.HandleFunc("/api/missing", filesLimiter.limit(handleAPIFiles(database, "missing")))
+	mux.HandleFunc("/api/files", filesLimiter.limit(handleAPIFiles(database, "")))
+	mux.HandleFunc("/api/stale", handleAPIStale(database))
+	mux.HandleFunc("/api/history", handleAPIHistory(database))
+	mux.HandleFunc("/api/history/stats", handleAPIStatsHistory(database))
+	mux.HandleFunc("/api/history/errors", handleAPIScanErrors(database))
 
 	// Runner endpoints
 	if runner != nil {
-		mux.HandleFunc("/api/scan", handleAPIScan(runner))
-		mux.HandleFunc("/api/verify", handleAPIVerify(runner))
-		mux.HandleFunc("/api/stop", handleAPIStop(runner))
+		mux.HandleFunc("/api/scan", requireToken(apiToken, handleAPIScan(runner)))
+		mux.HandleFunc("/api/verify", requireToken(apiToken, handleAPIVerify(runner)))
+		mux.HandleFunc("/api/stop", requireToken(apiToken, handleAPIStop(runner)))
 		mux.HandleFunc("/api/progress", handleAPIProgress(runner))
+		mux.HandleFunc("/api/jobs/{id}", handleAPIJob(runner))
 	}
 
+	// Liveness/readiness probe for container healthchecks
+	mux.HandleFunc("/healthz", handleHealthz(database))
+
 	// Config endpoint (read-only, for JS options panel)
 	mux.HandleFunc("/api/config", handleAPIConfig())
 
-	return http.ListenAndServe(addr, mux)
+	var handler http.Handler = mux
+	if basePath != "" {
+		root := http.NewServeMux()
+		root.Handle(basePath+"/", http.StripPrefix(basePath, mux))
+		root.Handle(basePath, http.RedirectHandler(basePath+"/", http.StatusMovedPermanently))
+		handler = root
+	}
+	return handler
+}
+
+// CleanBasePath normalizes a --base-path to a leading slash and no trailing
+// one, e.g. "filehasher/" to "/filehasher". The root ("" or "/") is "".
+func CleanBasePath(p string) (string, error) {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return "", nil
+	}
+	if strings.ContainsAny(p, "?#{} ") {
+		return "", fmt.Errorf("%q is not a URL path (expected e.g. /filehasher)", p)
+	}
+	return "/" + p, nil
+}
+
+// handleHealthz answers 200 {"status":"ok","db":"reachable"} when the
+// database responds, and 503 otherwise. It's cheap enough to poll.
+func handleHealthz(database *db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := database.Ping(ctx); err != nil {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{"status": "error", "db": "unreachable", "error": err.Error()})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "ok", "db": "reachable"})
+	}
 }
 
 func handleOverview(database *db.DB) http.HandlerFunc {
@@ -62,12 +194,19 @@ func handleOverview(database *db.DB) http.HandlerFunc {
 			http.NotFound(w, r)
 			return
 		}
-		stats, err := database.GetStats()
+		// ?tag= narrows every figure to files scanned with scan --tag
+		tag := r.URL.Query().Get("tag")
+		stats, err := database.GetStats(db.StatsOptions{Duplicates: true, Tag: tag})
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
 		}
-		diskStats, err := database.GetDiskStats()
+		diskStats, err := database.GetDiskStats(db.StatsOptions{Tag: tag})
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		tags, err := database.GetTags()
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -76,8 +215,14 @@ func handleOverview(database *db.DB) http.HandlerFunc {
 		data := map[string]interface{}{
 			"Stats":     stats,
 			"DiskStats": diskStats,
+			"Tags":      tags,
+			"Tag":       tag,
 			"Page":      "overview",
 		}
+		// Average over recent runs that recorded how much they hashed
+		if bytes, dur, err := database.AverageThroughput(20); err == nil && bytes > 0 {
+			data["AvgThroughput"] = format.Rate(bytes, dur)
+		}
 		renderTemplate(w, "overview", data)
 	}
 }
@@ -86,7 +231,7 @@ func handleDisks(database *db.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		disk := r.URL.Query().Get("name")
 		if disk == "" {
-			diskStats, err := database.GetDiskStats()
+			diskStats, err := database.GetDiskStats(db.StatsOptions{})
 			if err != nil {
 				http.Error(w, err.Error(), 500)
 				return
@@ -126,11 +271,35 @@ func handleCorrupted(database *db.DB) http.HandlerFunc {
 			"Count":      len(files),
 			"Page":       "corrupted",
 			"StatusName": "Corrupted",
+			"Ackable":    true,
 		}
 		renderTemplate(w, "status_list", data)
 	}
 }
 
+// handleAck marks a corrupted file as acknowledged (POST path=...) and returns
+// to the corrupted list.
+func handleAck(database *db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		r.ParseForm()
+		path := r.FormValue("path")
+		if path == "" {
+			http.Error(w, "path is required", http.StatusBadRequest)
+			return
+		}
+		if err := database.AcknowledgeFile(path); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		logx.Infof("acknowledged corrupted file %s\n", path)
+		http.Redirect(w, r, basePath+"/corrupted", http.StatusSeeOther)
+	}
+}
+
 func handleMissing(database *db.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		files, err := database.GetFilesByStatus("missing")
@@ -182,6 +351,40 @@ func handleNew(database *db.DB) http.HandlerFunc {
 	}
 }
 
+func handleChanged(database *db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		files, err := database.GetFilesByStatus("changed")
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		data := map[string]interface{}{
+			"Files":      files,
+			"Count":      len(files),
+			"Page":       "changed",
+			"StatusName": "Changed",
+		}
+		renderTemplate(w, "status_list", data)
+	}
+}
+
+func handleUnreadable(database *db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		files, err := database.GetFilesByStatus("error")
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		data := map[string]interface{}{
+			"Files":      files,
+			"Count":      len(files),
+			"Page":       "unreadable",
+			"StatusName": "Unreadable",
+		}
+		renderTemplate(w, "status_list", data)
+	}
+}
+
 func handleFiles(database *db.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		page := 1
@@ -230,26 +433,176 @@ func handleSearch(database *db.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		query := r.URL.Query().Get("q")
 		var files []*db.FileRecord
-		var err error
+		var message string
 		if query != "" {
+			message = checkSearchQuery(query)
+		}
+		if query != "" && message == "" {
+			var err error
 			files, err = database.SearchFiles(query, 200)
 			if err != nil {
 				http.Error(w, err.Error(), 500)
 				return
 			}
 		}
+		if len(query) > maxSearchLen {
+			query = query[:maxSearchLen]
+		}
 		data := map[string]interface{}{
-			"Query": query,
-			"Files": files,
-			"Count": len(files),
-			"Page":  "search",
+			"Query":   query,
+			"Message": message,
+			"Files":   files,
+			"Count":   len(files),
+			"Page":    "search",
 		}
 		renderTemplate(w, "search", data)
 	}
 }
 
+// checkSearchQuery returns why q is refused, or "" if it may be searched.
+// LIKE wildcards and spaces don't count towards the minimum length, so "%%"
+// or "a_" can't be used to list the whole catalog.
+func checkSearchQuery(q string) string {
+	if len(q) > maxSearchLen {
+		return fmt.Sprintf("Search terms are limited to %d characters.", maxSearchLen)
+	}
+	literal := 0
+	for _, c := range q {
+		if !strings.ContainsRune("%_ \t", c) {
+			literal++
+		}
+	}
+	if literal < minSearchLen {
+		return fmt.Sprintf("Enter at least %d characters to search.", minSearchLen)
+	}
+	return ""
+}
+
+// duplicateSet is one row on the duplicates page.
+type duplicateSet struct {
+	Files  []*db.FileRecord
+	Size   int64
+	Wasted int64
+}
+
+func handleDuplicates(database *db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		minSize := int64(1024 * 1024)
+		if v := r.URL.Query().Get("min_size"); v != "" {
+			n, err := format.ParseSize(v)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			minSize = n
+		}
+
+		sets, err := database.FindDuplicates(2, minSize)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		var view []duplicateSet
+		var totalWasted int64
+		for _, set := range sets {
+			wasted := set[0].Size * int64(len(set)-1)
+			totalWasted += wasted
+			view = append(view, duplicateSet{Files: set, Size: set[0].Size, Wasted: wasted})
+		}
+
+		data := map[string]interface{}{
+			"Sets":        view,
+			"Count":       len(view),
+			"TotalWasted": totalWasted,
+			"MinSize":     format.Bytes(minSize),
+			"Page":        "duplicates",
+		}
+		renderTemplate(w, "duplicates", data)
+	}
+}
+
+func handleExtensions(database *db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats, err := database.GetExtensionStats()
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		data := map[string]interface{}{
+			"Extensions": stats,
+			"Count":      len(stats),
+			"Page":       "extensions",
+		}
+		renderTemplate(w, "extensions", data)
+	}
+}
+
+// handleLargest lists the biggest files, like report --largest. ?smallest=1
+// flips the order, ?disk= narrows to a disk and ?limit= sets how many
+// (default 100, at most 10000).
+func handleLargest(database *db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		limit := 100
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = min(n, 10000)
+		}
+		order := "desc"
+		smallest := q.Get("smallest") != ""
+		if smallest {
+			order = "asc"
+		}
+		disk := q.Get("disk")
+
+		files, err := database.GetFilesBySize(order, limit, disk)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		var total int64
+		for _, f := range files {
+			total += f.Size
+		}
+		data := map[string]interface{}{
+			"Files":     files,
+			"Count":     len(files),
+			"TotalSize": total,
+			"Disk":      disk,
+			"Smallest":  smallest,
+			"Page":      "largest",
+		}
+		renderTemplate(w, "largest", data)
+	}
+}
+
 func handleHistory(database *db.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if v := r.URL.Query().Get("id"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid id", http.StatusBadRequest)
+				return
+			}
+			errs, err := database.GetScanErrors(id, maxScanErrors)
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			data := map[string]interface{}{
+				"ScanID":    id,
+				"Errors":    errs,
+				"Truncated": len(errs) == maxScanErrors,
+				"Page":      "history",
+			}
+			renderTemplate(w, "scan_errors", data)
+			return
+		}
+
 		history, err := database.GetScanHistory(50)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
@@ -303,7 +656,7 @@ func defaultAppConfig() appConfig {
 		Mode:             "full",
 		ScanHddTwoPhase:  true,
 		ScanDiskType:     "auto",
-		VerifyWorkers:    4,
+		VerifyWorkers:    0, // per disk type
 		ThermalEnabled:   true,
 		ThermalPollSecs:  60,
 		ThermalHddPause:  55,
@@ -367,7 +720,7 @@ func readAppConfig() appConfig {
 
 		// Verify defaults
 		case "VERIFY_WORKERS":
-			if n, err := strconv.Atoi(val); err == nil && n > 0 {
+			if n, err := strconv.Atoi(val); err == nil && n >= 0 {
 				cfg.VerifyWorkers = n
 			}
 		case "VERIFY_QUICK":
@@ -546,6 +899,8 @@ func handleSettings() http.HandlerFunc {
 			}
 
 			// Verify defaults
+			// Empty means per disk type
+			cfg.VerifyWorkers = 0
 			if v := r.FormValue("verify_workers"); v != "" {
 				if n, err := strconv.Atoi(v); err == nil && n > 0 {
 					cfg.VerifyWorkers = n
@@ -617,7 +972,7 @@ func handleSettings() http.HandlerFunc {
 
 func handleAPIStats(database *db.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		stats, err := database.GetStats()
+		stats, err := database.GetStats(db.StatsOptions{Tag: r.URL.Query().Get("tag")})
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -630,7 +985,7 @@ func handleAPIStats(database *db.DB) http.HandlerFunc {
 
 func handleAPIDisks(database *db.DB) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		diskStats, err := database.GetDiskStats()
+		diskStats, err := database.GetDiskStats(db.StatsOptions{Tag: r.URL.Query().Get("tag")})
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 			return
@@ -641,6 +996,153 @@ func handleAPIDisks(database *db.DB) http.HandlerFunc {
 	}
 }
 
+// handleAPIFiles returns file records as JSON, like report --status --json.
+// A non-empty status fixes the status filter; otherwise ?status= picks one.
+// ?disk= narrows to a disk and ?limit= sets how many records (default
+// defaultAPIFiles, at most maxAPIFiles).
+func handleAPIFiles(database *db.DB, status string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		st := status
+		if st == "" {
+			st = q.Get("status")
+		}
+		limit := defaultAPIFiles
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = min(n, maxAPIFiles)
+		}
+
+		files, err := database.ListFiles(st, q.Get("disk"), limit)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if files == nil {
+			files = []*db.FileRecord{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		json.NewEncoder(w).Encode(files)
+	}
+}
+
+// handleAPIStale returns files not verified within ?age= (default 90d),
+// oldest first, optionally narrowed by ?disk= and capped by ?limit=.
+func handleAPIStale(database *db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		age := q.Get("age")
+		if age == "" {
+			age = "90d"
+		}
+		olderThan, err := format.ParseAge(age)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		limit := 0
+		if v := q.Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		files, err := database.GetStaleFiles(olderThan, q.Get("disk"), limit)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if files == nil {
+			files = []*db.FileRecord{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		json.NewEncoder(w).Encode(files)
+	}
+}
+
+// handleAPIScanErrors lists the errors recorded for one run, ?id= as in
+// /api/history.
+func handleAPIScanErrors(database *db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		errs, err := database.GetScanErrors(id, 0)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if errs == nil {
+			errs = []*db.ScanError{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		json.NewEncoder(w).Encode(errs)
+	}
+}
+
+// handleAPIHistory returns the last ?limit= scan/verify runs (default 50) as
+// JSON, the same rows the History page shows.
+func handleAPIHistory(database *db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 50
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > 10000 {
+				http.Error(w, "invalid limit (1-10000)", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		history, err := database.GetScanHistory(limit)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if history == nil {
+			history = []map[string]interface{}{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		json.NewEncoder(w).Encode(history)
+	}
+}
+
+// handleAPIStatsHistory returns stats snapshots for the last ?days= days (default 30).
+func handleAPIStatsHistory(database *db.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		days := 30
+		if v := r.URL.Query().Get("days"); v != "" {
+			if n, err := strconv.Atoi(v); err == nil && n > 0 && n <= 3650 {
+				days = n
+			}
+		}
+		snaps, err := database.GetStatsSnapshots(time.Now().AddDate(0, 0, -days))
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		if snaps == nil {
+			snaps = []*db.StatsSnapshot{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		json.NewEncoder(w).Encode(snaps)
+	}
+}
+
 func handleAPIScan(runner *Runner) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -661,6 +1163,7 @@ func handleAPIScan(runner *Runner) http.HandlerFunc {
 				FullScan       *bool     `json:"fullScan"`
 				HddTwoPhase    *bool     `json:"hddTwoPhase"`
 				DiskType       *string   `json:"diskType"`
+				Disk           *string   `json:"disk"`
 			}
 			if err := json.NewDecoder(r.Body).Decode(&body); err == nil {
 				if body.Excludes != nil {
@@ -678,17 +1181,25 @@ func handleAPIScan(runner *Runner) http.HandlerFunc {
 				if body.DiskType != nil {
 					opts.DiskType = *body.DiskType
 				}
+				if body.Disk != nil {
+					opts.Disk = *body.Disk
+				}
 			}
 		}
 
-		if err := runner.StartScan(opts, thermal, cfg.toDndConfig()); err != nil {
+		if d := r.URL.Query().Get("disk"); d != "" {
+			opts.Disk = d
+		}
+
+		id, err := runner.StartScan(opts, thermal, cfg.toDndConfig())
+		if err != nil {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusConflict)
 			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"status": "started"})
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "started", "id": id})
 	}
 }
 
@@ -707,8 +1218,9 @@ func handleAPIVerify(runner *Runner) http.HandlerFunc {
 		// Parse optional JSON overrides from request body
 		if r.Body != nil && r.ContentLength > 0 {
 			var body struct {
-				Workers *int  `json:"workers"`
-				Quick   *bool `json:"quick"`
+				Workers *int    `json:"workers"`
+				Quick   *bool   `json:"quick"`
+				Disk    *string `json:"disk"`
 			}
 			if err := json.NewDecoder(r.Body).Decode(&body); err == nil {
 				if body.Workers != nil && *body.Workers > 0 {
@@ -717,17 +1229,25 @@ func handleAPIVerify(runner *Runner) http.HandlerFunc {
 				if body.Quick != nil {
 					opts.Quick = *body.Quick
 				}
+				if body.Disk != nil {
+					opts.Disk = *body.Disk
+				}
 			}
 		}
 
-		if err := runner.StartVerify(opts, thermal, cfg.toDndConfig()); err != nil {
+		if d := r.URL.Query().Get("disk"); d != "" {
+			opts.Disk = d
+		}
+
+		id, err := runner.StartVerify(opts, thermal, cfg.toDndConfig())
+		if err != nil {
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusConflict)
 			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"status": "started"})
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "started", "id": id})
 	}
 }
 
@@ -752,6 +1272,25 @@ func handleAPIConfig() http.HandlerFunc {
 	}
 }
 
+// handleAPIJob reports a job started through /api/scan or /api/verify.
+func handleAPIJob(runner *Runner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid job id", http.StatusBadRequest)
+			return
+		}
+		job, ok := runner.Job(id)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		json.NewEncoder(w).Encode(job)
+	}
+}
+
 func handleAPIStop(runner *Runner) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -811,7 +1350,11 @@ func handleAPIProgress(runner *Runner) http.HandlerFunc {
 
 // templateFuncMap is the shared FuncMap used across all templates.
 var templateFuncMap = template.FuncMap{
-	"formatBytes": format.Size,
+	"formatBytes": format.Bytes,
+	"sizeUnits":   format.UnitsName,
+	"formatRate": func(bytes int64, seconds float64) string {
+		return format.Rate(bytes, time.Duration(seconds*float64(time.Second)))
+	},
 	"formatTime": func(t *time.Time) string {
 		if t == nil {
 			return "Never"
@@ -836,12 +1379,44 @@ var templateFuncMap = template.FuncMap{
 			return "status-ok"
 		case "corrupted":
 			return "status-corrupted"
+		case "changed":
+			return "status-changed"
+		case "error":
+			return "status-error"
 		case "missing":
 			return "status-missing"
+		case "acknowledged":
+			return "status-acknowledged"
 		default:
 			return "status-unknown"
 		}
 	},
+	"healthClass": func(health string) string {
+		switch health {
+		case "good":
+			return "status-ok"
+		case "degraded":
+			return "status-missing"
+		default:
+			return "status-corrupted"
+		}
+	},
+	"daysSince": func(t *time.Time) int {
+		if t == nil {
+			return 0
+		}
+		return int(time.Since(*t).Hours() / 24)
+	},
+	"coverageClass": func(pct float64) string {
+		switch {
+		case pct >= 90:
+			return "bar-success"
+		case pct >= 50:
+			return "bar-warning"
+		default:
+			return "bar-danger"
+		}
+	},
 	"unixTime": func(t *time.Time) int64 {
 		if t == nil {
 			return 0
@@ -860,6 +1435,24 @@ var templateFuncMap = template.FuncMap{
 		}
 		return time.Unix(mtime, 0).Format("2006-01-02 15:04:05")
 	},
+	// cls and diskURL style and link partialTemplates; the summary mail
+	// swaps in inline styles and no links
+	"cls": func(classes string) template.HTMLAttr {
+		return template.HTMLAttr(`class="` + template.HTMLEscapeString(classes) + `"`)
+	},
+	"diskURL": func(disk string) string {
+		return basePath + "/disks?name=" + url.QueryEscape(disk)
+	},
+}
+
+// TemplateFuncs returns a copy of the functions dashboard templates can
+// call, for text templates elsewhere (report --template) to offer the same.
+func TemplateFuncs() template.FuncMap {
+	funcs := make(template.FuncMap, len(templateFuncMap))
+	for name, fn := range templateFuncMap {
+		funcs[name] = fn
+	}
+	return funcs
 }
 
 // cachedTemplates holds parsed templates, keyed by content template name.
@@ -871,6 +1464,7 @@ func init() {
 		tmpl := template.Must(
 			template.New("page").Funcs(templateFuncMap).Parse(baseTemplate),
 		)
+		template.Must(tmpl.Parse(partialTemplates))
 		template.Must(tmpl.Parse(contentTmpl))
 		cachedTemplates[name] = tmpl
 	}
@@ -883,13 +1477,19 @@ func renderTemplate(w http.ResponseWriter, name string, data map[string]interfac
 		return
 	}
 
-	// Inject version into every render
+	// Inject version and base path into every render
 	data["Version"] = appVersion
+	data["BasePath"] = basePath
+	if lockPath != "" {
+		if h := oplock.Holder(lockPath); h != nil && h.PID != os.Getpid() {
+			data["Lock"] = h
+		}
+	}
 
 	// Buffer template output so errors don't result in partial HTML responses
 	var buf bytes.Buffer
 	if err := tmpl.Execute(&buf, data); err != nil {
-		log.Printf("template render error (%s): %v", name, err)
+		logx.Errorf("template render error (%s): %v\n", name, err)
 		http.Error(w, "internal server error", 500)
 		return
 	}
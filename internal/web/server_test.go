@@ -0,0 +1,166 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maisi/unraid-filehasher/internal/db"
+)
+
+func TestCheckSearchQuery(t *testing.T) {
+	tests := []struct {
+		q    string
+		want string // substring of the refusal, "" if allowed
+	}{
+		{"ab", ""},
+		{"movie", ""},
+		{"a_b", ""},
+		{"a", "at least 2"},
+		{"%%", "at least 2"},
+		{"a_", "at least 2"},
+		{" % _\t", "at least 2"},
+		{strings.Repeat("x", maxSearchLen), ""},
+		{strings.Repeat("x", maxSearchLen+1), "limited to 256"},
+	}
+	for _, tt := range tests {
+		got := checkSearchQuery(tt.q)
+		if tt.want == "" && got != "" {
+			t.Errorf("checkSearchQuery(%q) = %q, want allowed", tt.q, got)
+		}
+		if tt.want != "" && !strings.Contains(got, tt.want) {
+			t.Errorf("checkSearchQuery(%q) = %q, want it to mention %q", tt.q, got, tt.want)
+		}
+	}
+}
+
+func TestAPIFilesLimit(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "test.db"), db.Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	total := defaultAPIFiles + 5
+	tx, err := database.BeginBatch()
+	if err != nil {
+		t.Fatalf("BeginBatch: %v", err)
+	}
+	now := time.Now()
+	for i := 0; i < total; i++ {
+		if err := database.UpsertFileTx(tx, &db.FileRecord{
+			Path:         fmt.Sprintf("/mnt/disk1/f%05d", i),
+			Disk:         "disk1",
+			Size:         1,
+			SHA256:       "aa",
+			FirstSeen:    now,
+			LastVerified: now,
+			Status:       "ok",
+		}); err != nil {
+			t.Fatalf("UpsertFileTx: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+
+	h := handleAPIFiles(database, "")
+	tests := []struct {
+		query  string
+		status int
+		count  int
+	}{
+		{"", http.StatusOK, defaultAPIFiles},
+		{"?limit=10", http.StatusOK, 10},
+		{fmt.Sprintf("?limit=%d", maxAPIFiles+1), http.StatusOK, total},
+		{"?limit=0", http.StatusBadRequest, 0},
+		{"?limit=-1", http.StatusBadRequest, 0},
+		{"?limit=abc", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest("GET", "/api/files"+tt.query, nil))
+		if rec.Code != tt.status {
+			t.Errorf("/api/files%s: status %d, want %d", tt.query, rec.Code, tt.status)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var files []*db.FileRecord
+		if err := json.Unmarshal(rec.Body.Bytes(), &files); err != nil {
+			t.Fatalf("/api/files%s: decode: %v", tt.query, err)
+		}
+		if len(files) != tt.count {
+			t.Errorf("/api/files%s: %d records, want %d", tt.query, len(files), tt.count)
+		}
+	}
+}
+
+func TestBasePath(t *testing.T) {
+	database, err := db.Open(filepath.Join(t.TempDir(), "test.db"), db.Options{})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	basePath = "/filehasher"
+	t.Cleanup(func() { basePath = "" })
+	srv := httptest.NewServer(newHandler(database, nil, ""))
+	t.Cleanup(srv.Close)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	tests := []struct {
+		path     string
+		want     int
+		location string
+	}{
+		{"/filehasher/disks", http.StatusOK, ""},
+		{"/filehasher/", http.StatusOK, ""},
+		{"/filehasher", http.StatusMovedPermanently, "/filehasher/"},
+		{"/disks", http.StatusNotFound, ""},
+		{"/", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		resp, err := client.Get(srv.URL + tt.path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", tt.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("GET %s = %d, want %d", tt.path, resp.StatusCode, tt.want)
+		}
+		if loc := resp.Header.Get("Location"); loc != tt.location {
+			t.Errorf("GET %s redirects to %q, want %q", tt.path, loc, tt.location)
+		}
+	}
+}
+
+func TestCleanBasePath(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"", "", false},
+		{"/", "", false},
+		{"filehasher", "/filehasher", false},
+		{"/filehasher/", "/filehasher", false},
+		{"/apps/filehasher", "/apps/filehasher", false},
+		{"a b", "", true},
+		{"x?y", "", true},
+		{"/x#y", "", true},
+	}
+	for _, tt := range tests {
+		got, err := CleanBasePath(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("CleanBasePath(%q) = %q, %v; want %q, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -89,6 +89,7 @@ var baseTemplate = `<!DOCTYPE html>
         .stat-card.danger .value { color: #f85149; }
         .stat-card.warning .value { color: #d29922; }
         .stat-card.success .value { color: #3fb950; }
+        .stat-card.info .value { color: #58a6ff; }
         
         /* Tables */
         table {
@@ -128,7 +129,10 @@ var baseTemplate = `<!DOCTYPE html>
         /* Status badges */
         .status-ok { color: #3fb950; }
         .status-corrupted { color: #f85149; font-weight: 700; }
+        .status-changed { color: #58a6ff; }
+        .status-error { color: #db6d28; }
         .status-missing { color: #d29922; }
+        .status-acknowledged { color: #a371f7; }
         .status-unknown { color: #8b949e; }
         
         /* Search */
@@ -210,6 +214,18 @@ var baseTemplate = `<!DOCTYPE html>
         .progress-bar.bar-warning { background: #d29922; }
         .progress-bar.bar-danger { background: #f85149; }
 
+        /* Verify coverage */
+        .coverage-row {
+            display: grid;
+            grid-template-columns: 110px 1fr 130px;
+            align-items: center;
+            gap: 12px;
+            margin-bottom: 8px;
+        }
+        .coverage-label { font-size: 13px; color: #8b949e; }
+        .coverage-pct { font-size: 13px; text-align: right; }
+        .coverage-note { font-size: 13px; color: #8b949e; margin-top: 8px; }
+
         /* Result banner */
         .result-banner {
             padding: 12px 16px;
@@ -419,21 +435,29 @@ var baseTemplate = `<!DOCTYPE html>
 <body>
     <nav>
         <div class="container">
-            <a href="/" class="logo">filehasher</a>
-            <a href="/" {{if eq .Page "overview"}}class="active"{{end}}>Overview</a>
-            <a href="/disks" {{if eq .Page "disks"}}class="active"{{end}}>Disks</a>
-            <a href="/ok" {{if eq .Page "ok"}}class="active"{{end}}>OK</a>
-            <a href="/new" {{if eq .Page "new"}}class="active"{{end}}>New</a>
-            <a href="/corrupted" {{if eq .Page "corrupted"}}class="active"{{end}}>Corrupted</a>
-            <a href="/missing" {{if eq .Page "missing"}}class="active"{{end}}>Missing</a>
-            <a href="/search" {{if eq .Page "search"}}class="active"{{end}}>Search</a>
-            <a href="/files" {{if eq .Page "files"}}class="active"{{end}}>All Files</a>
-            <a href="/history" {{if eq .Page "history"}}class="active"{{end}}>History</a>
-            <a href="/settings" {{if eq .Page "settings"}}class="active"{{end}}>Settings</a>
+            <a href="{{$.BasePath}}/" class="logo">filehasher</a>
+            <a href="{{$.BasePath}}/" {{if eq .Page "overview"}}class="active"{{end}}>Overview</a>
+            <a href="{{$.BasePath}}/disks" {{if eq .Page "disks"}}class="active"{{end}}>Disks</a>
+            <a href="{{$.BasePath}}/ok" {{if eq .Page "ok"}}class="active"{{end}}>OK</a>
+            <a href="{{$.BasePath}}/new" {{if eq .Page "new"}}class="active"{{end}}>New</a>
+            <a href="{{$.BasePath}}/corrupted" {{if eq .Page "corrupted"}}class="active"{{end}}>Corrupted</a>
+            <a href="{{$.BasePath}}/changed" {{if eq .Page "changed"}}class="active"{{end}}>Changed</a>
+            <a href="{{$.BasePath}}/unreadable" {{if eq .Page "unreadable"}}class="active"{{end}}>Unreadable</a>
+            <a href="{{$.BasePath}}/missing" {{if eq .Page "missing"}}class="active"{{end}}>Missing</a>
+            <a href="{{$.BasePath}}/duplicates" {{if eq .Page "duplicates"}}class="active"{{end}}>Duplicates</a>
+            <a href="{{$.BasePath}}/extensions" {{if eq .Page "extensions"}}class="active"{{end}}>Extensions</a>
+            <a href="{{$.BasePath}}/largest" {{if eq .Page "largest"}}class="active"{{end}}>Largest</a>
+            <a href="{{$.BasePath}}/search" {{if eq .Page "search"}}class="active"{{end}}>Search</a>
+            <a href="{{$.BasePath}}/files" {{if eq .Page "files"}}class="active"{{end}}>All Files</a>
+            <a href="{{$.BasePath}}/history" {{if eq .Page "history"}}class="active"{{end}}>History</a>
+            <a href="{{$.BasePath}}/settings" {{if eq .Page "settings"}}class="active"{{end}}>Settings</a>
             {{if .Version}}<span class="text-muted" style="margin-left:auto;font-size:12px;">v{{.Version}}</span>{{end}}
         </div>
     </nav>
     <div class="container">
+        {{with .Lock}}
+        <div class="result-banner banner-cancelled" style="margin-top:16px;">A {{.Operation}} started outside the dashboard is running (pid {{.PID}}, since {{formatTimeVal .Started}}). Scans and verifies started here will be refused until it finishes.</div>
+        {{end}}
         {{template "content" .}}
     </div>
     <script>
@@ -476,15 +500,28 @@ var baseTemplate = `<!DOCTYPE html>
     </script>
     <script>
     // --- Utility functions ---
+    // Same unit system as the server-side formatBytes (--units)
+    var sizeUnits = "{{sizeUnits}}";
+    // Prefix for links and API calls (server --base-path)
+    var basePath = "{{.BasePath}}";
     function formatBytes(bytes) {
         if (bytes === 0) return "0 B";
-        var units = ["B", "KB", "MB", "GB", "TB"];
+        var si = sizeUnits === "si";
+        var base = si ? 1000 : 1024;
+        var units = si ? ["B", "KB", "MB", "GB", "TB"] : ["B", "KiB", "MiB", "GiB", "TiB"];
         var i = 0;
         var v = bytes;
-        while (v >= 1024 && i < units.length - 1) { v /= 1024; i++; }
+        while (v >= base && i < units.length - 1) { v /= base; i++; }
         return v.toFixed(i === 0 ? 0 : 1) + " " + units[i];
     }
 
+    function formatETA(seconds) {
+        var h = Math.floor(seconds / 3600);
+        var m = Math.floor((seconds % 3600) / 60);
+        var s = Math.floor(seconds % 60);
+        return (h < 10 ? "0" : "") + h + ":" + (m < 10 ? "0" : "") + m + ":" + (s < 10 ? "0" : "") + s;
+    }
+
     function formatElapsed(seconds) {
         var h = Math.floor(seconds / 3600);
         var m = Math.floor((seconds % 3600) / 60);
@@ -499,6 +536,47 @@ var baseTemplate = `<!DOCTYPE html>
     var elapsedTimer = null;
     var opStartTime = null;
 
+    // apiPost sends a POST to a runner endpoint. When the server was started
+    // with --api-token it answers 401 until the token is sent, so ask for it
+    // once and keep it in this browser.
+    function apiPost(url, body, headers) {
+        var token = localStorage.getItem("filehasherToken");
+        if (token) headers["Authorization"] = "Bearer " + token;
+        return fetch(url, {method: "POST", body: body, headers: headers}).then(function(r) {
+            if (r.status !== 401) return r;
+            localStorage.removeItem("filehasherToken");
+            token = prompt("API token (filehasher server --api-token):");
+            if (!token) throw "an API token is required";
+            localStorage.setItem("filehasherToken", token);
+            headers["Authorization"] = "Bearer " + token;
+            return fetch(url, {method: "POST", body: body, headers: headers}).then(function(r2) {
+                if (r2.status === 401) {
+                    localStorage.removeItem("filehasherToken");
+                    throw "wrong API token";
+                }
+                return r2;
+            });
+        });
+    }
+
+    // Forms that change state (acknowledge, settings) post through apiPost
+    // as well, so they carry the token, and show the page the server answers
+    // with.
+    document.querySelectorAll("form[data-token]").forEach(function(form) {
+        form.addEventListener("submit", function(e) {
+            if (e.defaultPrevented) return; // e.g. a declined confirm()
+            e.preventDefault();
+            apiPost(form.action, new URLSearchParams(new FormData(form)), {}).then(function(r) {
+                return r.text().then(function(html) {
+                    history.replaceState(null, "", r.url);
+                    document.open();
+                    document.write(html);
+                    document.close();
+                });
+            }).catch(function(err) { alert(err); });
+        });
+    });
+
     function startOp(type) {
         var btn = document.getElementById("btn-" + type);
         if (btn) btn.disabled = true;
@@ -530,12 +608,14 @@ var baseTemplate = `<!DOCTYPE html>
                 if (wEl && wEl.value) opts.workers = parseInt(wEl.value, 10);
                 var qEl = document.getElementById("opt-quick");
                 if (qEl) opts.quick = qEl.checked;
+                var dEl = document.getElementById("opt-verify-disk");
+                if (dEl && dEl.value) opts.disk = dEl.value;
                 body = JSON.stringify(opts);
                 headers["Content-Type"] = "application/json";
             }
         }
 
-        fetch("/api/" + type, {method: "POST", body: body, headers: headers})
+        apiPost(basePath + "/api/" + type, body, headers)
             .then(function(r) { return r.json(); })
             .then(function(d) {
                 if (d.error) { alert(d.error); if (btn) btn.disabled = false; }
@@ -547,7 +627,7 @@ var baseTemplate = `<!DOCTYPE html>
     function stopOp() {
         var btn = document.getElementById("btn-stop");
         if (btn) btn.disabled = true;
-        fetch("/api/stop", {method: "POST"})
+        apiPost(basePath + "/api/stop", null, {})
             .then(function(r) { return r.json(); })
             .then(function(d) {
                 if (d.error) { alert(d.error); if (btn) btn.disabled = false; }
@@ -637,7 +717,7 @@ var baseTemplate = `<!DOCTYPE html>
         if (section) section.style.display = "block";
         if (banner) banner.style.display = "none";
 
-        evtSource = new EventSource("/api/progress");
+        evtSource = new EventSource(basePath + "/api/progress");
         evtSource.onmessage = function(e) {
             var p = JSON.parse(e.data);
             var barEl = document.getElementById("progress-bar");
@@ -689,13 +769,18 @@ var baseTemplate = `<!DOCTYPE html>
                     }
                 }
 
-                // Speed calculation
-                if (speedEl && opStartTime && doneBytes > 0) {
-                    var elapsedSec = (Date.now() - opStartTime) / 1000;
-                    if (elapsedSec > 0) {
-                        var speed = doneBytes / elapsedSec;
-                        speedEl.textContent = formatBytes(speed) + "/s";
+                // Rate and ETA come from the server's progress tracker
+                if (speedEl && p.rate > 0) {
+                    var text = formatBytes(p.rate) + "/s";
+                    // Totals only stop growing once every disk has finished walking
+                    var walking = false;
+                    for (var j = 0; j < p.disks.length; j++) {
+                        if (p.disks[j].phase === "walking") walking = true;
                     }
+                    if (!walking && p.eta > 0) {
+                        text += " \u00b7 ETA " + formatETA(p.eta);
+                    }
+                    speedEl.textContent = text;
                 } else if (speedEl) {
                     speedEl.textContent = "";
                 }
@@ -805,7 +890,7 @@ var baseTemplate = `<!DOCTYPE html>
     }
 
     function loadDefaults() {
-        fetch("/api/config")
+        fetch(basePath + "/api/config")
             .then(function(r) { return r.json(); })
             .then(function(cfg) {
                 // Scan defaults
@@ -840,34 +925,46 @@ var baseTemplate = `<!DOCTYPE html>
 
 var templates = map[string]string{
 	"overview": `{{define "content"}}
+{{if .Tags}}
+<div style="margin-bottom: 16px;">
+    <a href="{{$.BasePath}}/" class="btn {{if not .Tag}}btn-primary{{end}}">All files</a>
+    {{range .Tags}}<a href="{{$.BasePath}}/?tag={{.}}" class="btn {{if eq . $.Tag}}btn-primary{{end}}">{{.}}</a> {{end}}
+</div>
+{{end}}
 <div class="stats-grid">
+    {{template "statCards" .Stats}}
+    {{if gt .Stats.ReclaimableBytes 0}}
     <div class="stat-card">
-        <div class="value">{{.Stats.TotalFiles}}</div>
-        <div class="label">Total Files</div>
+        <div class="value"><a href="{{$.BasePath}}/duplicates?min_size=0">{{formatBytes .Stats.ReclaimableBytes}}</a></div>
+        <div class="label">Reclaimable ({{.Stats.DuplicateFiles}} duplicates)</div>
     </div>
+    {{end}}
+    {{if .AvgThroughput}}
     <div class="stat-card">
-        <div class="value">{{formatBytes .Stats.TotalSize}}</div>
-        <div class="label">Total Size</div>
-    </div>
-    <div class="stat-card success">
-        <div class="value">{{.Stats.OKFiles}}</div>
-        <div class="label">OK</div>
-    </div>
-    <div class="stat-card {{if gt .Stats.CorruptedFiles 0}}danger{{end}}">
-        <div class="value">{{.Stats.CorruptedFiles}}</div>
-        <div class="label">Corrupted</div>
+        <div class="value">{{.AvgThroughput}}</div>
+        <div class="label">Avg Throughput</div>
     </div>
-    <div class="stat-card {{if gt .Stats.MissingFiles 0}}warning{{end}}">
-        <div class="value">{{.Stats.MissingFiles}}</div>
-        <div class="label">Missing</div>
+    {{end}}
+</div>
+
+{{if gt .Stats.TotalFiles .Stats.MissingFiles}}
+<div class="card">
+    <h2>Verify Coverage</h2>
+    <div class="coverage-row">
+        <span class="coverage-label">Last 30 days</span>
+        <div class="progress-bar-container"><div class="progress-bar {{coverageClass .Stats.Coverage30d}}" style="width:{{printf "%.1f" .Stats.Coverage30d}}%"></div></div>
+        <span class="coverage-pct">{{printf "%.1f" .Stats.Coverage30d}}% ({{.Stats.Verified30d}})</span>
     </div>
-    {{if gt .Stats.NewFiles 0}}
-    <div class="stat-card">
-        <div class="value">{{.Stats.NewFiles}}</div>
-        <div class="label">New</div>
+    <div class="coverage-row">
+        <span class="coverage-label">Last 90 days</span>
+        <div class="progress-bar-container"><div class="progress-bar {{coverageClass .Stats.Coverage90d}}" style="width:{{printf "%.1f" .Stats.Coverage90d}}%"></div></div>
+        <span class="coverage-pct">{{printf "%.1f" .Stats.Coverage90d}}% ({{.Stats.Verified90d}})</span>
     </div>
+    {{if .Stats.OldestVerified}}
+    <p class="coverage-note">Oldest verification: {{formatTime .Stats.OldestVerified}} ({{daysSince .Stats.OldestVerified}} days ago) &middot; <a href="{{$.BasePath}}/api/stale?age=90d&amp;limit=100">files not verified in 90 days</a></p>
     {{end}}
 </div>
+{{end}}
 
 <div class="card">
     <h2>Actions</h2>
@@ -920,8 +1017,8 @@ var templates = map[string]string{
         <!-- Verify options tab -->
         <div id="opt-tab-verify" class="options-grid" style="display:none;">
             <div class="opt-group">
-                <label>Worker Threads</label>
-                <input type="number" id="opt-workers" min="1" max="64" value="4" style="max-width:80px;">
+                <label>Workers per Disk</label>
+                <input type="number" id="opt-workers" min="1" max="64" placeholder="auto" style="max-width:80px;">
             </div>
             <div style="padding-top:18px;">
                 <label class="opt-check">
@@ -929,6 +1026,14 @@ var templates = map[string]string{
                     <span>Quick verify</span>
                 </label>
             </div>
+            <div class="opt-group">
+                <label>Disk</label>
+                <select id="opt-verify-disk">
+                    <option value="">All disks</option>
+                    {{range .DiskStats}}<option value="{{.Disk}}">{{.Disk}}</option>
+                    {{end}}
+                </select>
+            </div>
         </div>
     </div>
     <div id="result-banner" class="result-banner" style="display:none;"></div>
@@ -953,70 +1058,114 @@ var templates = map[string]string{
 
 <div class="card">
     <h2>Scan Information</h2>
-    <table>
-        <tr><td>Last Scan</td><td>{{formatTime .Stats.LastScan}}</td></tr>
-        <tr><td>Last Verify</td><td>{{formatTime .Stats.LastVerify}}</td></tr>
-    </table>
+    {{template "scanTimes" .Stats}}
 </div>
 
 {{if .DiskStats}}
 <div class="card">
     <h2>Disk Breakdown</h2>
+    {{template "diskTable" .DiskStats}}
+</div>
+{{end}}
+{{end}}`,
+
+	"disks": `{{define "content"}}
+<div class="card">
+    <h2>All Disks</h2>
+    {{template "diskTable" .DiskStats}}
+</div>
+{{end}}`,
+
+	"duplicates": `{{define "content"}}
+<div class="card">
+    <h2>Duplicates — {{.Count}} sets, {{formatBytes .TotalWasted}} wasted</h2>
+    <p class="text-muted">Files of at least {{.MinSize}} sharing the same hash and size. Change the threshold with <span class="mono">?min_size=100M</span>.</p>
+    {{if .Sets}}
     <table>
         <thead>
             <tr>
-                <th>Disk</th>
-                <th class="text-right">Files</th>
+                <th class="text-right">Copies</th>
                 <th class="text-right">Size</th>
-                <th class="text-right">Corrupted</th>
-                <th class="text-right">Missing</th>
-                <th>Last Verified</th>
+                <th class="text-right">Wasted</th>
+                <th>SHA-256</th>
+                <th>Paths</th>
             </tr>
         </thead>
         <tbody>
-            {{range .DiskStats}}
+            {{range .Sets}}
             <tr>
-                <td><a href="/disks?name={{.Disk}}" class="disk-link">{{.Disk}}</a></td>
-                <td class="text-right">{{.TotalFiles}}</td>
-                <td class="text-right" data-sort-value="{{.TotalSize}}">{{formatBytes .TotalSize}}</td>
-                <td class="text-right {{if gt .CorruptedFiles 0}}status-corrupted{{end}}">{{.CorruptedFiles}}</td>
-                <td class="text-right {{if gt .MissingFiles 0}}status-missing{{end}}">{{.MissingFiles}}</td>
-                <td class="text-muted" data-sort-value="{{unixTime .LastVerified}}">{{formatTime .LastVerified}}</td>
+                <td class="text-right">{{len .Files}}</td>
+                <td class="text-right" data-sort-value="{{.Size}}">{{formatBytes .Size}}</td>
+                <td class="text-right" data-sort-value="{{.Wasted}}">{{formatBytes .Wasted}}</td>
+                <td class="mono">{{truncHash (index .Files 0).SHA256}}</td>
+                <td class="path-cell mono">{{range .Files}}<a href="{{$.BasePath}}/disks?name={{.Disk}}" class="disk-link">{{.Disk}}</a> {{.Path}}<br>{{end}}</td>
             </tr>
             {{end}}
         </tbody>
     </table>
+    {{else}}
+    <p class="text-muted">No duplicate files found.</p>
+    {{end}}
 </div>
-{{end}}
 {{end}}`,
 
-	"disks": `{{define "content"}}
+	"extensions": `{{define "content"}}
 <div class="card">
-    <h2>All Disks</h2>
+    <h2>Extensions — {{.Count}} types</h2>
+    <p class="text-muted">Tracked files grouped by lowercased extension, largest total size first. Missing files are not counted.</p>
+    {{if .Extensions}}
     <table>
         <thead>
             <tr>
-                <th>Disk</th>
+                <th>Extension</th>
                 <th class="text-right">Files</th>
-                <th class="text-right">Size</th>
-                <th class="text-right">Corrupted</th>
-                <th class="text-right">Missing</th>
-                <th>Last Verified</th>
+                <th class="text-right">Total Size</th>
             </tr>
         </thead>
         <tbody>
-            {{range .DiskStats}}
+            {{range .Extensions}}
             <tr>
-                <td><a href="/disks?name={{.Disk}}" class="disk-link">{{.Disk}}</a></td>
-                <td class="text-right">{{.TotalFiles}}</td>
+                <td class="mono">{{if .Ext}}{{.Ext}}{{else}}(none){{end}}</td>
+                <td class="text-right">{{.Files}}</td>
                 <td class="text-right" data-sort-value="{{.TotalSize}}">{{formatBytes .TotalSize}}</td>
-                <td class="text-right {{if gt .CorruptedFiles 0}}status-corrupted{{end}}">{{.CorruptedFiles}}</td>
-                <td class="text-right {{if gt .MissingFiles 0}}status-missing{{end}}">{{.MissingFiles}}</td>
-                <td class="text-muted" data-sort-value="{{unixTime .LastVerified}}">{{formatTime .LastVerified}}</td>
             </tr>
             {{end}}
         </tbody>
     </table>
+    {{else}}
+    <p class="text-muted">No files tracked yet.</p>
+    {{end}}
+</div>
+{{end}}`,
+
+	"largest": `{{define "content"}}
+<div class="card">
+    <h2>{{if .Smallest}}Smallest{{else}}Largest{{end}} {{.Count}} files{{if .Disk}} on {{.Disk}}{{end}} — {{formatBytes .TotalSize}}</h2>
+    <p class="text-muted">Tracked files by size, missing files left out. Narrow with <span class="mono">?disk=disk1</span>, show more with <span class="mono">?limit=500</span>{{if .Smallest}} or see the <a href="{{$.BasePath}}/largest">largest</a>{{else}} or see the <a href="{{$.BasePath}}/largest?smallest=1">smallest</a>{{end}}.</p>
+    {{if .Files}}
+    <table>
+        <thead>
+            <tr>
+                <th class="text-right">Size</th>
+                <th>Disk</th>
+                <th>Path</th>
+                <th>Status</th>
+            </tr>
+        </thead>
+        <tbody>
+            {{range .Files}}
+            <tr>
+                <td class="text-right" data-sort-value="{{.Size}}">{{formatBytes .Size}}</td>
+                <td><a href="{{$.BasePath}}/disks?name={{.Disk}}" class="disk-link">{{.Disk}}</a></td>
+                <td class="path-cell mono">{{.Path}}</td>
+                <td class="{{statusClass .Status}}">{{.Status}}</td>
+            </tr>
+            {{end}}
+        </tbody>
+    </table>
+    {{else}}
+    <p class="text-muted">No files tracked yet.</p>
+    {{end}}
 </div>
 {{end}}`,
 
@@ -1067,19 +1216,28 @@ var templates = map[string]string{
                 <th>Modified</th>
                 <th>First Seen</th>
                 <th>Last Verified</th>
+                {{if $.Ackable}}<th></th>{{end}}
             </tr>
         </thead>
         <tbody>
             {{range .Files}}
             <tr>
                 <td class="{{statusClass .Status}}">{{.Status}}</td>
-                <td><a href="/disks?name={{.Disk}}" class="disk-link">{{.Disk}}</a></td>
+                <td><a href="{{$.BasePath}}/disks?name={{.Disk}}" class="disk-link">{{.Disk}}</a></td>
                 <td class="path-cell mono">{{.Path}}</td>
                 <td class="text-right" data-sort-value="{{.Size}}">{{formatBytes .Size}}</td>
                 <td class="mono">{{truncHash .SHA256}}</td>
                 <td class="text-muted" data-sort-value="{{.Mtime}}">{{formatMtime .Mtime}}</td>
                 <td class="text-muted" data-sort-value="{{unixTimeVal .FirstSeen}}">{{formatTimeVal .FirstSeen}}</td>
                 <td class="text-muted" data-sort-value="{{unixTimeVal .LastVerified}}">{{formatTimeVal .LastVerified}}</td>
+                {{if $.Ackable}}
+                <td>
+                    <form method="POST" action="{{$.BasePath}}/ack" data-token onsubmit="return confirm('Acknowledge this file? It will no longer count as corrupted.');">
+                        <input type="hidden" name="path" value="{{.Path}}">
+                        <button type="submit" class="btn" style="padding:2px 8px;font-size:12px;">Acknowledge</button>
+                    </form>
+                </td>
+                {{end}}
             </tr>
             {{end}}
         </tbody>
@@ -1093,11 +1251,13 @@ var templates = map[string]string{
 	"search": `{{define "content"}}
 <div class="card">
     <h2>Search Files</h2>
-    <form class="search-form" method="GET" action="/search">
-        <input type="text" name="q" placeholder="Search by file path..." value="{{.Query}}" autofocus>
+    <form class="search-form" method="GET" action="{{$.BasePath}}/search">
+        <input type="text" name="q" placeholder="Search by file path..." value="{{.Query}}" maxlength="256" autofocus>
         <button type="submit">Search</button>
     </form>
-    {{if .Query}}
+    {{if .Message}}
+    <p class="text-muted" style="margin-bottom: 12px;">{{.Message}}</p>
+    {{else if .Query}}
     <p class="text-muted" style="margin-bottom: 12px;">{{.Count}} results for "{{.Query}}"</p>
     {{if .Files}}
     <table>
@@ -1116,7 +1276,7 @@ var templates = map[string]string{
             {{range .Files}}
             <tr>
                 <td class="{{statusClass .Status}}">{{.Status}}</td>
-                <td><a href="/disks?name={{.Disk}}" class="disk-link">{{.Disk}}</a></td>
+                <td><a href="{{$.BasePath}}/disks?name={{.Disk}}" class="disk-link">{{.Disk}}</a></td>
                 <td class="path-cell mono">{{.Path}}</td>
                 <td class="text-right" data-sort-value="{{.Size}}">{{formatBytes .Size}}</td>
                 <td class="mono">{{truncHash .SHA256}}</td>
@@ -1144,8 +1304,12 @@ var templates = map[string]string{
                 <th>Duration</th>
                 <th>Disks</th>
                 <th class="text-right">Files</th>
+                <th class="text-right">Bytes</th>
+                <th class="text-right">Throughput</th>
                 <th class="text-right">Errors</th>
                 <th>Status</th>
+                <th>Algorithm</th>
+                <th>Version</th>
             </tr>
         </thead>
         <tbody>
@@ -1154,11 +1318,15 @@ var templates = map[string]string{
                 <td>{{.scan_type}}</td>
                 <td class="text-muted">{{.started_at}}</td>
                 <td class="text-muted">{{if .ended_at}}{{.ended_at}}{{else}}-{{end}}</td>
-                <td class="text-muted">{{if .duration}}{{.duration}}{{else}}-{{end}}</td>
+                <td class="text-muted"{{with .duration_seconds}} data-sort-value="{{.}}"{{end}}>{{if .duration}}{{.duration}}{{else}}-{{end}}</td>
                 <td>{{.disks}}</td>
                 <td class="text-right">{{.files_processed}}</td>
-                <td class="text-right {{if gt .errors 0}}status-corrupted{{end}}">{{.errors}}</td>
+                <td class="text-right" data-sort-value="{{.bytes_processed}}">{{if .bytes_processed}}{{formatBytes .bytes_processed}}{{else}}-{{end}}</td>
+                <td class="text-right"{{with .mbps}} data-sort-value="{{.}}"{{end}}>{{if .mbps}}{{formatRate .bytes_processed .duration_seconds}}{{else}}-{{end}}</td>
+                <td class="text-right {{if gt .errors 0}}status-corrupted{{end}}">{{.errors}}{{if gt .logged_errors 0}} <a href="{{$.BasePath}}/history?id={{.id}}">({{.logged_errors}} logged)</a>{{end}}</td>
                 <td>{{.status}}</td>
+                <td class="text-muted">{{if .algo}}{{.algo}}{{else}}-{{end}}</td>
+                <td class="text-muted">{{if .version}}{{.version}}{{else}}-{{end}}</td>
             </tr>
             {{end}}
         </tbody>
@@ -1167,6 +1335,36 @@ var templates = map[string]string{
     <p class="text-muted">No scan history yet. Run a scan first!</p>
     {{end}}
 </div>
+{{end}}`,
+
+	"scan_errors": `{{define "content"}}
+<div class="card">
+    <h2>Errors of run #{{.ScanID}}</h2>
+    <p><a href="{{$.BasePath}}/history">&larr; Scan History</a> &middot; <a href="{{$.BasePath}}/api/history/errors?id={{.ScanID}}">JSON</a></p>
+    {{if .Errors}}
+    <table>
+        <thead>
+            <tr>
+                <th>Time</th>
+                <th>Path</th>
+                <th>Error</th>
+            </tr>
+        </thead>
+        <tbody>
+            {{range .Errors}}
+            <tr>
+                <td class="text-muted">{{formatTimeVal .OccurredAt}}</td>
+                <td class="path-cell mono">{{.Path}}</td>
+                <td>{{.Message}}</td>
+            </tr>
+            {{end}}
+        </tbody>
+    </table>
+    {{if .Truncated}}<p class="text-muted">Showing the first {{len .Errors}} errors; the JSON link has them all.</p>{{end}}
+    {{else}}
+    <p class="text-muted">No errors were recorded for this run.</p>
+    {{end}}
+</div>
 {{end}}`,
 
 	"files": `{{define "content"}}
@@ -1189,7 +1387,7 @@ var templates = map[string]string{
             {{range .Files}}
             <tr>
                 <td class="{{statusClass .Status}}">{{.Status}}</td>
-                <td><a href="/disks?name={{.Disk}}" class="disk-link">{{.Disk}}</a></td>
+                <td><a href="{{$.BasePath}}/disks?name={{.Disk}}" class="disk-link">{{.Disk}}</a></td>
                 <td class="path-cell mono">{{.Path}}</td>
                 <td class="text-right" data-sort-value="{{.Size}}">{{formatBytes .Size}}</td>
                 <td class="mono">{{truncHash .SHA256}}</td>
@@ -1201,16 +1399,16 @@ var templates = map[string]string{
         </tbody>
     </table>
     <div class="pagination">
-        {{if .HasPrev}}<a href="/files?page={{.PrevPage}}&per_page={{.PerPage}}" class="btn">Previous</a>{{end}}
+        {{if .HasPrev}}<a href="{{$.BasePath}}/files?page={{.PrevPage}}&per_page={{.PerPage}}" class="btn">Previous</a>{{end}}
         <span class="text-muted">Page {{.CurrentPage}} of {{.TotalPages}}</span>
-        {{if .HasNext}}<a href="/files?page={{.NextPage}}&per_page={{.PerPage}}" class="btn">Next</a>{{end}}
+        {{if .HasNext}}<a href="{{$.BasePath}}/files?page={{.NextPage}}&per_page={{.PerPage}}" class="btn">Next</a>{{end}}
     </div>
 </div>
 {{end}}`,
 
 	"settings": `{{define "content"}}
 {{if .Message}}<div class="result-banner banner-success" style="max-width:640px;">{{.Message}}</div>{{end}}
-<form method="POST" action="/settings">
+<form method="POST" action="{{$.BasePath}}/settings" data-token>
 <div class="settings-grid">
 
     <!-- Scheduled Verification -->
@@ -1280,8 +1478,8 @@ var templates = map[string]string{
             These are used when starting a verify from the Overview page.
         </p>
         <div class="opt-group" style="margin-bottom:12px;">
-            <label>Worker Threads</label>
-            <input type="number" name="verify_workers" value="{{.Config.VerifyWorkers}}" min="1" max="64" style="max-width:100px;">
+            <label>Workers per Disk</label>
+            <input type="number" name="verify_workers" value="{{if .Config.VerifyWorkers}}{{.Config.VerifyWorkers}}{{end}}" placeholder="auto" min="1" max="64" style="max-width:100px;">
             <p class="text-muted" style="margin-top:4px;font-size:12px;">
                 Concurrent file verification workers. Default: 4.
             </p>
@@ -1372,3 +1570,131 @@ var templates = map[string]string{
 </form>
 {{end}}`,
 }
+
+// partialTemplates are shared by the overview and disk pages and the summary
+// mail. Styling goes through cls, which renders class names for the
+// dashboard's stylesheet and inline styles in mail (see emailStyles).
+var partialTemplates = `
+{{define "statCards"}}
+    <div {{cls "stat-card"}}>
+        <div {{cls "value"}}>{{.TotalFiles}}</div>
+        <div {{cls "label"}}>Total Files</div>
+    </div>
+    <div {{cls "stat-card"}}>
+        <div {{cls "value"}}>{{formatBytes .TotalSize}}</div>
+        <div {{cls "label"}}>Total Size</div>
+    </div>
+    <div {{cls "stat-card success"}}>
+        <div {{cls "value"}}>{{.OKFiles}}</div>
+        <div {{cls "label"}}>OK</div>
+    </div>
+    <div {{if gt .CorruptedFiles 0}}{{cls "stat-card danger"}}{{else}}{{cls "stat-card"}}{{end}}>
+        <div {{cls "value"}}>{{.CorruptedFiles}}</div>
+        <div {{cls "label"}}>Corrupted</div>
+    </div>
+    {{if gt .ChangedFiles 0}}
+    <div {{cls "stat-card info"}}>
+        <div {{cls "value"}}>{{.ChangedFiles}}</div>
+        <div {{cls "label"}}>Changed</div>
+    </div>
+    {{end}}
+    {{if gt .ErrorFiles 0}}
+    <div {{cls "stat-card warning"}}>
+        <div {{cls "value"}}>{{.ErrorFiles}}</div>
+        <div {{cls "label"}}>Unreadable</div>
+    </div>
+    {{end}}
+    <div {{if gt .MissingFiles 0}}{{cls "stat-card warning"}}{{else}}{{cls "stat-card"}}{{end}}>
+        <div {{cls "value"}}>{{.MissingFiles}}</div>
+        <div {{cls "label"}}>Missing</div>
+    </div>
+    {{if gt .AckedFiles 0}}
+    <div {{cls "stat-card"}}>
+        <div {{cls "value"}}>{{.AckedFiles}}</div>
+        <div {{cls "label"}}>Acknowledged</div>
+    </div>
+    {{end}}
+    {{if gt .NewFiles 0}}
+    <div {{cls "stat-card"}}>
+        <div {{cls "value"}}>{{.NewFiles}}</div>
+        <div {{cls "label"}}>New</div>
+    </div>
+    {{end}}
+{{end}}
+
+{{define "scanTimes"}}
+<table cellpadding="4">
+    <tr><td>Last Scan</td><td>{{formatTime .LastScan}}</td></tr>
+    <tr><td>Last Verify</td><td>{{formatTime .LastVerify}}</td></tr>
+</table>
+{{end}}
+
+{{define "diskTable"}}
+<table cellpadding="4">
+    <thead>
+        <tr>
+            <th>Disk</th>
+            <th>Type</th>
+            <th {{cls "text-right"}}>Files</th>
+            <th {{cls "text-right"}}>Size</th>
+            <th {{cls "text-right"}}>Corrupted</th>
+            <th {{cls "text-right"}}>Missing</th>
+            <th>Health</th>
+            <th>Last Verified</th>
+        </tr>
+    </thead>
+    <tbody>
+        {{range .}}
+        <tr>
+            <td>{{$url := diskURL .Disk}}{{if $url}}<a href="{{$url}}" {{cls "disk-link"}}>{{.Disk}}</a>{{else}}{{.Disk}}{{end}}</td>
+            <td {{cls "text-muted"}}>{{if .DiskType}}{{.DiskType}}{{else}}-{{end}}</td>
+            <td {{cls "text-right"}}>{{.TotalFiles}}</td>
+            <td {{cls "text-right"}} data-sort-value="{{.TotalSize}}">{{formatBytes .TotalSize}}</td>
+            <td {{if gt .CorruptedFiles 0}}{{cls "text-right status-corrupted"}}{{else}}{{cls "text-right"}}{{end}}>{{.CorruptedFiles}}</td>
+            <td {{if gt .MissingFiles 0}}{{cls "text-right status-missing"}}{{else}}{{cls "text-right"}}{{end}}>{{.MissingFiles}}</td>
+            <td {{cls (healthClass .Health)}} data-sort-value="{{printf "%.4f" .CorruptedPct}}" title="{{printf "%.2f" .CorruptedPct}}% of present files corrupted">{{.Health}}{{if gt .CorruptedFiles 0}} ({{printf "%.2f" .CorruptedPct}}%){{end}}</td>
+            <td {{cls "text-muted"}} data-sort-value="{{unixTime .LastVerified}}">{{formatTime .LastVerified}}</td>
+        </tr>
+        {{end}}
+    </tbody>
+</table>
+{{end}}
+`
+
+// emailTemplate is the summary mail sent by report --email: the overview's
+// totals and disk breakdown from partialTemplates, with the styling inline
+// since mail clients drop style blocks and scripts.
+var emailTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="UTF-8"><title>filehasher summary</title></head>
+<body style="font-family: -apple-system, 'Segoe UI', Roboto, Arial, sans-serif; color: #24292f; background: #ffffff; margin: 0; padding: 16px;">
+<h2 style="margin: 0 0 4px 0;">filehasher on {{.Host}}</h2>
+<p style="margin: 0 0 16px 0; color: #57606a;">{{.Generated.Format "Mon, 02 Jan 2006 15:04"}}{{if .Version}} &middot; filehasher {{.Version}}{{end}}</p>
+{{if .Problems}}
+<p style="padding: 8px 12px; background: #ffebe9; border: 1px solid #cf222e; border-radius: 6px;"><strong>Attention needed:</strong> {{.Stats.CorruptedFiles}} corrupted, {{.Stats.ErrorFiles}} unreadable, {{.Stats.MissingFiles}} missing.</p>
+{{else}}
+<p style="padding: 8px 12px; background: #dafbe1; border: 1px solid #1a7f37; border-radius: 6px;"><strong>All clear:</strong> no corrupted, unreadable or missing files.</p>
+{{end}}
+<div {{cls "stats-grid"}}>{{template "statCards" .Stats}}</div>
+{{template "scanTimes" .Stats}}
+
+<h3 style="margin: 16px 0 8px 0;">Newly corrupted since {{.Since.Format "Mon 15:04"}}</h3>
+{{if .NewCorrupted}}
+<table style="border-collapse: collapse; font-size: 13px;">
+    <tr style="text-align: left; border-bottom: 1px solid #d0d7de;"><th style="padding: 4px 8px;">Disk</th><th style="padding: 4px 8px;">Path</th><th style="padding: 4px 8px; text-align: right;">Size</th><th style="padding: 4px 8px;">Last Verified</th></tr>
+    {{range .NewCorrupted}}
+    <tr style="border-bottom: 1px solid #eaeef2;"><td style="padding: 4px 8px;">{{.Disk}}</td><td style="padding: 4px 8px; font-family: monospace;">{{.Path}}</td><td style="padding: 4px 8px; text-align: right;">{{formatBytes .Size}}</td><td style="padding: 4px 8px;">{{formatTimeVal .LastVerified}}</td></tr>
+    {{end}}
+</table>
+{{if .MoreCorrupted}}<p style="color: #57606a;">&hellip; and {{.MoreCorrupted}} more; see <code>filehasher report --status corrupted</code>.</p>{{end}}
+{{else}}
+<p style="color: #57606a;">None.</p>
+{{end}}
+
+{{if .Disks}}
+<h3 style="margin: 16px 0 8px 0;">Disk Breakdown</h3>
+{{template "diskTable" .Disks}}
+{{end}}
+</body>
+</html>
+`
@@ -0,0 +1,105 @@
+package web
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTLSConfigPlainHTTP(t *testing.T) {
+	cfg, fingerprint, err := TLSConfig("", "", false, nil)
+	if err != nil || cfg != nil || fingerprint != "" {
+		t.Errorf("TLSConfig() = %v, %q, %v; want nil, \"\", nil", cfg, fingerprint, err)
+	}
+}
+
+func TestTLSConfigLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+	garbage := filepath.Join(dir, "garbage.pem")
+	if err := os.WriteFile(garbage, []byte("not a certificate\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.pem")
+
+	for _, tt := range []struct{ name, cert, key string }{
+		{"missing files", missing, missing},
+		{"not PEM", garbage, garbage},
+	} {
+		cfg, _, err := TLSConfig(tt.cert, tt.key, false, nil)
+		if err == nil || cfg != nil {
+			t.Errorf("%s: TLSConfig = %v, %v; want an error", tt.name, cfg, err)
+		}
+	}
+}
+
+func TestTLSConfigSelfSigned(t *testing.T) {
+	cfg, fingerprint, err := TLSConfig("", "", true, []string{"192.168.1.5", "::", "tower.local"})
+	if err != nil {
+		t.Fatalf("TLSConfig: %v", err)
+	}
+	if cfg == nil || len(cfg.Certificates) != 1 {
+		t.Fatalf("config = %+v, want one certificate", cfg)
+	}
+	if parts := strings.Split(fingerprint, ":"); len(parts) != 32 {
+		t.Errorf("fingerprint = %q, want 32 hex pairs", fingerprint)
+	}
+
+	der := cfg.Certificates[0].Certificate[0]
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	if err := cert.VerifyHostname("192.168.1.5"); err != nil {
+		t.Errorf("bind address: %v", err)
+	}
+	if err := cert.VerifyHostname("tower.local"); err != nil {
+		t.Errorf("host name: %v", err)
+	}
+	if err := cert.VerifyHostname("localhost"); err != nil {
+		t.Errorf("localhost: %v", err)
+	}
+	for _, ip := range cert.IPAddresses {
+		if ip.Equal(net.IPv6unspecified) {
+			t.Errorf("certificate lists the unspecified address %v", ip)
+		}
+	}
+
+	// The same certificate saved as PEM files loads with the same fingerprint
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cfg.Certificates[0].PrivateKey)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey: %v", err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	loaded, loadedFingerprint, err := TLSConfig(certFile, keyFile, false, nil)
+	if err != nil || loaded == nil {
+		t.Fatalf("TLSConfig from files: %v, %v", loaded, err)
+	}
+	if loadedFingerprint != fingerprint {
+		t.Errorf("fingerprint from files = %q, want %q", loadedFingerprint, fingerprint)
+	}
+
+	// A key that doesn't belong to the certificate is refused
+	other, _, err := TLSConfig("", "", true, nil)
+	if err != nil {
+		t.Fatalf("TLSConfig: %v", err)
+	}
+	otherKey, _ := x509.MarshalPKCS8PrivateKey(other.Certificates[0].PrivateKey)
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: otherKey}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := TLSConfig(certFile, keyFile, false, nil); err == nil {
+		t.Error("mismatched key accepted")
+	}
+}
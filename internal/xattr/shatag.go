@@ -0,0 +1,82 @@
+package xattr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The attributes cshatag and the older Python shatag keep a file's checksum
+// in: the hex SHA-256 of the contents and the mtime the file had when it was
+// computed.
+const (
+	ShatagSHA256 = "user.shatag.sha256"
+	ShatagTs     = "user.shatag.ts"
+)
+
+// Shatag is a checksum in the cshatag format.
+type Shatag struct {
+	SHA256    string
+	Mtime     int64 // seconds
+	MtimeNsec int64
+}
+
+// Current reports whether t was computed at the given mtime. cshatag treats
+// a checksum whose timestamp differs from the file's mtime as outdated (the
+// file was modified since) and only one with the same timestamp but other
+// contents as corrupt.
+func (t Shatag) Current(mtime, mtimeNsec int64) bool {
+	return t.Mtime == mtime && t.MtimeNsec == mtimeNsec
+}
+
+// GetShatag reads the cshatag attributes of path. It returns an error
+// wrapping ErrNotSet unless both are set.
+func GetShatag(path string) (Shatag, error) {
+	sum, err := Get(path, ShatagSHA256)
+	if err != nil {
+		return Shatag{}, err
+	}
+	ts, err := Get(path, ShatagTs)
+	if err != nil {
+		return Shatag{}, err
+	}
+	t := Shatag{SHA256: strings.ToLower(strings.TrimSpace(sum))}
+	if t.Mtime, t.MtimeNsec, err = parseTs(strings.TrimSpace(ts)); err != nil {
+		return Shatag{}, fmt.Errorf("%s: %s: %w", path, ShatagTs, err)
+	}
+	return t, nil
+}
+
+// SetShatag writes t to the cshatag attributes of path, timestamp first as
+// cshatag does.
+func SetShatag(path string, t Shatag) error {
+	if err := Set(path, ShatagTs, formatTs(t.Mtime, t.MtimeNsec)); err != nil {
+		return err
+	}
+	return Set(path, ShatagSHA256, t.SHA256)
+}
+
+// formatTs writes an mtime the way cshatag does, e.g. "1700000000.123456789".
+func formatTs(sec, nsec int64) string {
+	return fmt.Sprintf("%010d.%09d", sec, nsec)
+}
+
+// parseTs reads cshatag's timestamps as well as the Python shatag's, which
+// are floats with fewer fractional digits.
+func parseTs(s string) (sec, nsec int64, err error) {
+	whole, frac, _ := strings.Cut(s, ".")
+	if sec, err = strconv.ParseInt(whole, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	if frac == "" {
+		return sec, 0, nil
+	}
+	if len(frac) > 9 {
+		frac = frac[:9]
+	}
+	frac += strings.Repeat("0", 9-len(frac))
+	if nsec, err = strconv.ParseInt(frac, 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("invalid timestamp %q", s)
+	}
+	return sec, nsec, nil
+}
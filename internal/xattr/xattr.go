@@ -0,0 +1,14 @@
+// Package xattr reads and writes checksums kept in a file's extended
+// attributes, so they travel with the file when it is copied with its
+// attributes (cp -a, rsync -X). Extended attributes need Linux; elsewhere
+// every call fails with ErrUnsupported.
+package xattr
+
+import "errors"
+
+// ErrNotSet is returned by Get for a file without the attribute.
+var ErrNotSet = errors.New("attribute not set")
+
+// ErrUnsupported is returned where the platform or filesystem has no user
+// extended attributes.
+var ErrUnsupported = errors.New("extended attributes not supported")
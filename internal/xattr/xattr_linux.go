@@ -0,0 +1,51 @@
+//go:build linux
+
+package xattr
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// Get returns the value of the attribute name of path.
+func Get(path, name string) (string, error) {
+	buf := make([]byte, 256)
+	for {
+		n, err := unix.Getxattr(path, name, buf)
+		switch {
+		case errors.Is(err, unix.ERANGE):
+			// Grown since; ask for its size and retry
+			size, err := unix.Getxattr(path, name, nil)
+			if err != nil {
+				return "", wrap(path, name, err)
+			}
+			buf = make([]byte, size)
+			continue
+		case err != nil:
+			return "", wrap(path, name, err)
+		}
+		return string(buf[:n]), nil
+	}
+}
+
+// Set writes value to the attribute name of path, replacing any value it
+// had.
+func Set(path, name, value string) error {
+	return wrap(path, name, unix.Setxattr(path, name, []byte(value), 0))
+}
+
+// wrap maps the errno of a failed call to ErrNotSet or ErrUnsupported where
+// one applies.
+func wrap(path, name string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, unix.ENODATA):
+		return fmt.Errorf("%s: %s: %w", path, name, ErrNotSet)
+	case errors.Is(err, unix.ENOTSUP):
+		return fmt.Errorf("%s: %s: %w", path, name, ErrUnsupported)
+	}
+	return fmt.Errorf("%s: %s: %w", path, name, err)
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package xattr
+
+import "fmt"
+
+// Get returns the value of the attribute name of path.
+func Get(path, name string) (string, error) {
+	return "", fmt.Errorf("%s: %s: %w", path, name, ErrUnsupported)
+}
+
+// Set writes value to the attribute name of path, replacing any value it
+// had.
+func Set(path, name, value string) error {
+	return fmt.Errorf("%s: %s: %w", path, name, ErrUnsupported)
+}
@@ -0,0 +1,76 @@
+package xattr
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a")
+	if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Get(path, "user.sha256"); errors.Is(err, ErrUnsupported) {
+		t.Skip("no user extended attributes here")
+	} else if !errors.Is(err, ErrNotSet) {
+		t.Fatalf("Get before Set: err = %v, want ErrNotSet", err)
+	}
+
+	long := string(make([]byte, 1000)) // beyond Get's first buffer
+	for _, v := range []string{"2d711642b726b04401627ca9fbac32f5c8530fb1903cc4db02258717921a4881", long} {
+		if err := Set(path, "user.sha256", v); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		if got, err := Get(path, "user.sha256"); err != nil || got != v {
+			t.Errorf("Get = %d bytes, %v; want the %d bytes set", len(got), err, len(v))
+		}
+	}
+}
+
+func TestParseTs(t *testing.T) {
+	tests := []struct {
+		in        string
+		sec, nsec int64
+		ok        bool
+	}{
+		{"1700000000.123456789", 1700000000, 123456789, true},
+		{"0012345678.000000001", 12345678, 1, true},
+		{"1700000000.5", 1700000000, 500000000, true}, // Python shatag
+		{"1700000000", 1700000000, 0, true},
+		{"1700000000.1234567891", 1700000000, 123456789, true},
+		{"yesterday", 0, 0, false},
+		{"1700000000.x", 0, 0, false},
+	}
+	for _, tt := range tests {
+		sec, nsec, err := parseTs(tt.in)
+		if (err == nil) != tt.ok || sec != tt.sec || nsec != tt.nsec {
+			t.Errorf("parseTs(%q) = %d, %d, %v", tt.in, sec, nsec, err)
+		}
+	}
+	if got := formatTs(12345678, 1); got != "0012345678.000000001" {
+		t.Errorf("formatTs = %q", got)
+	}
+}
+
+func TestShatag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a")
+	if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := Shatag{SHA256: "2d711642b726b04401627ca9fbac32f5c8530fb1903cc4db02258717921a4881", Mtime: 1700000000, MtimeNsec: 42}
+	if err := SetShatag(path, want); errors.Is(err, ErrUnsupported) {
+		t.Skip("no user extended attributes here")
+	} else if err != nil {
+		t.Fatalf("SetShatag: %v", err)
+	}
+	got, err := GetShatag(path)
+	if err != nil || got != want {
+		t.Fatalf("GetShatag = %+v, %v; want %+v", got, err, want)
+	}
+	if !got.Current(1700000000, 42) || got.Current(1700000000, 0) {
+		t.Errorf("Current compares the wrong way")
+	}
+}